@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log/slog"
 	"net/http"
 	"os"
 	"sync"
@@ -19,18 +19,17 @@ type DatabaseConnection struct {
 	mu        sync.Mutex
 }
 
-func (db *DatabaseConnection) Connect() error {
+func (db *DatabaseConnection) Connect(ctx context.Context) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	// Note: We can't use svc.Logger here as it's not available in this context
-	// In a real application, you'd pass the logger to this function
+	service.LoggerFromContext(ctx).Info("Connecting to database...")
 	time.Sleep(100 * time.Millisecond) // Simulate connection time
 	db.connected = true
 	return nil
 }
 
-func (db *DatabaseConnection) Close() error {
+func (db *DatabaseConnection) Close(ctx context.Context) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -38,8 +37,7 @@ func (db *DatabaseConnection) Close() error {
 		return nil
 	}
 
-	// Note: We can't use svc.Logger here as it's not available in this context
-	// In a real application, you'd pass the logger to this function
+	service.LoggerFromContext(ctx).Info("Closing database connection...")
 	time.Sleep(200 * time.Millisecond) // Simulate cleanup time
 	db.connected = false
 	return nil
@@ -56,18 +54,17 @@ type CacheService struct {
 	mu     sync.Mutex
 }
 
-func (c *CacheService) Start() error {
+func (c *CacheService) Start(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Note: We can't use svc.Logger here as it's not available in this context
-	// In a real application, you'd pass the logger to this function
+	service.LoggerFromContext(ctx).Info("Starting cache service...")
 	time.Sleep(50 * time.Millisecond)
 	c.active = true
 	return nil
 }
 
-func (c *CacheService) Stop() error {
+func (c *CacheService) Stop(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -75,75 +72,75 @@ func (c *CacheService) Stop() error {
 		return nil
 	}
 
-	// Note: We can't use svc.Logger here as it's not available in this context
-	// In a real application, you'd pass the logger to this function
+	service.LoggerFromContext(ctx).Info("Stopping cache service...")
 	time.Sleep(150 * time.Millisecond)
 	c.active = false
 	return nil
 }
 
 func main() {
+	// Create service
+	svc := service.New("shutdown-hook-service", nil)
+
+	// The service context carries the service logger, so resources can log without a request
+	ctx := svc.Context()
+
 	// Initialize resources
 	db := &DatabaseConnection{}
 	cache := &CacheService{}
 
 	// Connect to resources
-	if err := db.Connect(); err != nil {
-		// We can't use svc.Logger here yet, so we'll use slog for this error
-		slog.Error("Failed to connect to database", "error", err)
+	if err := db.Connect(ctx); err != nil {
+		svc.Logger.Error("Failed to connect to database", "error", err)
 		os.Exit(1)
 	}
 
-	if err := cache.Start(); err != nil {
-		// We can't use svc.Logger here yet, so we'll use slog for this error
-		slog.Error("Failed to start cache service", "error", err)
+	if err := cache.Start(ctx); err != nil {
+		svc.Logger.Error("Failed to start cache service", "error", err)
 		os.Exit(1)
 	}
 
-	// Create service
-	svc := service.New("shutdown-hook-service", nil)
-
 	// Register shutdown hooks in reverse order of initialization
 	// The last registered hook runs first during shutdown
 
 	// Hook 1: Cache cleanup (runs first during shutdown)
-	svc.AddShutdownHook(func() error {
-		slog.Info("Shutdown hook: Cleaning up cache service...")
-		return cache.Stop()
+	svc.AddShutdownHook(func(ctx context.Context) error {
+		svc.Logger.Info("Shutdown hook: Cleaning up cache service...")
+		return cache.Stop(ctx)
 	})
 
 	// Hook 2: Database cleanup (runs second during shutdown)
-	svc.AddShutdownHook(func() error {
-		slog.Info("Shutdown hook: Cleaning up database connection...")
-		return db.Close()
-	})
+	svc.AddShutdownHook(func(ctx context.Context) error {
+		svc.Logger.Info("Shutdown hook: Cleaning up database connection...")
+		return db.Close(ctx)
+	}, service.WithHookTimeout(5*time.Second))
 
 	// Hook 3: Final cleanup (runs last during shutdown)
-	svc.AddShutdownHook(func() error {
-		slog.Info("Shutdown hook: Performing final cleanup...")
+	svc.AddShutdownHook(func(context.Context) error {
+		svc.Logger.Info("Shutdown hook: Performing final cleanup...")
 
 		// Simulate final cleanup operations
-		slog.Info("Saving application state...")
+		svc.Logger.Info("Saving application state...")
 		time.Sleep(100 * time.Millisecond)
 
-		slog.Info("Flushing logs...")
+		svc.Logger.Info("Flushing logs...")
 		time.Sleep(50 * time.Millisecond)
 
-		slog.Info("Final cleanup completed")
+		svc.Logger.Info("Final cleanup completed")
 		return nil
 	})
 
 	// Hook 4: Demonstrate error handling in shutdown hooks
-	svc.AddShutdownHook(func() error {
-		slog.Info("Shutdown hook: Demonstrating error handling...")
+	svc.AddShutdownHook(func(context.Context) error {
+		svc.Logger.Info("Shutdown hook: Demonstrating error handling...")
 
 		// Simulate a non-critical error during shutdown
 		if time.Now().UnixNano()%2 == 0 {
-			slog.Warn("Non-critical error during shutdown (this is expected)")
+			svc.Logger.Warn("Non-critical error during shutdown (this is expected)")
 			return fmt.Errorf("simulated non-critical shutdown error")
 		}
 
-		slog.Info("Shutdown hook completed without errors")
+		svc.Logger.Info("Shutdown hook completed without errors")
 		return nil
 	})
 
@@ -171,20 +168,14 @@ func main() {
 		}`, db.IsConnected(), cache.active, time.Since(startTime))))
 	})
 
-	// Simulate some background work
-	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				if db.IsConnected() {
-					svc.Logger.Info("Background task: Database is healthy")
-				}
-			}
+	// Simulate some background work (stops automatically during shutdown)
+	svc.Every("db-health", 5*time.Second, func(ctx context.Context) error {
+		if db.IsConnected() {
+			service.LoggerFromContext(ctx).Info("Background task: Database is healthy")
 		}
-	}()
+
+		return nil
+	})
 
 	svc.Logger.Info("Starting shutdown hook demo service...")
 	svc.Logger.Info("Service available at http://localhost:8080")
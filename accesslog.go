@@ -0,0 +1,126 @@
+package service
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogConfig configures the access log
+type AccessLogConfig struct {
+	// SampleRate is the fraction of requests that are logged, e.g. 0.1 for every tenth request. 0 logs all requests.
+	// Server errors (5xx) are always logged.
+	SampleRate float64
+	// SkipPaths are request paths that are never logged, e.g. /health of load balancer probes
+	SkipPaths []string
+}
+
+// sampled reports whether a completed request with the status is logged
+func (c AccessLogConfig) sampled(status int) bool {
+	if c.SampleRate <= 0 || c.SampleRate >= 1 || status >= http.StatusInternalServerError {
+		return true
+	}
+
+	return rand.Float64() < c.SampleRate //nolint:gosec
+}
+
+// AccessLogMiddleware logs every request after its response completed, with the status, the bytes written, the
+// duration, the route pattern, and the request ID. Requests canceled by the client are logged with status 499.
+func AccessLogMiddleware(logger *slog.Logger, config AccessLogConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(config.SkipPaths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			wrapped := &accessLogWriter{ResponseWriter: w}
+
+			if DebugRequest(r) {
+				var completed func()
+
+				w, completed = logRequestDetails(GetLogger(r), wrapped, r)
+				defer completed()
+			} else {
+				w = wrapped
+			}
+
+			next.ServeHTTP(w, r)
+
+			status := wrapped.status()
+			if ClientCanceled(r) {
+				status = StatusClientClosedRequest
+			}
+
+			if !config.sampled(status) {
+				return
+			}
+
+			requestLogger(logger, r).Info("access",
+				"method", r.Method,
+				"route", RoutePattern(r),
+				"path", r.URL.Path,
+				"status", status,
+				"bytes", wrapped.written.Load(),
+				"duration", time.Since(start),
+				"remote_addr", r.RemoteAddr,
+				"user_agent", r.UserAgent())
+		})
+	}
+}
+
+// RequestLoggingMiddleware logs every request.
+//
+// Deprecated: Use AccessLogMiddleware, which logs requests after their response completed.
+func RequestLoggingMiddleware(logger *slog.Logger) Middleware {
+	return AccessLogMiddleware(logger, AccessLogConfig{})
+}
+
+// accessLogWriter captures the status code and the number of bytes of a response
+type accessLogWriter struct {
+	http.ResponseWriter
+
+	statusCode int
+	// written is read by middleware after the handler returned, while a handler that outlived its timeout may
+	// still write
+	written atomic.Int64
+}
+
+// WriteHeader captures the first status code
+func (a *accessLogWriter) WriteHeader(code int) {
+	if a.statusCode == 0 {
+		a.statusCode = code
+	}
+
+	a.ResponseWriter.WriteHeader(code)
+}
+
+// Write counts the bytes written
+func (a *accessLogWriter) Write(data []byte) (int, error) {
+	if a.statusCode == 0 {
+		a.statusCode = http.StatusOK
+	}
+
+	n, err := a.ResponseWriter.Write(data)
+	a.written.Add(int64(n))
+
+	return n, err //nolint:wrapcheck
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (a *accessLogWriter) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+// status returns the status code of the response, 200 if the handler wrote none
+func (a *accessLogWriter) status() int {
+	if a.statusCode == 0 {
+		return http.StatusOK
+	}
+
+	return a.statusCode
+}
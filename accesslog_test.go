@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	})
+
+	handler := applyMiddleware(mux,
+		RequestIDMiddleware(DefaultRequestIDHeader),
+		AccessLogMiddleware(logger, AccessLogConfig{SkipPaths: []string{"/health"}}))
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/42", nil)
+	req.Header.Set(DefaultRequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry struct {
+		Msg       string `json:"msg"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+		Bytes     int64  `json:"bytes"`
+		Duration  int64  `json:"duration"`
+		RequestID string `json:"request_id"`
+	}
+
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode access log %q: %v", logs.String(), err)
+	}
+
+	if entry.Msg != "access" || entry.Route != "GET /orders/{id}" || entry.Status != http.StatusCreated ||
+		entry.Bytes != int64(len("created")) || entry.RequestID != "req-1" {
+		t.Errorf("unexpected access log: %+v", entry)
+	}
+
+	logs.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if logs.Len() != 0 {
+		t.Errorf("expected skipped paths not to be logged, got %q", logs.String())
+	}
+}
+
+func TestAccessLogMiddlewareClientCanceled(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	handler := AccessLogMiddleware(slog.New(slog.NewTextHandler(&logs, nil)), AccessLogConfig{})(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if !bytes.Contains(logs.Bytes(), []byte("status=499")) {
+		t.Errorf("expected status 499, got %q", logs.String())
+	}
+}
+
+func TestAccessLogConfigSampled(t *testing.T) {
+	t.Parallel()
+
+	config := AccessLogConfig{SampleRate: 0.000001}
+
+	if !config.sampled(http.StatusBadGateway) {
+		t.Error("expected server errors to be logged")
+	}
+
+	logged := 0
+
+	for range 100 {
+		if config.sampled(http.StatusOK) {
+			logged++
+		}
+	}
+
+	if logged > 1 {
+		t.Errorf("expected requests to be sampled, %d of 100 logged", logged)
+	}
+
+	if !(AccessLogConfig{}).sampled(http.StatusOK) {
+		t.Error("expected all requests to be logged without a sample rate")
+	}
+}
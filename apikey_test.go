@@ -0,0 +1,83 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.APIKeys = map[string]string{"billing": "billing-key", "reports": "reports-key"}
+
+	svc := New("apikey_test", config)
+
+	handler := svc.APIKeyAuth()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(APIKeyIdentity(r)))
+	}))
+
+	tests := []struct {
+		name     string
+		key      string
+		expected int
+		identity string
+	}{
+		{"billing key", "billing-key", http.StatusOK, "billing"},
+		{"reports key", "reports-key", http.StatusOK, "reports"},
+		{"missing key", "", http.StatusUnauthorized, ""},
+		{"unknown key", "other-key", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/internal/invoices", nil)
+		if tt.key != "" {
+			req.Header.Set(DefaultAPIKeyHeader, tt.key)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, rec.Code)
+		}
+
+		if tt.expected == http.StatusOK && rec.Body.String() != tt.identity {
+			t.Errorf("%s: expected identity %q, got %q", tt.name, tt.identity, rec.Body.String())
+		}
+	}
+
+	attempts := svc.Metrics.builtinCounterVec("auth_attempts_total", "", "method", "outcome")
+
+	for outcome, want := range map[string]float64{"success": 2, "failure": 2} {
+		if got := testutil.ToFloat64(attempts.WithLabelValues("api_key", outcome)); got != want {
+			t.Errorf("expected %v %s attempts, got %v", want, outcome, got)
+		}
+	}
+}
+
+func TestAPIKeyMiddleware_Validate(t *testing.T) {
+	t.Parallel()
+
+	handler := APIKeyMiddleware(nil, APIKeyConfig{
+		Header: "X-Token",
+		Validate: func(key string) (string, bool) {
+			return "db-" + key, key == "valid"
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(APIKeyIdentity(r)))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Token", "valid")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "db-valid" {
+		t.Errorf("expected the identity of the validator, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Authentication outcomes recorded in {service_name}_auth_attempts_total{method,outcome}
+const (
+	authOutcomeSuccess = "success"
+	authOutcomeFailure = "failure"
+	authOutcomeLocked  = "locked"
+)
+
+// authAudit records the authentication attempts of an auth middleware in
+// {service_name}_auth_attempts_total{method,outcome} and logs failures without credentials
+type authAudit struct {
+	method   string
+	attempts *prometheus.CounterVec
+}
+
+// newAuthAudit creates the audit of an authentication method, e.g. "basic" or "signature"
+func newAuthAudit(metrics *MetricsCollector, method string) *authAudit {
+	audit := &authAudit{method: method}
+
+	if metrics != nil {
+		audit.attempts = metrics.builtinCounterVec("auth_attempts_total",
+			"Total number of authentication attempts by method and outcome", "method", "outcome")
+	}
+
+	return audit
+}
+
+// success records a successful authentication
+func (a *authAudit) success() {
+	a.record(authOutcomeSuccess)
+}
+
+// failure records and logs a failed authentication. The attributes must never contain credentials.
+func (a *authAudit) failure(r *http.Request, outcome, reason string, attrs ...any) {
+	a.record(outcome)
+
+	attrs = append([]any{
+		"method", a.method,
+		"outcome", outcome,
+		"reason", reason,
+		"client_ip", clientIP(r),
+		"route", RoutePattern(r),
+		"path", r.URL.Path,
+	}, attrs...)
+
+	GetLogger(r).Warn("authentication failed", attrs...)
+}
+
+// record counts an authentication attempt
+func (a *authAudit) record(outcome string) {
+	if a.attempts != nil {
+		a.attempts.WithLabelValues(a.method, outcome).Inc()
+	}
+}
+
+// clientIP returns the IP address of the connection, ignoring forwarding headers
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
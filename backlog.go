@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrBacklogExceeded is reported by the health check of a backlog above its threshold
+var ErrBacklogExceeded = NewError(CodeResourceExhausted, "backlog exceeds threshold")
+
+// BacklogConfig holds configuration for the backpressure of a backlog
+type BacklogConfig struct {
+	// Threshold is the depth above which the backlog is exceeded
+	Threshold int
+	// Resume is the depth at or below which an exceeded backlog recovers. Defaults to the threshold; a lower value
+	// keeps the backpressure from flapping around the threshold.
+	Resume int
+	// Degrade registers a degraded health check failing while the backlog is exceeded, so the service reports
+	// "Partially Available" but stays in rotation
+	Degrade bool
+	// Shed rejects HTTP requests up to ShedPriority with 503 while the backlog is exceeded, so the capacity of the
+	// process goes to working off the backlog
+	Shed bool
+	// ShedPriority is the highest priority class that is shed. Defaults to PriorityLow.
+	ShedPriority Priority
+}
+
+// Backlog is the depth of the inbox or queue of a worker, which applies backpressure to HTTP traffic and readiness
+// once it exceeds its threshold (see Service.Backlog)
+type Backlog struct {
+	name   string
+	config BacklogConfig
+	logger *slog.Logger
+
+	depth    atomic.Int64
+	exceeded atomic.Bool
+	gauge    prometheus.Gauge
+
+	// mu serializes the transitions between exceeded and recovered
+	mu sync.Mutex
+}
+
+// backlogs is the registry of the backlogs of a service
+type backlogs struct {
+	mu   sync.RWMutex
+	list []*Backlog
+}
+
+// Backlog registers a backlog, e.g. of a queue consumer started with AddWorker, whose depth the worker reports
+// with Backlog.Set. The depth is exported as {service_name}_backlog_depth{backlog}, and requests shed while it is
+// exceeded are counted in {service_name}_backlog_shed_total{backlog}.
+func (s *Service) Backlog(name string, config BacklogConfig) *Backlog {
+	if config.Resume <= 0 || config.Resume > config.Threshold {
+		config.Resume = config.Threshold
+	}
+
+	backlog := &Backlog{
+		name:   name,
+		config: config,
+		logger: s.Logger.With("backlog", name),
+		gauge: s.Metrics.builtinGaugeVec("backlog_depth", "Current depth of worker backlogs", "backlog").
+			WithLabelValues(name),
+	}
+
+	s.backlogs.mu.Lock()
+	s.backlogs.list = append(s.backlogs.list, backlog)
+	s.backlogs.mu.Unlock()
+
+	if config.Degrade {
+		_, err := s.AddHealthCheck(HealthCheck{
+			Name:     "backlog_" + name,
+			Degraded: true,
+			Check: func(context.Context) error {
+				if backlog.Exceeded() {
+					return fmt.Errorf("%w: %d > %d", ErrBacklogExceeded, backlog.Depth(), config.Threshold)
+				}
+
+				return nil
+			},
+		})
+		if err != nil {
+			s.Logger.Error("failed to register backlog health check", "backlog", name, "error", err)
+		}
+	}
+
+	return backlog
+}
+
+// Set reports the current depth of the backlog
+func (b *Backlog) Set(depth int) {
+	b.depth.Store(int64(depth))
+	b.gauge.Set(float64(depth))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch exceeded := b.exceeded.Load(); {
+	case !exceeded && depth > b.config.Threshold:
+		b.exceeded.Store(true)
+		b.logger.Warn("backlog exceeds threshold, applying backpressure", "depth", depth, "threshold", b.config.Threshold)
+	case exceeded && depth <= b.config.Resume:
+		b.exceeded.Store(false)
+		b.logger.Info("backlog recovered", "depth", depth, "resume", b.config.Resume)
+	}
+}
+
+// Depth returns the last reported depth of the backlog
+func (b *Backlog) Depth() int {
+	return int(b.depth.Load())
+}
+
+// Exceeded reports whether the backlog is above its threshold (and not yet back at its resume depth)
+func (b *Backlog) Exceeded() bool {
+	return b.exceeded.Load()
+}
+
+// backlogSheddingMiddleware rejects requests up to the shed priority of an exceeded backlog with 503
+func (s *Service) backlogSheddingMiddleware(priorityConfig PriorityConfig) Middleware {
+	shed := s.Metrics.builtinCounterVec("backlog_shed_total",
+		"Total number of requests shed because a worker backlog exceeded its threshold", "backlog")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.backlogs.mu.RLock()
+			list := s.backlogs.list
+			s.backlogs.mu.RUnlock()
+
+			if len(list) > 0 {
+				priority := RequestPriority(r, priorityConfig)
+
+				for _, backlog := range list {
+					if !backlog.config.Shed || !backlog.Exceeded() || priority > backlog.config.ShedPriority {
+						continue
+					}
+
+					shed.WithLabelValues(backlog.name).Inc()
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
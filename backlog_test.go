@@ -0,0 +1,89 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellofresh/health-go/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBacklog(t *testing.T) {
+	t.Parallel()
+
+	svc := New("backlog_test", nil)
+
+	backlog := svc.Backlog("orders", BacklogConfig{Threshold: 100, Resume: 50, Degrade: true, Shed: true})
+
+	svc.HandleFunc("GET /reports", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, WithPriority(PriorityLow))
+	svc.HandleFunc("GET /orders", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	handler := svc.handler()
+
+	request := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return rec.Code
+	}
+
+	status := func() health.Status {
+		return svc.HealthChecker.Measure(t.Context()).Status
+	}
+
+	backlog.Set(100)
+
+	if backlog.Exceeded() || request("/reports") != http.StatusNoContent || status() != health.StatusOK {
+		t.Error("expected no backpressure at the threshold")
+	}
+
+	backlog.Set(150)
+
+	if !backlog.Exceeded() {
+		t.Fatal("expected the backlog to be exceeded")
+	}
+
+	if code := request("/reports"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected low-priority requests to be shed, got %d", code)
+	}
+
+	if code := request("/orders"); code != http.StatusNoContent {
+		t.Errorf("expected normal-priority requests to be served, got %d", code)
+	}
+
+	if got := status(); got != health.StatusPartiallyAvailable {
+		t.Errorf("expected a degraded status, got %s", got)
+	}
+
+	if !svc.HealthChecker.IsReady(t.Context()) {
+		t.Error("expected a degraded service to stay ready")
+	}
+
+	// The backpressure holds until the backlog is back at the resume depth
+	backlog.Set(80)
+
+	if !backlog.Exceeded() {
+		t.Error("expected the backlog to stay exceeded above the resume depth")
+	}
+
+	backlog.Set(50)
+
+	if backlog.Exceeded() || request("/reports") != http.StatusNoContent || status() != health.StatusOK {
+		t.Error("expected the backpressure to end at the resume depth")
+	}
+
+	shed := svc.Metrics.builtinCounterVec("backlog_shed_total", "", "backlog")
+	if got := testutil.ToFloat64(shed.WithLabelValues("orders")); got != 1 {
+		t.Errorf("expected 1 shed request, got %v", got)
+	}
+
+	depth := svc.Metrics.builtinGaugeVec("backlog_depth", "", "backlog")
+	if got := testutil.ToFloat64(depth.WithLabelValues("orders")); got != 50 {
+		t.Errorf("expected a depth of 50, got %v", got)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxLockoutDuration caps the exponential backoff of basic auth lockouts
+const maxLockoutDuration = time.Hour
+
+// BasicAuthConfig holds configuration for the basic auth middleware
+type BasicAuthConfig struct {
+	// Realm is sent in the WWW-Authenticate header. Defaults to "Restricted".
+	Realm string
+	// Users maps user names to passwords, compared in constant time
+	Users map[string]string
+	// Validate checks credentials instead of Users, e.g. against hashed passwords
+	Validate func(user, password string) bool
+	// MaxFailures locks out a client IP after this many consecutive failures (0 disables the lockout)
+	MaxFailures int
+	// LockoutDuration is the duration of the first lockout. Each further failure doubles it, up to 1h. Defaults to 1m.
+	LockoutDuration time.Duration
+}
+
+// BasicAuthMiddleware requires HTTP basic authentication. Attempts are counted in
+// {service_name}_auth_attempts_total{method="basic",outcome} and failures are logged without credentials.
+// Locked out clients are rejected with 429 and a Retry-After header.
+func BasicAuthMiddleware(metrics *MetricsCollector, config BasicAuthConfig) Middleware {
+	if config.Realm == "" {
+		config.Realm = "Restricted"
+	}
+
+	if config.LockoutDuration <= 0 {
+		config.LockoutDuration = time.Minute
+	}
+
+	if config.Validate == nil {
+		config.Validate = usersValidator(config.Users)
+	}
+
+	audit := newAuthAudit(metrics, "basic")
+	lockouts := newLockouts(config.MaxFailures, config.LockoutDuration)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+
+			if retryAfter := lockouts.lockedFor(ip); retryAfter > 0 {
+				audit.failure(r, authOutcomeLocked, "locked_out")
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				http.Error(w, "Too many failed login attempts", http.StatusTooManyRequests)
+
+				return
+			}
+
+			user, password, ok := r.BasicAuth()
+			if !ok || !config.Validate(user, password) {
+				reason := "invalid_credentials"
+				if !ok {
+					reason = "missing_credentials"
+				}
+
+				// Missing credentials are the normal browser challenge flow and don't count towards the lockout
+				if ok {
+					lockouts.fail(ip)
+				}
+
+				// The user name isn't logged, as users sometimes type their password into the user field
+				audit.failure(r, authOutcomeFailure, reason)
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+config.Realm+`", charset="UTF-8"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			lockouts.reset(ip)
+			audit.success()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// usersValidator returns a validator comparing credentials against a static user map in constant time
+func usersValidator(users map[string]string) func(user, password string) bool {
+	return func(user, password string) bool {
+		expected, ok := users[user]
+
+		// Compare hashes, so the comparison takes the same time for all password lengths
+		given := sha256.Sum256([]byte(password))
+		want := sha256.Sum256([]byte(expected))
+
+		return subtle.ConstantTimeCompare(given[:], want[:]) == 1 && ok
+	}
+}
+
+// lockoutState holds the consecutive failures of a client
+type lockoutState struct {
+	failures    int
+	lockedUntil time.Time
+}
+
+// lockouts tracks failed logins per client IP and locks out clients with exponential backoff
+type lockouts struct {
+	maxFailures int
+	duration    time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*lockoutState
+}
+
+// newLockouts creates a new lockout tracker (maxFailures <= 0 disables lockouts)
+func newLockouts(maxFailures int, duration time.Duration) *lockouts {
+	return &lockouts{
+		maxFailures: maxFailures,
+		duration:    duration,
+		clients:     make(map[string]*lockoutState),
+	}
+}
+
+// lockedFor returns the remaining lockout duration of a client
+func (l *lockouts) lockedFor(ip string) time.Duration {
+	if l.maxFailures <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.clients[ip]
+	if !ok {
+		return 0
+	}
+
+	return max(time.Until(state.lockedUntil), 0)
+}
+
+// fail records a failed login and locks out the client once it reached the maximum failures
+func (l *lockouts) fail(ip string) {
+	if l.maxFailures <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.clients) >= 1024 {
+		l.pruneLocked()
+	}
+
+	state, ok := l.clients[ip]
+	if !ok {
+		state = &lockoutState{}
+		l.clients[ip] = state
+	}
+
+	state.failures++
+
+	if excess := state.failures - l.maxFailures; excess >= 0 {
+		lockout := l.duration << min(excess, 16)
+		state.lockedUntil = time.Now().Add(min(lockout, maxLockoutDuration))
+	}
+}
+
+// reset forgets the failures of a client after a successful login
+func (l *lockouts) reset(ip string) {
+	if l.maxFailures <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.clients, ip)
+}
+
+// pruneLocked removes clients that are not locked out, so the tracker can't grow without bounds.
+// The caller must hold the lock.
+func (l *lockouts) pruneLocked() {
+	now := time.Now()
+
+	for ip, state := range l.clients {
+		if now.After(state.lockedUntil) {
+			delete(l.clients, ip)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("basicauth_test")
+
+	handler := BasicAuthMiddleware(metrics, BasicAuthConfig{
+		Users:           map[string]string{"admin": "secret"},
+		MaxFailures:     2,
+		LockoutDuration: time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := func(remoteAddr, user, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.RemoteAddr = remoteAddr
+
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	if rec := request("10.0.0.1:1", "", ""); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected a basic auth challenge, got %d", rec.Code)
+	}
+
+	if rec := request("10.0.0.1:1", "admin", "secret"); rec.Code != http.StatusNoContent {
+		t.Errorf("expected valid credentials to pass, got %d", rec.Code)
+	}
+
+	for range 2 {
+		if rec := request("10.0.0.2:1", "admin", "guess"); rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for invalid credentials, got %d", rec.Code)
+		}
+	}
+
+	// Locked out, even with valid credentials
+	rec := request("10.0.0.2:1", "admin", "secret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected lockout with Retry-After, got %d", rec.Code)
+	}
+
+	// Other clients are not affected
+	if rec := request("10.0.0.3:1", "admin", "secret"); rec.Code != http.StatusNoContent {
+		t.Errorf("expected other clients not to be locked out, got %d", rec.Code)
+	}
+
+	attempts := metrics.builtinCounterVec("auth_attempts_total", "", "method", "outcome")
+
+	for outcome, want := range map[string]float64{"success": 2, "failure": 3, "locked": 1} {
+		if got := testutil.ToFloat64(attempts.WithLabelValues("basic", outcome)); got != want {
+			t.Errorf("expected %v %s attempts, got %v", want, outcome, got)
+		}
+	}
+}
+
+func TestLockoutsBackoff(t *testing.T) {
+	t.Parallel()
+
+	l := newLockouts(1, time.Minute)
+
+	l.fail("ip")
+
+	if got := l.lockedFor("ip"); got <= 59*time.Second || got > time.Minute {
+		t.Errorf("expected a 1m lockout, got %v", got)
+	}
+
+	l.fail("ip")
+
+	if got := l.lockedFor("ip"); got <= 119*time.Second || got > 2*time.Minute {
+		t.Errorf("expected the lockout to double, got %v", got)
+	}
+
+	for range 20 {
+		l.fail("ip")
+	}
+
+	if got := l.lockedFor("ip"); got > maxLockoutDuration {
+		t.Errorf("expected the lockout to be capped at %v, got %v", maxLockoutDuration, got)
+	}
+
+	l.reset("ip")
+
+	if got := l.lockedFor("ip"); got != 0 {
+		t.Errorf("expected no lockout after reset, got %v", got)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultJSONBodyLimit is the default maximum size of request bodies decoded with DecodeJSON
+const DefaultJSONBodyLimit = 1 << 20
+
+// jsonConfig holds the configuration of DecodeJSON
+type jsonConfig struct {
+	limit         int64
+	unknownFields bool
+}
+
+// JSONOption configures DecodeJSON
+type JSONOption func(*jsonConfig)
+
+// WithJSONLimit sets the maximum size of the request body in bytes. Defaults to DefaultJSONBodyLimit.
+func WithJSONLimit(limit int64) JSONOption {
+	return func(config *jsonConfig) {
+		config.limit = limit
+	}
+}
+
+// AllowUnknownFields accepts fields in the request body that the target doesn't have, e.g. for forward
+// compatibility with newer clients
+func AllowUnknownFields() JSONOption {
+	return func(config *jsonConfig) {
+		config.unknownFields = true
+	}
+}
+
+// statusError overrides the HTTP status of an error (see HTTPStatus), e.g. for statuses without a canonical code
+type statusError struct {
+	error
+
+	status int
+}
+
+// Unwrap returns the error
+func (e statusError) Unwrap() error {
+	return e.error
+}
+
+// DecodeJSON decodes the JSON body of a request into v. The body must have a JSON content type, must not exceed
+// the size limit, must contain exactly one JSON value, and mustn't contain fields v doesn't have (see
+// AllowUnknownFields). Errors are service Errors with a message for the client, so handlers can pass them to
+// WriteError with a status of 0: 400 for invalid bodies, 413 for bodies that are too large, and 415 for other
+// content types.
+func DecodeJSON(r *http.Request, v any, opts ...JSONOption) error {
+	config := jsonConfig{limit: DefaultJSONBodyLimit}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if contentType := r.Header.Get("Content-Type"); !isJSON(contentType) {
+		return statusError{
+			error:  NewError(CodeInvalidArgument, fmt.Sprintf("unsupported content type %q, expected application/json", contentType)),
+			status: http.StatusUnsupportedMediaType,
+		}
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return NewError(CodeInvalidArgument, "request body is empty")
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, config.limit))
+	if !config.unknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(v); err != nil {
+		return jsonDecodeError(err)
+	}
+
+	if decoder.More() {
+		return NewError(CodeInvalidArgument, "request body must contain a single JSON value")
+	}
+
+	return nil
+}
+
+// jsonDecodeError translates an error of the JSON decoder into an error for the client
+func jsonDecodeError(err error) error {
+	var (
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		maxBytesErr *http.MaxBytesError
+	)
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return statusError{
+			error:  WrapError(err, CodeOutOfRange, "request body exceeds "+strconv.FormatInt(maxBytesErr.Limit, 10)+" bytes"),
+			status: http.StatusRequestEntityTooLarge,
+		}
+	case errors.As(err, &syntaxErr):
+		return WrapError(err, CodeInvalidArgument, fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		return WrapError(err, CodeInvalidArgument, fmt.Sprintf("invalid value for field %q", typeErr.Field)).
+			WithDetail("field", typeErr.Field)
+	case errors.Is(err, io.EOF):
+		return NewError(CodeInvalidArgument, "request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return WrapError(err, CodeInvalidArgument, "malformed JSON")
+	default:
+		// Unknown fields are only reported as a message, e.g. `json: unknown field "nmae"`
+		return NewError(CodeInvalidArgument, "invalid JSON body: "+strings.TrimPrefix(err.Error(), "json: "))
+	}
+}
+
+// RespondJSON writes v as a JSON response with the status. Encoding errors are returned before anything is written
+// and answered with an opaque 500.
+func RespondJSON(w http.ResponseWriter, status int, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return fmt.Errorf("failed to encode JSON response: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err //nolint:wrapcheck
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        []JSONOption
+		status      int
+		detail      string
+	}{
+		{"valid", "application/json", `{"name":"gopher","age":13}`, nil, http.StatusOK, ""},
+		{"json suffix", "application/merge-patch+json; charset=utf-8", `{"name":"gopher"}`, nil, http.StatusOK, ""},
+		{"content type", "text/plain", `{"name":"gopher"}`, nil, http.StatusUnsupportedMediaType, "unsupported content type"},
+		{"empty", "application/json", ``, nil, http.StatusBadRequest, "request body is empty"},
+		{"malformed", "application/json", `{"name":`, nil, http.StatusBadRequest, "malformed JSON"},
+		{"syntax", "application/json", `{"name" "gopher"}`, nil, http.StatusBadRequest, "malformed JSON at offset"},
+		{"type", "application/json", `{"age":"old"}`, nil, http.StatusBadRequest, `invalid value for field "age"`},
+		{"unknown field", "application/json", `{"nmae":"gopher"}`, nil, http.StatusBadRequest, `unknown field "nmae"`},
+		{"allowed unknown field", "application/json", `{"name":"gopher","nick":"go"}`, []JSONOption{AllowUnknownFields()}, http.StatusOK, ""},
+		{"trailing", "application/json", `{"name":"a"}{"name":"b"}`, nil, http.StatusBadRequest, "single JSON value"},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("x", 64) + `"}`, []JSONOption{WithJSONLimit(32)}, http.StatusRequestEntityTooLarge, "exceeds 32 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			var target user
+
+			err := DecodeJSON(req, &target, tt.opts...)
+			if tt.status == http.StatusOK {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+
+				if target.Name != "gopher" {
+					t.Errorf("expected the body to be decoded, got %+v", target)
+				}
+
+				return
+			}
+
+			if status := HTTPStatus(err); status != tt.status {
+				t.Errorf("expected status %d, got %d (%v)", tt.status, status, err)
+			}
+
+			if !errors.Is(err, ErrInvalidArgument) && !errors.Is(err, ErrOutOfRange) {
+				t.Errorf("expected a client error code, got %s", CodeOf(err))
+			}
+
+			if problem := NewProblem(req, tt.status, err); !strings.Contains(problem.Detail, tt.detail) {
+				t.Errorf("expected the detail to contain %q, got %q", tt.detail, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestRespondJSON(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	if err := RespondJSON(rec, http.StatusCreated, map[string]string{"id": "42"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["id"] != "42" {
+		t.Errorf("unexpected body %v (%v)", body, err)
+	}
+
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	if err := RespondJSON(rec, http.StatusOK, func() {}); err == nil || rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected encoding errors to be answered with 500, got %d (%v)", rec.Code, err)
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MaxBodySizeConfig holds configuration for the request body size limit
+type MaxBodySizeConfig struct {
+	// Limit is the maximum request body size in bytes of routes without their own limit (0 disables it)
+	Limit int64
+}
+
+// WithMaxBodySize sets the maximum request body size in bytes of a single route, overriding MAX_BODY_SIZE, e.g. for
+// upload routes. A limit of 0 disables the limit.
+func WithMaxBodySize(limit int64) RouteOption {
+	return func(rt *route) {
+		rt.maxBodySize = &limit
+	}
+}
+
+// MaxBodySizeMiddleware limits the size of request bodies (see WithMaxBodySize for per-route limits).
+// Requests whose Content-Length exceeds the limit are rejected with 413 before the handler runs; reads of bodies
+// without a Content-Length fail with *http.MaxBytesError once they exceed it, and the connection is closed after
+// the response. Requests over the limit are counted in {service_name}_request_body_too_large_total{route}.
+func MaxBodySizeMiddleware(metrics *MetricsCollector, config MaxBodySizeConfig) Middleware {
+	tooLarge := metrics.builtinCounterVec("request_body_too_large_total",
+		"Total number of requests whose body exceeded the size limit", "route")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := config.Limit
+			if rt := getRoute(r); rt != nil && rt.maxBodySize != nil {
+				limit = *rt.maxBodySize
+			}
+
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			counter := tooLarge.WithLabelValues(RoutePattern(r))
+
+			if r.ContentLength > limit {
+				counter.Inc()
+				GetLogger(r).Warn("request body too large", "route", RoutePattern(r), "size", r.ContentLength, "limit", limit)
+
+				w.Header().Set("Connection", "close")
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+
+				return
+			}
+
+			r.Body = &maxBodyReader{ReadCloser: http.MaxBytesReader(w, r.Body, limit), counter: counter}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// maxBodyReader counts the first read of a request body beyond the size limit
+type maxBodyReader struct {
+	io.ReadCloser
+
+	counter prometheus.Counter
+	once    sync.Once
+}
+
+// Read reads from the limited body
+func (m *maxBodyReader) Read(p []byte) (int, error) {
+	n, err := m.ReadCloser.Read(p)
+
+	if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+		m.once.Do(m.counter.Inc)
+	}
+
+	return n, err //nolint:wrapcheck
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMaxBodySizeMiddleware(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.MaxBodySize = 8
+	svc := New("bodylimit_test", config)
+
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+			http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		_, _ = w.Write(body)
+	}
+
+	svc.HandleFunc("POST /echo", echo)
+	svc.HandleFunc("POST /upload", echo, WithMaxBodySize(64))
+	svc.HandleFunc("POST /unlimited", echo, WithMaxBodySize(0))
+
+	handler := svc.handler()
+
+	request := func(path, body string, chunked bool) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	large := strings.Repeat("x", 32)
+
+	if code := request("/echo", "small", false); code != http.StatusOK {
+		t.Errorf("expected bodies within the limit to pass, got %d", code)
+	}
+
+	if code := request("/echo", large, false); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a large Content-Length, got %d", code)
+	}
+
+	if code := request("/echo", large, true); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected reads of a large streamed body to fail, got %d", code)
+	}
+
+	if code := request("/upload", large, false); code != http.StatusOK {
+		t.Errorf("expected the route limit to override the default, got %d", code)
+	}
+
+	if code := request("/unlimited", strings.Repeat("x", 1024), true); code != http.StatusOK {
+		t.Errorf("expected a route limit of 0 to disable the limit, got %d", code)
+	}
+
+	tooLarge := svc.Metrics.builtinCounterVec("request_body_too_large_total", "", "route")
+	if got := testutil.ToFloat64(tooLarge.WithLabelValues("POST /echo")); got != 2 {
+		t.Errorf("expected 2 requests over the limit, got %v", got)
+	}
+}
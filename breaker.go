@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+// Circuit breaker states
+const (
+	CircuitClosed CircuitState = iota
+	CircuitHalfOpen
+	CircuitOpen
+)
+
+// String returns the name of the circuit state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half_open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig holds configuration for a route circuit breaker
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the failure ratio that opens the circuit. Defaults to 0.5.
+	FailureThreshold float64
+	// MinRequests is the minimum number of requests in a window before the circuit can open. Defaults to 10.
+	MinRequests int
+	// Window is the duration failures are counted in. Defaults to 10s.
+	Window time.Duration
+	// OpenDuration is the time the circuit stays open before probe requests are let through. Defaults to 30s.
+	OpenDuration time.Duration
+	// HalfOpenRequests is the number of concurrent probe requests in the half-open state. Defaults to 1.
+	HalfOpenRequests int
+	// IsFailure reports whether a response status counts as a failure. Defaults to server errors.
+	// Requests that exceed their deadline (see WithTimeout) always count as failures.
+	IsFailure func(status int) bool
+	// Fallback serves requests while the circuit is open. Defaults to 503 Service Unavailable.
+	Fallback http.Handler
+}
+
+// WithCircuitBreaker protects a route backed by a flaky dependency with a circuit breaker.
+// Once the failure ratio exceeds the threshold, requests are served by the fallback handler
+// instead of tying up server workers until the dependency recovers.
+func WithCircuitBreaker(config CircuitBreakerConfig) RouteOption {
+	return func(rt *route) {
+		breaker := newCircuitBreaker(rt.service, rt.pattern, config)
+		rt.use(breaker.middleware)
+	}
+}
+
+// circuitBreaker implements the closed, open, and half-open states of a route circuit breaker
+type circuitBreaker struct {
+	config  CircuitBreakerConfig
+	route   string
+	service *Service
+
+	stateGauge  *prometheus.GaugeVec
+	transitions *prometheus.CounterVec
+	rejected    *prometheus.CounterVec
+
+	mu          sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+}
+
+// newCircuitBreaker creates a new closed circuit breaker for a route
+func newCircuitBreaker(svc *Service, pattern string, config CircuitBreakerConfig) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 0.5
+	}
+
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+
+	if config.Window <= 0 {
+		config.Window = 10 * time.Second
+	}
+
+	if config.OpenDuration <= 0 {
+		config.OpenDuration = 30 * time.Second
+	}
+
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+
+	if config.IsFailure == nil {
+		config.IsFailure = func(status int) bool {
+			return status >= http.StatusInternalServerError
+		}
+	}
+
+	if config.Fallback == nil {
+		config.Fallback = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		})
+	}
+
+	breaker := &circuitBreaker{
+		config:      config,
+		route:       pattern,
+		service:     svc,
+		windowStart: time.Now(),
+		stateGauge: svc.Metrics.builtinGaugeVec("circuit_breaker_state",
+			"State of the route circuit breaker (0 = closed, 1 = half-open, 2 = open)", "route"),
+		transitions: svc.Metrics.builtinCounterVec("circuit_breaker_transitions_total",
+			"Total number of circuit breaker state changes", "route", "state"),
+		rejected: svc.Metrics.builtinCounterVec("circuit_breaker_rejected_total",
+			"Total number of requests rejected by an open circuit breaker", "route"),
+	}
+
+	breaker.stateGauge.WithLabelValues(pattern).Set(float64(CircuitClosed))
+
+	return breaker
+}
+
+// middleware serves requests through the circuit breaker
+func (b *circuitBreaker) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probe, ok := b.allow()
+		if !ok {
+			b.rejected.WithLabelValues(b.route).Inc()
+			b.config.Fallback.ServeHTTP(w, r)
+
+			return
+		}
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				b.done(probe, true)
+				panic(recovered)
+			}
+
+			// The timeout response is sent by the timeout middleware, so the handler may have written no status
+			timedOut := errors.Is(r.Context().Err(), context.DeadlineExceeded)
+			b.done(probe, timedOut || b.config.IsFailure(wrapped.statusCode))
+		}()
+
+		next.ServeHTTP(wrapped, r)
+	})
+}
+
+// allow reports whether a request is a half-open probe and whether it may pass
+func (b *circuitBreaker) allow() (bool, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen {
+		if time.Since(b.openedAt) < b.config.OpenDuration {
+			return false, false
+		}
+
+		b.transitionLocked(CircuitHalfOpen)
+	}
+
+	if b.state == CircuitHalfOpen {
+		if b.probes >= b.config.HalfOpenRequests {
+			return false, false
+		}
+
+		b.probes++
+
+		return true, true
+	}
+
+	return false, true
+}
+
+// done records the outcome of a request
+func (b *circuitBreaker) done(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probes--
+
+		if b.state != CircuitHalfOpen {
+			return
+		}
+
+		if failed {
+			b.transitionLocked(CircuitOpen)
+		} else {
+			b.transitionLocked(CircuitClosed)
+		}
+
+		return
+	}
+
+	if b.state != CircuitClosed {
+		return
+	}
+
+	if time.Since(b.windowStart) >= b.config.Window {
+		b.windowStart = time.Now()
+		b.requests, b.failures = 0, 0
+	}
+
+	b.requests++
+
+	if failed {
+		b.failures++
+	}
+
+	if b.requests >= b.config.MinRequests && float64(b.failures)/float64(b.requests) >= b.config.FailureThreshold {
+		b.transitionLocked(CircuitOpen)
+	}
+}
+
+// transitionLocked changes the state of the circuit breaker. The caller must hold the lock.
+func (b *circuitBreaker) transitionLocked(state CircuitState) {
+	if b.state == state {
+		return
+	}
+
+	b.service.Logger.Warn("circuit breaker state changed", "route", b.route, "from", b.state.String(), "to", state.String())
+
+	b.state = state
+
+	switch state {
+	case CircuitOpen:
+		b.openedAt = time.Now()
+	case CircuitClosed:
+		b.windowStart = time.Now()
+		b.requests, b.failures = 0, 0
+	case CircuitHalfOpen:
+	}
+
+	b.stateGauge.WithLabelValues(b.route).Set(float64(state))
+	b.transitions.WithLabelValues(b.route, state.String()).Inc()
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	svc := New("breaker_test", nil)
+
+	var (
+		calls   atomic.Int32
+		failing atomic.Bool
+	)
+
+	failing.Store(true)
+
+	svc.HandleFunc("/dependency", func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}, WithCircuitBreaker(CircuitBreakerConfig{
+		MinRequests:  4,
+		OpenDuration: 30 * time.Millisecond,
+		Fallback: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	}))
+
+	request := func() int {
+		recorder := httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/dependency", nil))
+
+		return recorder.Code
+	}
+
+	for range 4 {
+		request()
+	}
+
+	if code := request(); code != http.StatusTeapot {
+		t.Fatalf("expected fallback once the circuit is open, got %d", code)
+	}
+
+	if count := calls.Load(); count != 4 {
+		t.Errorf("expected open circuit not to call the handler, got %d calls", count)
+	}
+
+	// A failing probe opens the circuit again
+	time.Sleep(40 * time.Millisecond)
+
+	if code := request(); code != http.StatusBadGateway {
+		t.Errorf("expected probe request to reach the handler, got %d", code)
+	}
+
+	if code := request(); code != http.StatusTeapot {
+		t.Errorf("expected failed probe to reopen the circuit, got %d", code)
+	}
+
+	// A successful probe closes the circuit
+	failing.Store(false)
+	time.Sleep(40 * time.Millisecond)
+
+	for range 3 {
+		if code := request(); code != http.StatusOK {
+			t.Errorf("expected closed circuit after successful probe, got %d", code)
+		}
+	}
+}
+
+func TestCircuitState_String(t *testing.T) {
+	t.Parallel()
+
+	tests := map[CircuitState]string{
+		CircuitClosed:   "closed",
+		CircuitHalfOpen: "half_open",
+		CircuitOpen:     "open",
+		CircuitState(9): "unknown",
+	}
+
+	for state, expected := range tests {
+		if state.String() != expected {
+			t.Errorf("expected %q, got %q", expected, state.String())
+		}
+	}
+}
+
+func TestWithCircuitBreaker_Timeout(t *testing.T) {
+	t.Parallel()
+
+	svc := New("breaker_timeout_test", nil)
+
+	svc.HandleFunc("/slow", func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}, WithTimeout(10*time.Millisecond), WithCircuitBreaker(CircuitBreakerConfig{
+		MinRequests: 2,
+		Fallback: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	}))
+
+	handler := svc.handler()
+
+	request := func() int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+		return recorder.Code
+	}
+
+	for range 2 {
+		if code := request(); code != http.StatusGatewayTimeout {
+			t.Fatalf("expected 504 for timed out request, got %d", code)
+		}
+	}
+
+	// The timeout middleware may answer before the handler returns
+	time.Sleep(20 * time.Millisecond)
+
+	if code := request(); code != http.StatusTeapot {
+		t.Errorf("expected timed out requests to open the circuit, got %d", code)
+	}
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheConfig holds configuration for the response cache of a route
+type CacheConfig struct {
+	// TTL is the duration a cached response is fresh
+	TTL time.Duration
+	// StaleWhileRevalidate serves stale responses for this duration after the TTL while refreshing them in the background
+	StaleWhileRevalidate time.Duration
+	// StaleIfError serves stale responses for this duration after the TTL when the handler fails with a server error
+	StaleIfError time.Duration
+	// MaxEntries limits the number of cached responses. Defaults to 1000.
+	MaxEntries int
+	// Key returns the cache key of a request. Defaults to the method, the URI, and the tenant (see TenantID).
+	// Responses are additionally keyed by the request headers listed in their Vary header.
+	Key func(r *http.Request) string
+	// Authenticated caches requests with credentials (Authorization or Cookie headers), which bypass the cache by
+	// default. Only enable it if Key or the Vary header of the responses separates the users.
+	Authenticated bool
+}
+
+// WithCache caches successful GET and HEAD responses of a route in memory. Responses with Set-Cookie, "Vary: *",
+// or "Cache-Control: private", "no-store", or "no-cache" are not cached, and requests with credentials bypass the
+// cache (see CacheConfig.Authenticated).
+// Cache results are recorded in {service_name}_cache_requests_total{route,result}.
+func WithCache(config CacheConfig) RouteOption {
+	return func(rt *route) {
+		cache := newResponseCache(rt.service.Metrics, rt.pattern, config)
+		rt.use(cache.middleware)
+	}
+}
+
+// cachedResponse is a response stored in the cache
+type cachedResponse struct {
+	status     int
+	header     http.Header
+	body       []byte
+	storedAt   time.Time
+	refreshing bool
+}
+
+// responseCache is the in-memory response cache of a single route
+type responseCache struct {
+	config  CacheConfig
+	route   string
+	results *prometheus.CounterVec
+
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	// vary holds the request headers of the Vary header of the latest response by key
+	vary map[string][]string
+}
+
+// newResponseCache creates a new response cache for a route
+func newResponseCache(metrics *MetricsCollector, pattern string, config CacheConfig) *responseCache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 1000
+	}
+
+	if config.Key == nil {
+		config.Key = func(r *http.Request) string {
+			return r.Method + " " + r.URL.RequestURI() + " " + TenantID(r)
+		}
+	}
+
+	return &responseCache{
+		config:  config,
+		route:   pattern,
+		results: metrics.builtinCounterVec("cache_requests_total", "Total number of cached route requests by result", "route", "result"),
+		entries: make(map[string]*cachedResponse),
+		vary:    make(map[string][]string),
+	}
+}
+
+// middleware serves responses from the cache
+func (c *responseCache) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.config.Authenticated && (r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "") {
+			c.record("bypass")
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		key := c.config.Key(r)
+		entry, age, stale, refresh := c.lookup(key, r)
+
+		switch {
+		case entry != nil && age < c.config.TTL:
+			c.record("hit")
+			entry.write(w)
+
+			return
+		case stale:
+			c.record("stale")
+			entry.write(w)
+
+			if refresh {
+				go c.revalidate(key, next, r)
+			}
+
+			return
+		}
+
+		recorder := newGuardedBufferedResponse(w, r)
+		next.ServeHTTP(recorder, r)
+
+		if recorder.streamed {
+			c.record("too_large")
+			return
+		}
+
+		if recorder.status >= http.StatusInternalServerError && entry != nil && age < c.config.TTL+c.config.StaleIfError {
+			c.record("stale_if_error")
+			GetLogger(r).Warn("serving stale response", "route", c.route, "status", recorder.status, "age", age)
+			entry.write(w)
+
+			return
+		}
+
+		c.record("miss")
+		c.store(key, r, recorder)
+		recorder.writeTo(w)
+	})
+}
+
+// lookup returns the cached response, its age, and whether it may be served stale while revalidating.
+// Only the first stale lookup is asked to refresh the entry, so revalidations are not duplicated.
+func (c *responseCache) lookup(key string, r *http.Request) (*cachedResponse, time.Duration, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[varyKey(key, c.vary[key], r)]
+	if !ok {
+		return nil, 0, false, false
+	}
+
+	age := time.Since(entry.storedAt)
+	if age < c.config.TTL || age >= c.config.TTL+c.config.StaleWhileRevalidate {
+		return entry, age, false, false
+	}
+
+	refresh := !entry.refreshing
+	entry.refreshing = true
+
+	return entry, age, true, refresh
+}
+
+// revalidate refreshes a cached response in the background. It runs outside the recovery middleware, so panics of
+// the handler are recovered and logged here, and the stale entry is served until the next revalidation.
+func (c *responseCache) revalidate(key string, next http.Handler, r *http.Request) {
+	stored := false
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			GetLogger(r).Error("panic recovered while revalidating cached response", "error", recovered, "route", c.route)
+		}
+
+		if !stored {
+			c.mu.Lock()
+			if entry, ok := c.entries[varyKey(key, c.vary[key], r)]; ok {
+				entry.refreshing = false
+			}
+			c.mu.Unlock()
+		}
+	}()
+
+	recorder := newGuardedBufferedResponse(nil, r)
+	next.ServeHTTP(recorder, r.Clone(context.WithoutCancel(r.Context())))
+
+	stored = c.store(key, r, recorder)
+}
+
+// store caches a successful, cacheable response and reports whether it was stored
+func (c *responseCache) store(key string, r *http.Request, recorder *bufferedResponse) bool {
+	if recorder.status != http.StatusOK || recorder.streamed || !cacheable(recorder.header) {
+		return false
+	}
+
+	vary := varyHeaders(recorder.header)
+
+	entry := &cachedResponse{
+		status:   recorder.status,
+		header:   recorder.header.Clone(),
+		body:     recorder.body.Bytes(),
+		storedAt: time.Now(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.vary[key] = vary
+	key = varyKey(key, vary, r)
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.config.MaxEntries {
+		c.evictLocked()
+	}
+
+	c.entries[key] = entry
+
+	return true
+}
+
+// cacheable reports whether the headers of a response allow caching it for other requests
+func cacheable(header http.Header) bool {
+	if header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	for _, directive := range strings.Split(strings.ToLower(strings.Join(header.Values("Cache-Control"), ",")), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if name == "private" || name == "no-store" || name == "no-cache" {
+			return false
+		}
+	}
+
+	return !slices.Contains(varyHeaders(header), "*")
+}
+
+// varyHeaders returns the canonical request headers of the Vary header of a response
+func varyHeaders(header http.Header) []string {
+	var names []string
+
+	for _, value := range header.Values("Vary") {
+		for name := range strings.SplitSeq(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
+
+// varyKey returns the cache key of a request extended by the values of the request headers a response varies by
+func varyKey(key string, vary []string, r *http.Request) string {
+	for _, name := range vary {
+		key += "\n" + name + ": " + strings.Join(r.Header.Values(name), ",")
+	}
+
+	return key
+}
+
+// evictLocked removes the oldest cached response. The caller must hold the lock.
+func (c *responseCache) evictLocked() {
+	var (
+		oldestKey string
+		oldest    time.Time
+	)
+
+	for key, entry := range c.entries {
+		if oldestKey == "" || entry.storedAt.Before(oldest) {
+			oldestKey, oldest = key, entry.storedAt
+		}
+	}
+
+	delete(c.entries, oldestKey)
+}
+
+// record increments the cache result counter
+func (c *responseCache) record(result string) {
+	c.results.WithLabelValues(c.route, result).Inc()
+}
+
+// write writes the cached response
+func (e *cachedResponse) write(w http.ResponseWriter) {
+	for name, values := range e.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+
+	w.WriteHeader(e.status)
+	_, _ = w.Write(e.body)
+}
+
+// bufferedResponse is a response writer that buffers the response in memory.
+// With a threshold, it streams the response to the target once the body exceeds the threshold, or fails the
+// writes beyond it with reject.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+
+	threshold int64
+	target    http.ResponseWriter
+	onExceed  func()
+	streamed  bool
+	reject    bool
+	rejected  bool
+}
+
+// newBufferedResponse creates a new buffered response
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+// Header returns the response headers
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader captures the status code
+func (b *bufferedResponse) WriteHeader(code int) {
+	b.status = code
+}
+
+// Write buffers the response body, or streams it once it exceeds the threshold
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	if b.rejected {
+		return 0, ErrResponseTooLarge
+	}
+
+	if !b.streamed && b.threshold > 0 && int64(b.body.Len()+len(data)) > b.threshold {
+		b.onExceed()
+
+		if b.reject {
+			b.rejected = true
+			b.body.Reset()
+
+			return 0, ErrResponseTooLarge
+		}
+
+		b.streamed = true
+
+		for name, values := range b.header {
+			b.target.Header()[name] = values
+		}
+
+		b.target.WriteHeader(b.status)
+
+		if _, err := b.target.Write(b.body.Bytes()); err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+
+		b.body.Reset()
+	}
+
+	if b.streamed {
+		return b.target.Write(data) //nolint:wrapcheck
+	}
+
+	return b.body.Write(data) //nolint:wrapcheck
+}
+
+// writeTo writes the buffered response, unless it was already streamed
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	if b.streamed {
+		return
+	}
+
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+
+	w.WriteHeader(b.status)
+	_, _ = w.Write(b.body.Bytes())
+}
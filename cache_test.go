@@ -0,0 +1,220 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithCache(t *testing.T) {
+	t.Parallel()
+
+	svc := New("cache_test", nil)
+
+	var (
+		calls   atomic.Int32
+		failing atomic.Bool
+	)
+
+	svc.HandleFunc("/data", func(w http.ResponseWriter, _ *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("X-Call", strconv.Itoa(int(calls.Add(1))))
+		w.WriteHeader(http.StatusOK)
+	}, WithCache(CacheConfig{
+		TTL:                  20 * time.Millisecond,
+		StaleWhileRevalidate: 40 * time.Millisecond,
+		StaleIfError:         time.Minute,
+	}))
+
+	request := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/data", nil))
+
+		return recorder
+	}
+
+	results := svc.Metrics.builtinCounterVec("cache_requests_total", "", "route", "result")
+
+	request()
+
+	if recorder := request(); recorder.Header().Get("X-Call") != "1" {
+		t.Errorf("expected cached response, got call %q", recorder.Header().Get("X-Call"))
+	}
+
+	// Stale while revalidate serves the old response and refreshes it in the background
+	time.Sleep(30 * time.Millisecond)
+
+	if recorder := request(); recorder.Header().Get("X-Call") != "1" {
+		t.Errorf("expected stale response, got call %q", recorder.Header().Get("X-Call"))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if recorder := request(); recorder.Header().Get("X-Call") != "2" {
+		t.Errorf("expected revalidated response, got call %q", recorder.Header().Get("X-Call"))
+	}
+
+	// Stale if error serves the old response when the handler fails
+	failing.Store(true)
+	time.Sleep(70 * time.Millisecond)
+
+	if recorder := request(); recorder.Code != http.StatusOK || recorder.Header().Get("X-Call") != "2" {
+		t.Errorf("expected stale response on error, got %d / call %q", recorder.Code, recorder.Header().Get("X-Call"))
+	}
+
+	for result, expected := range map[string]float64{"hit": 2, "stale": 1, "stale_if_error": 1, "miss": 1} {
+		if count := testutil.ToFloat64(results.WithLabelValues("/data", result)); count != expected {
+			t.Errorf("expected %v %s results, got %v", expected, result, count)
+		}
+	}
+}
+
+func TestWithCache_NotCached(t *testing.T) {
+	t.Parallel()
+
+	svc := New("cache_not_cached_test", nil)
+
+	var calls atomic.Int32
+
+	svc.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}, WithCache(CacheConfig{TTL: time.Minute}))
+
+	for _, target := range []string{"/data?fail=1", "/data?fail=1"} {
+		svc.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	for range 2 {
+		svc.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/data", nil))
+	}
+
+	if count := calls.Load(); count != 4 {
+		t.Errorf("expected errors and POST requests not to be cached, got %d handler calls", count)
+	}
+}
+
+func TestWithCache_Privacy(t *testing.T) {
+	t.Parallel()
+
+	svc := New("cache_privacy_test", nil)
+
+	var calls atomic.Int32
+
+	svc.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		switch r.URL.Query().Get("kind") {
+		case "private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "cookie":
+			w.Header().Set("Set-Cookie", "session=abc")
+		case "vary":
+			w.Header().Set("Vary", "Accept-Language")
+		}
+
+		_, _ = w.Write([]byte(r.Header.Get("Accept-Language")))
+	}, WithCache(CacheConfig{TTL: time.Minute}))
+
+	request := func(target string, header ...string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+
+		recorder := httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, req)
+
+		return recorder.Body.String()
+	}
+
+	for _, target := range []string{"/data?kind=private", "/data?kind=cookie"} {
+		request(target)
+		request(target)
+	}
+
+	request("/data", "Authorization", "Bearer alice")
+	request("/data", "Cookie", "session=bob")
+
+	if count := calls.Load(); count != 6 {
+		t.Errorf("expected private responses and credentials not to be cached, got %d handler calls", count)
+	}
+
+	if body := request("/data?kind=vary", "Accept-Language", "de"); body != "de" {
+		t.Errorf("expected de, got %q", body)
+	}
+
+	if body := request("/data?kind=vary", "Accept-Language", "fr"); body != "fr" {
+		t.Errorf("expected responses to be keyed by the Vary header, got %q", body)
+	}
+
+	if body := request("/data?kind=vary", "Accept-Language", "fr"); body != "fr" || calls.Load() != 8 {
+		t.Errorf("expected a cached fr response, got %q after %d calls", body, calls.Load())
+	}
+}
+
+func TestWithCache_RevalidatePanic(t *testing.T) {
+	t.Parallel()
+
+	svc := New("cache_revalidate_panic_test", nil)
+
+	var calls atomic.Int32
+
+	svc.HandleFunc("/data", func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 2 {
+			panic("boom")
+		}
+
+		w.Header().Set("X-Call", strconv.Itoa(int(calls.Load())))
+	}, WithCache(CacheConfig{TTL: 10 * time.Millisecond, StaleWhileRevalidate: time.Minute}))
+
+	request := func() string {
+		recorder := httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/data", nil))
+
+		return recorder.Header().Get("X-Call")
+	}
+
+	request()
+	time.Sleep(15 * time.Millisecond)
+
+	// The revalidation panics in the background without crashing the process
+	request()
+
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	// The entry is revalidated again by the next stale request
+	if call := request(); call != "1" {
+		t.Errorf("expected the stale response, got call %q", call)
+	}
+
+	for calls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("expected a second revalidation, got %d calls", calls.Load())
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachePolicy describes the Cache-Control, Expires, and Vary headers of responses
+type CachePolicy struct {
+	// CacheControl is the Cache-Control header value
+	CacheControl string
+	// MaxAge is used for the Expires header (0 means the response is already expired)
+	MaxAge time.Duration
+	// VaryHeaders are added to the Vary header, keeping the values set by the handler or other middleware
+	VaryHeaders []string
+}
+
+// NoStore is a policy for responses that must never be cached, e.g. responses with personal data
+func NoStore() CachePolicy {
+	return CachePolicy{CacheControl: "no-store"}
+}
+
+// Public is a policy for responses that may be cached by browsers and shared caches (CDNs, proxies) for maxAge
+func Public(maxAge time.Duration) CachePolicy {
+	return CachePolicy{
+		CacheControl: "public, max-age=" + cacheSeconds(maxAge),
+		MaxAge:       maxAge,
+		VaryHeaders:  []string{"Accept-Encoding"},
+	}
+}
+
+// PrivateStaleWhileRevalidate is a policy for responses that may only be cached by the browser for maxAge.
+// After maxAge, the browser may use the stale response for staleWhileRevalidate while it refreshes it.
+func PrivateStaleWhileRevalidate(maxAge, staleWhileRevalidate time.Duration) CachePolicy {
+	return CachePolicy{
+		CacheControl: "private, max-age=" + cacheSeconds(maxAge) + ", stale-while-revalidate=" + cacheSeconds(staleWhileRevalidate),
+		MaxAge:       maxAge,
+		VaryHeaders:  []string{"Accept-Encoding"},
+	}
+}
+
+// Vary returns a copy of the policy that also varies by the given request headers
+func (p CachePolicy) Vary(headers ...string) CachePolicy {
+	p.VaryHeaders = append(append([]string(nil), p.VaryHeaders...), headers...)
+	return p
+}
+
+// WithCachePolicy sets the cache headers of a single route
+func WithCachePolicy(policy CachePolicy) RouteOption {
+	return func(rt *route) {
+		rt.use(CachePolicyMiddleware(policy))
+	}
+}
+
+// CachePolicyMiddleware sets the cache headers of all responses, e.g. of a route group.
+// The policy owns Cache-Control and Expires: a handler setting them logs a warning once per route and is overridden.
+// Server errors are never cached and get "Cache-Control: no-store".
+func CachePolicyMiddleware(policy CachePolicy) Middleware {
+	var warned sync.Map
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writer := &cachePolicyWriter{
+				ResponseWriter: w,
+				policy:         policy,
+				conflict: func(header, value string) {
+					pattern := RoutePattern(r)
+					if pattern == "" {
+						pattern = r.URL.Path
+					}
+
+					if _, loaded := warned.LoadOrStore(pattern, true); !loaded {
+						GetLogger(r).Warn("route sets conflicting cache headers, the cache policy takes precedence",
+							"route", pattern, "header", header, "value", value, "policy", policy.CacheControl)
+					}
+				},
+			}
+
+			next.ServeHTTP(writer, r)
+
+			// Handlers that write nothing get an implicit 200 after they return
+			if !writer.wroteHeader {
+				writer.wroteHeader = true
+				writer.apply(http.StatusOK)
+			}
+		})
+	}
+}
+
+// cachePolicyWriter is a response writer that sets the cache headers before the response is written
+type cachePolicyWriter struct {
+	http.ResponseWriter
+
+	policy      CachePolicy
+	conflict    func(header, value string)
+	wroteHeader bool
+}
+
+// WriteHeader sets the cache headers and writes the status code
+func (c *cachePolicyWriter) WriteHeader(code int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		c.apply(code)
+	}
+
+	c.ResponseWriter.WriteHeader(code)
+}
+
+// Write sets the cache headers, if the status code wasn't written yet, and writes the data
+func (c *cachePolicyWriter) Write(data []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	return c.ResponseWriter.Write(data) //nolint:wrapcheck
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (c *cachePolicyWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// apply sets the cache headers for a response with the given status code
+func (c *cachePolicyWriter) apply(code int) {
+	header := c.Header()
+
+	if code >= http.StatusInternalServerError {
+		header.Set("Cache-Control", "no-store")
+		header.Del("Expires")
+
+		return
+	}
+
+	if value := header.Get("Cache-Control"); value != "" && value != c.policy.CacheControl {
+		c.conflict("Cache-Control", value)
+	} else if value := header.Get("Expires"); value != "" {
+		c.conflict("Expires", value)
+	}
+
+	header.Set("Cache-Control", c.policy.CacheControl)
+
+	if c.policy.CacheControl == "no-store" {
+		header.Del("Expires")
+	} else {
+		header.Set("Expires", time.Now().Add(c.policy.MaxAge).UTC().Format(http.TimeFormat))
+	}
+
+	for _, name := range c.policy.VaryHeaders {
+		addVary(header, name)
+	}
+}
+
+// addVary adds a header name to the Vary header, unless it is already listed
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, name) {
+				return
+			}
+		}
+	}
+
+	header.Add("Vary", http.CanonicalHeaderKey(name))
+}
+
+// cacheSeconds formats a duration as whole seconds for cache directives
+func cacheSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(max(d, 0)/time.Second), 10)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachePolicies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		policy       CachePolicy
+		cacheControl string
+		expires      bool
+	}{
+		{"no store", NoStore(), "no-store", false},
+		{"public", Public(time.Hour), "public, max-age=3600", true},
+		{
+			"private stale while revalidate", PrivateStaleWhileRevalidate(time.Minute, 5*time.Minute),
+			"private, max-age=60, stale-while-revalidate=300", true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := CachePolicyMiddleware(tt.policy)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Vary", "Accept-Encoding")
+				_, _ = w.Write([]byte("ok"))
+			}))
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got := recorder.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("expected Cache-Control %q, got %q", tt.cacheControl, got)
+			}
+
+			if got := recorder.Header().Get("Expires") != ""; got != tt.expires {
+				t.Errorf("expected Expires set to be %v, got %v", tt.expires, got)
+			}
+
+			if got := recorder.Header().Values("Vary"); len(got) != 1 {
+				t.Errorf("expected Vary not to be duplicated, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCachePolicyServerError(t *testing.T) {
+	t.Parallel()
+
+	handler := CachePolicyMiddleware(Public(time.Hour))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := recorder.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected server errors not to be cached, got %q", got)
+	}
+}
+
+func TestCachePolicyConflict(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	config := DefaultConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&output, nil))
+
+	svc := New("cache_policy_test", config)
+	svc.HandleFunc("/profile", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=600")
+	}, WithCachePolicy(NoStore().Vary("Authorization")))
+
+	for range 2 {
+		recorder := httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/profile", nil))
+
+		if got := recorder.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("expected the policy to take precedence, got %q", got)
+		}
+
+		if got := recorder.Header().Get("Vary"); got != "Authorization" {
+			t.Errorf("expected Vary Authorization, got %q", got)
+		}
+	}
+
+	if count := strings.Count(output.String(), "conflicting cache headers"); count != 1 {
+		t.Errorf("expected one warning, got %d:\n%s", count, output.String())
+	}
+}
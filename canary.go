@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TrafficKey is the context key for the traffic track of a request (canary, shadow, or stable)
+const TrafficKey ContextKey = "traffic"
+
+// Traffic tracks recorded in the context and the canary metrics
+const (
+	TrafficStable = "stable"
+	TrafficCanary = "canary"
+	TrafficShadow = "shadow"
+)
+
+// defaultMaxShadowBody is the default maximum request body size duplicated to the shadow handler
+const defaultMaxShadowBody = 1 << 20
+
+// CanaryConfig holds configuration for the canary middleware
+type CanaryConfig struct {
+	// Header marks canary requests when its value is true, e.g. "X-Canary: true". Defaults to "X-Canary".
+	Header string
+	// ShadowHeader marks shadow (mirrored) requests, whose responses are discarded. Defaults to "X-Shadow".
+	// It is only honored from TrustedNetworks and removed from requests of other clients.
+	ShadowHeader string
+	// TrustedNetworks are the networks that may mark requests as shadow traffic, e.g. of the service mirroring
+	// requests (see MirrorMiddleware). Without them, only the shadow copies of canary requests are shadow traffic.
+	TrustedNetworks []netip.Prefix
+	// MetricsLabel records requests per track in {service_name}_traffic_requests_total{track,status}.
+	// The track label only takes the values "stable", "canary", and "shadow".
+	MetricsLabel bool
+	// Shadow receives a copy of every canary request in the background, e.g. the new implementation of a handler.
+	// Its response is discarded and never affects the primary response.
+	Shadow http.Handler
+	// MaxShadowBody is the maximum request body size duplicated to the shadow handler; larger requests
+	// are not duplicated. Defaults to 1MiB.
+	MaxShadowBody int64
+	// MaxShadowInFlight is the maximum number of concurrent shadow requests; further canary requests are not
+	// duplicated. Defaults to 100.
+	MaxShadowInFlight int
+}
+
+// CanaryMiddleware recognizes canary and shadow headers, stores the traffic track in the context (see IsCanary),
+// and optionally duplicates canary requests to a shadow handler
+func CanaryMiddleware(metrics *MetricsCollector, config CanaryConfig) Middleware {
+	if config.Header == "" {
+		config.Header = "X-Canary"
+	}
+
+	if config.ShadowHeader == "" {
+		config.ShadowHeader = "X-Shadow"
+	}
+
+	if config.MaxShadowBody <= 0 {
+		config.MaxShadowBody = defaultMaxShadowBody
+	}
+
+	if config.MaxShadowInFlight <= 0 {
+		config.MaxShadowInFlight = 100
+	}
+
+	// Shadow requests skip side effects, so clients must not be able to mark their own requests as shadow traffic
+	trusted := HeaderSanitizationConfig{TrustedProxies: config.TrustedNetworks}
+	inFlight := make(chan struct{}, config.MaxShadowInFlight)
+
+	var requests *prometheus.CounterVec
+	if config.MetricsLabel && metrics != nil {
+		requests = metrics.builtinCounterVec("traffic_requests_total",
+			"Total number of HTTP requests per traffic track", "track", "status")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			track := TrafficStable
+
+			if r.Header.Get(config.ShadowHeader) != "" && !trusted.trusted(r) {
+				r = r.Clone(r.Context())
+				r.Header.Del(config.ShadowHeader)
+			}
+
+			switch {
+			case headerTrue(r, config.ShadowHeader):
+				track = TrafficShadow
+			case headerTrue(r, config.Header):
+				track = TrafficCanary
+			}
+
+			if track != TrafficStable {
+				ctx := context.WithValue(r.Context(), TrafficKey, track)
+				ctx = context.WithValue(ctx, LoggerKey, GetLogger(r).With("traffic", track))
+				r = r.WithContext(ctx)
+			}
+
+			if track == TrafficCanary && config.Shadow != nil {
+				select {
+				case inFlight <- struct{}{}:
+					if shadow, ok := shadowRequest(r, config.ShadowHeader, config.MaxShadowBody); ok {
+						go func() {
+							defer func() { <-inFlight }()
+
+							serveShadow(config.Shadow, shadow)
+						}()
+					} else {
+						<-inFlight
+					}
+				default:
+					GetLogger(r).Debug("shadow request skipped, too many in flight")
+				}
+			}
+
+			if requests == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapped, r)
+
+			requests.WithLabelValues(track, strconv.Itoa(wrapped.statusCode)).Inc()
+		})
+	}
+}
+
+// IsCanary returns true if the request was marked as canary traffic
+func IsCanary(r *http.Request) bool {
+	return r.Context().Value(TrafficKey) == TrafficCanary
+}
+
+// IsShadow returns true if the request is shadow traffic, whose response is discarded.
+// Handlers should avoid side effects like sending emails for shadow requests.
+func IsShadow(r *http.Request) bool {
+	return r.Context().Value(TrafficKey) == TrafficShadow
+}
+
+// headerTrue returns true if the header value is a true boolean, e.g. "true" or "1"
+func headerTrue(r *http.Request, header string) bool {
+	value, err := strconv.ParseBool(r.Header.Get(header))
+	return err == nil && value
+}
+
+// shadowRequest copies a request for the shadow handler, restoring the body of the original request.
+// It returns false if the body exceeds the limit.
+func shadowRequest(r *http.Request, shadowHeader string, maxBody int64) (*http.Request, bool) {
+	body, ok := copyBody(r, maxBody)
+	if !ok {
+		return nil, false
+	}
+
+	ctx := context.WithValue(context.WithoutCancel(r.Context()), TrafficKey, TrafficShadow)
+	ctx = context.WithValue(ctx, LoggerKey, GetLogger(r).With("traffic", TrafficShadow))
+
+	shadow := r.Clone(ctx)
+	shadow.Body = io.NopCloser(bytes.NewReader(body))
+	shadow.ContentLength = int64(len(body))
+	shadow.Header.Set(shadowHeader, "true")
+
+	return shadow, true
+}
+
+// copyBody reads a copy of the request body up to the limit and restores the body of the request.
+// It returns false if the body exceeds the limit or could not be read.
+func copyBody(r *http.Request, maxBody int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	// Restore the original body, including any unread remainder
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+
+	if err != nil || int64(len(data)) > maxBody {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// serveShadow serves a shadow request and discards the response. Panics are logged and never affect the service.
+func serveShadow(handler http.Handler, r *http.Request) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			GetLogger(r).Error("panic recovered in shadow handler", "error", recovered)
+		}
+	}()
+
+	handler.ServeHTTP(&discardResponse{header: make(http.Header)}, r)
+}
+
+// discardResponse is a response writer that discards the response
+type discardResponse struct {
+	header http.Header
+}
+
+// Header returns the response headers
+func (d *discardResponse) Header() http.Header {
+	return d.header
+}
+
+// WriteHeader discards the status code
+func (d *discardResponse) WriteHeader(int) {}
+
+// Write discards the response body
+func (d *discardResponse) Write(data []byte) (int, error) {
+	return len(data), nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCanaryMiddleware(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("canary_test")
+	shadowed := make(chan string, 1)
+
+	middleware := CanaryMiddleware(metrics, CanaryConfig{
+		MetricsLabel:    true,
+		TrustedNetworks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		Shadow: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if IsShadow(r) {
+				shadowed <- string(body)
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+		}),
+	})
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		switch {
+		case IsCanary(r):
+			_, _ = w.Write([]byte("canary:" + string(body)))
+		case IsShadow(r):
+			_, _ = w.Write([]byte("shadow"))
+		default:
+			_, _ = w.Write([]byte("stable"))
+		}
+	}))
+
+	tests := []struct {
+		name       string
+		header     string
+		remoteAddr string
+		want       string
+	}{
+		{name: "stable", want: "stable"},
+		{name: "canary", header: "X-Canary", want: "canary:payload"},
+		{name: "shadow", header: "X-Shadow", remoteAddr: "10.0.0.7:1234", want: "shadow"},
+		{name: "untrusted shadow", header: "X-Shadow", want: "stable"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+		if tt.remoteAddr != "" {
+			req.RemoteAddr = tt.remoteAddr
+		}
+
+		if tt.header != "" {
+			req.Header.Set(tt.header, "true")
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+			t.Errorf("%s: expected 200 %q, got %d %q", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	select {
+	case body := <-shadowed:
+		if body != "payload" {
+			t.Errorf("expected shadow handler to receive the request body, got %q", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected canary request to be duplicated to the shadow handler")
+	}
+
+	requests := metrics.builtinCounterVec("traffic_requests_total", "", "track", "status")
+	for track, want := range map[string]float64{TrafficStable: 2, TrafficCanary: 1, TrafficShadow: 1} {
+		if got := testutil.ToFloat64(requests.WithLabelValues(track, "200")); got != want {
+			t.Errorf("expected %v %s requests, got %v", want, track, got)
+		}
+	}
+}
+
+func TestCanaryMiddlewareShadowBodyLimit(t *testing.T) {
+	t.Parallel()
+
+	shadowed := make(chan struct{}, 1)
+
+	middleware := CanaryMiddleware(nil, CanaryConfig{
+		MaxShadowBody: 4,
+		Shadow: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			shadowed <- struct{}{}
+		}),
+	})
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too large"))
+	req.Header.Set("X-Canary", "1")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "too large" {
+		t.Errorf("expected the primary handler to receive the full body, got %q", rec.Body.String())
+	}
+
+	select {
+	case <-shadowed:
+		t.Error("expected requests exceeding the body limit not to be duplicated")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
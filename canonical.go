@@ -0,0 +1,185 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// TrailingSlashPolicy controls trailing slashes of canonical URLs
+type TrailingSlashPolicy string
+
+// Trailing slash policies
+const (
+	// TrailingSlashKeep leaves trailing slashes unchanged
+	TrailingSlashKeep TrailingSlashPolicy = ""
+	// TrailingSlashStrip redirects "/users/" to "/users"
+	TrailingSlashStrip TrailingSlashPolicy = "strip"
+	// TrailingSlashAdd redirects "/users" to "/users/". Paths with a file extension ("/logo.png") are not changed.
+	TrailingSlashAdd TrailingSlashPolicy = "add"
+)
+
+// WWWPolicy controls the "www." prefix of canonical hosts
+type WWWPolicy string
+
+// WWW policies
+const (
+	// WWWKeep leaves the host unchanged
+	WWWKeep WWWPolicy = ""
+	// WWWAdd redirects "example.com" to "www.example.com"
+	WWWAdd WWWPolicy = "add"
+	// WWWStrip redirects "www.example.com" to "example.com"
+	WWWStrip WWWPolicy = "strip"
+)
+
+// CanonicalConfig holds configuration for URL canonicalization
+type CanonicalConfig struct {
+	// TrailingSlash is the trailing slash policy of paths
+	TrailingSlash TrailingSlashPolicy
+	// CollapseSlashes redirects "/users//1" to "/users/1"
+	CollapseSlashes bool
+	// LowercaseHost redirects "Example.com" to "example.com"
+	LowercaseHost bool
+	// HTTPS redirects plain HTTP requests to HTTPS on the default port
+	HTTPS bool
+	// TrustForwardedProto detects HTTPS requests by the X-Forwarded-Proto header of a TLS terminating proxy
+	TrustForwardedProto bool
+	// WWW is the policy of the "www." prefix. IP addresses and hosts without a dot are never changed.
+	WWW WWWPolicy
+}
+
+// CanonicalMiddleware redirects requests to their canonical URL, so URL conventions are handled consistently
+// instead of per handler. All changes are made in a single permanent redirect (301 for GET and HEAD, 308 otherwise,
+// so the method and body are kept). Redirects are counted in {service_name}_canonical_redirects_total{reason}.
+// Register it with Service.UseBeforeRouting, so paths are canonicalized before they are matched against routes.
+func CanonicalMiddleware(metrics *MetricsCollector, config CanonicalConfig) Middleware {
+	redirects := metrics.builtinCounterVec("canonical_redirects_total",
+		"Total number of redirects to canonical URLs by the first non-canonical part", "reason")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target, reason := canonicalURL(r, config)
+			if reason == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			redirects.WithLabelValues(reason).Inc()
+
+			status := http.StatusPermanentRedirect
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				status = http.StatusMovedPermanently
+			}
+
+			http.Redirect(w, r, target, status)
+		})
+	}
+}
+
+// canonicalURL returns the canonical URL of a request and the first non-canonical part ("scheme", "host", or "path").
+// The reason is empty if the request URL is canonical.
+func canonicalURL(r *http.Request, config CanonicalConfig) (string, string) {
+	var reasons []string
+
+	https := r.TLS != nil || (config.TrustForwardedProto && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"))
+
+	scheme := "http"
+	if https {
+		scheme = "https"
+	}
+
+	host := r.Host
+
+	if config.HTTPS && !https {
+		scheme = "https"
+
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+
+		reasons = append(reasons, "scheme")
+	}
+
+	if canonical := canonicalHost(host, config); canonical != host {
+		host = canonical
+
+		reasons = append(reasons, "host")
+	}
+
+	urlPath := canonicalPath(r.URL.Path, config)
+	if urlPath != r.URL.Path {
+		reasons = append(reasons, "path")
+	}
+
+	if len(reasons) == 0 {
+		return "", ""
+	}
+
+	target := &url.URL{Path: urlPath, RawQuery: r.URL.RawQuery}
+
+	// Only a change of the scheme or host needs an absolute URL
+	if reasons[0] != "path" {
+		target.Scheme = scheme
+		target.Host = host
+	}
+
+	return target.String(), reasons[0]
+}
+
+// canonicalHost applies the host policies
+func canonicalHost(host string, config CanonicalConfig) string {
+	if config.LowercaseHost {
+		host = strings.ToLower(host)
+	}
+
+	hostname := host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		hostname = name
+	}
+
+	if net.ParseIP(hostname) != nil || !strings.Contains(hostname, ".") {
+		return host
+	}
+
+	switch config.WWW {
+	case WWWAdd:
+		if !strings.HasPrefix(strings.ToLower(host), "www.") {
+			return "www." + host
+		}
+	case WWWStrip:
+		if strings.HasPrefix(strings.ToLower(host), "www.") {
+			return host[len("www."):]
+		}
+	}
+
+	return host
+}
+
+// canonicalPath applies the path policies
+func canonicalPath(urlPath string, config CanonicalConfig) string {
+	if config.CollapseSlashes {
+		for strings.Contains(urlPath, "//") {
+			urlPath = strings.ReplaceAll(urlPath, "//", "/")
+		}
+	}
+
+	if urlPath == "/" || urlPath == "" {
+		return urlPath
+	}
+
+	switch config.TrailingSlash {
+	case TrailingSlashStrip:
+		urlPath = strings.TrimRight(urlPath, "/")
+		if urlPath == "" {
+			urlPath = "/"
+		}
+	case TrailingSlashAdd:
+		if !strings.HasSuffix(urlPath, "/") && path.Ext(urlPath) == "" {
+			urlPath += "/"
+		}
+	}
+
+	return urlPath
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalMiddleware(t *testing.T) {
+	t.Parallel()
+
+	svc := New("canonical_test", nil)
+	svc.UseBeforeRouting(CanonicalMiddleware(svc.Metrics, CanonicalConfig{
+		TrailingSlash:       TrailingSlashStrip,
+		CollapseSlashes:     true,
+		LowercaseHost:       true,
+		HTTPS:               true,
+		TrustForwardedProto: true,
+		WWW:                 WWWAdd,
+	}))
+	svc.HandleFunc("/users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		proto    string
+		status   int
+		location string
+	}{
+		{"canonical", http.MethodGet, "https://www.example.com/users/1", "https", http.StatusNoContent, ""},
+		{"http to https", http.MethodGet, "http://www.example.com:8080/users/1", "", http.StatusMovedPermanently, "https://www.example.com/users/1"},
+		{"apex to www", http.MethodGet, "https://example.com/users/1?a=b", "https", http.StatusMovedPermanently, "https://www.example.com/users/1?a=b"},
+		{"lowercase host", http.MethodGet, "https://WWW.Example.com/users/1", "https", http.StatusMovedPermanently, "https://www.example.com/users/1"},
+		{"trailing slash", http.MethodGet, "https://www.example.com/users/1/", "https", http.StatusMovedPermanently, "/users/1"},
+		{"duplicate slashes", http.MethodGet, "https://www.example.com//users//1", "https", http.StatusMovedPermanently, "/users/1"},
+		{"method is kept", http.MethodPost, "https://www.example.com/users/1/", "https", http.StatusPermanentRedirect, "/users/1"},
+		{"ip host", http.MethodGet, "https://10.0.0.1/users/1", "https", http.StatusNoContent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+
+			recorder := httptest.NewRecorder()
+			svc.handler().ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, recorder.Code)
+			}
+
+			if got := recorder.Header().Get("Location"); got != tt.location {
+				t.Errorf("expected location %q, got %q", tt.location, got)
+			}
+		})
+	}
+}
+
+func TestCanonicalPathTrailingSlashAdd(t *testing.T) {
+	t.Parallel()
+
+	config := CanonicalConfig{TrailingSlash: TrailingSlashAdd}
+
+	for path, want := range map[string]string{
+		"/":         "/",
+		"/docs":     "/docs/",
+		"/docs/":    "/docs/",
+		"/logo.png": "/logo.png",
+	} {
+		if got := canonicalPath(path, config); got != want {
+			t.Errorf("expected %q for %q, got %q", want, path, got)
+		}
+	}
+}
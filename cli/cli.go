@@ -0,0 +1,278 @@
+/*
+Package cli wires the common subcommands of a service binary around a service constructor,
+so every service doesn't rebuild the same main.go command plumbing:
+
+	func main() {
+		cli.Main(func() (*service.Service, error) {
+			config, err := service.LoadFromEnv()
+			if err != nil {
+				return nil, err
+			}
+
+			svc := service.New("my-service", config)
+			svc.HandleFunc("GET /hello", hello)
+
+			return svc, nil
+		})
+	}
+
+The binary then supports "serve" (the default), "version", "config print", "healthcheck [url]", "routes",
+and "smoke-test".
+*/
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"atomicgo.dev/service"
+)
+
+// Exit codes of the commands
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+// healthcheckTimeout is the timeout of the healthcheck command
+const healthcheckTimeout = 5 * time.Second
+
+// usage is printed for unknown commands
+const usage = `Usage: %s [command]
+
+Commands:
+  serve               Start the service (default)
+  version             Print the service name and version
+  config print        Print the effective configuration with secrets redacted
+  healthcheck [url]   Check the readiness endpoint, e.g. for a container HEALTHCHECK
+  routes              Print the registered routes
+  smoke-test          Boot the service on ephemeral ports and check readiness and SMOKE_TEST_ROUTES
+`
+
+// App is a service binary with the common subcommands
+type App struct {
+	// NewService creates the service with its routes. It is called once per command.
+	NewService func() (*service.Service, error)
+	// Stdout receives the output of the commands. Defaults to os.Stdout.
+	Stdout io.Writer
+	// Stderr receives errors and the usage. Defaults to os.Stderr.
+	Stderr io.Writer
+}
+
+// Main runs the command of the command line arguments and exits with its exit code
+func Main(newService func() (*service.Service, error)) {
+	app := &App{NewService: newService}
+	os.Exit(app.Run(os.Args[1:]))
+}
+
+// Run runs the command of the arguments (without the program name) and returns the exit code
+func (a *App) Run(args []string) int {
+	if a.Stdout == nil {
+		a.Stdout = os.Stdout
+	}
+
+	if a.Stderr == nil {
+		a.Stderr = os.Stderr
+	}
+
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch {
+	case command == "serve" && len(args) == 0:
+		return a.withService(func(svc *service.Service) error { return svc.Start() })
+	case command == "version" && len(args) == 0:
+		return a.withService(a.version)
+	case command == "config" && len(args) == 1 && args[0] == "print":
+		return a.withService(a.printConfig)
+	case command == "healthcheck" && len(args) <= 1:
+		return a.healthcheck(args)
+	case command == "routes" && len(args) == 0:
+		return a.withService(a.routes)
+	case command == "smoke-test" && len(args) == 0:
+		return a.withService(a.smokeTest)
+	default:
+		fmt.Fprintf(a.Stderr, usage, programName())
+		return ExitUsage
+	}
+}
+
+// withService creates the service and runs a command with it
+func (a *App) withService(command func(svc *service.Service) error) int {
+	svc, err := a.NewService()
+	if err != nil {
+		fmt.Fprintf(a.Stderr, "failed to create service: %v\n", err)
+		return ExitError
+	}
+
+	if err := command(svc); err != nil {
+		fmt.Fprintf(a.Stderr, "%v\n", err)
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+// version prints the service name and version
+func (a *App) version(svc *service.Service) error {
+	_, err := fmt.Fprintf(a.Stdout, "%s %s (%s)\n", svc.Name, svc.Config.Version, runtime.Version())
+
+	return err //nolint:wrapcheck
+}
+
+// routes prints the registered routes
+func (a *App) routes(svc *service.Service) error {
+	for _, pattern := range svc.Routes() {
+		fmt.Fprintln(a.Stdout, pattern)
+	}
+
+	for _, pattern := range svc.InternalRoutes() {
+		fmt.Fprintf(a.Stdout, "%s (internal)\n", pattern)
+	}
+
+	return nil
+}
+
+// smokeTest runs the smoke test of the service
+func (a *App) smokeTest(svc *service.Service) error {
+	ctx, cancel := context.WithTimeout(context.Background(), svc.Config.SmokeTestTimeout)
+	defer cancel()
+
+	return svc.RunSmokeTest(ctx)
+}
+
+// healthcheck checks the readiness endpoint. Without a URL, the service is created to find the local endpoint.
+func (a *App) healthcheck(args []string) int {
+	var url string
+
+	if len(args) == 1 {
+		url = args[0]
+	} else {
+		svc, err := a.NewService()
+		if err != nil {
+			fmt.Fprintf(a.Stderr, "failed to create service: %v\n", err)
+			return ExitError
+		}
+
+		if strings.HasPrefix(svc.Config.MetricsAddr, "unix://") {
+			fmt.Fprintln(a.Stderr, "the metrics server listens on a Unix domain socket, pass the URL of the readiness endpoint")
+			return ExitUsage
+		}
+
+		scheme := "http://"
+		if svc.Config.MetricsTLSCertFile != "" {
+			scheme = "https://"
+		}
+
+		url = scheme + localAddr(svc.Config.MetricsAddr) + svc.Config.ReadinessPath
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthcheckTimeout)
+	defer cancel()
+
+	if err := service.CheckEndpoint(ctx, url); err != nil {
+		fmt.Fprintf(a.Stderr, "healthcheck failed: %v\n", err)
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+// printConfig prints the configuration as environment variables. Secrets are redacted.
+func (a *App) printConfig(svc *service.Service) error {
+	for _, line := range configLines(reflect.ValueOf(svc.Config).Elem(), "") {
+		fmt.Fprintln(a.Stdout, line)
+	}
+
+	return nil
+}
+
+// secretSuffixes identify environment variables holding secrets. Webhook URLs often contain a token.
+var secretSuffixes = []string{"_SECRET", "_TOKEN", "_PASSWORD", "_BASIC_AUTH", "_KEY", "_WEBHOOK_URL"}
+
+// configLines returns the fields of a config struct with env tags as NAME=value lines
+func configLines(value reflect.Value, prefix string) []string {
+	var lines []string
+
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+
+		if nested, ok := field.Tag.Lookup("envPrefix"); ok && field.Type.Kind() == reflect.Struct {
+			lines = append(lines, configLines(value.Field(i), prefix+nested)...)
+			continue
+		}
+
+		name, ok := field.Tag.Lookup("env")
+		if !ok || name == "-" || !field.IsExported() {
+			continue
+		}
+
+		name = prefix + name
+
+		formatted := formatConfigValue(value.Field(i))
+		if formatted != "" && isSecret(name) {
+			formatted = "[REDACTED]"
+		}
+
+		lines = append(lines, name+"="+formatted)
+	}
+
+	return lines
+}
+
+// formatConfigValue formats a config value like its environment variable
+func formatConfigValue(value reflect.Value) string {
+	if value.Kind() == reflect.Slice {
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(value.Index(i).Interface())
+		}
+
+		return strings.Join(items, ",")
+	}
+
+	return fmt.Sprint(value.Interface())
+}
+
+// isSecret reports whether an environment variable holds a secret
+func isSecret(name string) bool {
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// localAddr returns a listen address that can be requested locally
+func localAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+
+	return addr
+}
+
+// programName returns the name of the binary
+func programName() string {
+	if len(os.Args) == 0 {
+		return "service"
+	}
+
+	name := os.Args[0]
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"atomicgo.dev/service"
+)
+
+func newTestApp(t *testing.T) (*App, *bytes.Buffer) {
+	t.Helper()
+
+	var stdout bytes.Buffer
+
+	return &App{
+		NewService: func() (*service.Service, error) {
+			config := service.DefaultConfig()
+			config.Version = "v1.2.3"
+			config.OAuthClientSecret = "hunter2"
+			config.HealthAuth.Token = "s3cr3t"
+
+			svc := service.New("test-service", config)
+			svc.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {})
+			svc.Internal().HandleFunc("GET /debug/state", func(w http.ResponseWriter, _ *http.Request) {})
+
+			return svc, nil
+		},
+		Stdout: &stdout,
+		Stderr: &bytes.Buffer{},
+	}, &stdout
+}
+
+func TestApp_Version(t *testing.T) {
+	t.Parallel()
+
+	app, stdout := newTestApp(t)
+
+	if code := app.Run([]string{"version"}); code != ExitOK {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	if !strings.HasPrefix(stdout.String(), "test-service v1.2.3") {
+		t.Errorf("expected name and version, got %q", stdout.String())
+	}
+}
+
+func TestApp_Routes(t *testing.T) {
+	t.Parallel()
+
+	app, stdout := newTestApp(t)
+
+	if code := app.Run([]string{"routes"}); code != ExitOK {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	if expected := "GET /users/{id}\nGET /debug/state (internal)\n"; stdout.String() != expected {
+		t.Errorf("expected routes %q, got %q", expected, stdout.String())
+	}
+}
+
+func TestApp_ConfigPrint(t *testing.T) {
+	t.Parallel()
+
+	app, stdout := newTestApp(t)
+
+	if code := app.Run([]string{"config", "print"}); code != ExitOK {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	output := stdout.String()
+
+	for _, expected := range []string{"ADDR=:8080\n", "OAUTH_CLIENT_SECRET=[REDACTED]\n", "HEALTH_AUTH_TOKEN=[REDACTED]\n", "OAUTH_TOKEN_URL=\n"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in the config, got:\n%s", expected, output)
+		}
+	}
+
+	if strings.Contains(output, "hunter2") || strings.Contains(output, "s3cr3t") {
+		t.Error("expected secrets to be redacted")
+	}
+}
+
+func TestApp_Healthcheck(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	app, _ := newTestApp(t)
+
+	if code := app.Run([]string{"healthcheck", server.URL + "/ready"}); code != ExitOK {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+
+	if code := app.Run([]string{"healthcheck", server.URL + "/down"}); code != ExitError {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+}
+
+func TestApp_Usage(t *testing.T) {
+	t.Parallel()
+
+	app, _ := newTestApp(t)
+
+	if code := app.Run([]string{"unknown"}); code != ExitUsage {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+
+	if code := app.Run([]string{"config"}); code != ExitUsage {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ClientConfig holds configuration for an instrumented HTTP client
+type ClientConfig struct {
+	// Name identifies the client in metrics (e.g. the name of the downstream service)
+	Name string
+	// Timeout is the overall timeout of a request, including reading the response body
+	Timeout time.Duration
+	// Transport is the underlying transport. Defaults to a clone of http.DefaultTransport.
+	Transport http.RoundTripper
+	// Egress restricts the destinations the client may connect to
+	Egress *EgressPolicy
+	// SigningKey signs outbound requests with HMAC-SHA256 (see SignRequest)
+	SigningKey *SigningKey
+	// TokenSource authorizes outbound requests with an OAuth2 access token
+	TokenSource *TokenSource
+	// Hedge sends a second attempt of slow idempotent requests to cut tail latency
+	Hedge *HedgePolicy
+	// PropagateTo restricts the propagated headers and request ID (see PropagationMiddleware) to requests to these
+	// hosts. Without it, clients propagate them to every host, except clients with an egress policy, which usually
+	// call user-provided URLs and don't propagate them at all.
+	PropagateTo []string
+}
+
+// clientTransport is an http.RoundTripper that records metrics for outbound requests
+type clientTransport struct {
+	next      http.RoundTripper
+	name      string
+	guard     *egressGuard
+	propagate func(host string) bool
+
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+}
+
+// NewClient creates an HTTP client for outbound requests.
+// It records request metrics in the given collector (if not nil) and enforces the egress policy.
+func NewClient(metrics *MetricsCollector, config ClientConfig) *http.Client {
+	if config.Name == "" {
+		config.Name = "default"
+	}
+
+	next := config.Transport
+	if next == nil {
+		defaultTransport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+
+		// The egress guard dials with the same settings, but validates addresses before connecting
+		if config.Egress != nil {
+			defaultTransport.DialContext = nil
+		}
+
+		next = defaultTransport
+	}
+
+	transport := &clientTransport{
+		next:      next,
+		name:      config.Name,
+		propagate: propagationFilter(config.PropagateTo, config.Egress != nil),
+	}
+
+	if metrics != nil {
+		transport.requestsTotal = metrics.builtinCounterVec("client_requests_total",
+			"Total number of outbound HTTP requests", "client", "method", "status_code")
+		transport.requestDuration = metrics.builtinHistogramVec("client_request_duration_seconds",
+			"Outbound HTTP request duration in seconds", prometheus.DefBuckets, "client", "method")
+	}
+
+	if config.Egress != nil {
+		transport.guard = newEgressGuard(*config.Egress, config.Name, metrics)
+		transport.next = transport.guard.wrap(next)
+	}
+
+	// Each hedge attempt is signed separately, so it carries its own nonce
+	if config.SigningKey != nil {
+		transport.next = &signingTransport{next: transport.next, key: *config.SigningKey}
+	}
+
+	if config.Hedge != nil {
+		transport.next = newHedgeTransport(transport.next, *config.Hedge, config.Name, metrics)
+	}
+
+	if config.TokenSource != nil {
+		transport.next = &tokenTransport{next: transport.next, source: config.TokenSource}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+	}
+}
+
+// NewClient creates an instrumented HTTP client that records metrics in the service's metrics collector
+func (s *Service) NewClient(config ClientConfig) *http.Client {
+	return NewClient(s.Metrics, config)
+}
+
+// RoundTrip executes a single outbound request and records its metrics
+func (t *clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.guard != nil {
+		if err := t.guard.checkHost(req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.propagate(req.URL.Hostname()) {
+		req = propagateHeaders(req)
+	}
+
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+
+	if t.requestsTotal != nil {
+		statusCode := "error"
+		if err == nil {
+			statusCode = strconv.Itoa(resp.StatusCode)
+		}
+
+		t.requestsTotal.WithLabelValues(t.name, req.Method, statusCode).Inc()
+		t.requestDuration.WithLabelValues(t.name, req.Method).Observe(time.Since(start).Seconds())
+	}
+
+	return resp, err //nolint:wrapcheck
+}
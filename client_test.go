@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestNewClient_Metrics(t *testing.T) {
+	t.Parallel()
+
+	upstream := newTestUpstream(t)
+	svc := New("client_test", nil)
+
+	client := svc.NewClient(ClientConfig{Name: "upstream"})
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	requests := svc.Metrics.builtinCounterVec("client_requests_total", "", "client", "method", "status_code")
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("upstream", http.MethodGet, "418")); got != 1 {
+		t.Errorf("expected 1 recorded request, got %v", got)
+	}
+}
+
+func TestNewClient_Egress(t *testing.T) {
+	t.Parallel()
+
+	upstream := newTestUpstream(t)
+
+	tests := []struct {
+		name    string
+		policy  EgressPolicy
+		url     string
+		blocked bool
+	}{
+		{"private address denied by default", EgressPolicy{}, upstream.URL, true},
+		{"private address allowed", EgressPolicy{AllowPrivate: true}, upstream.URL, false},
+		{"allowed CIDR", EgressPolicy{AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}, upstream.URL, false},
+		{"other CIDR", EgressPolicy{AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}, upstream.URL, true},
+		{"host not allowed", EgressPolicy{AllowPrivate: true, AllowedHosts: []string{"example.com"}}, upstream.URL, true},
+		{"host allowed", EgressPolicy{AllowPrivate: true, AllowedHosts: []string{"127.0.0.1"}}, upstream.URL, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := NewClient(NewMetricsCollector("egress_test"), ClientConfig{Egress: &tt.policy})
+
+			resp, err := client.Get(tt.url)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if tt.blocked {
+				if !errors.Is(err, ErrEgressBlocked) {
+					t.Fatalf("expected request to be blocked, got %v", err)
+				}
+
+				if CodeOf(err) != CodePermissionDenied {
+					t.Errorf("expected code permission_denied, got %s", CodeOf(err))
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected request to pass, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewClient_EgressCustomDialer(t *testing.T) {
+	t.Parallel()
+
+	upstream := newTestUpstream(t)
+
+	for _, allowPrivate := range []bool{false, true} {
+		var dials atomic.Int32
+
+		transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		}
+
+		client := NewClient(nil, ClientConfig{Transport: transport, Egress: &EgressPolicy{AllowPrivate: allowPrivate}})
+
+		resp, err := client.Get(upstream.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if dials.Load() == 0 {
+			t.Errorf("expected the dialer of the transport to be kept (private allowed: %v)", allowPrivate)
+		}
+
+		if blocked := errors.Is(err, ErrEgressBlocked); blocked == allowPrivate {
+			t.Errorf("expected the connection to be validated (private allowed: %v), got %v", allowPrivate, err)
+		}
+	}
+}
+
+func TestEgressGuard_CheckHost(t *testing.T) {
+	t.Parallel()
+
+	guard := newEgressGuard(EgressPolicy{AllowedHosts: []string{"api.example.com", "*.internal.example.com"}}, "test", nil)
+
+	for host, allowed := range map[string]bool{
+		"api.example.com":          true,
+		"API.example.com.":         true,
+		"a.internal.example.com":   true,
+		"internal.example.com":     false,
+		"example.com":              false,
+		"api.example.com.evil.com": false,
+	} {
+		if err := guard.checkHost(host); (err == nil) != allowed {
+			t.Errorf("checkHost(%q): expected allowed=%v, got %v", host, allowed, err)
+		}
+	}
+}
+
+func TestIsPrivateAddr(t *testing.T) {
+	t.Parallel()
+
+	for addr, private := range map[string]bool{
+		"10.1.2.3":            true,
+		"192.168.0.1":         true,
+		"127.0.0.1":           true,
+		"169.254.169.254":     true,
+		"100.64.0.1":          true,
+		"::1":                 true,
+		"fd00::1":             true,
+		"::ffff:10.0.0.1":     true,
+		"8.8.8.8":             false,
+		"2606:4700::6810:1":   false,
+		"::ffff:93.184.216.3": false,
+	} {
+		if got := isPrivateAddr(netip.MustParseAddr(addr).Unmap()); got != private {
+			t.Errorf("isPrivateAddr(%s): expected %v, got %v", addr, private, got)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrComponentDependency is returned when the component dependencies are unknown or cyclic
+var ErrComponentDependency = NewError(CodeFailedPrecondition, "invalid component dependencies")
+
+// Component is a managed resource (database, consumer, cache) that is stopped during graceful shutdown
+type Component struct {
+	Name string
+	// DependsOn lists the components this component uses. They are stopped after this component.
+	DependsOn []string
+	// Stop releases the resource. The context expires with the shutdown timeout.
+	Stop func(ctx context.Context) error
+	// Stats reports the connection pool of the resource, if it has one (see SQLPoolStats)
+	Stats func() PoolStats
+}
+
+// AddComponent registers a managed component. During graceful shutdown, after the servers stopped,
+// components are stopped in dependency order: a consumer depending on a database is stopped before the database.
+func (s *Service) AddComponent(component Component) {
+	s.components = append(s.components, component)
+}
+
+// componentShutdownOrder returns the components ordered so that each component is stopped before its dependencies
+func componentShutdownOrder(components []Component) ([]Component, error) {
+	byName := make(map[string]Component, len(components))
+	for _, component := range components {
+		byName[component.Name] = component
+	}
+
+	// Count the components depending on each component; they have to stop first
+	dependents := make(map[string]int, len(components))
+
+	for _, component := range components {
+		for _, dependency := range component.DependsOn {
+			if _, ok := byName[dependency]; !ok {
+				return nil, fmt.Errorf("%w: %s depends on unknown component %s", ErrComponentDependency, component.Name, dependency)
+			}
+
+			dependents[dependency]++
+		}
+	}
+
+	order := make([]Component, 0, len(components))
+
+	// Start with components nothing depends on, in reverse registration order
+	var ready []string
+
+	for _, component := range slices.Backward(components) {
+		if dependents[component.Name] == 0 {
+			ready = append(ready, component.Name)
+		}
+	}
+
+	for len(ready) > 0 {
+		component := byName[ready[0]]
+		ready = ready[1:]
+		order = append(order, component)
+
+		for _, dependency := range component.DependsOn {
+			dependents[dependency]--
+			if dependents[dependency] == 0 {
+				ready = append(ready, dependency)
+			}
+		}
+	}
+
+	if len(order) != len(components) {
+		return nil, fmt.Errorf("%w: dependency cycle", ErrComponentDependency)
+	}
+
+	return order, nil
+}
+
+// stopComponents stops all components in dependency order and returns the components that failed to stop
+func (s *Service) stopComponents(ctx context.Context) ([]string, []error) {
+	if len(s.components) == 0 {
+		return nil, nil
+	}
+
+	logger := LoggerFromContext(ctx)
+
+	order, err := componentShutdownOrder(s.components)
+	if err != nil {
+		// Still release all resources, in reverse registration order
+		logger.Error("failed to order components, stopping them in reverse registration order", "error", err)
+		order = slices.Clone(s.components)
+		slices.Reverse(order)
+	}
+
+	duration := s.Metrics.builtinGaugeVec("shutdown_component_duration_seconds",
+		"Duration of stopping each component during shutdown in seconds", "component")
+
+	var (
+		failed []string
+		errs   []error
+	)
+
+	for _, component := range order {
+		logger.Info("stopping component", "component", component.Name)
+
+		start := time.Now()
+
+		if err := component.Stop(ctx); err != nil {
+			logger.Error("failed to stop component", "component", component.Name, "error", err)
+			failed = append(failed, component.Name)
+			errs = append(errs, fmt.Errorf("component %s: %w", component.Name, err))
+		}
+
+		duration.WithLabelValues(component.Name).Set(time.Since(start).Seconds())
+	}
+
+	return failed, errs
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestComponentShutdownOrder(t *testing.T) {
+	t.Parallel()
+
+	var stopped []string
+
+	svc := New("component_test", nil)
+
+	stop := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			stopped = append(stopped, name)
+			return nil
+		}
+	}
+
+	// Registered in "wrong" order on purpose
+	svc.AddComponent(Component{Name: "db", Stop: stop("db")})
+	svc.AddComponent(Component{Name: "cache", DependsOn: []string{"db"}, Stop: stop("cache")})
+	svc.AddComponent(Component{Name: "consumer", DependsOn: []string{"db", "cache"}, Stop: stop("consumer")})
+
+	if err := svc.gracefulShutdown(); err != nil {
+		t.Fatalf("graceful shutdown failed: %v", err)
+	}
+
+	if want := []string{"consumer", "cache", "db"}; !slices.Equal(stopped, want) {
+		t.Errorf("expected components to stop in order %v, got %v", want, stopped)
+	}
+}
+
+func TestComponentShutdownOrderErrors(t *testing.T) {
+	t.Parallel()
+
+	noop := func(context.Context) error { return nil }
+
+	tests := []struct {
+		name       string
+		components []Component
+	}{
+		{
+			name:       "unknown dependency",
+			components: []Component{{Name: "consumer", DependsOn: []string{"db"}, Stop: noop}},
+		},
+		{
+			name: "cycle",
+			components: []Component{
+				{Name: "a", DependsOn: []string{"b"}, Stop: noop},
+				{Name: "b", DependsOn: []string{"a"}, Stop: noop},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := componentShutdownOrder(tt.components)
+			if !errors.Is(err, ErrComponentDependency) {
+				t.Errorf("expected ErrComponentDependency, got %v", err)
+			}
+		})
+	}
+}
+
+func TestComponentStopErrors(t *testing.T) {
+	t.Parallel()
+
+	svc := New("component_error_test", nil)
+
+	var dbStopped bool
+
+	svc.AddComponent(Component{Name: "db", Stop: func(context.Context) error {
+		dbStopped = true
+		return nil
+	}})
+	svc.AddComponent(Component{Name: "consumer", DependsOn: []string{"db"}, Stop: func(context.Context) error {
+		return errors.New("consumer stuck") //nolint:err113
+	}})
+
+	if err := svc.gracefulShutdown(); err == nil {
+		t.Error("expected shutdown to report the component error")
+	}
+
+	if !dbStopped {
+		t.Error("expected db to be stopped even though the consumer failed")
+	}
+}
@@ -1,8 +1,8 @@
 package service
 
 import (
-	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"time"
 
@@ -11,65 +11,324 @@ import (
 
 // Config holds all configuration for the service
 type Config struct {
+	// Profile of defaults for the environment ("dev", "staging", or "prod"), applied by LoadFromEnv.
+	// Each setting of the profile can be overridden by its environment variable.
+	Profile Profile `env:"PROFILE"`
+
 	// HTTP Server configuration
-	Addr         string        `env:"ADDR"          envDefault:":8080"`
-	ReadTimeout  time.Duration `env:"READ_TIMEOUT"  envDefault:"10s"`
-	WriteTimeout time.Duration `env:"WRITE_TIMEOUT" envDefault:"10s"`
-	IdleTimeout  time.Duration `env:"IDLE_TIMEOUT"  envDefault:"120s"`
+	Addr              string        `env:"ADDR"                envDefault:":8080"`
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT"        envDefault:"10s"`
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" envDefault:"5s"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT"       envDefault:"10s"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT"        envDefault:"120s"`
+
+	// Deadline of each request, which cancels the request context and answers with 504 (0 disables it; routes and
+	// groups can override it, see WithTimeout)
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" envDefault:"0s"`
+
+	// TLS configuration (TLS is enabled with a certificate file)
+	TLSCertFile              string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile               string        `env:"TLS_KEY_FILE"`
+	TLSSessionTicketRotation time.Duration `env:"TLS_SESSION_TICKET_ROTATION" envDefault:"1h"`
+	TLSSessionTicketKeyFile  string        `env:"TLS_SESSION_TICKET_KEY_FILE"`
+	TLSOCSPStapling          bool          `env:"TLS_OCSP_STAPLING"           envDefault:"false"`
+	TLSStrictSNI             bool          `env:"TLS_STRICT_SNI"              envDefault:"false"`
+
+	// Response size guard: responses beyond the maximum size are aborted, responses beyond the buffer threshold
+	// are streamed instead of buffered by middleware (0 disables a limit)
+	MaxResponseSize         int64 `env:"MAX_RESPONSE_SIZE"         envDefault:"0"`
+	ResponseBufferThreshold int64 `env:"RESPONSE_BUFFER_THRESHOLD" envDefault:"0"`
+
+	// Maximum request body size in bytes, larger requests are rejected with 413 (0 disables it; routes can override
+	// it, see WithMaxBodySize)
+	MaxBodySize int64 `env:"MAX_BODY_SIZE" envDefault:"0"`
+
+	// Slowloris protection: minimum request body transfer rate in bytes per second (0 disables it)
+	MinBodyRate      int64         `env:"MIN_BODY_RATE"       envDefault:"0"`
+	MinBodyRateGrace time.Duration `env:"MIN_BODY_RATE_GRACE" envDefault:"5s"`
+
+	// Listener limits of the HTTP server (0 disables a limit)
+	MaxConnections      int     `env:"MAX_CONNECTIONS"        envDefault:"0"`
+	MaxConnectionsPerIP int     `env:"MAX_CONNECTIONS_PER_IP" envDefault:"0"`
+	AcceptRate          float64 `env:"ACCEPT_RATE"            envDefault:"0"`
 
 	// Metrics server configuration
-	MetricsAddr string `env:"METRICS_ADDR" envDefault:":9090"`
-	MetricsPath string `env:"METRICS_PATH" envDefault:"/metrics"`
+	MetricsAddr    string `env:"METRICS_ADDR" envDefault:":9090"`
+	MetricsPath    string `env:"METRICS_PATH" envDefault:"/metrics"`
+	SLOPath        string `env:"SLO_PATH"     envDefault:"/slo"`
+	AdminPath      string `env:"ADMIN_PATH"   envDefault:"/admin"`
+	MetricsPushURL string `env:"METRICS_PUSH_URL"`
+
+	// Access control of the metrics server: METRICS_ADDR also accepts a Unix domain socket (unix:///path/to/socket),
+	// a certificate enables TLS, and a client CA requires client certificates (mTLS) on all endpoints
+	MetricsTLSCertFile     string `env:"METRICS_TLS_CERT_FILE"`
+	MetricsTLSKeyFile      string `env:"METRICS_TLS_KEY_FILE"`
+	MetricsTLSClientCAFile string `env:"METRICS_TLS_CLIENT_CA_FILE"`
+
+	// Protection of the metrics and SLO endpoints and of the admin endpoints (e.g. METRICS_AUTH_TOKEN,
+	// ADMIN_ALLOWED_NETWORKS), configured like the health endpoints
+	MetricsAuth EndpointAuth `envPrefix:"METRICS_"`
+	AdminAuth   EndpointAuth `envPrefix:"ADMIN_"`
+
+	// Handling of invalid characters in metric names, e.g. dashes in the service name ("underscore" or "keep")
+	MetricsNamePolicy MetricNamePolicy `env:"METRICS_NAME_POLICY" envDefault:"underscore"`
+
+	// Label HTTP metrics with the raw request path instead of the route pattern (only for a small, fixed set of paths)
+	MetricsRawPath bool `env:"METRICS_RAW_PATH" envDefault:"false"`
+
+	// Endpoint label of the HTTP metrics, e.g. the route template of a non-stdlib router (see
+	// MetricsMiddlewareConfig.EndpointLabel)
+	MetricsEndpointLabel func(r *http.Request) string `env:"-"`
+
+	// Labels with fixed values added to custom metrics as comma-separated "name:value" pairs,
+	// e.g. instance:pod-1,region:eu-west-1
+	MetricsDefaultLabels map[string]string `env:"METRICS_DEFAULT_LABELS"`
+
+	// Return errors when recording unknown metrics or wrong labels. Otherwise, unknown metrics are registered on first
+	// use and errors are logged (see MetricsCollector.SetStrict).
+	MetricsStrict bool `env:"METRICS_STRICT" envDefault:"true"`
+
+	// Health status in the metrics output ({service_name}_health_status) and 503 from the metrics endpoint during shutdown
+	MetricsHealthStatus      bool `env:"METRICS_HEALTH_STATUS"       envDefault:"false"`
+	MetricsUnavailableOnStop bool `env:"METRICS_UNAVAILABLE_ON_STOP" envDefault:"false"`
+
+	// Metrics self-check on start (fails the start on invalid names, duplicate help strings, or too many series)
+	MetricsSelfCheck bool `env:"METRICS_SELF_CHECK" envDefault:"false"`
+	MetricsMaxSeries int  `env:"METRICS_MAX_SERIES" envDefault:"1000"`
+
+	// Networks (CIDR) allowed to call internal routes registered with Service.Internal, empty allows all
+	InternalAllowedNetworks []string `env:"INTERNAL_ALLOWED_NETWORKS" envSeparator:","`
+
+	// Header sanitization: forwarding headers (X-Forwarded-*, X-Real-IP, ...) and internal headers are stripped from
+	// clients outside the trusted proxies (CIDR), and invalid values of trusted proxies and invalid request IDs
+	SanitizeHeaders bool     `env:"SANITIZE_HEADERS"  envDefault:"false"`
+	TrustedProxies  []string `env:"TRUSTED_PROXIES"   envSeparator:","`
+	InternalHeaders []string `env:"INTERNAL_HEADERS"  envSeparator:","`
+
+	// Built-in handlers of /robots.txt, /favicon.ico, and /.well-known/* instead of 404s. Routes registered for these
+	// paths take precedence. robots.txt is only served with ROBOTS_TXT as its content; security.txt (RFC 9116) is
+	// served with SECURITY_CONTACTS (e.g. mailto:security@example.com), change-password redirects to
+	// CHANGE_PASSWORD_URL.
+	WellKnownHandlers bool     `env:"WELL_KNOWN_HANDLERS" envDefault:"true"`
+	RobotsTxt         string   `env:"ROBOTS_TXT"`
+	SecurityContacts  []string `env:"SECURITY_CONTACTS"   envSeparator:","`
+	ChangePasswordURL string   `env:"CHANGE_PASSWORD_URL"`
 
 	// Graceful shutdown configuration
-	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	ShutdownTimeout   time.Duration `env:"SHUTDOWN_TIMEOUT"    envDefault:"30s"`
+	ShutdownStateFile string        `env:"SHUTDOWN_STATE_FILE"`
+
+	// Shutdown hooks: timeout of each hook (0 only limits them by the shutdown timeout) and parallel execution
+	ShutdownHookTimeout   time.Duration `env:"SHUTDOWN_HOOK_TIMEOUT"   envDefault:"0s"`
+	ShutdownHooksParallel bool          `env:"SHUTDOWN_HOOKS_PARALLEL" envDefault:"false"`
+
+	// Connection draining configuration
+	PreShutdownDelay      time.Duration `env:"PRE_SHUTDOWN_DELAY"      envDefault:"0s"`
+	LBHealthPath          string        `env:"LB_HEALTH_PATH"          envDefault:"/lb-health"`
+	DrainCloseConnections bool          `env:"DRAIN_CLOSE_CONNECTIONS" envDefault:"false"`
+
+	// Coordination with a service mesh sidecar: wait for its readiness before serving and shut it down after
+	// the graceful shutdown (e.g. :15021/healthz/ready and :15020/quitquitquit of the Istio proxy)
+	SidecarReadyURL     string        `env:"SIDECAR_READY_URL"`
+	SidecarReadyTimeout time.Duration `env:"SIDECAR_READY_TIMEOUT" envDefault:"60s"`
+	SidecarShutdownURL  string        `env:"SIDECAR_SHUTDOWN_URL"`
 
 	// Service information
 	Version string `env:"SERVICE_VERSION" envDefault:"v1.0.0"`
 
+	// Format of the IDs generated by NewID ("uuidv7" or "ulid")
+	IDFormat IDFormat `env:"ID_FORMAT" envDefault:"uuidv7"`
+
 	// Health check configuration
-	HealthPath    string `env:"HEALTH_PATH"    envDefault:"/health"`
-	ReadinessPath string `env:"READINESS_PATH" envDefault:"/ready"`
-	LivenessPath  string `env:"LIVENESS_PATH"  envDefault:"/live"`
+	HealthPath    string        `env:"HEALTH_PATH"    envDefault:"/health"`
+	ReadinessPath string        `env:"READINESS_PATH" envDefault:"/ready"`
+	LivenessPath  string        `env:"LIVENESS_PATH"  envDefault:"/live"`
+	HealthTimeout time.Duration `env:"HEALTH_TIMEOUT" envDefault:"5s"`
+
+	// Protection of the health endpoints, configured independently (e.g. HEALTH_AUTH_TOKEN, READINESS_ALLOWED_NETWORKS).
+	// Unprotected by default, so kubelet probes keep working.
+	HealthAuth    EndpointAuth `envPrefix:"HEALTH_"`
+	ReadinessAuth EndpointAuth `envPrefix:"READINESS_"`
+	LivenessAuth  EndpointAuth `envPrefix:"LIVENESS_"`
+
+	// Dependency health checks declared as comma-separated "[name=]target" entries (http(s)://, tcp://, or dns://
+	// targets), and a file with one entry per line
+	HealthChecks     []string `env:"HEALTH_CHECKS"      envSeparator:","`
+	HealthChecksFile string   `env:"HEALTH_CHECKS_FILE"`
+
+	// Load shedding configuration
+	LoadShedding         bool          `env:"LOAD_SHEDDING"          envDefault:"false"`
+	LoadSheddingTarget   time.Duration `env:"LOAD_SHEDDING_TARGET"   envDefault:"500ms"`
+	LoadSheddingInterval time.Duration `env:"LOAD_SHEDDING_INTERVAL" envDefault:"1s"`
+
+	// Concurrency limit configuration (0 disables the limiter)
+	MaxConcurrentRequests int    `env:"MAX_CONCURRENT_REQUESTS" envDefault:"0"`
+	PriorityHeader        string `env:"PRIORITY_HEADER"         envDefault:"X-Priority"`
 
-	// Logger configuration
-	Logger *slog.Logger `env:"-"`
+	// Header of request IDs, propagated from clients or generated (empty disables request IDs)
+	RequestIDHeader string `env:"REQUEST_ID_HEADER" envDefault:"X-Request-ID"`
+
+	// Access log of completed requests: the fraction of requests logged (0 logs all, server errors are always logged)
+	// and request paths that are never logged
+	AccessLog           bool     `env:"ACCESS_LOG"             envDefault:"true"`
+	AccessLogSampleRate float64  `env:"ACCESS_LOG_SAMPLE_RATE" envDefault:"1"`
+	AccessLogSkipPaths  []string `env:"ACCESS_LOG_SKIP_PATHS"  envSeparator:","`
+
+	// Request event pipeline for analytics: one compact JSON record per request, written to a file or sent to a UDP
+	// address (e.g. of a log shipper) separately from the logs. Events are dropped when the buffer is full, so a slow
+	// sink can't stall requests (see EventPipeline).
+	RequestEventsFile    string    `env:"REQUEST_EVENTS_FILE"`
+	RequestEventsUDPAddr string    `env:"REQUEST_EVENTS_UDP_ADDR"`
+	RequestEventsBuffer  int       `env:"REQUEST_EVENTS_BUFFER"   envDefault:"10000"`
+	RequestEventSink     EventSink `env:"-"`
+
+	// Usage accounting of requests and body bytes per tenant and API key for usage-based billing (see UsageMeter),
+	// flushed to a URL, a file, or the log. Keys are the identities of APIKeyMiddleware, never raw headers.
+	UsageAccounting    bool          `env:"USAGE_ACCOUNTING"     envDefault:"false"`
+	UsageFlushInterval time.Duration `env:"USAGE_FLUSH_INTERVAL" envDefault:"1m"`
+	UsageTenantHeader  string        `env:"USAGE_TENANT_HEADER"`
+	UsageMaxKeys       int           `env:"USAGE_MAX_KEYS"       envDefault:"10000"`
+	UsageSinkURL       string        `env:"USAGE_SINK_URL"`
+	UsageSinkFile      string        `env:"USAGE_SINK_FILE"`
+
+	// Headers copied from incoming requests into the context and onto outbound requests of instrumented clients
+	PropagatedHeaders []string `env:"PROPAGATED_HEADERS" envSeparator:","`
+
+	// OAuth2 client credentials configuration for outbound requests (see Service.TokenSource)
+	OAuthIssuer       string   `env:"OAUTH_ISSUER"`
+	OAuthTokenURL     string   `env:"OAUTH_TOKEN_URL"`
+	OAuthClientID     string   `env:"OAUTH_CLIENT_ID"`
+	OAuthClientSecret string   `env:"OAUTH_CLIENT_SECRET"`
+	OAuthScopes       []string `env:"OAUTH_SCOPES"        envSeparator:","`
+
+	// API keys of internal callers as identity:key pairs, checked by Service.APIKeyAuth
+	APIKeys      map[string]string `env:"API_KEYS"`
+	APIKeyHeader string            `env:"API_KEY_HEADER" envDefault:"X-API-Key"`
+
+	// Notifications about critical events (panic spikes, unhealthy status, shutdown timeouts)
+	NotifyWebhookURL    string        `env:"NOTIFY_WEBHOOK_URL"`
+	NotifyInterval      time.Duration `env:"NOTIFY_INTERVAL"       envDefault:"5m"`
+	PanicSpikeThreshold int           `env:"PANIC_SPIKE_THRESHOLD" envDefault:"10"`
+
+	// Registered plugins installed on start (see Register)
+	Plugins []string `env:"PLUGINS" envSeparator:","`
+
+	// File with runtime settings as "name=value" lines (see Service.Setting), loaded on start and reloaded on SIGHUP
+	SettingsFile string `env:"SETTINGS_FILE"`
+
+	// Smoke test (see Service.RunSmokeTest): routes requested after the service is ready, and the overall timeout
+	SmokeTestRoutes  []string      `env:"SMOKE_TEST_ROUTES"  envSeparator:","`
+	SmokeTestTimeout time.Duration `env:"SMOKE_TEST_TIMEOUT" envDefault:"30s"`
+
+	// Debug logging of single requests: requests with the header (and the token as its value, if set) or of sampled
+	// W3C traces are logged at debug level, without changing the level of the service
+	DebugHeader        string `env:"DEBUG_HEADER"`
+	DebugToken         string `env:"DEBUG_TOKEN"`
+	DebugSampledTraces bool   `env:"DEBUG_SAMPLED_TRACES" envDefault:"false"`
+
+	// pprof and expvar endpoints of the metrics server below DEBUG_PATH (e.g. /debug/pprof/ and /debug/vars),
+	// protected like the health endpoints (e.g. DEBUG_ENDPOINTS_AUTH_TOKEN)
+	DebugEndpoints     bool         `env:"DEBUG_ENDPOINTS" envDefault:"false"`
+	DebugPath          string       `env:"DEBUG_PATH"      envDefault:"/debug"`
+	DebugEndpointsAuth EndpointAuth `envPrefix:"DEBUG_ENDPOINTS_"`
+
+	// Development configuration (pretty logs unless Logger is replaced, a route table on start, and panic details in
+	// responses, never enable in production)
+	DevMode bool `env:"DEV_MODE" envDefault:"false"`
+
+	// Persistence of the last runs of interval tasks across restarts (see WithCatchUp); the store takes precedence
+	// over the file
+	JobStateFile  string        `env:"JOB_STATE_FILE"`
+	JobStateStore JobStateStore `env:"-"`
+
+	// Response of requests whose handler panicked (DevPanicRenderer in dev mode, DefaultPanicRenderer otherwise)
+	PanicRenderer PanicRenderer `env:"-"`
+
+	// Response of failed requests written with WriteError, panics, and timeouts (ProblemErrorHandler if nil)
+	ErrorHandler ErrorHandler `env:"-"`
+
+	// Admin endpoints of the metrics server (ADMIN_PATH), read-only without ADMIN_AUTH_*
+	AdminEnabled bool `env:"ADMIN_ENABLED" envDefault:"true"`
+
+	// Strict-Transport-Security max age of responses (0 disables the header)
+	HSTSMaxAge time.Duration `env:"HSTS_MAX_AGE" envDefault:"0s"`
+
+	// Logger configuration: format ("text" or "json") and level of the logger created by LoadFromEnv
+	LogFormat string       `env:"LOG_FORMAT" envDefault:"text"`
+	LogLevel  slog.Level   `env:"LOG_LEVEL"  envDefault:"info"`
+	Logger    *slog.Logger `env:"-"`
 
 	// Custom shutdown hooks
-	ShutdownHooks []func() error `env:"-"`
+	ShutdownHooks []ShutdownHook `env:"-"`
+
+	// defaultLogger is the logger created with the config, which DevMode replaces with a pretty logger
+	defaultLogger *slog.Logger
 }
 
 // DefaultConfig creates a new config with default values
 func DefaultConfig() *Config {
-	return &Config{
-		Addr:            ":8080",
-		ReadTimeout:     10 * time.Second,
-		WriteTimeout:    10 * time.Second,
-		IdleTimeout:     120 * time.Second,
-		MetricsAddr:     ":9090",
-		MetricsPath:     "/metrics",
-		ShutdownTimeout: 30 * time.Second,
-		Version:         "v1.0.0",
-		HealthPath:      "/health",
-		ReadinessPath:   "/ready",
-		LivenessPath:    "/live",
-		Logger:          slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})),
-		ShutdownHooks:   make([]func() error, 0),
+	config := &Config{
+		Addr:                     ":8080",
+		ReadTimeout:              10 * time.Second,
+		ReadHeaderTimeout:        5 * time.Second,
+		WriteTimeout:             10 * time.Second,
+		IdleTimeout:              120 * time.Second,
+		TLSSessionTicketRotation: time.Hour,
+		MinBodyRateGrace:         5 * time.Second,
+		MetricsAddr:              ":9090",
+		MetricsPath:              "/metrics",
+		SLOPath:                  "/slo",
+		AdminPath:                "/admin",
+		DebugPath:                "/debug",
+		MetricsMaxSeries:         1000,
+		MetricsNamePolicy:        MetricNameUnderscore,
+		MetricsStrict:            true,
+		ShutdownTimeout:          30 * time.Second,
+		Version:                  "v1.0.0",
+		IDFormat:                 IDFormatUUIDv7,
+		HealthPath:               "/health",
+		ReadinessPath:            "/ready",
+		LivenessPath:             "/live",
+		HealthTimeout:            5 * time.Second,
+		LBHealthPath:             "/lb-health",
+		WellKnownHandlers:        true,
+		SidecarReadyTimeout:      time.Minute,
+		LoadSheddingTarget:       500 * time.Millisecond,
+		LoadSheddingInterval:     time.Second,
+		PriorityHeader:           "X-Priority",
+		RequestIDHeader:          DefaultRequestIDHeader,
+		AccessLog:                true,
+		AccessLogSampleRate:      1,
+		RequestEventsBuffer:      10000,
+		UsageFlushInterval:       time.Minute,
+		UsageMaxKeys:             10000,
+		APIKeyHeader:             DefaultAPIKeyHeader,
+		NotifyInterval:           5 * time.Minute,
+		PanicSpikeThreshold:      10,
+		SmokeTestTimeout:         30 * time.Second,
+		AdminEnabled:             true,
+		LogFormat:                "text",
+		LogLevel:                 slog.LevelInfo,
+		ShutdownHooks:            make([]ShutdownHook, 0),
 	}
-}
 
-// LoadFromEnv loads configuration from environment variables
-func LoadFromEnv() (*Config, error) {
-	config := DefaultConfig()
+	config.setDefaultLogger(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})))
 
-	if err := env.Parse(config); err != nil {
-		return nil, fmt.Errorf("failed to parse environment variables: %w", err)
-	}
+	return config
+}
 
-	return config, nil
+// setDefaultLogger sets the logger created with the config
+func (c *Config) setDefaultLogger(logger *slog.Logger) {
+	c.Logger = logger
+	c.defaultLogger = logger
+}
+
+// LoadFromEnv loads configuration from environment variables, with the defaults of the profile in PROFILE
+func LoadFromEnv() (*Config, error) {
+	return loadConfig(env.ToMap(os.Environ()))
 }
 
 // AddShutdownHook adds a function to be called during graceful shutdown
-func (c *Config) AddShutdownHook(hook func() error) {
+func (c *Config) AddShutdownHook(hook ShutdownHook) {
 	c.ShutdownHooks = append(c.ShutdownHooks, hook)
 }
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caarlos0/env/v11"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidConfig is returned when a config file can't be read or contains invalid fields
+var ErrInvalidConfig = NewError(CodeInvalidArgument, "invalid config")
+
+// ConfigFileEnv is the environment variable of the config file read by LoadConfig
+const ConfigFileEnv = "CONFIG_FILE"
+
+// LoadFromFile loads configuration from a YAML, JSON, or TOML file (by its extension), with the defaults of the
+// profile in its "profile" key. Keys are the names of the environment variables in any case, e.g. "read_timeout",
+// and nested keys are joined with underscores, e.g. "metrics: {addr: ':9090'}" sets METRICS_ADDR.
+func LoadFromFile(path string) (*Config, error) {
+	environment, err := fileEnvironment(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadConfig(environment)
+}
+
+// LoadConfig loads configuration from the file in CONFIG_FILE (see LoadFromFile), overridden by environment
+// variables. Without CONFIG_FILE, it is the same as LoadFromEnv.
+func LoadConfig() (*Config, error) {
+	environment := env.ToMap(os.Environ())
+
+	if path := environment[ConfigFileEnv]; path != "" {
+		file, err := fileEnvironment(path)
+		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(file, environment)
+		environment = file
+	}
+
+	return loadConfig(environment)
+}
+
+// loadConfig parses the config from environment variables, with the defaults of the profile in PROFILE
+func loadConfig(environment map[string]string) (*Config, error) {
+	config := DefaultConfig()
+
+	environment, err := profileEnvironment(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := env.ParseWithOptions(config, env.Options{Environment: environment}); err != nil {
+		return nil, fmt.Errorf("failed to parse environment variables: %w", err)
+	}
+
+	config.setDefaultLogger(newLogger(os.Stdout, config.LogFormat, config.LogLevel))
+
+	return config, nil
+}
+
+// fileEnvironment reads a config file as environment variables, and validates all its keys and values
+func fileEnvironment(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	values := make(map[string]any)
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported config file format %q", ErrInvalidConfig, ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, path, err)
+	}
+
+	keys, err := configKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	environment := make(map[string]string)
+	flattenConfig(environment, keys, "", values)
+
+	if problems := validateEnvironment(environment, keys); len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, path, strings.Join(problems, "; "))
+	}
+
+	return environment, nil
+}
+
+// configKeys returns the environment variables of the config fields by name, with whether the field is a map
+func configKeys() (map[string]bool, error) {
+	params, err := env.GetFieldParams(&Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config fields: %w", err)
+	}
+
+	keys := map[string]bool{"PROFILE": false}
+
+	config := reflect.TypeFor[Config]()
+	for i := range config.NumField() {
+		field := config.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("env"), ","); name != "" && field.Type.Kind() == reflect.Map {
+			keys[name] = true
+		}
+	}
+
+	for _, param := range params {
+		if _, ok := keys[param.Key]; !ok {
+			keys[param.Key] = false
+		}
+	}
+
+	return keys, nil
+}
+
+// flattenConfig converts nested config values into environment variables. Lists are joined with commas and maps of
+// map fields with "key:value" entries, like in the environment.
+func flattenConfig(environment map[string]string, keys map[string]bool, prefix string, values map[string]any) {
+	for name, value := range values {
+		key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch value := value.(type) {
+		case map[string]any:
+			if keys[key] {
+				entries := make([]string, 0, len(value))
+				for _, entry := range slices.Sorted(maps.Keys(value)) {
+					entries = append(entries, entry+":"+configValue(value[entry]))
+				}
+
+				environment[key] = strings.Join(entries, ",")
+
+				continue
+			}
+
+			flattenConfig(environment, keys, key, value)
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, configValue(item))
+			}
+
+			environment[key] = strings.Join(items, ",")
+		default:
+			environment[key] = configValue(value)
+		}
+	}
+}
+
+// configValue formats a scalar config value like an environment variable
+func configValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// validateEnvironment returns the unknown keys and invalid values of a config file, sorted by key
+func validateEnvironment(environment map[string]string, keys map[string]bool) []string {
+	var problems []string
+
+	for _, key := range slices.Sorted(maps.Keys(environment)) {
+		if _, ok := keys[key]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown key %s", key))
+			continue
+		}
+
+		if key == "PROFILE" {
+			if _, ok := profileDefaults[Profile(environment[key])]; !ok && environment[key] != "" {
+				problems = append(problems, fmt.Sprintf("%s: %v %q", key, ErrUnknownProfile, environment[key]))
+			}
+
+			continue
+		}
+
+		// Parse each value on its own, so every invalid field is reported by its key
+		err := env.ParseWithOptions(DefaultConfig(), env.Options{Environment: map[string]string{key: environment[key]}})
+		if parseErr := (env.ParseError{}); errors.As(err, &parseErr) {
+			err = parseErr.Err
+		}
+
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+
+	return problems
+}
+
+// parseTOML parses the subset of TOML used by config files: tables, dotted keys, strings, numbers, booleans, and
+// single-line arrays
+func parseTOML(data []byte) (map[string]any, error) {
+	root := make(map[string]any)
+	table := root
+
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripTOMLComment(line))
+		if line == "" {
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			name, ok = strings.CutSuffix(name, "]")
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid table %q", number+1, line) //nolint:err113
+			}
+
+			table = tomlTable(root, strings.Split(strings.TrimSpace(name), "."))
+
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", number+1, line) //nolint:err113
+		}
+
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number+1, err)
+		}
+
+		path := strings.Split(strings.TrimSpace(key), ".")
+		tomlTable(table, path[:len(path)-1])[strings.Trim(strings.TrimSpace(path[len(path)-1]), `"`)] = value
+	}
+
+	return root, nil
+}
+
+// tomlTable returns the nested table of a path, creating missing tables
+func tomlTable(root map[string]any, path []string) map[string]any {
+	table := root
+
+	for _, name := range path {
+		name = strings.Trim(strings.TrimSpace(name), `"`)
+
+		next, ok := table[name].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			table[name] = next
+		}
+
+		table = next
+	}
+
+	return table
+}
+
+// parseTOMLValue parses a TOML string, number, boolean, or single-line array
+func parseTOMLValue(raw string) (any, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		value, err := strconv.Unquote(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s: %w", raw, err)
+		}
+
+		return value, nil
+	case strings.HasPrefix(raw, "'"):
+		value, ok := strings.CutSuffix(raw[1:], "'")
+		if !ok {
+			return nil, fmt.Errorf("invalid string %s", raw) //nolint:err113
+		}
+
+		return value, nil
+	case strings.HasPrefix(raw, "["):
+		inner, ok := strings.CutSuffix(raw[1:], "]")
+		if !ok {
+			return nil, fmt.Errorf("invalid array %s", raw) //nolint:err113
+		}
+
+		var items []any
+
+		for _, item := range splitTOMLArray(inner) {
+			value, err := parseTOMLValue(strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, value)
+		}
+
+		return items, nil
+	case raw == "true" || raw == "false":
+		return raw == "true", nil
+	}
+
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(raw, "_", ""), 64); err != nil {
+		return nil, fmt.Errorf("invalid value %s", raw) //nolint:err113
+	}
+
+	return strings.ReplaceAll(raw, "_", ""), nil
+}
+
+// splitTOMLArray splits the items of a single-line array on commas outside of strings
+func splitTOMLArray(inner string) []string {
+	var (
+		items []string
+		quote rune
+		start int
+	)
+
+	for i, char := range inner {
+		switch {
+		case quote != 0:
+			if char == quote && (quote == '\'' || i == 0 || inner[i-1] != '\\') {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == ',':
+			items = append(items, inner[start:i])
+			start = i + 1
+		}
+	}
+
+	items = append(items, inner[start:])
+
+	return slices.DeleteFunc(items, func(item string) bool {
+		return strings.TrimSpace(item) == ""
+	})
+}
+
+// stripTOMLComment removes a comment from a line, ignoring # in strings
+func stripTOMLComment(line string) string {
+	var quote rune
+
+	for i, char := range line {
+		switch {
+		case quote != 0:
+			if char == quote && (quote == '\'' || line[i-1] != '\\') {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a config file to a temporary directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	return path
+}
+
+func TestLoadFromFile(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"service.yaml": `
+profile: prod
+addr: ":8081"
+read_timeout: 3s
+access_log_skip_paths: [/health, /lb-health]
+metrics_default_labels:
+  region: eu-west-1
+metrics:
+  auth:
+    token: scrape
+`,
+		"service.json": `{
+  "profile": "prod",
+  "ADDR": ":8081",
+  "read_timeout": "3s",
+  "access_log_skip_paths": ["/health", "/lb-health"],
+  "metrics_default_labels": {"region": "eu-west-1"},
+  "metrics": {"auth": {"token": "scrape"}}
+}`,
+		"service.toml": `
+profile = "prod"
+addr = ":8081" # the main server
+read_timeout = "3s"
+access_log_skip_paths = ["/health", "/lb-health"]
+
+[metrics_default_labels]
+region = "eu-west-1"
+
+[metrics.auth]
+token = 'scrape'
+`,
+	}
+
+	for name, content := range files {
+		config, err := LoadFromFile(writeConfigFile(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: failed to load config: %v", name, err)
+		}
+
+		if config.Addr != ":8081" || config.ReadTimeout != 3*time.Second || config.MetricsAuth.Token != "scrape" ||
+			!slices.Equal(config.AccessLogSkipPaths, []string{"/health", "/lb-health"}) ||
+			config.MetricsDefaultLabels["region"] != "eu-west-1" {
+			t.Errorf("%s: unexpected config: %+v", name, config)
+		}
+
+		if config.Profile != ProfileProd || config.LogFormat != "json" {
+			t.Errorf("%s: expected the prod defaults, got log format %q", name, config.LogFormat)
+		}
+	}
+}
+
+func TestLoadFromFileInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := LoadFromFile(writeConfigFile(t, "service.yaml", "adr: :8081\nread_timeout: soon\nmax_connections: many\n"))
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+
+	for _, field := range []string{"unknown key ADR", "READ_TIMEOUT", "MAX_CONNECTIONS"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected %q in %q", field, err)
+		}
+	}
+
+	if _, err := LoadFromFile(writeConfigFile(t, "service.ini", "addr=:8081")); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unsupported format, got %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv(ConfigFileEnv, writeConfigFile(t, "service.yaml", "addr: \":8081\"\nread_timeout: 3s\n"))
+	t.Setenv("READ_TIMEOUT", "7s")
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if config.Addr != ":8081" || config.ReadTimeout != 7*time.Second {
+		t.Errorf("expected the environment to override the file, got addr %q and read timeout %s",
+			config.Addr, config.ReadTimeout)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConnectionLimits holds the listener-level limits of the HTTP server (0 disables a limit)
+type ConnectionLimits struct {
+	// MaxConnections is the maximum number of open connections. Further connections wait in the accept queue.
+	MaxConnections int
+	// MaxConnectionsPerIP is the maximum number of open connections per client IP. Further connections are closed.
+	MaxConnectionsPerIP int
+	// AcceptRate is the maximum number of connections accepted per second, with bursts of the same size
+	AcceptRate float64
+}
+
+// enabled reports whether any limit is set
+func (l ConnectionLimits) enabled() bool {
+	return l.MaxConnections > 0 || l.MaxConnectionsPerIP > 0 || l.AcceptRate > 0
+}
+
+// LimitListener limits the connections accepted by a listener, so a single misbehaving client can't exhaust
+// file descriptors before the HTTP layer sees a request.
+// Open connections are tracked in {service_name}_connections_active, closed connections of clients
+// over their limit in {service_name}_connections_rejected_total{reason}, and accepts delayed by the limits
+// in {service_name}_connections_delayed_total{reason}.
+func LimitListener(listener net.Listener, metrics *MetricsCollector, limits ConnectionLimits) net.Listener {
+	limited := &limitListener{
+		Listener: listener,
+		limits:   limits,
+		perIP:    make(map[string]int),
+		tokens:   limits.AcceptRate,
+		last:     time.Now(),
+		done:     make(chan struct{}),
+		active: metrics.builtinGaugeVec("connections_active",
+			"Number of open connections of the HTTP server").WithLabelValues(),
+		rejected: metrics.builtinCounterVec("connections_rejected_total",
+			"Total number of connections closed by the connection limits", "reason"),
+		delayed: metrics.builtinCounterVec("connections_delayed_total",
+			"Total number of connection accepts delayed by the connection limits", "reason"),
+	}
+
+	if limits.MaxConnections > 0 {
+		limited.slots = make(chan struct{}, limits.MaxConnections)
+	}
+
+	return limited
+}
+
+// limitListener is a listener that enforces ConnectionLimits
+type limitListener struct {
+	net.Listener
+
+	limits ConnectionLimits
+	slots  chan struct{}
+
+	mu     sync.Mutex
+	perIP  map[string]int
+	tokens float64
+	last   time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+
+	active   prometheus.Gauge
+	rejected *prometheus.CounterVec
+	delayed  *prometheus.CounterVec
+}
+
+// Accept waits for a free connection slot and an accept token, and accepts the next connection
+// of a client below its connection limit
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		if !l.acquire() {
+			return nil, net.ErrClosed
+		}
+
+		if !l.throttle() {
+			l.release()
+			return nil, net.ErrClosed
+		}
+
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.release()
+			return nil, err //nolint:wrapcheck
+		}
+
+		ip := connIP(conn)
+		if !l.addIP(ip) {
+			l.rejected.WithLabelValues("per_ip").Inc()
+			l.release()
+			_ = conn.Close()
+
+			continue
+		}
+
+		l.active.Inc()
+
+		return &limitedConn{Conn: conn, listener: l, ip: ip}, nil
+	}
+}
+
+// Close closes the listener and unblocks waiting accepts
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+
+	return l.Listener.Close() //nolint:wrapcheck
+}
+
+// acquire waits for a free connection slot and returns false if the listener was closed
+func (l *limitListener) acquire() bool {
+	if l.slots == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	l.delayed.WithLabelValues("max_connections").Inc()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// release frees a connection slot
+func (l *limitListener) release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// throttle waits for an accept token and returns false if the listener was closed
+func (l *limitListener) throttle() bool {
+	if l.limits.AcceptRate <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.limits.AcceptRate, max(l.limits.AcceptRate, 1))
+	l.last = now
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.limits.AcceptRate * float64(time.Second))
+
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return true
+	}
+
+	l.delayed.WithLabelValues("accept_rate").Inc()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// addIP counts a connection of a client and returns false if the client is at its limit
+func (l *limitListener) addIP(ip string) bool {
+	if l.limits.MaxConnectionsPerIP <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.perIP[ip] >= l.limits.MaxConnectionsPerIP {
+		return false
+	}
+
+	l.perIP[ip]++
+
+	return true
+}
+
+// removeIP uncounts a closed connection of a client
+func (l *limitListener) removeIP(ip string) {
+	if l.limits.MaxConnectionsPerIP <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.perIP[ip]--
+	if l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// limitedConn is a connection that frees its limits when it is closed
+type limitedConn struct {
+	net.Conn
+
+	listener *limitListener
+	ip       string
+	once     sync.Once
+}
+
+// Close closes the connection and frees its limits
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+
+	c.once.Do(func() {
+		c.listener.removeIP(c.ip)
+		c.listener.release()
+		c.listener.active.Dec()
+	})
+
+	return err //nolint:wrapcheck
+}
+
+// connIP returns the IP address of the remote end of a connection
+func connIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+
+	return host
+}
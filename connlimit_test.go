@@ -0,0 +1,189 @@
+package service
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLimitListenerPerIP(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("connlimit_per_ip_test")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limited := LimitListener(listener, metrics, ConnectionLimits{MaxConnectionsPerIP: 1})
+	t.Cleanup(func() { _ = limited.Close() })
+
+	accepted := make(chan net.Conn, 2)
+
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted <- conn
+		}
+	}()
+
+	first, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	serverConn := <-accepted
+
+	second, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	_ = second.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF { //nolint:errorlint
+		t.Errorf("expected the second connection to be closed, got %v", err)
+	}
+
+	if got := testutil.ToFloat64(metrics.builtinCounterVec("connections_rejected_total", "", "reason").WithLabelValues("per_ip")); got != 1 {
+		t.Errorf("expected 1 rejected connection, got %v", got)
+	}
+
+	// Closing the first connection frees the slot of the client
+	_ = serverConn.Close()
+
+	third, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+
+	select {
+	case conn := <-accepted:
+		_ = conn.Close()
+	case <-time.After(time.Second):
+		t.Error("expected the connection to be accepted after the first one was closed")
+	}
+}
+
+func TestLimitListenerMaxConnections(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("connlimit_max_test")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limited := LimitListener(listener, metrics, ConnectionLimits{MaxConnections: 1})
+
+	accepted := make(chan net.Conn, 2)
+	closed := make(chan struct{})
+
+	go func() {
+		defer close(closed)
+
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted <- conn
+		}
+	}()
+
+	for range 2 {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+
+	first := <-accepted
+
+	select {
+	case <-accepted:
+		t.Fatal("expected the second connection to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	active := metrics.builtinGaugeVec("connections_active", "").WithLabelValues()
+	if got := testutil.ToFloat64(active); got != 1 {
+		t.Errorf("expected 1 active connection, got %v", got)
+	}
+
+	_ = first.Close()
+
+	select {
+	case conn := <-accepted:
+		_ = conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("expected the second connection to be accepted once the slot was freed")
+	}
+
+	// Closing the listener unblocks a waiting accept
+	_ = limited.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Error("expected Accept to return after Close")
+	}
+}
+
+func TestLimitListenerAcceptRate(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("connlimit_rate_test")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limited := LimitListener(listener, metrics, ConnectionLimits{AcceptRate: 20})
+	t.Cleanup(func() { _ = limited.Close() })
+
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = conn.Close()
+		}
+	}()
+
+	for range 25 {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = conn.Close()
+	}
+
+	delayed := metrics.builtinCounterVec("connections_delayed_total", "", "reason").WithLabelValues("accept_rate")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(delayed) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if testutil.ToFloat64(delayed) == 0 {
+		t.Error("expected accepts beyond the burst to be delayed")
+	}
+}
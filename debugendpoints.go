@@ -0,0 +1,31 @@
+package service
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// debugEndpointsHandler returns the pprof and expvar handlers under DEBUG_PATH, e.g. /debug/pprof/ and /debug/vars
+func (s *Service) debugEndpointsHandler() http.Handler {
+	// The pprof index links to profiles below /debug/pprof/, so requests are mapped to the standard paths
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return http.StripPrefix(s.debugPath(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = "/debug" + r.URL.Path
+		r.URL.RawPath = ""
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+// debugPath returns DEBUG_PATH without a trailing slash
+func (s *Service) debugPath() string {
+	return strings.TrimSuffix(s.Config.DebugPath, "/")
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.DebugEndpoints = true
+	config.DebugPath = "/ops/debug/"
+	config.DebugEndpointsAuth = EndpointAuth{Token: "profile"}
+
+	handler := New("debug_endpoints_test", config).metricsHandler()
+
+	tests := []struct {
+		path     string
+		token    string
+		expected int
+		contains string
+	}{
+		{path: "/ops/debug/pprof/", expected: http.StatusUnauthorized},
+		{path: "/ops/debug/pprof/", token: "profile", expected: http.StatusOK, contains: "goroutine"},
+		{path: "/ops/debug/pprof/cmdline", token: "profile", expected: http.StatusOK},
+		{path: "/ops/debug/vars", token: "profile", expected: http.StatusOK, contains: "memstats"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != tt.expected || !strings.Contains(recorder.Body.String(), tt.contains) {
+			t.Errorf("%s: expected status %d with %q, got %d", tt.path, tt.expected, tt.contains, recorder.Code)
+		}
+	}
+}
+
+func TestDebugEndpointsDisabled(t *testing.T) {
+	t.Parallel()
+
+	recorder := httptest.NewRecorder()
+	New("debug_disabled_test", nil).metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without DEBUG_ENDPOINTS, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// debugRequestKey is the context key marking requests with debug logging, with the debug header as its value
+const debugRequestKey ContextKey = "debug_request"
+
+// redactedHeaders are not logged with request details, as they carry credentials
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// DebugRequestConfig selects requests that are logged at debug level, e.g. to triage a production issue
+// without changing the level of the service
+type DebugRequestConfig struct {
+	// Header marks a request for debug logging, e.g. X-Debug
+	Header string
+	// Token is the value required in the header. Without a token, any value is accepted.
+	Token string
+	// SampledTraces logs requests of sampled W3C traces (traceparent header) at debug level
+	SampledTraces bool
+}
+
+// DebugRequestMiddleware elevates the logger of selected requests (see DebugRequestConfig) to debug level and logs
+// the request details (headers with credentials redacted) and the response status. The level of other requests
+// doesn't change.
+func DebugRequestMiddleware(config DebugRequestConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reason, attrs := config.match(r)
+			if reason == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger := LoggerFromContext(r.Context())
+			logger = slog.New(debugHandler{logger.Handler()}).With(attrs...)
+
+			ctx := context.WithValue(ContextWithLogger(r.Context(), logger), debugRequestKey, config.Header)
+
+			logger.Debug("debug logging enabled for request", "reason", reason)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// match returns why a request is logged at debug level, or an empty string if it isn't, and attributes for its logger
+func (c DebugRequestConfig) match(r *http.Request) (string, []any) {
+	if c.Header != "" {
+		if value := r.Header.Get(c.Header); value != "" && (c.Token == "" || tokensEqual(value, c.Token)) {
+			return "header", nil
+		}
+	}
+
+	if c.SampledTraces {
+		if traceID, sampled := parseTraceparent(r.Header.Get("Traceparent")); sampled {
+			return "sampled_trace", []any{"trace_id", traceID}
+		}
+	}
+
+	return "", nil
+}
+
+// DebugRequest reports whether the request is logged at debug level, e.g. to log expensive details only then
+func DebugRequest(r *http.Request) bool {
+	_, debug := r.Context().Value(debugRequestKey).(string)
+	return debug
+}
+
+// parseTraceparent returns the trace ID of a W3C traceparent header ("00-{trace-id}-{parent-id}-{flags}") and whether
+// the trace is sampled
+func parseTraceparent(header string) (string, bool) {
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[3]) != 2 {
+		return "", false
+	}
+
+	flags := parts[3][1]
+
+	// The sampled flag is the lowest bit of the flags
+	return parts[1], strings.IndexByte("13579bdf", flags) >= 0
+}
+
+// logRequestDetails logs the details of a debug request and returns a function that logs its completion
+func logRequestDetails(logger *slog.Logger, w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	// The debug header may carry a token
+	debugHeader, _ := r.Context().Value(debugRequestKey).(string)
+
+	logger.Debug("request details",
+		"proto", r.Proto,
+		"host", r.Host,
+		"query", r.URL.RawQuery,
+		"content_length", r.ContentLength,
+		"headers", redactHeaders(r.Header, debugHeader))
+
+	wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	start := time.Now()
+
+	return wrapped, func() {
+		logger.Debug("request completed",
+			"status", wrapped.statusCode,
+			"response_headers", redactHeaders(wrapped.Header()),
+			"duration", time.Since(start))
+	}
+}
+
+// redactHeaders returns a copy of the headers with the values of credentials and the extra headers redacted
+func redactHeaders(header http.Header, extra ...string) http.Header {
+	header = header.Clone()
+
+	for _, name := range append(extra, redactedHeaders...) {
+		if header.Get(name) != "" {
+			header.Set(name, "[REDACTED]")
+		}
+	}
+
+	return header
+}
+
+// debugHandler is a slog.Handler that handles records of all levels
+type debugHandler struct {
+	slog.Handler
+}
+
+// Enabled reports that records of all levels are handled
+func (h debugHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// WithAttrs returns a debug handler with the attributes
+func (h debugHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return debugHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a debug handler with the group
+func (h debugHandler) WithGroup(name string) slog.Handler {
+	return debugHandler{h.Handler.WithGroup(name)}
+}
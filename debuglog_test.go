@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugRequestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	config := DefaultConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	config.DebugHeader = "X-Debug"
+	config.DebugToken = "triage"
+	config.DebugSampledTraces = true
+
+	svc := New("debug_request_test", config)
+	svc.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		GetLogger(r).Debug("handler detail", "debug", DebugRequest(r))
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		debug  bool
+	}{
+		{name: "no header"},
+		{name: "wrong token", header: map[string]string{"X-Debug": "guess"}},
+		{name: "token", header: map[string]string{"X-Debug": "triage", "Authorization": "Bearer secret"}, debug: true},
+		{name: "unsampled trace", header: map[string]string{"Traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"}},
+		{name: "sampled trace", header: map[string]string{"Traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}, debug: true},
+	}
+
+	for _, tt := range tests {
+		logs.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for name, value := range tt.header {
+			req.Header.Set(name, value)
+		}
+
+		svc.handler().ServeHTTP(httptest.NewRecorder(), req)
+
+		output := logs.String()
+
+		if got := strings.Contains(output, "handler detail"); got != tt.debug {
+			t.Errorf("%s: expected debug logs %v, got:\n%s", tt.name, tt.debug, output)
+		}
+
+		if tt.debug && !strings.Contains(output, "msg=\"request completed\"") {
+			t.Errorf("%s: expected the request details to be logged:\n%s", tt.name, output)
+		}
+
+		if strings.Contains(output, "secret") || strings.Contains(output, "triage") {
+			t.Errorf("%s: expected credentials to be redacted:\n%s", tt.name, output)
+		}
+	}
+
+	if !config.Logger.Enabled(t.Context(), slog.LevelInfo) || config.Logger.Enabled(t.Context(), slog.LevelDebug) {
+		t.Error("expected the level of the service to stay unchanged")
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		header  string
+		traceID string
+		sampled bool
+	}{
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "0af7651916cd43dd8448eb211c80319c", true},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-03", "0af7651916cd43dd8448eb211c80319c", true},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00", "0af7651916cd43dd8448eb211c80319c", false},
+		{"invalid", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		traceID, sampled := parseTraceparent(tt.header)
+		if traceID != tt.traceID || sampled != tt.sampled {
+			t.Errorf("parseTraceparent(%q) = %q, %v, expected %q, %v", tt.header, traceID, sampled, tt.traceID, tt.sampled)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// DependenciesKey is the context key for the dependencies provided to the service
+const DependenciesKey ContextKey = "dependencies"
+
+// dependencies holds the shared dependencies of a service by type
+type dependencies struct {
+	mu     sync.RWMutex
+	byType map[reflect.Type]any
+	order  []reflect.Type
+}
+
+// Provide makes a shared dependency, e.g. a *sql.DB or an API client, available to handlers (see Resolve) and
+// background tasks (see ResolveContext) by its type. Dependencies with a Shutdown(ctx) error, Close() error, or
+// Close() method are registered as components and closed during the graceful shutdown, in reverse order of
+// registration. It panics if a dependency of the same type was provided before.
+func (s *Service) Provide(dependency any) {
+	typ := reflect.TypeOf(dependency)
+	if typ == nil {
+		panic("service: Provide called with nil")
+	}
+
+	s.dependencies.mu.Lock()
+	defer s.dependencies.mu.Unlock()
+
+	if s.dependencies.byType == nil {
+		s.dependencies.byType = make(map[reflect.Type]any)
+	}
+
+	if _, exists := s.dependencies.byType[typ]; exists {
+		panic("service: Provide called twice for " + typ.String())
+	}
+
+	s.dependencies.byType[typ] = dependency
+	s.dependencies.order = append(s.dependencies.order, typ)
+
+	if stop := dependencyStop(dependency); stop != nil {
+		s.AddComponent(Component{Name: typ.String(), Stop: stop})
+	}
+}
+
+// dependencyStop returns the function releasing a dependency, or nil if it has nothing to release
+func dependencyStop(dependency any) func(ctx context.Context) error {
+	switch dependency := dependency.(type) {
+	case interface {
+		Shutdown(ctx context.Context) error
+	}:
+		return dependency.Shutdown
+	case io.Closer:
+		return func(context.Context) error { return dependency.Close() }
+	case interface{ Close() }:
+		return func(context.Context) error {
+			dependency.Close()
+			return nil
+		}
+	default:
+		return nil
+	}
+}
+
+// resolve returns the dependency of a type, or the first provided dependency assignable to it, e.g. for an
+// interface type
+func (d *dependencies) resolve(typ reflect.Type) (any, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if dependency, ok := d.byType[typ]; ok {
+		return dependency, true
+	}
+
+	if typ.Kind() == reflect.Interface {
+		for _, provided := range d.order {
+			if provided.Implements(typ) {
+				return d.byType[provided], true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Resolve returns the dependency of type T provided to the service (see Service.Provide), or one implementing the
+// interface T. It panics if there is none, as a missing dependency is a programming error.
+func Resolve[T any](r *http.Request) T {
+	dependency, ok := ResolveContext[T](r.Context())
+	if !ok {
+		panic("service: no dependency of type " + reflect.TypeFor[T]().String() + " provided")
+	}
+
+	return dependency
+}
+
+// ResolveContext returns the dependency of type T from a request context or the lifecycle context of the service,
+// e.g. in a worker, and reports whether it was provided
+func ResolveContext[T any](ctx context.Context) (T, bool) {
+	var zero T
+
+	deps, ok := ctx.Value(DependenciesKey).(*dependencies)
+	if !ok {
+		return zero, false
+	}
+
+	dependency, ok := deps.resolve(reflect.TypeFor[T]())
+	if !ok {
+		return zero, false
+	}
+
+	return dependency.(T), true //nolint:forcetypeassert
+}
+
+// dependenciesMiddleware injects the dependencies of the service into the request context
+func dependenciesMiddleware(deps *dependencies) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), DependenciesKey, deps)))
+		})
+	}
+}
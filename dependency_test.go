@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+type testStore struct {
+	name   string
+	closed *[]string
+}
+
+func (s *testStore) Get(key string) string { return s.name + ":" + key }
+
+func (s *testStore) Close() error {
+	*s.closed = append(*s.closed, s.name)
+	return nil
+}
+
+type testCache struct {
+	closed *[]string
+}
+
+func (c *testCache) Shutdown(context.Context) error {
+	*c.closed = append(*c.closed, "cache")
+	return nil
+}
+
+func TestProvide(t *testing.T) {
+	t.Parallel()
+
+	var closed []string
+
+	svc := New("dependency_test", nil)
+	svc.Provide(&testStore{name: "db", closed: &closed})
+	svc.Provide(&testCache{closed: &closed})
+	svc.Provide(42)
+
+	svc.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		store := Resolve[*testStore](r)
+		getter := Resolve[interface{ Get(key string) string }](r)
+
+		_, _ = fmt.Fprintf(w, "%s %s %d", store.Get(r.PathValue("id")), getter.Get("x"), Resolve[int](r))
+	})
+
+	rec := httptest.NewRecorder()
+	svc.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	if body := rec.Body.String(); body != "db:1 db:x 42" {
+		t.Errorf("unexpected response %q", body)
+	}
+
+	if _, ok := ResolveContext[*testCache](svc.Context()); !ok {
+		t.Error("expected dependencies in the lifecycle context")
+	}
+
+	if _, ok := ResolveContext[string](svc.Context()); ok {
+		t.Error("expected no dependency of a type that wasn't provided")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic when providing a type twice")
+			}
+		}()
+
+		svc.Provide(&testStore{name: "other", closed: &closed})
+	}()
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+
+	if !slices.Equal(closed, []string{"cache", "db"}) {
+		t.Errorf("expected dependencies to be closed in reverse order, got %v", closed)
+	}
+}
+
+func TestResolve_Missing(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a missing dependency")
+		}
+	}()
+
+	Resolve[*testStore](httptest.NewRequest(http.MethodGet, "/", nil))
+}
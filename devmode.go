@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ANSI escape codes used by the dev mode output
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// prettyHandler is a slog.Handler that writes colored, human-friendly log lines for local development
+type prettyHandler struct {
+	out    io.Writer
+	level  slog.Leveler
+	mu     *sync.Mutex
+	attrs  string
+	prefix string
+}
+
+// newPrettyHandler creates a new pretty log handler
+func newPrettyHandler(out io.Writer, level slog.Leveler) *prettyHandler {
+	return &prettyHandler{
+		out:   out,
+		level: level,
+		mu:    &sync.Mutex{},
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level
+func (h *prettyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle writes a log record as a single colored line
+func (h *prettyHandler) Handle(_ context.Context, record slog.Record) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(ansiDim + record.Time.Format(time.TimeOnly) + ansiReset + " ")
+	buf.WriteString(prettyLevel(record.Level) + " ")
+	buf.WriteString(ansiBold + record.Message + ansiReset)
+	buf.WriteString(h.attrs)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		writePrettyAttr(&buf, h.prefix, attr)
+		return true
+	})
+
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := h.out.Write(buf.Bytes())
+
+	return err //nolint:wrapcheck
+}
+
+// WithAttrs returns a handler that adds the attributes to every record
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var buf bytes.Buffer
+
+	for _, attr := range attrs {
+		writePrettyAttr(&buf, h.prefix, attr)
+	}
+
+	clone := *h
+	clone.attrs += buf.String()
+
+	return &clone
+}
+
+// WithGroup returns a handler that qualifies the keys of following attributes with the group name
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.prefix += name + "."
+
+	return &clone
+}
+
+// prettyLevel returns the colored short name of a log level
+func prettyLevel(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed + "ERR" + ansiReset
+	case level >= slog.LevelWarn:
+		return ansiYellow + "WRN" + ansiReset
+	case level >= slog.LevelInfo:
+		return ansiGreen + "INF" + ansiReset
+	default:
+		return ansiDim + "DBG" + ansiReset
+	}
+}
+
+// writePrettyAttr writes an attribute as a dimmed key and its value, flattening groups
+func writePrettyAttr(buf *bytes.Buffer, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+
+		for _, member := range attr.Value.Group() {
+			writePrettyAttr(buf, prefix, member)
+		}
+
+		return
+	}
+
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	fmt.Fprintf(buf, " %s%s%s=%s%v", ansiDim, prefix, attr.Key, ansiReset, attr.Value.Any())
+}
+
+// printBanner writes a human-friendly summary of the service and its route table
+func (s *Service) printBanner(out io.Writer) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "\n%s%s%s %s%s%s\n\n", ansiBold+ansiCyan, s.Name, ansiReset, ansiDim, s.Config.Version, ansiReset)
+
+	table := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "  HTTP\thttp://%s\n", displayAddr(s.Config.Addr))
+	fmt.Fprintf(table, "  Metrics\thttp://%s%s\n", displayAddr(s.Config.MetricsAddr), s.Config.MetricsPath)
+	fmt.Fprintf(table, "  Health\thttp://%s%s\n", displayAddr(s.Config.MetricsAddr), s.Config.HealthPath)
+	fmt.Fprintf(table, "  Subsystems\t%s\n", strings.Join(s.subsystems(), ", "))
+	_ = table.Flush()
+
+	buf.WriteString("\n  " + ansiBold + "Routes" + ansiReset + "\n")
+
+	for _, pattern := range s.Routes() {
+		buf.WriteString("    " + pattern + "\n")
+	}
+
+	if len(s.internalRoutes) > 0 {
+		buf.WriteString("\n  " + ansiBold + "Internal Routes" + ansiReset + " (" + displayAddr(s.Config.MetricsAddr) + ")\n")
+
+		for _, pattern := range s.InternalRoutes() {
+			buf.WriteString("    " + pattern + "\n")
+		}
+	}
+
+	buf.WriteByte('\n')
+
+	_, _ = out.Write(buf.Bytes())
+}
+
+// displayAddr returns a listen address that can be opened in a browser
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+
+	return addr
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPrettyHandler(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := slog.New(newPrettyHandler(&output, slog.LevelInfo))
+	logger.Debug("hidden")
+	logger.With("service", "api").WithGroup("req").Warn("slow request", "path", "/users", slog.Group("db", "queries", 3))
+
+	line := output.String()
+
+	if strings.Contains(line, "hidden") {
+		t.Error("expected debug records to be filtered")
+	}
+
+	for _, expected := range []string{"WRN", "slow request", "service=" + ansiReset + "api", "req.path=" + ansiReset + "/users", "req.db.queries=" + ansiReset + "3"} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("expected %q in %q", expected, line)
+		}
+	}
+}
+
+func TestPrintBanner(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.DevMode = true
+
+	svc := New("banner_test", config)
+	svc.HandleFunc("GET /users", func(http.ResponseWriter, *http.Request) {})
+	svc.HandleFunc("/admin", func(http.ResponseWriter, *http.Request) {})
+
+	if _, ok := svc.Logger.Handler().(*prettyHandler); !ok {
+		t.Error("expected dev mode to use the pretty log handler")
+	}
+
+	var output bytes.Buffer
+
+	svc.printBanner(&output)
+
+	for _, expected := range []string{"banner_test", "http://localhost:8080", "http://localhost:9090/metrics", "dev_mode", "/admin\n    GET /users"} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected %q in banner, got %s", expected, output.String())
+		}
+	}
+}
+
+func TestDevModeLogger(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.DevMode = true
+	defaultLogger := config.Logger
+
+	svc := New("dev_logger_test", config)
+
+	if config.Logger != defaultLogger {
+		t.Error("expected dev mode to leave the config of the caller unchanged")
+	}
+
+	if _, ok := svc.Logger.Handler().(*prettyHandler); !ok {
+		t.Error("expected dev mode to replace the default logger")
+	}
+
+	var output bytes.Buffer
+
+	config = DefaultConfig()
+	config.DevMode = true
+	config.Logger = slog.New(slog.NewTextHandler(&output, nil))
+
+	svc = New("dev-logger-test", config)
+
+	if svc.Logger != config.Logger {
+		t.Error("expected dev mode to keep the logger of the caller")
+	}
+
+	if strings.Contains(output.String(), "metric prefix") {
+		t.Errorf("expected no metric prefix warning in dev mode, got %s", output.String())
+	}
+}
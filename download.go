@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// downloadWriteTimeout is the time a write of a download may take. The write deadline is extended before every
+// write, so the server's WriteTimeout doesn't cut off large or rate-limited downloads, but stalled clients still do.
+const downloadWriteTimeout = 30 * time.Second
+
+// DownloadOptions holds options for ServeFile and ServeReader
+type DownloadOptions struct {
+	// Name is the file name suggested to clients with "Content-Disposition: attachment".
+	// ServeFile defaults to the base name of the file; ServeReader omits the header without a name.
+	Name string
+	// ModTime is used for Last-Modified and conditional requests. ServeFile defaults to the file modification time.
+	ModTime time.Time
+	// ETag is a strong entity tag, so clients can resume downloads with If-Range.
+	// ServeFile defaults to a tag derived from the file size and modification time.
+	ETag string
+	// RateLimit limits the transfer rate of each download in bytes per second (0 means unlimited)
+	RateLimit int64
+}
+
+// ServeFile serves a file as a download with Range and If-Range support, so interrupted downloads can be resumed.
+// Transfers are counted in {service_name}_downloads_total{result} and {service_name}_download_bytes_total.
+func ServeFile(w http.ResponseWriter, r *http.Request, path string, opts DownloadOptions) {
+	file, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		serveFileError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if opts.Name == "" {
+		opts.Name = filepath.Base(path)
+	}
+
+	if opts.ModTime.IsZero() {
+		opts.ModTime = info.ModTime()
+	}
+
+	if opts.ETag == "" {
+		opts.ETag = fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+	}
+
+	ServeReader(w, r, file, opts)
+}
+
+// ServeReader serves content as a download with Range and If-Range support, the transfer rate limit,
+// and transfer metrics. Content-Length is always set, so clients can show the progress. The server's WriteTimeout
+// doesn't apply to downloads; instead each write must finish within 30s.
+func ServeReader(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, opts DownloadOptions) {
+	if opts.Name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": opts.Name}))
+	}
+
+	if opts.ETag != "" {
+		w.Header().Set("ETag", opts.ETag)
+	}
+
+	writer := &downloadWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+		rate:           opts.RateLimit,
+		start:          time.Now(),
+		ctx:            r.Context(),
+		controller:     http.NewResponseController(w),
+	}
+
+	http.ServeContent(writer, r, opts.Name, opts.ModTime, content)
+
+	if metrics := GetMetrics(r); metrics != nil {
+		metrics.builtinCounterVec("downloads_total", "Total number of downloads by result", "result").
+			WithLabelValues(writer.result()).Inc()
+		metrics.builtinCounterVec("download_bytes_total", "Total number of bytes sent in downloads").
+			WithLabelValues().Add(float64(writer.written))
+	}
+}
+
+// serveFileError responds to a failure to open a file without leaking the file path
+func serveFileError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.NotFound(w, r)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	default:
+		GetLogger(r).Error("failed to open file", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// downloadWriter is a response writer that throttles the transfer rate and counts the bytes sent
+type downloadWriter struct {
+	http.ResponseWriter
+
+	status  int
+	rate    int64
+	start   time.Time
+	ctx     context.Context //nolint:containedctx
+	written int64
+	aborted bool
+
+	controller *http.ResponseController
+}
+
+// WriteHeader captures the status code
+func (d *downloadWriter) WriteHeader(code int) {
+	d.status = code
+	d.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the data in chunks and sleeps between chunks to stay within the rate limit
+func (d *downloadWriter) Write(data []byte) (int, error) {
+	if d.rate <= 0 {
+		d.extendDeadline()
+
+		n, err := d.ResponseWriter.Write(data)
+		d.record(n, err)
+
+		return n, err //nolint:wrapcheck
+	}
+
+	// Write at least ten chunks per second, so the transfer is smooth
+	chunk := max(d.rate/10, 1)
+	total := 0
+
+	for len(data) > 0 {
+		size := min(int64(len(data)), chunk)
+
+		d.extendDeadline()
+
+		n, err := d.ResponseWriter.Write(data[:size])
+		total += n
+		d.record(n, err)
+
+		if err != nil {
+			return total, err //nolint:wrapcheck
+		}
+
+		data = data[size:]
+
+		// Sleep until the bytes written so far are within the rate
+		wait := time.Duration(float64(d.written)/float64(d.rate)*float64(time.Second)) - time.Since(d.start)
+		if wait > 0 {
+			select {
+			case <-d.ctx.Done():
+				d.aborted = true
+				return total, d.ctx.Err() //nolint:wrapcheck
+			case <-time.After(wait):
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (d *downloadWriter) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
+
+// extendDeadline extends the write deadline of the connection for the next write. Writers that don't support
+// deadlines, e.g. in tests, keep the server's WriteTimeout.
+func (d *downloadWriter) extendDeadline() {
+	_ = d.controller.SetWriteDeadline(time.Now().Add(downloadWriteTimeout))
+}
+
+// record counts the bytes written and remembers write errors
+func (d *downloadWriter) record(n int, err error) {
+	d.written += int64(n)
+
+	if err != nil {
+		d.aborted = true
+	}
+}
+
+// result returns the result label of the download
+func (d *downloadWriter) result() string {
+	switch {
+	case d.aborted:
+		return "aborted"
+	case d.status == http.StatusOK:
+		return "complete"
+	case d.status == http.StatusPartialContent:
+		return "partial"
+	case d.status == http.StatusNotModified:
+		return "not_modified"
+	default:
+		return "error"
+	}
+}
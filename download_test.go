@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestServeFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "artifact.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := NewMetricsCollector("download_test")
+
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/download", nil)
+		req = req.WithContext(context.WithValue(req.Context(), MetricsKey, metrics))
+		req.Header = header
+
+		rec := httptest.NewRecorder()
+		ServeFile(rec, req, path, DownloadOptions{})
+
+		return rec
+	}
+
+	full := serve(http.Header{})
+	if full.Code != http.StatusOK || full.Body.String() != "0123456789" || full.Header().Get("Content-Length") != "10" {
+		t.Fatalf("expected the full file, got %d %q", full.Code, full.Body.String())
+	}
+
+	if got := full.Header().Get("Content-Disposition"); got != `attachment; filename=artifact.bin` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+
+	etag := full.Header().Get("ETag")
+
+	// Resume with a matching If-Range
+	resumed := serve(http.Header{"Range": {"bytes=4-"}, "If-Range": {etag}})
+	if resumed.Code != http.StatusPartialContent || resumed.Body.String() != "456789" {
+		t.Errorf("expected partial content 456789, got %d %q", resumed.Code, resumed.Body.String())
+	}
+
+	// A changed file restarts the download
+	changed := serve(http.Header{"Range": {"bytes=4-"}, "If-Range": {`"outdated"`}})
+	if changed.Code != http.StatusOK || changed.Body.String() != "0123456789" {
+		t.Errorf("expected the full file for a stale If-Range, got %d %q", changed.Code, changed.Body.String())
+	}
+
+	downloads := metrics.builtinCounterVec("downloads_total", "", "result")
+	if testutil.ToFloat64(downloads.WithLabelValues("complete")) != 2 || testutil.ToFloat64(downloads.WithLabelValues("partial")) != 1 {
+		t.Error("expected 2 complete and 1 partial download")
+	}
+
+	if got := testutil.ToFloat64(metrics.builtinCounterVec("download_bytes_total", "").WithLabelValues()); got != 26 {
+		t.Errorf("expected 26 bytes sent, got %v", got)
+	}
+
+	missing := httptest.NewRecorder()
+	ServeFile(missing, httptest.NewRequest(http.MethodGet, "/", nil), filepath.Join(t.TempDir(), "missing"), DownloadOptions{})
+
+	if missing.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing file, got %d", missing.Code)
+	}
+}
+
+func TestServeReaderRateLimit(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+
+	ServeReader(rec, httptest.NewRequest(http.MethodGet, "/", nil), strings.NewReader(strings.Repeat("x", 300)),
+		DownloadOptions{RateLimit: 1000})
+
+	if rec.Body.Len() != 300 {
+		t.Fatalf("expected 300 bytes, got %d", rec.Body.Len())
+	}
+
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected 300 bytes at 1000 B/s to take about 300ms, took %v", elapsed)
+	}
+}
+
+func TestServeReaderWriteTimeout(t *testing.T) {
+	t.Parallel()
+
+	svc := New("download_timeout_test", DefaultConfig())
+	svc.HandleFunc("GET /download", func(w http.ResponseWriter, r *http.Request) {
+		ServeReader(w, r, strings.NewReader(strings.Repeat("x", 300)), DownloadOptions{RateLimit: 1000})
+	}, WithTimeout(0))
+
+	server := httptest.NewUnstartedServer(svc.handler())
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("expected the download to outlive the write timeout, got %v after %d bytes", err, len(body))
+	}
+
+	if len(body) != 300 {
+		t.Errorf("expected 300 bytes, got %d", len(body))
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"net/http"
+	"time"
+)
+
+// drain marks the service as draining and waits for the pre-shutdown delay,
+// so load balancers and Kubernetes can notice the failing LB health and readiness endpoints and stop routing traffic
+func (s *Service) drain() {
+	if s.draining.Swap(true) {
+		return
+	}
+
+	if s.Config.DrainCloseConnections && s.server != nil {
+		// Responses carry "Connection: close", so clients don't reuse connections to this instance
+		s.server.SetKeepAlivesEnabled(false)
+	}
+
+	if s.Config.PreShutdownDelay <= 0 {
+		return
+	}
+
+	s.Logger.Info("draining before shutdown", "delay", s.Config.PreShutdownDelay)
+	time.Sleep(s.Config.PreShutdownDelay)
+}
+
+// IsDraining returns true once the service started draining connections before shutdown
+func (s *Service) IsDraining() bool {
+	return s.draining.Load()
+}
+
+// lbHealthHandler reports whether load balancers should route traffic to this instance
+func (s *Service) lbHealthHandler(w http.ResponseWriter, _ *http.Request) {
+	if s.IsDraining() {
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("Draining"))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK"))
+}
+
+// drainingReadiness fails the readiness endpoint while the service is draining, so Kubernetes removes the pod from
+// the endpoints of its services before the listeners close
+func (s *Service) drainingReadiness(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.IsDraining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("Draining"))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
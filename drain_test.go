@@ -0,0 +1,68 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.PreShutdownDelay = 50 * time.Millisecond
+
+	svc := New("drain_test", config)
+
+	recorder := httptest.NewRecorder()
+	svc.lbHealthHandler(recorder, httptest.NewRequest(http.MethodGet, "/lb-health", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status 200 before draining, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	svc.metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected readiness before draining, got %d", recorder.Code)
+	}
+
+	start := time.Now()
+	svc.drain()
+
+	if elapsed := time.Since(start); elapsed < config.PreShutdownDelay {
+		t.Errorf("expected drain to wait for the pre-shutdown delay, took %v", elapsed)
+	}
+
+	if !svc.IsDraining() {
+		t.Error("expected service to be draining")
+	}
+
+	recorder = httptest.NewRecorder()
+	svc.lbHealthHandler(recorder, httptest.NewRequest(http.MethodGet, "/lb-health", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 while draining, got %d", recorder.Code)
+	}
+
+	if recorder.Header().Get("Connection") != "close" {
+		t.Error("expected Connection: close while draining")
+	}
+
+	recorder = httptest.NewRecorder()
+	svc.metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to fail while draining, got %d", recorder.Code)
+	}
+
+	// Draining only happens once
+	start = time.Now()
+	svc.drain()
+
+	if elapsed := time.Since(start); elapsed >= config.PreShutdownDelay {
+		t.Errorf("expected second drain to return immediately, took %v", elapsed)
+	}
+}
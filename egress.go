@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrEgressBlocked is returned when an outbound request is blocked by the egress policy
+var ErrEgressBlocked = NewError(CodePermissionDenied, "blocked by egress policy")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which netip doesn't consider private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// EgressPolicy restricts the destinations an instrumented client may connect to.
+//
+// Destination addresses are validated when the connection is established, after DNS resolution,
+// so a hostname that resolves to a different address later (DNS rebinding) can't bypass the policy.
+// Proxies from the environment are ignored for clients with an egress policy. Transports other than *http.Transport
+// are only restricted by AllowedHosts.
+type EgressPolicy struct {
+	// AllowedHosts restricts requests to these hostnames. Entries like "*.example.com" match all subdomains.
+	// An empty list allows all hosts.
+	AllowedHosts []string
+	// AllowedCIDRs restricts connections to these address ranges. Addresses in these ranges are allowed
+	// even if they are private. An empty list allows all public addresses.
+	AllowedCIDRs []netip.Prefix
+	// AllowPrivate allows connections to private, loopback, and link-local addresses
+	AllowPrivate bool
+}
+
+// egressGuard enforces an egress policy for a client
+type egressGuard struct {
+	policy  EgressPolicy
+	client  string
+	blocked *prometheus.CounterVec
+	logger  *slog.Logger
+}
+
+// newEgressGuard creates a new egress guard
+func newEgressGuard(policy EgressPolicy, client string, metrics *MetricsCollector) *egressGuard {
+	guard := &egressGuard{
+		policy: policy,
+		client: client,
+		logger: slog.Default(),
+	}
+
+	if metrics != nil {
+		guard.logger = metrics.logger
+
+		guard.blocked = metrics.builtinCounterVec("egress_blocked_total",
+			"Total number of outbound requests blocked by the egress policy", "client", "reason")
+	}
+
+	return guard
+}
+
+// wrap restricts the connections of a transport to the egress policy. The dialer of the transport is kept, and
+// the address of each connection is validated once it is established. Other transports than *http.Transport can
+// only be restricted by the host allowlist.
+func (g *egressGuard) wrap(transport http.RoundTripper) http.RoundTripper {
+	httpTransport, ok := transport.(*http.Transport)
+	if !ok {
+		g.logger.Warn("egress policy can't validate the addresses of a custom transport, only the host allowlist applies",
+			"client", g.client, "transport", fmt.Sprintf("%T", transport))
+
+		return transport
+	}
+
+	httpTransport = httpTransport.Clone()
+	httpTransport.Proxy = nil
+
+	if httpTransport.DialContext == nil {
+		// Without a dialer of the caller, addresses are validated before the connection is established
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				return g.checkAddress(address)
+			},
+		}
+
+		httpTransport.DialContext = dialer.DialContext
+	}
+
+	httpTransport.DialContext = g.dial(httpTransport.DialContext)
+
+	if httpTransport.DialTLSContext != nil {
+		httpTransport.DialTLSContext = g.dial(httpTransport.DialTLSContext)
+	}
+
+	return httpTransport
+}
+
+// dial wraps a dial function, so connections to addresses outside the egress policy are closed before use
+func (g *egressGuard) dial(
+	dial func(ctx context.Context, network, address string) (net.Conn, error),
+) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := g.checkAddress(conn.RemoteAddr().String()); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
+}
+
+// checkAddress validates the resolved address of a connection
+func (g *egressGuard) checkAddress(address string) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return g.block("invalid_address", fmt.Sprintf("invalid address %q", address))
+	}
+
+	return g.checkAddr(addrPort.Addr())
+}
+
+// checkHost validates the hostname of a request against the host allowlist
+func (g *egressGuard) checkHost(host string) error {
+	if len(g.policy.AllowedHosts) == 0 {
+		return nil
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, allowed := range g.policy.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return nil
+			}
+		} else if host == allowed {
+			return nil
+		}
+	}
+
+	return g.block("host", fmt.Sprintf("host %q is not allowed", host))
+}
+
+// checkAddr validates a destination address against the CIDR allowlist and the private range rules
+func (g *egressGuard) checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+
+	for _, prefix := range g.policy.AllowedCIDRs {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	if len(g.policy.AllowedCIDRs) > 0 {
+		return g.block("cidr", fmt.Sprintf("address %s is not allowed", addr))
+	}
+
+	if !g.policy.AllowPrivate && isPrivateAddr(addr) {
+		return g.block("private_address", fmt.Sprintf("private address %s is not allowed", addr))
+	}
+
+	return nil
+}
+
+// block records a blocked request and returns the corresponding error
+func (g *egressGuard) block(reason, message string) error {
+	if g.blocked != nil {
+		g.blocked.WithLabelValues(g.client, reason).Inc()
+	}
+
+	return fmt.Errorf("%w: %s", ErrEgressBlocked, message)
+}
+
+// isPrivateAddr reports whether an address is not publicly routable
+func isPrivateAddr(addr netip.Addr) bool {
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		sharedAddressSpace.Contains(addr)
+}
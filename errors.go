@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Code represents a canonical error code, modeled after gRPC status codes
+type Code int
+
+// Canonical error codes. The numeric values match the gRPC status codes.
+const (
+	CodeOK Code = iota
+	CodeCanceled
+	CodeUnknown
+	CodeInvalidArgument
+	CodeDeadlineExceeded
+	CodeNotFound
+	CodeAlreadyExists
+	CodePermissionDenied
+	CodeResourceExhausted
+	CodeFailedPrecondition
+	CodeAborted
+	CodeOutOfRange
+	CodeUnimplemented
+	CodeInternal
+	CodeUnavailable
+	CodeDataLoss
+	CodeUnauthenticated
+)
+
+// Sentinel errors for each canonical error code.
+// Wrap them with fmt.Errorf("...: %w", ErrNotFound) or use NewError / WrapError to attach details.
+var (
+	ErrCanceled           = errors.New("canceled")
+	ErrUnknown            = errors.New("unknown")
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrDeadlineExceeded   = errors.New("deadline exceeded")
+	ErrNotFound           = errors.New("not found")
+	ErrAlreadyExists      = errors.New("already exists")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrResourceExhausted  = errors.New("resource exhausted")
+	ErrFailedPrecondition = errors.New("failed precondition")
+	ErrAborted            = errors.New("aborted")
+	ErrOutOfRange         = errors.New("out of range")
+	ErrUnimplemented      = errors.New("unimplemented")
+	ErrInternal           = errors.New("internal")
+	ErrUnavailable        = errors.New("unavailable")
+	ErrDataLoss           = errors.New("data loss")
+	ErrUnauthenticated    = errors.New("unauthenticated")
+)
+
+// StatusClientClosedRequest is the status recorded for requests canceled by the client (nginx convention)
+const StatusClientClosedRequest = 499
+
+// codeInfo holds the sentinel error and HTTP status code for a canonical error code
+type codeInfo struct {
+	name       string
+	sentinel   error
+	httpStatus int
+}
+
+var codes = map[Code]codeInfo{
+	CodeOK:                 {"ok", nil, http.StatusOK},
+	CodeCanceled:           {"canceled", ErrCanceled, StatusClientClosedRequest},
+	CodeUnknown:            {"unknown", ErrUnknown, http.StatusInternalServerError},
+	CodeInvalidArgument:    {"invalid_argument", ErrInvalidArgument, http.StatusBadRequest},
+	CodeDeadlineExceeded:   {"deadline_exceeded", ErrDeadlineExceeded, http.StatusGatewayTimeout},
+	CodeNotFound:           {"not_found", ErrNotFound, http.StatusNotFound},
+	CodeAlreadyExists:      {"already_exists", ErrAlreadyExists, http.StatusConflict},
+	CodePermissionDenied:   {"permission_denied", ErrPermissionDenied, http.StatusForbidden},
+	CodeResourceExhausted:  {"resource_exhausted", ErrResourceExhausted, http.StatusTooManyRequests},
+	CodeFailedPrecondition: {"failed_precondition", ErrFailedPrecondition, http.StatusBadRequest},
+	CodeAborted:            {"aborted", ErrAborted, http.StatusConflict},
+	CodeOutOfRange:         {"out_of_range", ErrOutOfRange, http.StatusBadRequest},
+	CodeUnimplemented:      {"unimplemented", ErrUnimplemented, http.StatusNotImplemented},
+	CodeInternal:           {"internal", ErrInternal, http.StatusInternalServerError},
+	CodeUnavailable:        {"unavailable", ErrUnavailable, http.StatusServiceUnavailable},
+	CodeDataLoss:           {"data_loss", ErrDataLoss, http.StatusInternalServerError},
+	CodeUnauthenticated:    {"unauthenticated", ErrUnauthenticated, http.StatusUnauthorized},
+}
+
+// sentinelCodes lists the codes with a sentinel error in the order CodeOf checks them
+var sentinelCodes = []Code{
+	CodeCanceled,
+	CodeUnknown,
+	CodeInvalidArgument,
+	CodeDeadlineExceeded,
+	CodeNotFound,
+	CodeAlreadyExists,
+	CodePermissionDenied,
+	CodeResourceExhausted,
+	CodeFailedPrecondition,
+	CodeAborted,
+	CodeOutOfRange,
+	CodeUnimplemented,
+	CodeInternal,
+	CodeUnavailable,
+	CodeDataLoss,
+	CodeUnauthenticated,
+}
+
+// String returns the snake_case name of the code
+func (c Code) String() string {
+	if info, ok := codes[c]; ok {
+		return info.name
+	}
+
+	return codes[CodeUnknown].name
+}
+
+// HTTPStatus returns the HTTP status code that corresponds to the code
+func (c Code) HTTPStatus() int {
+	if info, ok := codes[c]; ok {
+		return info.httpStatus
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Error is an error with a canonical code, a message, and optional details
+type Error struct {
+	Code    Code
+	Message string
+	Details map[string]any
+	Err     error
+}
+
+// NewError creates a new error with the given code and message
+func NewError(code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WrapError wraps an existing error with a code and message
+func WrapError(err error, code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// WithDetail attaches a detail to the error and returns it for chaining
+func (e *Error) WithDetail(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+
+	e.Details[key] = value
+
+	return e
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	parts := make([]string, 0, 2)
+
+	if e.Message != "" {
+		parts = append(parts, e.Message)
+	}
+
+	if e.Err != nil {
+		parts = append(parts, e.Err.Error())
+	}
+
+	if len(parts) == 0 {
+		return e.Code.String()
+	}
+
+	return strings.Join(parts, ": ")
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the sentinel error of the error's code
+func (e *Error) Is(target error) bool {
+	info, ok := codes[e.Code]
+
+	return ok && info.sentinel != nil && target == info.sentinel
+}
+
+// CodeOf returns the canonical code of an error.
+// It returns CodeOK for nil errors and CodeUnknown for errors without a known code.
+// Errors that wrap several sentinels resolve to the first one in code order.
+func CodeOf(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Code
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeDeadlineExceeded
+	}
+
+	for _, code := range sentinelCodes {
+		if errors.Is(err, codes[code].sentinel) {
+			return code
+		}
+	}
+
+	return CodeUnknown
+}
+
+// HTTPStatus maps an error to the corresponding HTTP status code
+func HTTPStatus(err error) int {
+	var statusErr statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status
+	}
+
+	return CodeOf(err).HTTPStatus()
+}
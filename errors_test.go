@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, CodeOK},
+		{"plain error", errors.New("boom"), CodeUnknown},
+		{"sentinel", ErrNotFound, CodeNotFound},
+		{"wrapped sentinel", fmt.Errorf("user 42: %w", ErrInvalidArgument), CodeInvalidArgument},
+		{"service error", NewError(CodeUnavailable, "database down"), CodeUnavailable},
+		{"wrapped service error", fmt.Errorf("query: %w", NewError(CodeAlreadyExists, "duplicate")), CodeAlreadyExists},
+		{"context canceled", context.Canceled, CodeCanceled},
+		{"context deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), CodeDeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := CodeOf(tt.err); got != tt.want {
+				t.Errorf("expected code %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCodeOf_MultipleSentinels(t *testing.T) {
+	t.Parallel()
+
+	errs := []error{
+		errors.Join(ErrUnavailable, ErrNotFound, ErrInvalidArgument),
+		errors.Join(ErrInvalidArgument, ErrUnavailable, ErrNotFound),
+		fmt.Errorf("lookup: %w: %w", ErrUnavailable, ErrInvalidArgument),
+	}
+
+	for _, err := range errs {
+		for range 100 {
+			if got := CodeOf(err); got != CodeInvalidArgument {
+				t.Fatalf("CodeOf(%v): expected %s, got %s", err, CodeInvalidArgument, got)
+			}
+
+			if got := HTTPStatus(err); got != http.StatusBadRequest {
+				t.Fatalf("HTTPStatus(%v): expected %d, got %d", err, http.StatusBadRequest, got)
+			}
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{ErrNotFound, http.StatusNotFound},
+		{ErrInvalidArgument, http.StatusBadRequest},
+		{ErrUnavailable, http.StatusServiceUnavailable},
+		{ErrUnauthenticated, http.StatusUnauthorized},
+		{ErrPermissionDenied, http.StatusForbidden},
+		{ErrResourceExhausted, http.StatusTooManyRequests},
+		{ErrDeadlineExceeded, http.StatusGatewayTimeout},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := HTTPStatus(tt.err); got != tt.want {
+			t.Errorf("HTTPStatus(%v): expected %d, got %d", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("matches sentinel", func(t *testing.T) {
+		t.Parallel()
+
+		err := NewError(CodeNotFound, "user not found")
+
+		if !errors.Is(err, ErrNotFound) {
+			t.Error("expected error to match ErrNotFound")
+		}
+
+		if errors.Is(err, ErrInvalidArgument) {
+			t.Error("expected error not to match ErrInvalidArgument")
+		}
+	})
+
+	t.Run("wraps cause", func(t *testing.T) {
+		t.Parallel()
+
+		cause := errors.New("connection refused")
+		err := WrapError(cause, CodeUnavailable, "database unavailable")
+
+		if !errors.Is(err, cause) {
+			t.Error("expected error to match the wrapped cause")
+		}
+
+		if err.Error() != "database unavailable: connection refused" {
+			t.Errorf("unexpected error message: %s", err.Error())
+		}
+	})
+
+	t.Run("details", func(t *testing.T) {
+		t.Parallel()
+
+		err := NewError(CodeInvalidArgument, "invalid email").WithDetail("field", "email")
+
+		if err.Details["field"] != "email" {
+			t.Errorf("expected detail 'email', got %v", err.Details["field"])
+		}
+	})
+
+	t.Run("empty message", func(t *testing.T) {
+		t.Parallel()
+
+		err := NewError(CodeAborted, "")
+
+		if err.Error() != "aborted" {
+			t.Errorf("expected 'aborted', got %s", err.Error())
+		}
+	})
+}
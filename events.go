@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrEventSinkFailed is returned when request events could not be written to a sink
+var ErrEventSinkFailed = NewError(CodeUnavailable, "failed to write request events")
+
+// RequestEvent is the compact record of a completed request emitted by EventMiddleware
+type RequestEvent struct {
+	Time       time.Time `json:"ts"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route,omitempty"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Tenant     string    `json:"tenant,omitempty"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// EventSink receives batches of request events, e.g. to forward them to an analytics system
+type EventSink interface {
+	WriteEvents(ctx context.Context, events []RequestEvent) error
+}
+
+// WriterEventSink writes request events as JSON lines to a writer, e.g. a file, a UDP connection, or an adapter of
+// a message queue producer. Each event is written with its own Write call, so datagram-based writers receive one
+// event per datagram.
+type WriterEventSink struct {
+	Writer io.Writer
+}
+
+// NewFileEventSink creates a sink appending request events to a file
+func NewFileEventSink(path string) (*WriterEventSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEventSinkFailed, err)
+	}
+
+	return &WriterEventSink{Writer: file}, nil
+}
+
+// NewUDPEventSink creates a sink sending each request event as a datagram to a UDP address, e.g. of a log shipper
+func NewUDPEventSink(addr string) (*WriterEventSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEventSinkFailed, err)
+	}
+
+	return &WriterEventSink{Writer: conn}, nil
+}
+
+// WriteEvents writes the events as JSON lines
+func (s *WriterEventSink) WriteEvents(_ context.Context, events []RequestEvent) error {
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrEventSinkFailed, err)
+		}
+
+		if _, err := s.Writer.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("%w: %w", ErrEventSinkFailed, err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the writer, if it is closable
+func (s *WriterEventSink) Close() error {
+	if closer, ok := s.Writer.(io.Closer); ok {
+		return closer.Close() //nolint:wrapcheck
+	}
+
+	return nil
+}
+
+// EventPipelineConfig holds configuration for the request event pipeline
+type EventPipelineConfig struct {
+	// Sink receives the events
+	Sink EventSink
+	// BufferSize is the number of events buffered for the sink; further events are dropped. Defaults to 10000.
+	BufferSize int
+	// BatchSize is the maximum number of events written to the sink at once. Defaults to 100.
+	BatchSize int
+	// FlushInterval is the maximum time an event waits for its batch to fill up. Defaults to 1s.
+	FlushInterval time.Duration
+	// Tenant extracts the tenant of a request. Defaults to the tenant of TenantMiddleware (see TenantID).
+	Tenant TenantExtractor
+}
+
+// EventPipeline emits one RequestEvent per request to a sink, separately from the logs. Events are buffered and
+// written in batches by a background goroutine; when the sink can't keep up and the buffer is full, events are
+// dropped instead of stalling requests. Events are counted in {service_name}_request_events_total{result} (written,
+// failed, or dropped), and the buffered events are exported as {service_name}_request_events_buffered.
+type EventPipeline struct {
+	config   EventPipelineConfig
+	events   chan RequestEvent
+	results  *prometheus.CounterVec
+	buffered prometheus.Gauge
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+// NewEventPipeline creates a request event pipeline and starts writing events to its sink
+func NewEventPipeline(metrics *MetricsCollector, config EventPipelineConfig) *EventPipeline {
+	if config.BufferSize <= 0 {
+		config.BufferSize = 10000
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+
+	if config.Tenant == nil {
+		config.Tenant = func(r *http.Request) (string, bool) {
+			tenant := TenantID(r)
+			return tenant, tenant != ""
+		}
+	}
+
+	pipeline := &EventPipeline{
+		config: config,
+		events: make(chan RequestEvent, config.BufferSize),
+		results: metrics.builtinCounterVec("request_events_total",
+			"Total number of request events by result", "result"),
+		buffered: metrics.builtinGaugeVec("request_events_buffered",
+			"Current number of request events waiting for the sink").WithLabelValues(),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go pipeline.run()
+
+	return pipeline
+}
+
+// Emit adds an event to the pipeline without blocking. It reports false if the event was dropped because the buffer
+// is full or the pipeline is closed.
+func (p *EventPipeline) Emit(event RequestEvent) bool {
+	select {
+	case <-p.closing:
+		p.results.WithLabelValues("dropped").Inc()
+		return false
+	default:
+	}
+
+	select {
+	case p.events <- event:
+		p.buffered.Inc()
+		return true
+	default:
+		p.results.WithLabelValues("dropped").Inc()
+		return false
+	}
+}
+
+// run writes the buffered events in batches until the pipeline is closed
+func (p *EventPipeline) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]RequestEvent, 0, p.config.BatchSize)
+
+	for {
+		select {
+		case event := <-p.events:
+			batch = append(batch, event)
+			if len(batch) >= p.config.BatchSize {
+				batch = p.write(batch)
+			}
+		case <-ticker.C:
+			batch = p.write(batch)
+		case <-p.closing:
+			// Write the events emitted before the pipeline was closed
+			for {
+				select {
+				case event := <-p.events:
+					batch = append(batch, event)
+					if len(batch) >= p.config.BatchSize {
+						batch = p.write(batch)
+					}
+				default:
+					p.write(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// write writes a batch to the sink and returns the emptied batch. Failed batches are dropped, so a broken sink
+// can't grow the memory of the service.
+func (p *EventPipeline) write(batch []RequestEvent) []RequestEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	p.buffered.Sub(float64(len(batch)))
+
+	if err := p.config.Sink.WriteEvents(context.Background(), batch); err != nil {
+		p.results.WithLabelValues("failed").Add(float64(len(batch)))
+	} else {
+		p.results.WithLabelValues("written").Add(float64(len(batch)))
+	}
+
+	return batch[:0]
+}
+
+// Close stops accepting events and waits until the buffered events are written or the context expires.
+// A sink implementing io.Closer is closed afterwards.
+func (p *EventPipeline) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() { close(p.closing) })
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrEventSinkFailed, ctx.Err())
+	}
+
+	if closer, ok := p.config.Sink.(io.Closer); ok {
+		return closer.Close() //nolint:wrapcheck
+	}
+
+	return nil
+}
+
+// EventMiddleware emits a RequestEvent to the pipeline for every completed request. Requests canceled by the client
+// are recorded with status 499.
+func EventMiddleware(pipeline *EventPipeline) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := &accessLogWriter{ResponseWriter: w}
+
+			next.ServeHTTP(wrapped, r)
+
+			status := wrapped.status()
+			if ClientCanceled(r) {
+				status = StatusClientClosedRequest
+			}
+
+			tenant, _ := pipeline.config.Tenant(r)
+
+			pipeline.Emit(RequestEvent{
+				Time:       start,
+				Method:     r.Method,
+				Route:      RoutePattern(r),
+				Path:       r.URL.Path,
+				Status:     status,
+				Bytes:      wrapped.written.Load(),
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+				RequestID:  RequestID(r),
+				Tenant:     tenant,
+				ClientIP:   clientIP(r),
+				UserAgent:  r.UserAgent(),
+			})
+		})
+	}
+}
+
+// eventSink returns the sink of the request event pipeline configured in the environment, or nil without one
+func (s *Service) eventSink() EventSink {
+	if s.Config.RequestEventSink != nil {
+		return s.Config.RequestEventSink
+	}
+
+	var (
+		sink EventSink
+		err  error
+	)
+
+	switch {
+	case s.Config.RequestEventsFile != "":
+		sink, err = NewFileEventSink(s.Config.RequestEventsFile)
+	case s.Config.RequestEventsUDPAddr != "":
+		sink, err = NewUDPEventSink(s.Config.RequestEventsUDPAddr)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		s.Logger.Error("failed to create request event sink, request events are disabled", "error", err)
+		return nil
+	}
+
+	return sink
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// memoryEventSink collects request events and blocks writes while it is paused
+type memoryEventSink struct {
+	mu     sync.Mutex
+	events []RequestEvent
+	paused chan struct{}
+}
+
+func (s *memoryEventSink) WriteEvents(_ context.Context, events []RequestEvent) error {
+	if s.paused != nil {
+		<-s.paused
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+func (s *memoryEventSink) written() []RequestEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]RequestEvent(nil), s.events...)
+}
+
+func TestEventMiddleware(t *testing.T) {
+	t.Parallel()
+
+	sink := &memoryEventSink{}
+	config := DefaultConfig()
+	config.RequestEventSink = sink
+	svc := New("events_test", config)
+
+	svc.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("user"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	svc.handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := svc.Events.Close(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	events := sink.written()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+	if event.Route != "GET /users/{id}" || event.Path != "/users/42" || event.Status != http.StatusOK ||
+		event.Bytes != 4 || event.RequestID != "req-1" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestEventPipelineBackpressure(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("events_backpressure_test")
+	sink := &memoryEventSink{paused: make(chan struct{})}
+	pipeline := NewEventPipeline(metrics, EventPipelineConfig{Sink: sink, BufferSize: 2, BatchSize: 1})
+
+	// The first event is taken by the blocked sink, two are buffered, and the rest is dropped
+	pipeline.Emit(RequestEvent{Path: "/1"})
+
+	deadline := time.Now().Add(time.Second)
+	for len(pipeline.events) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, path := range []string{"/2", "/3", "/4", "/5"} {
+		pipeline.Emit(RequestEvent{Path: path})
+	}
+
+	close(sink.paused)
+
+	if err := pipeline.Close(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if events := sink.written(); len(events) != 3 {
+		t.Errorf("expected 3 written events, got %d", len(events))
+	}
+
+	results := metrics.builtinCounterVec("request_events_total", "", "result")
+	if got := testutil.ToFloat64(results.WithLabelValues("dropped")); got != 2 {
+		t.Errorf("expected 2 dropped events, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(results.WithLabelValues("written")); got != 3 {
+		t.Errorf("expected 3 written events, got %v", got)
+	}
+
+	if pipeline.Emit(RequestEvent{}) {
+		t.Error("expected events to be dropped after Close")
+	}
+}
+
+func TestWriterEventSink(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	sink := &WriterEventSink{Writer: &buf}
+	if err := sink.WriteEvents(t.Context(), []RequestEvent{{Path: "/a", Status: 200}, {Path: "/b", Status: 404}}); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := json.NewDecoder(&buf)
+
+	for _, want := range []string{"/a", "/b"} {
+		var event RequestEvent
+		if err := decoder.Decode(&event); err != nil || event.Path != want {
+			t.Errorf("expected the event of %s, got %+v (%v)", want, event, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Errors returned by FetchURL. Requests blocked by the egress policy fail with ErrEgressBlocked.
+var (
+	ErrFetchInvalidURL  = NewError(CodeInvalidArgument, "invalid fetch URL")
+	ErrFetchTooLarge    = NewError(CodeOutOfRange, "fetched response too large")
+	ErrFetchTimeout     = NewError(CodeDeadlineExceeded, "fetch timed out")
+	ErrFetchTooManyHops = NewError(CodeFailedPrecondition, "too many redirects")
+)
+
+// FetchOptions configures FetchURL
+type FetchOptions struct {
+	// Timeout limits the whole fetch, including reading the body. Defaults to 10 seconds.
+	Timeout time.Duration
+	// MaxBytes limits the size of the response body. Defaults to 10 MiB.
+	MaxBytes int64
+	// MaxRedirects limits the number of redirects that are followed. Defaults to 5.
+	MaxRedirects int
+	// Header is added to the request
+	Header http.Header
+	// Egress restricts the destinations. The zero value rejects private, loopback, and link-local addresses.
+	Egress EgressPolicy
+}
+
+// FetchResult is the response of FetchURL
+type FetchResult struct {
+	// URL is the final URL after following redirects
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// FetchURL fetches a user-provided URL safely, e.g. for webhooks or link previews.
+// The destination is validated against the egress policy after DNS resolution (including all redirects),
+// and the response is limited in size and time.
+func FetchURL(ctx context.Context, rawURL string, opts FetchOptions) (*FetchResult, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 10 << 20
+	}
+
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = 5
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return nil, fmt.Errorf("%w: %q", ErrFetchInvalidURL, rawURL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	client := NewClient(nil, ClientConfig{Name: "fetch", Egress: &opts.Egress})
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > opts.MaxRedirects {
+			return ErrFetchTooManyHops
+		}
+
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("%w: redirect to %q", ErrFetchInvalidURL, req.URL.String())
+		}
+
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchInvalidURL, err)
+	}
+
+	for key, values := range opts.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fetchError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > opts.MaxBytes {
+		return nil, fmt.Errorf("%w: content length %d exceeds %d bytes", ErrFetchTooLarge, resp.ContentLength, opts.MaxBytes)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, opts.MaxBytes+1))
+	if err != nil {
+		return nil, fetchError(ctx, err)
+	}
+
+	if int64(len(body)) > opts.MaxBytes {
+		return nil, fmt.Errorf("%w: body exceeds %d bytes", ErrFetchTooLarge, opts.MaxBytes)
+	}
+
+	return &FetchResult{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// fetchError converts an error of a fetch into a typed error
+func fetchError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrEgressBlocked), errors.Is(err, ErrFetchInvalidURL), errors.Is(err, ErrFetchTooManyHops):
+		return err
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrFetchTimeout, err)
+	default:
+		return WrapError(err, CodeUnavailable, "fetch failed")
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchURL(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Write([]byte(strings.Repeat("a", 2048)))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	allowLocal := EgressPolicy{AllowPrivate: true}
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+
+		result, err := FetchURL(context.Background(), upstream.URL+"/redirect", FetchOptions{Egress: allowLocal})
+		if err != nil {
+			t.Fatalf("fetch failed: %v", err)
+		}
+
+		if string(result.Body) != "hello" || result.StatusCode != http.StatusOK {
+			t.Errorf("unexpected result: %d %q", result.StatusCode, result.Body)
+		}
+
+		if !strings.HasSuffix(result.URL, "/ok") {
+			t.Errorf("expected final URL to end with /ok, got %s", result.URL)
+		}
+	})
+
+	t.Run("private address rejected by default", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := FetchURL(context.Background(), upstream.URL, FetchOptions{})
+		if !errors.Is(err, ErrEgressBlocked) {
+			t.Errorf("expected ErrEgressBlocked, got %v", err)
+		}
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		t.Parallel()
+
+		for _, rawURL := range []string{"file:///etc/passwd", "gopher://example.com", "http://", "::"} {
+			_, err := FetchURL(context.Background(), rawURL, FetchOptions{})
+			if !errors.Is(err, ErrFetchInvalidURL) {
+				t.Errorf("expected ErrFetchInvalidURL for %q, got %v", rawURL, err)
+			}
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := FetchURL(context.Background(), upstream.URL+"/large", FetchOptions{Egress: allowLocal, MaxBytes: 1024})
+		if !errors.Is(err, ErrFetchTooLarge) {
+			t.Errorf("expected ErrFetchTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := FetchURL(context.Background(), upstream.URL+"/slow", FetchOptions{Egress: allowLocal, Timeout: 50 * time.Millisecond})
+		if !errors.Is(err, ErrFetchTimeout) {
+			t.Errorf("expected ErrFetchTimeout, got %v", err)
+		}
+
+		if HTTPStatus(err) != http.StatusGatewayTimeout {
+			t.Errorf("expected status 504, got %d", HTTPStatus(err))
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RouteGroup registers routes under a path prefix with their own middleware stack on top of the service-wide
+// middleware, e.g. auth middleware for /admin routes only
+type RouteGroup struct {
+	service     *Service
+	parent      *RouteGroup
+	prefix      string
+	mux         *http.ServeMux
+	middlewares []Middleware
+	routes      *[]string
+	timeout     *time.Duration
+}
+
+// Group returns a route group of the main server. Patterns registered on the group are prefixed with the prefix,
+// e.g. "GET /users" on Group("/admin") becomes "GET /admin/users".
+func (s *Service) Group(prefix string) *RouteGroup {
+	return &RouteGroup{
+		service: s,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		mux:     s.mux,
+		routes:  &s.routes,
+	}
+}
+
+// Group returns a nested route group with the prefix appended to the prefix of the group.
+// Routes of the nested group run the middleware of the group first.
+func (g *RouteGroup) Group(prefix string) *RouteGroup {
+	return &RouteGroup{
+		service: g.service,
+		parent:  g,
+		prefix:  g.prefix + strings.TrimSuffix(prefix, "/"),
+		mux:     g.mux,
+		routes:  g.routes,
+	}
+}
+
+// Internal returns the route group of the metrics/admin listener (METRICS_ADDR), so debug and admin routes
+// can't accidentally be exposed on the public port. With INTERNAL_ALLOWED_NETWORKS, internal routes
+// additionally only accept clients from these networks.
+func (s *Service) Internal() *RouteGroup {
+	return s.internal
+}
+
+// newInternalGroup creates the route group of the metrics/admin listener
+func (s *Service) newInternalGroup() *RouteGroup {
+	group := &RouteGroup{
+		service: s,
+		mux:     http.NewServeMux(),
+		routes:  &s.internalRoutes,
+	}
+
+	if len(s.Config.InternalAllowedNetworks) > 0 {
+		group.Use(AllowNetworksMiddleware(s.parseNetworks("internal allowed network", s.Config.InternalAllowedNetworks)))
+	}
+
+	return group
+}
+
+// Use adds middleware to all routes of the group and its nested groups, including routes registered before
+func (g *RouteGroup) Use(middleware Middleware) {
+	g.middlewares = append(g.middlewares, middleware)
+}
+
+// SetTimeout sets the request deadline of the routes of the group and its nested groups (see TimeoutMiddleware),
+// including routes registered before. Nested groups and routes can override it; 0 disables the deadline.
+func (g *RouteGroup) SetTimeout(timeout time.Duration) {
+	g.timeout = &timeout
+}
+
+// chain returns the middleware of the group, after the middleware of its parent groups
+func (g *RouteGroup) chain() []Middleware {
+	if g == nil {
+		return nil
+	}
+
+	return slices.Concat(g.parent.chain(), g.middlewares)
+}
+
+// HandleFunc registers a handler function for the given pattern
+func (g *RouteGroup) HandleFunc(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
+	g.Handle(pattern, handler, opts...)
+}
+
+// Handle registers a handler for the given pattern
+func (g *RouteGroup) Handle(pattern string, handler http.Handler, opts ...RouteOption) {
+	pattern = prefixPattern(g.prefix, pattern)
+
+	g.mux.Handle(pattern, g.service.buildRoute(pattern, handler, g, opts...))
+	*g.routes = append(*g.routes, pattern)
+}
+
+// prefixPattern inserts the prefix before the path of a pattern ("[METHOD ][HOST]/PATH")
+func prefixPattern(prefix, pattern string) string {
+	if i := strings.IndexByte(pattern, '/'); i >= 0 && prefix != "" {
+		return pattern[:i] + prefix + pattern[i:]
+	}
+
+	return pattern
+}
+
+// AllowNetworksMiddleware rejects requests from clients outside the given networks with 403.
+// The client address is taken from the connection, not from forwarding headers.
+func AllowNetworksMiddleware(networks []netip.Prefix) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+
+			addr, err := netip.ParseAddr(host)
+			if err == nil {
+				addr = addr.Unmap()
+
+				for _, network := range networks {
+					if network.Contains(addr) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// parseNetworks parses networks in CIDR notation. Invalid networks are logged and skipped, so they fail closed.
+func (s *Service) parseNetworks(kind string, networks []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			s.Logger.Error("invalid "+kind, "network", network, "error", err)
+			continue
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes
+}
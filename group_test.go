@@ -0,0 +1,180 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestInternalRoutes(t *testing.T) {
+	t.Parallel()
+
+	svc := New("internal_test", nil)
+
+	var groupMiddleware bool
+
+	internal := svc.Internal()
+	internal.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			groupMiddleware = true
+
+			next.ServeHTTP(w, r)
+		})
+	})
+	internal.HandleFunc("/debug/cache", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("cache"))
+	})
+
+	rec := httptest.NewRecorder()
+	svc.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected internal route not to be served on the public port, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	internal.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "cache" {
+		t.Errorf("expected internal route to be served, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if !groupMiddleware {
+		t.Error("expected group middleware to run")
+	}
+
+	if len(svc.internalRoutes) != 1 || len(svc.routes) != 0 {
+		t.Errorf("expected 1 internal and 0 public routes, got %v and %v", svc.internalRoutes, svc.routes)
+	}
+}
+
+func TestInternalAllowedNetworks(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.InternalAllowedNetworks = []string{"10.0.0.0/8"}
+
+	svc := New("internal_allowlist_test", config)
+	svc.Internal().HandleFunc("/debug", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := map[string]int{
+		"10.1.2.3:1234":          http.StatusNoContent,
+		"[::ffff:10.1.2.3]:1234": http.StatusNoContent,
+		"192.168.1.1:1234":       http.StatusForbidden,
+		"not an address":         http.StatusForbidden,
+	}
+
+	for remoteAddr, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/debug", nil)
+		req.RemoteAddr = remoteAddr
+
+		rec := httptest.NewRecorder()
+		svc.internal.mux.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", remoteAddr, want, rec.Code)
+		}
+	}
+}
+
+func TestAllowNetworksMiddlewareEmpty(t *testing.T) {
+	t.Parallel()
+
+	handler := AllowNetworksMiddleware([]netip.Prefix{})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected an empty allowlist to reject all clients, got %d", rec.Code)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	t.Parallel()
+
+	svc := New("group_test", nil)
+
+	var calls []string
+
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(RoutePattern(r)))
+	}
+
+	svc.HandleFunc("GET /public", ok)
+
+	admin := svc.Group("/admin/")
+	admin.Use(record("admin"))
+	admin.HandleFunc("GET /users", ok)
+
+	audit := admin.Group("/audit")
+	audit.HandleFunc("GET /events", ok)
+	audit.Use(record("audit"))
+
+	// Middleware added after the routes were registered applies as well
+	svc.Use(record("service"))
+
+	tests := []struct {
+		path    string
+		pattern string
+		calls   []string
+	}{
+		{path: "/public", pattern: "GET /public", calls: []string{"service"}},
+		{path: "/admin/users", pattern: "GET /admin/users", calls: []string{"service", "admin"}},
+		{path: "/admin/audit/events", pattern: "GET /admin/audit/events", calls: []string{"service", "admin", "audit"}},
+	}
+
+	for _, tt := range tests {
+		calls = nil
+
+		rec := httptest.NewRecorder()
+		svc.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Code != http.StatusOK || rec.Body.String() != tt.pattern {
+			t.Errorf("%s: expected route %q, got %d %q", tt.path, tt.pattern, rec.Code, rec.Body.String())
+		}
+
+		if !slices.Equal(calls, tt.calls) {
+			t.Errorf("%s: expected middleware %v, got %v", tt.path, tt.calls, calls)
+		}
+	}
+
+	if routes := svc.Routes(); len(routes) != 3 || routes[0] != "GET /admin/audit/events" {
+		t.Errorf("expected the prefixed patterns in the routes, got %v", routes)
+	}
+}
+
+func TestPrefixPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		prefix, pattern, expected string
+	}{
+		{"/api", "/users", "/api/users"},
+		{"/api", "GET /users/{id}", "GET /api/users/{id}"},
+		{"/api", "example.com/users", "example.com/api/users"},
+		{"/api", "POST example.com/", "POST example.com/api/"},
+		{"", "/users", "/users"},
+	}
+
+	for _, tt := range tests {
+		if got := prefixPattern(tt.prefix, tt.pattern); got != tt.expected {
+			t.Errorf("prefixPattern(%q, %q) = %q, expected %q", tt.prefix, tt.pattern, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// spoofableHeaders are set by proxies to describe the original request, and are stripped from untrusted clients
+var spoofableHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Forwarded-Port",
+	"X-Forwarded-Prefix",
+	"X-Real-IP",
+	"X-Client-IP",
+	"X-Original-URL",
+	"X-Rewrite-URL",
+	// Proxy queue time of the load shedder (see LoadSheddingMiddleware)
+	"X-Request-Start",
+	// Shadow requests skip side effects (see IsShadow)
+	"X-Shadow",
+}
+
+// forwardedHeaderValidators validate the values of forwarding headers sent by trusted proxies
+var forwardedHeaderValidators = map[string]func(value string) bool{
+	"X-Forwarded-For": func(value string) bool {
+		for _, addr := range strings.Split(value, ",") {
+			if _, err := netip.ParseAddr(strings.TrimSpace(addr)); err != nil {
+				return false
+			}
+		}
+
+		return true
+	},
+	"X-Real-IP": func(value string) bool {
+		_, err := netip.ParseAddr(strings.TrimSpace(value))
+		return err == nil
+	},
+	"X-Forwarded-Proto": func(value string) bool {
+		return strings.EqualFold(value, "http") || strings.EqualFold(value, "https")
+	},
+	"X-Forwarded-Port": func(value string) bool {
+		port, err := strconv.ParseUint(value, 10, 16)
+		return err == nil && port > 0
+	},
+}
+
+// HeaderSanitizationConfig holds configuration for the header sanitization middleware
+type HeaderSanitizationConfig struct {
+	// TrustedProxies are the networks of proxies that may set forwarding and internal headers
+	TrustedProxies []netip.Prefix
+	// InternalHeaders are set by a trusted proxy, e.g. the user of an authenticating gateway, and are stripped
+	// from untrusted clients like the forwarding headers
+	InternalHeaders []string
+	// RequestIDHeader is the header of request IDs, removed if its value isn't a valid request ID
+	RequestIDHeader string
+}
+
+// trusted reports whether the connection of a request comes from a trusted proxy
+func (c HeaderSanitizationConfig) trusted(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, network := range c.TrustedProxies {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HeaderSanitizationMiddleware strips the forwarding headers (X-Forwarded-*, Forwarded, X-Real-IP, ...) and the
+// internal headers from clients outside the trusted proxies, so they can't spoof their address, scheme, or identity.
+// Forwarding headers of trusted proxies with invalid values and invalid request IDs are stripped as well.
+// Stripped headers are counted in {service_name}_sanitized_headers_total{header}.
+// Register it with Service.UseBeforeRouting, so no other middleware sees the spoofed headers.
+func HeaderSanitizationMiddleware(metrics *MetricsCollector, config HeaderSanitizationConfig) Middleware {
+	var stripped *prometheus.CounterVec
+	if metrics != nil {
+		stripped = metrics.builtinCounterVec("sanitized_headers_total",
+			"Total number of request headers stripped from untrusted or invalid sources", "header")
+	}
+
+	untrusted := append(append([]string(nil), spoofableHeaders...), config.InternalHeaders...)
+
+	strip := func(r *http.Request, header string) {
+		if _, ok := r.Header[http.CanonicalHeaderKey(header)]; ok {
+			r.Header.Del(header)
+
+			if stripped != nil {
+				stripped.WithLabelValues(http.CanonicalHeaderKey(header)).Inc()
+			}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.Clone(r.Context())
+
+			if config.trusted(r) {
+				for header, valid := range forwardedHeaderValidators {
+					if value := r.Header.Get(header); value != "" && !valid(value) {
+						strip(r, header)
+					}
+				}
+			} else {
+				for _, header := range untrusted {
+					strip(r, header)
+				}
+			}
+
+			if config.RequestIDHeader != "" {
+				if id := r.Header.Get(config.RequestIDHeader); id != "" && !validRequestID(id) {
+					strip(r, config.RequestIDHeader)
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
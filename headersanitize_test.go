@@ -0,0 +1,63 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHeaderSanitizationMiddleware(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("sanitize_test")
+
+	var received http.Header
+
+	handler := HeaderSanitizationMiddleware(metrics, HeaderSanitizationConfig{
+		TrustedProxies:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		InternalHeaders: []string{"X-User-ID"},
+		RequestIDHeader: DefaultRequestIDHeader,
+	})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		value      string
+		kept       bool
+	}{
+		{name: "untrusted forwarded for", remoteAddr: "203.0.113.1:1234", header: "X-Forwarded-For", value: "10.0.0.1"},
+		{name: "untrusted internal header", remoteAddr: "203.0.113.1:1234", header: "X-User-ID", value: "admin"},
+		{name: "trusted forwarded for", remoteAddr: "10.1.2.3:1234", header: "X-Forwarded-For", value: "203.0.113.1, 10.0.0.1", kept: true},
+		{name: "trusted internal header", remoteAddr: "10.1.2.3:1234", header: "X-User-ID", value: "admin", kept: true},
+		{name: "trusted invalid proto", remoteAddr: "10.1.2.3:1234", header: "X-Forwarded-Proto", value: "gopher"},
+		{name: "valid request ID", remoteAddr: "203.0.113.1:1234", header: DefaultRequestIDHeader, value: "req-1", kept: true},
+		{name: "invalid request ID", remoteAddr: "203.0.113.1:1234", header: DefaultRequestIDHeader, value: "req 1\x00"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set(tt.header, tt.value)
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if kept := received.Get(tt.header) == tt.value; kept != tt.kept {
+			t.Errorf("%s: expected kept=%v, got %q", tt.name, tt.kept, received.Get(tt.header))
+		}
+
+		if req.Header.Get(tt.header) != tt.value {
+			t.Errorf("%s: expected the headers of the original request to be unchanged", tt.name)
+		}
+	}
+
+	stripped := metrics.builtinCounterVec("sanitized_headers_total", "", "header")
+	if value := testutil.ToFloat64(stripped.WithLabelValues("X-Forwarded-For")); value != 1 {
+		t.Errorf("expected 1 stripped X-Forwarded-For header, got %v", value)
+	}
+}
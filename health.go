@@ -2,53 +2,159 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hellofresh/health-go/v5"
 )
 
-// HealthChecker wraps the health-go library health checker
+// defaultHealthTimeout is the default time budget of the health endpoints
+const defaultHealthTimeout = 5 * time.Second
+
+// HealthChecker runs the health checks of the service. Checks are configured with HealthCheck,
+// and health-go check configs are supported through HealthCheckFromConfig.
 type HealthChecker struct {
-	checker *health.Health
+	checker   healthBackend
+	checks    atomic.Int32
+	unhealthy atomic.Bool
+
+	// onUnhealthy is called when the health status flips to unhealthy
+	onUnhealthy func(status string, failures map[string]string)
+
+	// timeout is the time budget of the health endpoints
+	timeout atomic.Int64
+
+	gatesMu sync.RWMutex
+	gates   map[string]func(ctx context.Context) error
 }
 
 // NewHealthChecker creates a new health checker with the service component information
 func NewHealthChecker(serviceName, version string) (*HealthChecker, error) {
-	checker, err := health.New(
-		health.WithComponent(health.Component{
+	return &HealthChecker{
+		checker: newNativeHealth(health.Component{
 			Name:    serviceName,
 			Version: version,
 		}),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create health checker: %w", err)
+	}, nil
+}
+
+// SetTimeout sets the time budget of the health endpoints (default 5s). The remaining budget is propagated
+// to the checks. Probes can lower it with the timeout query parameter, e.g. /ready?timeout=1s to match the
+// timeoutSeconds of a Kubernetes probe.
+func (hc *HealthChecker) SetTimeout(timeout time.Duration) {
+	hc.timeout.Store(int64(timeout))
+}
+
+// requestContext returns the request context with the time budget of the health endpoints
+func (hc *HealthChecker) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(hc.timeout.Load())
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
 	}
 
-	return &HealthChecker{
-		checker: checker,
-	}, nil
+	if requested, err := time.ParseDuration(r.URL.Query().Get("timeout")); err == nil && requested > 0 && requested < timeout {
+		timeout = requested
+	}
+
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// Register adds a health-go health check to the health checker
+func (hc *HealthChecker) Register(config health.Config) (*HealthCheckHandle, error) {
+	return hc.RegisterCheck(HealthCheckFromConfig(config))
+}
+
+// RegisterCheck adds a health check to the health checker
+func (hc *HealthChecker) RegisterCheck(check HealthCheck) (*HealthCheckHandle, error) {
+	if err := hc.checker.register(check); err != nil {
+		return nil, fmt.Errorf("failed to register health check: %w", err)
+	}
+
+	hc.checks.Add(1)
+
+	return &HealthCheckHandle{name: check.Name, checker: hc}, nil
+}
+
+// RemoveCheck deregisters a health check
+func (hc *HealthChecker) RemoveCheck(name string) error {
+	if !hc.checker.remove(name) {
+		return fmt.Errorf("%w: %q", ErrUnknownHealthCheck, name)
+	}
+
+	hc.checks.Add(-1)
+
+	return nil
 }
 
-// Register adds a health check to the health checker
-func (hc *HealthChecker) Register(config health.Config) error {
-	return fmt.Errorf("failed to register health check: %w", hc.checker.Register(config))
+// SetCheckEnabled enables or disables a health check at runtime. Disabled checks are skipped.
+func (hc *HealthChecker) SetCheckEnabled(name string, enabled bool) error {
+	if !hc.checker.setEnabled(name, enabled) {
+		return fmt.Errorf("%w: %q", ErrUnknownHealthCheck, name)
+	}
+
+	return nil
+}
+
+// AddReadinessGate adds a condition that has to pass, in addition to the health checks, before the service is ready
+func (hc *HealthChecker) AddReadinessGate(name string, gate func(ctx context.Context) error) {
+	hc.gatesMu.Lock()
+	defer hc.gatesMu.Unlock()
+
+	if hc.gates == nil {
+		hc.gates = make(map[string]func(ctx context.Context) error)
+	}
+
+	hc.gates[name] = gate
+}
+
+// Count returns the number of registered health checks
+func (hc *HealthChecker) Count() int {
+	return int(hc.checks.Load())
 }
 
 // Handler returns the HTTP handler for health checks
 func (hc *HealthChecker) Handler() http.Handler {
-	return hc.checker.Handler()
+	return http.HandlerFunc(hc.HandlerFunc)
 }
 
-// HandlerFunc returns the HTTP handler function for health checks
+// HandlerFunc returns the HTTP handler function for health checks.
+// It responds with the status as JSON, and with 503 while the service is unavailable.
 func (hc *HealthChecker) HandlerFunc(w http.ResponseWriter, r *http.Request) {
-	hc.checker.HandlerFunc(w, r)
+	ctx, cancel := hc.requestContext(r)
+	defer cancel()
+
+	check := hc.Measure(ctx)
+
+	data, err := json.Marshal(check)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	code := http.StatusOK
+	if check.Status == health.StatusUnavailable {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
 }
 
 // Measure returns the current health status
 func (hc *HealthChecker) Measure(ctx context.Context) health.Check {
-	return hc.checker.Measure(ctx)
+	check := hc.checker.measure(ctx)
+
+	unhealthy := check.Status != health.StatusOK
+	if hc.unhealthy.Swap(unhealthy) != unhealthy && unhealthy && hc.onUnhealthy != nil {
+		hc.onUnhealthy(string(check.Status), check.Failures)
+	}
+
+	return check
 }
 
 // IsHealthy returns true if all health checks are passing
@@ -61,8 +167,22 @@ func (hc *HealthChecker) IsHealthy(ctx context.Context) bool {
 // This is typically used for Kubernetes readiness probes
 func (hc *HealthChecker) IsReady(ctx context.Context) bool {
 	// For readiness, we want to check if critical services are available
-	// This is the same as health check for now, but can be customized
-	return hc.IsHealthy(ctx)
+	// and all readiness gates (e.g. warmed connection pools) pass.
+	// Failing degraded checks don't take the service out of rotation.
+	if hc.Measure(ctx).Status == health.StatusUnavailable {
+		return false
+	}
+
+	hc.gatesMu.RLock()
+	defer hc.gatesMu.RUnlock()
+
+	for _, gate := range hc.gates {
+		if gate(ctx) != nil {
+			return false
+		}
+	}
+
+	return true
 }
 
 // IsAlive returns true if the service is alive
@@ -77,7 +197,7 @@ func (hc *HealthChecker) IsAlive(ctx context.Context) bool {
 // ReadinessHandler returns an HTTP handler for readiness checks
 func (hc *HealthChecker) ReadinessHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		ctx, cancel := hc.requestContext(r)
 		defer cancel()
 
 		if hc.IsReady(ctx) {
@@ -93,7 +213,7 @@ func (hc *HealthChecker) ReadinessHandler() http.HandlerFunc {
 // LivenessHandler returns an HTTP handler for liveness checks
 func (hc *HealthChecker) LivenessHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		ctx, cancel := hc.requestContext(r)
 		defer cancel()
 
 		if hc.IsAlive(ctx) {
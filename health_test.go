@@ -5,6 +5,8 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -420,3 +422,254 @@ func TestService_GetHealthChecker(t *testing.T) {
 		t.Error("expected returned health checker to match service health checker")
 	}
 }
+
+func TestHealthChecker_RegisterCheck(t *testing.T) {
+	t.Parallel()
+
+	t.Run("caches results for the interval", func(t *testing.T) {
+		t.Parallel()
+
+		healthChecker, _ := NewHealthChecker("test-service", "v1.0.0")
+
+		var runs atomic.Int32
+
+		if _, err := healthChecker.RegisterCheck(HealthCheck{
+			Name:     "cached",
+			Interval: time.Hour,
+			Check: func(_ context.Context) error {
+				runs.Add(1)
+				return nil
+			},
+		}); err != nil {
+			t.Fatalf("failed to register health check: %v", err)
+		}
+
+		for range 3 {
+			healthChecker.Measure(context.Background())
+		}
+
+		if runs.Load() != 1 {
+			t.Errorf("expected 1 run, got %d", runs.Load())
+		}
+	})
+
+	t.Run("reports failures after the threshold", func(t *testing.T) {
+		t.Parallel()
+
+		healthChecker, _ := NewHealthChecker("test-service", "v1.0.0")
+
+		_, _ = healthChecker.RegisterCheck(HealthCheck{
+			Name:             "flaky",
+			FailureThreshold: 2,
+			Check: func(_ context.Context) error {
+				return errors.New("check failed") //nolint:err113
+			},
+		})
+
+		if !healthChecker.IsHealthy(context.Background()) {
+			t.Error("expected the first failure to be tolerated")
+		}
+
+		check := healthChecker.Measure(context.Background())
+		if check.Status != health.StatusUnavailable {
+			t.Errorf("expected status %q, got %q", health.StatusUnavailable, check.Status)
+		}
+
+		if check.Failures["flaky"] != "check failed" {
+			t.Errorf("expected failure message, got %v", check.Failures)
+		}
+	})
+
+	t.Run("degraded checks keep the service ready", func(t *testing.T) {
+		t.Parallel()
+
+		healthChecker, _ := NewHealthChecker("test-service", "v1.0.0")
+
+		_, _ = healthChecker.Register(health.Config{
+			Name:      "optional",
+			SkipOnErr: true,
+			Check: func(_ context.Context) error {
+				return errors.New("check failed") //nolint:err113
+			},
+		})
+
+		if status := healthChecker.Measure(context.Background()).Status; status != health.StatusPartiallyAvailable {
+			t.Errorf("expected status %q, got %q", health.StatusPartiallyAvailable, status)
+		}
+
+		if !healthChecker.IsReady(context.Background()) {
+			t.Error("expected a degraded service to be ready")
+		}
+
+		recorder := httptest.NewRecorder()
+		healthChecker.HandlerFunc(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("rejects duplicate and invalid checks", func(t *testing.T) {
+		t.Parallel()
+
+		healthChecker, _ := NewHealthChecker("test-service", "v1.0.0")
+
+		check := HealthCheck{Name: "db", Check: func(_ context.Context) error { return nil }}
+
+		if _, err := healthChecker.RegisterCheck(check); err != nil {
+			t.Fatalf("failed to register health check: %v", err)
+		}
+
+		if _, err := healthChecker.RegisterCheck(check); !errors.Is(err, ErrDuplicateHealthCheck) {
+			t.Errorf("expected ErrDuplicateHealthCheck, got %v", err)
+		}
+
+		if _, err := healthChecker.RegisterCheck(HealthCheck{Name: "no-func"}); !errors.Is(err, ErrInvalidHealthCheck) {
+			t.Errorf("expected ErrInvalidHealthCheck, got %v", err)
+		}
+	})
+}
+
+func TestHealthCheckHandle(t *testing.T) {
+	t.Parallel()
+
+	svc := New("test-service", nil)
+
+	handle, err := svc.RegisterHealthCheck(health.Config{
+		Name: "upstream",
+		Check: func(_ context.Context) error {
+			return errors.New("maintenance") //nolint:err113
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to register health check: %v", err)
+	}
+
+	if svc.HealthChecker.IsHealthy(context.Background()) {
+		t.Fatal("expected the failing check to make the service unhealthy")
+	}
+
+	handle.Disable()
+
+	if handle.Enabled() || !svc.HealthChecker.IsHealthy(context.Background()) {
+		t.Error("expected the disabled check to be skipped")
+	}
+
+	handle.Enable()
+
+	if !handle.Enabled() || svc.HealthChecker.IsHealthy(context.Background()) {
+		t.Error("expected the enabled check to run again")
+	}
+
+	handle.Remove()
+
+	if svc.HealthChecker.Count() != 0 || !svc.HealthChecker.IsHealthy(context.Background()) {
+		t.Error("expected the removed check to be gone")
+	}
+
+	if err := svc.HealthChecker.RemoveCheck("upstream"); !errors.Is(err, ErrUnknownHealthCheck) {
+		t.Errorf("expected ErrUnknownHealthCheck, got %v", err)
+	}
+}
+
+func TestHealthChecksHandler(t *testing.T) {
+	t.Parallel()
+
+	svc := New("test-service", nil)
+
+	_, _ = svc.AddHealthCheck(HealthCheck{Name: "db", Check: func(_ context.Context) error { return nil }})
+
+	handler := svc.healthChecksHandler()
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/admin/health-checks?name=db&enabled=false", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+
+	if body := recorder.Body.String(); !strings.Contains(body, `{"name":"db","enabled":false,"degraded":false}`) {
+		t.Errorf("expected the disabled check to be listed, got %s", body)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/admin/health-checks?name=unknown&enabled=true", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown check, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/admin/health-checks?name=db", nil))
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without enabled, got %d", recorder.Code)
+	}
+}
+
+func TestHealthChecker_Deadline(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports the previous result when the deadline ends a run", func(t *testing.T) {
+		t.Parallel()
+
+		healthChecker, _ := NewHealthChecker("test-service", "v1.0.0")
+
+		var slow atomic.Bool
+
+		_, _ = healthChecker.RegisterCheck(HealthCheck{
+			Name:    "slow",
+			Timeout: 200 * time.Millisecond,
+			Check: func(ctx context.Context) error {
+				if slow.Load() {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+
+				return nil
+			},
+		})
+
+		if !healthChecker.IsHealthy(context.Background()) {
+			t.Fatal("expected the first run to pass")
+		}
+
+		slow.Store(true)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if !healthChecker.IsHealthy(ctx) {
+			t.Error("expected the previous result within a short deadline")
+		}
+
+		if healthChecker.IsHealthy(context.Background()) {
+			t.Error("expected the check to time out with the full budget")
+		}
+	})
+
+	t.Run("limits the budget by the timeout query parameter", func(t *testing.T) {
+		t.Parallel()
+
+		healthChecker, _ := NewHealthChecker("test-service", "v1.0.0")
+		healthChecker.SetTimeout(10 * time.Second)
+
+		tests := map[string]time.Duration{
+			"/ready":              10 * time.Second,
+			"/ready?timeout=1s":   time.Second,
+			"/ready?timeout=1m":   10 * time.Second,
+			"/ready?timeout=oops": 10 * time.Second,
+		}
+
+		for target, expected := range tests {
+			ctx, cancel := healthChecker.requestContext(httptest.NewRequest(http.MethodGet, target, nil))
+
+			deadline, _ := ctx.Deadline()
+			if remaining := time.Until(deadline); remaining > expected || remaining < expected-time.Second {
+				t.Errorf("%s: expected a budget of %s, got %s", target, expected, remaining)
+			}
+
+			cancel()
+		}
+	})
+}
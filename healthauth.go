@@ -0,0 +1,120 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// EndpointAuth protects an endpoint of the metrics server with credentials and/or a network allowlist.
+// Without any setting, the endpoint is open.
+type EndpointAuth struct {
+	// Token is a bearer token accepted in the Authorization header
+	Token string `env:"AUTH_TOKEN"`
+	// BasicAuth is a "user:password" pair accepted with basic authentication. With a token, either is accepted.
+	BasicAuth string `env:"BASIC_AUTH"`
+	// AllowedNetworks are the networks (CIDR) clients must connect from
+	AllowedNetworks []string `env:"ALLOWED_NETWORKS" envSeparator:","`
+}
+
+// enabled reports whether the endpoint is protected
+func (a EndpointAuth) enabled() bool {
+	return a.Token != "" || a.BasicAuth != "" || len(a.AllowedNetworks) > 0
+}
+
+// protectEndpoint wraps a handler of the metrics server with the endpoint auth. Invalid networks allow nobody.
+func (s *Service) protectEndpoint(auth EndpointAuth, handler http.Handler) http.Handler {
+	if !auth.enabled() {
+		return handler
+	}
+
+	if auth.Token != "" || auth.BasicAuth != "" {
+		handler = credentialsMiddleware(s.Metrics, auth.Token, auth.BasicAuth)(handler)
+	}
+
+	if len(auth.AllowedNetworks) > 0 {
+		handler = AllowNetworksMiddleware(s.parseNetworks("endpoint allowed network", auth.AllowedNetworks))(handler)
+	}
+
+	return handler
+}
+
+// adminEndpoint wraps a handler of an admin endpoint with the admin auth. Without ADMIN_AUTH_*, the admin endpoints
+// are read-only, so nobody who can reach the metrics server can change the service.
+func (s *Service) adminEndpoint(handler http.Handler) http.Handler {
+	if s.Config.AdminAuth.enabled() {
+		return s.protectEndpoint(s.Config.AdminAuth, handler)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			WriteError(w, r, http.StatusForbidden,
+				NewError(CodePermissionDenied, "admin actions require ADMIN_AUTH_TOKEN, ADMIN_BASIC_AUTH, or ADMIN_ALLOWED_NETWORKS"))
+
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// credentialsMiddleware requires a bearer token or basic auth credentials, compared in constant time.
+// Attempts are counted in {service_name}_auth_attempts_total{method,outcome}.
+func credentialsMiddleware(metrics *MetricsCollector, token, basicAuth string) Middleware {
+	bearerAudit := newAuthAudit(metrics, "bearer")
+	basicAudit := newAuthAudit(metrics, "basic")
+
+	user, password, _ := strings.Cut(basicAuth, ":")
+	validBasic := usersValidator(map[string]string{user: password})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+				if !tokensEqual(bearer, token) {
+					bearerAudit.failure(r, authOutcomeFailure, "invalid_credentials")
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+					return
+				}
+
+				bearerAudit.success()
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			if givenUser, givenPassword, ok := r.BasicAuth(); ok && basicAuth != "" {
+				if !validBasic(givenUser, givenPassword) {
+					basicAudit.failure(r, authOutcomeFailure, "invalid_credentials")
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+					return
+				}
+
+				basicAudit.success()
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			if basicAuth != "" {
+				basicAudit.failure(r, authOutcomeFailure, "missing_credentials")
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted", charset="UTF-8"`)
+			} else {
+				bearerAudit.failure(r, authOutcomeFailure, "missing_credentials")
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		})
+	}
+}
+
+// tokensEqual compares two tokens in constant time, also for different lengths
+func tokensEqual(given, expected string) bool {
+	givenHash := sha256.Sum256([]byte(given))
+	expectedHash := sha256.Sum256([]byte(expected))
+
+	return subtle.ConstantTimeCompare(givenHash[:], expectedHash[:]) == 1
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProtectEndpoint(t *testing.T) {
+	t.Parallel()
+
+	svc := New("test-service", nil)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		auth     EndpointAuth
+		setup    func(r *http.Request)
+		expected int
+	}{
+		{name: "open without settings", expected: http.StatusOK},
+		{
+			name:     "valid bearer token",
+			auth:     EndpointAuth{Token: "secret"},
+			setup:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+			expected: http.StatusOK,
+		},
+		{
+			name:     "invalid bearer token",
+			auth:     EndpointAuth{Token: "secret"},
+			setup:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") },
+			expected: http.StatusUnauthorized,
+		},
+		{name: "missing credentials", auth: EndpointAuth{Token: "secret"}, expected: http.StatusUnauthorized},
+		{
+			name:     "valid basic auth with token configured",
+			auth:     EndpointAuth{Token: "secret", BasicAuth: "ops:hunter2"},
+			setup:    func(r *http.Request) { r.SetBasicAuth("ops", "hunter2") },
+			expected: http.StatusOK,
+		},
+		{
+			name:     "invalid basic auth",
+			auth:     EndpointAuth{BasicAuth: "ops:hunter2"},
+			setup:    func(r *http.Request) { r.SetBasicAuth("ops", "wrong") },
+			expected: http.StatusUnauthorized,
+		},
+		{name: "allowed network", auth: EndpointAuth{AllowedNetworks: []string{"192.0.2.0/24"}}, expected: http.StatusOK},
+		{name: "other network", auth: EndpointAuth{AllowedNetworks: []string{"10.0.0.0/8"}}, expected: http.StatusForbidden},
+		{
+			name:     "allowed network without credentials",
+			auth:     EndpointAuth{Token: "secret", AllowedNetworks: []string{"192.0.2.0/24"}},
+			expected: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+
+			recorder := httptest.NewRecorder()
+			svc.protectEndpoint(tt.auth, ok).ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestAdminEndpoint(t *testing.T) {
+	t.Parallel()
+
+	open := New("test-service", nil).metricsHandler()
+
+	config := DefaultConfig()
+	config.AdminAuth.Token = "secret"
+	protected := New("test-service", config).metricsHandler()
+
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		method   string
+		token    string
+		expected int
+	}{
+		{name: "read without auth", handler: open, method: http.MethodGet, expected: http.StatusOK},
+		{name: "action without auth", handler: open, method: http.MethodPost, expected: http.StatusForbidden},
+		{name: "action with token", handler: protected, method: http.MethodPost, token: "secret", expected: http.StatusNotFound},
+		{name: "action without token", handler: protected, method: http.MethodPost, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/admin/jobs?name=missing", nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		recorder := httptest.NewRecorder()
+		tt.handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, recorder.Code)
+		}
+	}
+}
+
+func TestLoadFromEnv_EndpointAuth(t *testing.T) {
+	t.Setenv("HEALTH_AUTH_TOKEN", "secret")
+	t.Setenv("READINESS_ALLOWED_NETWORKS", "10.0.0.0/8,192.168.0.0/16")
+
+	config, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if config.HealthAuth.Token != "secret" {
+		t.Errorf("expected the health endpoint token, got %q", config.HealthAuth.Token)
+	}
+
+	if len(config.ReadinessAuth.AllowedNetworks) != 2 || config.LivenessAuth.enabled() {
+		t.Errorf("expected only the readiness networks, got %+v and %+v", config.ReadinessAuth, config.LivenessAuth)
+	}
+}
@@ -0,0 +1,425 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hellofresh/health-go/v5"
+)
+
+// Health check errors
+var (
+	ErrInvalidHealthCheck   = NewError(CodeInvalidArgument, "invalid health check")
+	ErrDuplicateHealthCheck = NewError(CodeAlreadyExists, "health check already registered")
+	ErrUnknownHealthCheck   = NewError(CodeNotFound, "unknown health check")
+)
+
+// errHealthCheckTimeout is the failure of a health check that didn't finish within its timeout
+var errHealthCheckTimeout = errors.New(string(health.StatusTimeout)) //nolint:err113
+
+// defaultHealthCheckTimeout is the timeout of health checks without a timeout
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// maxHealthResponseReserve is the maximum part of a deadline reserved to respond with the health status
+const maxHealthResponseReserve = 250 * time.Millisecond
+
+// HealthCheck configures a health check of the native health checker
+type HealthCheck struct {
+	// Name is the unique name of the check
+	Name string
+	// Check executes the check. It should return once the context is done.
+	Check func(ctx context.Context) error
+	// Timeout is the timeout of a single run of the check (default 2s)
+	Timeout time.Duration
+	// Interval caches the result of the check for the interval, so expensive checks don't run on every probe.
+	// With 0, the check runs on every measurement.
+	Interval time.Duration
+	// FailureThreshold is the number of consecutive failures before the check is reported as failing (default 1),
+	// so a single flaky run doesn't take the service out of rotation
+	FailureThreshold int
+	// Degraded reports failures of the check as a degraded ("Partially Available") instead of an unavailable service,
+	// for dependencies the service can work without
+	Degraded bool
+}
+
+// HealthCheckFromConfig adapts a health-go check config to a native health check.
+// Checks with SkipOnErr are degraded checks.
+func HealthCheckFromConfig(config health.Config) HealthCheck {
+	return HealthCheck{
+		Name:     config.Name,
+		Check:    config.Check,
+		Timeout:  config.Timeout,
+		Degraded: config.SkipOnErr,
+	}
+}
+
+// HealthCheckHandle is the registration of a health check, used to remove, disable, or enable it at runtime
+type HealthCheckHandle struct {
+	name    string
+	checker *HealthChecker
+}
+
+// Name returns the name of the health check
+func (h *HealthCheckHandle) Name() string {
+	return h.name
+}
+
+// Remove deregisters the health check
+func (h *HealthCheckHandle) Remove() {
+	if h.checker != nil {
+		_ = h.checker.RemoveCheck(h.name)
+	}
+}
+
+// Disable skips the health check until it is enabled again, e.g. during a known maintenance window of a dependency
+func (h *HealthCheckHandle) Disable() {
+	if h.checker != nil {
+		_ = h.checker.SetCheckEnabled(h.name, false)
+	}
+}
+
+// Enable runs the health check again after it was disabled
+func (h *HealthCheckHandle) Enable() {
+	if h.checker != nil {
+		_ = h.checker.SetCheckEnabled(h.name, true)
+	}
+}
+
+// Enabled reports whether the health check is registered and enabled
+func (h *HealthCheckHandle) Enabled() bool {
+	if h.checker == nil {
+		return false
+	}
+
+	for _, info := range h.checker.checker.list() {
+		if info.Name == h.name {
+			return info.Enabled
+		}
+	}
+
+	return false
+}
+
+// healthCheckInfo describes a registered health check
+type healthCheckInfo struct {
+	Name     string `json:"name"`
+	Enabled  bool   `json:"enabled"`
+	Degraded bool   `json:"degraded"`
+}
+
+// healthBackend runs the registered health checks of a health checker
+type healthBackend interface {
+	register(check HealthCheck) error
+	remove(name string) bool
+	setEnabled(name string, enabled bool) bool
+	list() []healthCheckInfo
+	measure(ctx context.Context) health.Check
+}
+
+// nativeHealth is the native health backend, supporting result caching, failure thresholds, and degraded checks
+type nativeHealth struct {
+	component health.Component
+
+	mu     sync.RWMutex
+	checks map[string]*healthCheckState
+}
+
+// newNativeHealth creates a native health backend
+func newNativeHealth(component health.Component) *nativeHealth {
+	return &nativeHealth{
+		component: component,
+		checks:    make(map[string]*healthCheckState),
+	}
+}
+
+// register adds a health check
+func (n *nativeHealth) register(check HealthCheck) error {
+	if check.Name == "" || check.Check == nil {
+		return fmt.Errorf("%w: a name and a check function are required", ErrInvalidHealthCheck)
+	}
+
+	if check.Timeout <= 0 {
+		check.Timeout = defaultHealthCheckTimeout
+	}
+
+	if check.FailureThreshold < 1 {
+		check.FailureThreshold = 1
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.checks[check.Name]; ok {
+		return fmt.Errorf("%w: %q", ErrDuplicateHealthCheck, check.Name)
+	}
+
+	state := &healthCheckState{check: check, running: make(chan struct{}, 1)}
+	state.enabled.Store(true)
+
+	n.checks[check.Name] = state
+
+	return nil
+}
+
+// remove deletes a health check and reports whether it was registered
+func (n *nativeHealth) remove(name string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.checks[name]; !ok {
+		return false
+	}
+
+	delete(n.checks, name)
+
+	return true
+}
+
+// setEnabled enables or disables a health check and reports whether it is registered.
+// Enabling a check resets its failures, so it starts with a fresh result.
+func (n *nativeHealth) setEnabled(name string, enabled bool) bool {
+	n.mu.RLock()
+	state, ok := n.checks[name]
+	n.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	if state.enabled.Swap(enabled) || !enabled {
+		return true
+	}
+
+	state.mu.Lock()
+	state.lastRun = time.Time{}
+	state.consecutiveFailures = 0
+	state.mu.Unlock()
+
+	return true
+}
+
+// list returns the registered health checks sorted by name
+func (n *nativeHealth) list() []healthCheckInfo {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	infos := make([]healthCheckInfo, 0, len(n.checks))
+
+	for _, state := range n.checks {
+		infos = append(infos, healthCheckInfo{Name: state.check.Name, Enabled: state.enabled.Load(), Degraded: state.check.Degraded})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos
+}
+
+// measure runs all health checks concurrently and returns the aggregated status.
+// Any failing check makes the service unavailable, unless only degraded checks fail.
+func (n *nativeHealth) measure(ctx context.Context) health.Check {
+	// Keep part of the remaining budget to respond before the caller (e.g. a probe) gives up
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-min(time.Until(deadline)/10, maxHealthResponseReserve)))
+		defer cancel()
+	}
+
+	n.mu.RLock()
+	states := make([]*healthCheckState, 0, len(n.checks))
+
+	for _, state := range n.checks {
+		states = append(states, state)
+	}
+	n.mu.RUnlock()
+
+	sort.Slice(states, func(i, j int) bool { return states[i].check.Name < states[j].check.Name })
+
+	results := make([]error, len(states))
+
+	var wg sync.WaitGroup
+
+	for i, state := range states {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			results[i] = state.result(ctx)
+		}()
+	}
+
+	wg.Wait()
+
+	status := health.StatusOK
+	failures := make(map[string]string)
+
+	for i, err := range results {
+		if err == nil {
+			continue
+		}
+
+		failures[states[i].check.Name] = err.Error()
+
+		switch {
+		case !states[i].check.Degraded:
+			status = health.StatusUnavailable
+		case status == health.StatusOK:
+			status = health.StatusPartiallyAvailable
+		}
+	}
+
+	return health.Check{
+		Status:    status,
+		Timestamp: time.Now(),
+		Failures:  failures,
+		Component: n.component,
+	}
+}
+
+// healthCheckState holds the last result of a health check
+type healthCheckState struct {
+	check   HealthCheck
+	enabled atomic.Bool
+
+	// running serializes runs, so concurrent probes share a result instead of running the check in parallel
+	running chan struct{}
+
+	mu                  sync.Mutex
+	lastRun             time.Time
+	lastErr             error
+	consecutiveFailures int
+}
+
+// result returns the error of a failing check, running the check unless its cached result is still fresh.
+// If the deadline of the context ends a run before the timeout of the check (or while another run is in progress),
+// the previous result is reported, so a tight probe budget doesn't fail all slow checks together. Disabled checks pass.
+func (h *healthCheckState) result(ctx context.Context) error {
+	if !h.enabled.Load() {
+		return nil
+	}
+
+	select {
+	case h.running <- struct{}{}:
+		defer func() { <-h.running }()
+	case <-ctx.Done():
+		return h.reported()
+	}
+
+	h.mu.Lock()
+	fresh := h.check.Interval > 0 && !h.lastRun.IsZero() && time.Since(h.lastRun) < h.check.Interval
+	h.mu.Unlock()
+
+	if fresh {
+		return h.reported()
+	}
+
+	err := h.run(ctx)
+	if errors.Is(err, errHealthCheckTimeout) && ctx.Err() != nil {
+		return h.reported()
+	}
+
+	h.record(err)
+
+	return h.reported()
+}
+
+// record stores the result of a run
+func (h *healthCheckState) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastErr = err
+	h.lastRun = time.Now()
+
+	if err != nil {
+		h.consecutiveFailures++
+	} else {
+		h.consecutiveFailures = 0
+	}
+}
+
+// reported returns the error of the last result once the failure threshold is reached.
+// A check without a result yet is reported as timed out.
+func (h *healthCheckState) reported() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.lastRun.IsZero() {
+		return errHealthCheckTimeout
+	}
+
+	if h.consecutiveFailures < h.check.FailureThreshold {
+		return nil
+	}
+
+	return h.lastErr
+}
+
+// run executes the check with its timeout. Checks ignoring the context are abandoned once it is done.
+func (h *healthCheckState) run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.check.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- h.check.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			return errHealthCheckTimeout
+		}
+
+		return err
+	case <-ctx.Done():
+		return errHealthCheckTimeout
+	}
+}
+
+// healthChecksHandler returns the admin handler to list health checks, and to enable or disable them at runtime
+// with POST ?name=...&enabled=true|false
+func (s *Service) healthChecksHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.HealthChecker == nil {
+			http.Error(w, "health checker not available", http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+				return
+			}
+
+			name := r.URL.Query().Get("name")
+			if err := s.HealthChecker.SetCheckEnabled(name, enabled); err != nil {
+				http.Error(w, err.Error(), HTTPStatus(err))
+				return
+			}
+
+			s.Logger.Info("health check toggled via admin API", "name", name, "enabled", enabled)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"checks": s.HealthChecker.checker.list()})
+	}
+}
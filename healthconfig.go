@@ -0,0 +1,218 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrHealthCheckFailed is returned by the HTTP, TCP, and DNS health checks
+var ErrHealthCheckFailed = NewError(CodeUnavailable, "health check failed")
+
+// ParseHealthCheck parses a health check declared as "[name=]target", where the target is an http:// or https:// URL,
+// a tcp://host:port address, or a dns://hostname. Without a name, the check is named after the kind and host,
+// e.g. "tcp_db:5432". HTTP checks use the client to GET the URL and expect a 2xx response.
+func ParseHealthCheck(entry string, client *http.Client) (HealthCheck, error) {
+	entry = strings.TrimSpace(entry)
+
+	name, target, found := strings.Cut(entry, "=")
+	if !found || strings.Contains(name, "://") {
+		name, target = "", entry
+	}
+
+	name, target = strings.TrimSpace(name), strings.TrimSpace(target)
+
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Host == "" {
+		return HealthCheck{}, fmt.Errorf("%w: invalid target %q", ErrInvalidHealthCheck, target)
+	}
+
+	if name == "" {
+		name = parsed.Scheme + "_" + parsed.Host
+	}
+
+	check := HealthCheck{Name: name}
+
+	switch parsed.Scheme {
+	case "http", "https":
+		check.Check = HTTPCheck(client, target)
+	case "tcp":
+		check.Check = TCPCheck(parsed.Host)
+	case "dns":
+		check.Check = DNSCheck(parsed.Host)
+	default:
+		return HealthCheck{}, fmt.Errorf("%w: unsupported scheme %q in %q", ErrInvalidHealthCheck, parsed.Scheme, target)
+	}
+
+	return check, nil
+}
+
+// HTTPCheck returns a health check that sends a GET request to the URL and expects a 2xx response
+func HTTPCheck(client *http.Client, url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrHealthCheckFailed, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%w: %s returned status %d", ErrHealthCheckFailed, url, resp.StatusCode)
+		}
+
+		return nil
+	}
+}
+
+// TCPCheck returns a health check that opens a TCP connection to the address
+func TCPCheck(addr string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrHealthCheckFailed, err)
+		}
+
+		return conn.Close() //nolint:wrapcheck
+	}
+}
+
+// DNSCheck returns a health check that resolves the hostname
+func DNSCheck(hostname string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, hostname)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrHealthCheckFailed, err)
+		}
+
+		if len(addrs) == 0 {
+			return fmt.Errorf("%w: %s resolved to no addresses", ErrHealthCheckFailed, hostname)
+		}
+
+		return nil
+	}
+}
+
+// maxHealthCheckOutput is the maximum length of handler and command output in health check errors
+const maxHealthCheckOutput = 512
+
+// HandlerCheck returns a health check that serves a GET request with the handler in-process and expects a 2xx
+// response, e.g. to fold the diagnostics endpoint of a legacy component into the health checker.
+// The response body is included in the error.
+func HandlerCheck(handler http.Handler) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code < 200 || recorder.Code > 299 {
+			return fmt.Errorf("%w: handler returned status %d: %s", ErrHealthCheckFailed, recorder.Code,
+				truncateOutput(recorder.Body.Bytes()))
+		}
+
+		return nil
+	}
+}
+
+// CommandCheck returns a health check that runs a command and expects exit status 0. The command is killed when
+// the check times out; its combined output is included in the error.
+func CommandCheck(name string, args ...string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		cmd := exec.CommandContext(ctx, name, args...)
+		// Don't wait for children of a killed command that keep the output open
+		cmd.WaitDelay = time.Second
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w: %s", ErrHealthCheckFailed, name, err, truncateOutput(output))
+		}
+
+		return nil
+	}
+}
+
+// truncateOutput returns the trimmed output, truncated to its last maxHealthCheckOutput bytes
+func truncateOutput(output []byte) string {
+	output = bytes.TrimSpace(output)
+	if len(output) > maxHealthCheckOutput {
+		return "..." + string(output[len(output)-maxHealthCheckOutput:])
+	}
+
+	return string(output)
+}
+
+// registerConfiguredHealthChecks registers the health checks declared in HEALTH_CHECKS and HEALTH_CHECKS_FILE
+func (s *Service) registerConfiguredHealthChecks() error {
+	entries := s.Config.HealthChecks
+
+	if s.Config.HealthChecksFile != "" {
+		fileEntries, err := readLinesFile(s.Config.HealthChecksFile)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries[:len(entries):len(entries)], fileEntries...)
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	client := NewClient(s.Metrics, ClientConfig{Name: "health_check"})
+
+	for _, entry := range entries {
+		check, err := ParseHealthCheck(entry, client)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.AddHealthCheck(check); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readLinesFile reads the entries of a config file, one per line. Empty lines and lines starting with # are skipped.
+func readLinesFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entries = append(entries, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return entries, nil
+}
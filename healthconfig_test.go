@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		entry string
+		name  string
+		err   error
+	}{
+		{entry: "tcp://db:5432", name: "tcp_db:5432"},
+		{entry: "dns://example.com", name: "dns_example.com"},
+		{entry: "payments=https://payments.internal/health?verbose=1", name: "payments"},
+		{entry: " cache = tcp://cache:6379", name: "cache"},
+		{entry: "ftp://files", err: ErrInvalidHealthCheck},
+		{entry: "db:5432", err: ErrInvalidHealthCheck},
+	}
+
+	for _, tt := range tests {
+		check, err := ParseHealthCheck(tt.entry, http.DefaultClient)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%q: expected error %v, got %v", tt.entry, tt.err, err)
+			continue
+		}
+
+		if err == nil && (check.Name != tt.name || check.Check == nil) {
+			t.Errorf("%q: expected check %q, got %q", tt.entry, tt.name, check.Name)
+		}
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	if err := HTTPCheck(server.Client(), server.URL+"/up")(context.Background()); err != nil {
+		t.Errorf("expected the check to pass, got %v", err)
+	}
+
+	if err := HTTPCheck(server.Client(), server.URL+"/down")(context.Background()); !errors.Is(err, ErrHealthCheckFailed) {
+		t.Errorf("expected ErrHealthCheckFailed, got %v", err)
+	}
+}
+
+func TestTCPCheck(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	addr := listener.Addr().String()
+
+	if err := TCPCheck(addr)(context.Background()); err != nil {
+		t.Errorf("expected the check to pass, got %v", err)
+	}
+
+	listener.Close()
+
+	if err := TCPCheck(addr)(context.Background()); !errors.Is(err, ErrHealthCheckFailed) {
+		t.Errorf("expected ErrHealthCheckFailed, got %v", err)
+	}
+}
+
+func TestService_RegisterConfiguredHealthChecks(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "health-checks")
+	if err := os.WriteFile(path, []byte("# dependencies\n\ndb=tcp://db:5432\ndns://example.com\n"), 0o600); err != nil {
+		t.Fatalf("failed to write health checks file: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.HealthChecks = []string{"payments=https://payments.internal/health"}
+	config.HealthChecksFile = path
+
+	svc := New("test-service", config)
+
+	if err := svc.registerConfiguredHealthChecks(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if svc.HealthChecker.Count() != 3 {
+		t.Errorf("expected 3 health checks, got %d", svc.HealthChecker.Count())
+	}
+
+	config = DefaultConfig()
+	config.HealthChecks = []string{"db:5432"}
+
+	if err := New("test-service", config).registerConfiguredHealthChecks(); !errors.Is(err, ErrInvalidHealthCheck) {
+		t.Errorf("expected ErrInvalidHealthCheck, got %v", err)
+	}
+}
+
+func TestHandlerCheck(t *testing.T) {
+	t.Parallel()
+
+	healthy := true
+
+	check := HandlerCheck(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !healthy {
+			http.Error(w, "queue stalled", http.StatusInternalServerError)
+		}
+	}))
+
+	if err := check(context.Background()); err != nil {
+		t.Errorf("expected the check to pass, got %v", err)
+	}
+
+	healthy = false
+
+	err := check(context.Background())
+	if !errors.Is(err, ErrHealthCheckFailed) || !strings.Contains(err.Error(), "queue stalled") {
+		t.Errorf("expected ErrHealthCheckFailed with the response body, got %v", err)
+	}
+}
+
+func TestCommandCheck(t *testing.T) {
+	t.Parallel()
+
+	if err := CommandCheck("sh", "-c", "exit 0")(context.Background()); err != nil {
+		t.Errorf("expected the check to pass, got %v", err)
+	}
+
+	err := CommandCheck("sh", "-c", "echo replica lagging; exit 2")(context.Background())
+	if !errors.Is(err, ErrHealthCheckFailed) || !strings.Contains(err.Error(), "replica lagging") {
+		t.Errorf("expected ErrHealthCheckFailed with the output, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := CommandCheck("sleep", "10")(ctx); !errors.Is(err, ErrHealthCheckFailed) {
+		t.Errorf("expected ErrHealthCheckFailed on timeout, got %v", err)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Error("expected the command to be killed on timeout")
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	t.Parallel()
+
+	output := truncateOutput([]byte(strings.Repeat("a", maxHealthCheckOutput) + "end\n"))
+	if !strings.HasPrefix(output, "...") || !strings.HasSuffix(output, "end") || len(output) != maxHealthCheckOutput+3 {
+		t.Errorf("expected the last %d bytes, got %d bytes", maxHealthCheckOutput, len(output))
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultHealthcheckURL is the readiness endpoint of a service with the default configuration
+const DefaultHealthcheckURL = "http://localhost:9090/ready"
+
+// healthcheckTimeout is the timeout of Healthcheck
+const healthcheckTimeout = 3 * time.Second
+
+// Healthcheck requests the readiness endpoint at the URL (DefaultHealthcheckURL if empty) and exits with status 0
+// if it passes within a short timeout, or 1 otherwise. Use it for a -healthcheck mode of the service binary,
+// so a Docker HEALTHCHECK works without curl in distroless images:
+//
+//	if len(os.Args) > 1 && os.Args[1] == "-healthcheck" {
+//		service.Healthcheck("")
+//	}
+func Healthcheck(url string) {
+	if url == "" {
+		url = DefaultHealthcheckURL
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthcheckTimeout)
+
+	err := CheckEndpoint(ctx, url)
+
+	cancel()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// CheckEndpoint sends a GET request to a health endpoint and returns an error unless it responds with a 2xx status
+func CheckEndpoint(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid healthcheck URL: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHealthCheckFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s returned status %d", ErrHealthCheckFailed, url, resp.StatusCode)
+	}
+
+	return nil
+}
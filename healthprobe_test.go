@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckEndpoint(t *testing.T) {
+	t.Parallel()
+
+	svc := New("test-service", nil)
+
+	server := httptest.NewServer(svc.metricsHandler())
+	defer server.Close()
+
+	if err := CheckEndpoint(context.Background(), server.URL+"/ready"); err != nil {
+		t.Errorf("expected the readiness endpoint to pass, got %v", err)
+	}
+
+	svc.HealthChecker.AddReadinessGate("blocked", func(_ context.Context) error {
+		return errors.New("not ready") //nolint:err113
+	})
+
+	if err := CheckEndpoint(context.Background(), server.URL+"/ready"); !errors.Is(err, ErrHealthCheckFailed) {
+		t.Errorf("expected ErrHealthCheckFailed, got %v", err)
+	}
+
+	server.Close()
+
+	if err := CheckEndpoint(context.Background(), server.URL+"/ready"); !errors.Is(err, ErrHealthCheckFailed) {
+		t.Errorf("expected ErrHealthCheckFailed for an unreachable endpoint, got %v", err)
+	}
+
+	if err := CheckEndpoint(context.Background(), "://invalid"); err == nil {
+		t.Error("expected an error for an invalid URL")
+	}
+}
+
+func TestHealthcheckURL(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	if expected := "http://localhost" + config.MetricsAddr + config.ReadinessPath; DefaultHealthcheckURL != expected {
+		t.Errorf("expected the default URL to match the default config %q, got %q", expected, DefaultHealthcheckURL)
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HedgePolicy configures hedged requests of an instrumented client.
+// Idempotent requests that don't complete within the hedge delay are sent a second time,
+// the slower attempt is canceled.
+type HedgePolicy struct {
+	// Delay is a fixed hedge delay. Defaults to the p95 latency of recent requests.
+	Delay time.Duration
+	// MinDelay is the hedge delay until enough requests were observed, and the lower bound of the p95 delay.
+	// Defaults to 10ms.
+	MinDelay time.Duration
+	// MaxInFlight limits the number of concurrent hedge attempts of the client. Defaults to 10.
+	MaxInFlight int
+}
+
+// hedgeSamples is the number of recent request latencies used to estimate the p95 latency
+const hedgeSamples = 128
+
+// hedgeTransport is an http.RoundTripper that hedges idempotent requests
+type hedgeTransport struct {
+	next   http.RoundTripper
+	policy HedgePolicy
+	slots  chan struct{}
+
+	hedged *prometheus.CounterVec
+	won    *prometheus.CounterVec
+	client string
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	cursor    int
+}
+
+// hedgeResult is the outcome of a single attempt
+type hedgeResult struct {
+	resp    *http.Response
+	err     error
+	hedge   bool
+	elapsed time.Duration
+}
+
+// newHedgeTransport creates a new hedging transport
+func newHedgeTransport(next http.RoundTripper, policy HedgePolicy, client string, metrics *MetricsCollector) *hedgeTransport {
+	if policy.MinDelay <= 0 {
+		policy.MinDelay = 10 * time.Millisecond
+	}
+
+	if policy.MaxInFlight <= 0 {
+		policy.MaxInFlight = 10
+	}
+
+	transport := &hedgeTransport{
+		next:      next,
+		policy:    policy,
+		slots:     make(chan struct{}, policy.MaxInFlight),
+		client:    client,
+		latencies: make([]time.Duration, 0, hedgeSamples),
+	}
+
+	if metrics != nil {
+		transport.hedged = metrics.builtinCounterVec("client_hedged_requests_total",
+			"Total number of outbound requests that were hedged", "client")
+		transport.won = metrics.builtinCounterVec("client_hedge_wins_total",
+			"Total number of hedged requests won by the hedge attempt", "client")
+	}
+
+	return transport
+}
+
+// idempotent reports whether a request can safely be sent twice
+func idempotent(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return req.Header.Get("Idempotency-Key") != ""
+	}
+}
+
+// delay returns the current hedge delay
+func (t *hedgeTransport) delay() time.Duration {
+	if t.policy.Delay > 0 {
+		return t.policy.Delay
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.latencies) < hedgeSamples/4 {
+		return t.policy.MinDelay
+	}
+
+	sorted := slices.Clone(t.latencies)
+	slices.Sort(sorted)
+
+	return max(sorted[len(sorted)*95/100], t.policy.MinDelay)
+}
+
+// observe records the latency of a completed request
+func (t *hedgeTransport) observe(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.latencies) < hedgeSamples {
+		t.latencies = append(t.latencies, latency)
+		return
+	}
+
+	t.latencies[t.cursor] = latency
+	t.cursor = (t.cursor + 1) % hedgeSamples
+}
+
+// attempt sends a single attempt of a request
+func (t *hedgeTransport) attempt(ctx context.Context, req *http.Request, hedge bool, results chan<- hedgeResult) {
+	attempt := req.Clone(ctx)
+
+	if hedge && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			results <- hedgeResult{err: err, hedge: hedge}
+			return
+		}
+
+		attempt.Body = body
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(attempt)
+	results <- hedgeResult{resp: resp, err: err, hedge: hedge, elapsed: time.Since(start)}
+}
+
+// RoundTrip sends the request and hedges it if it doesn't complete within the hedge delay
+func (t *hedgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !idempotent(req) {
+		return t.next.RoundTrip(req) //nolint:wrapcheck
+	}
+
+	results := make(chan hedgeResult, 2)
+
+	primaryCtx, cancelPrimary := context.WithCancel(req.Context())
+	hedgeCtx, cancelHedge := context.WithCancel(req.Context())
+	pending := 1
+
+	go t.attempt(primaryCtx, req, false, results)
+
+	timer := time.NewTimer(t.delay())
+	defer timer.Stop()
+
+	var result hedgeResult
+
+	select {
+	case result = <-results:
+	case <-timer.C:
+		select {
+		case t.slots <- struct{}{}:
+			pending++
+
+			if t.hedged != nil {
+				t.hedged.WithLabelValues(t.client).Inc()
+			}
+
+			go func() {
+				defer func() { <-t.slots }()
+
+				t.attempt(hedgeCtx, req, true, results)
+			}()
+		default:
+			// Too many hedge attempts in flight, wait for the first attempt
+		}
+
+		result = <-results
+	}
+
+	pending--
+
+	// Prefer a successful attempt over a failed one
+	if result.err != nil && pending > 0 {
+		result = <-results
+		pending--
+	}
+
+	winner, loser := cancelPrimary, cancelHedge
+	if result.hedge {
+		winner, loser = cancelHedge, cancelPrimary
+	}
+
+	loser()
+
+	if pending > 0 {
+		go discardHedgeLoser(results)
+	}
+
+	if result.err != nil {
+		winner()
+		return nil, result.err
+	}
+
+	t.observe(result.elapsed)
+
+	if result.hedge && t.won != nil {
+		t.won.WithLabelValues(t.client).Inc()
+	}
+
+	result.resp.Body = &cancelBody{ReadCloser: result.resp.Body, cancel: winner}
+
+	return result.resp, nil
+}
+
+// discardHedgeLoser releases the response of the canceled attempt
+func discardHedgeLoser(results <-chan hedgeResult) {
+	result := <-results
+	if result.resp != nil {
+		_ = result.resp.Body.Close()
+	}
+}
+
+// cancelBody cancels the context of an attempt once its response body is closed
+type cancelBody struct {
+	io.ReadCloser
+
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the attempt's context
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err //nolint:wrapcheck
+}
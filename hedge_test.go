@@ -0,0 +1,97 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewClient_Hedge(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt hangs until it is canceled
+		if attempts.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+
+		_, _ = w.Write([]byte("hedged"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	svc := New("hedge_test", nil)
+	client := svc.NewClient(ClientConfig{
+		Name:  "upstream",
+		Hedge: &HedgePolicy{Delay: 20 * time.Millisecond},
+	})
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hedged" {
+		t.Errorf("expected response of the hedge attempt, got %q", body)
+	}
+
+	hedged := svc.Metrics.builtinCounterVec("client_hedged_requests_total", "", "client")
+	won := svc.Metrics.builtinCounterVec("client_hedge_wins_total", "", "client")
+
+	if testutil.ToFloat64(hedged.WithLabelValues("upstream")) != 1 || testutil.ToFloat64(won.WithLabelValues("upstream")) != 1 {
+		t.Error("expected hedge metrics to record one hedged and won request")
+	}
+}
+
+func TestNewClient_HedgeNotIdempotent(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(upstream.Close)
+
+	client := NewClient(nil, ClientConfig{Hedge: &HedgePolicy{Delay: time.Millisecond}})
+
+	resp, err := client.Post(upstream.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if count := attempts.Load(); count != 1 {
+		t.Errorf("expected POST requests not to be hedged, got %d attempts", count)
+	}
+}
+
+func TestHedgeTransport_Delay(t *testing.T) {
+	t.Parallel()
+
+	transport := newHedgeTransport(http.DefaultTransport, HedgePolicy{MinDelay: 5 * time.Millisecond}, "test", nil)
+
+	if delay := transport.delay(); delay != 5*time.Millisecond {
+		t.Errorf("expected min delay without samples, got %v", delay)
+	}
+
+	for i := range hedgeSamples {
+		transport.observe(time.Duration(i+1) * time.Millisecond)
+	}
+
+	if delay := transport.delay(); delay != 122*time.Millisecond {
+		t.Errorf("expected p95 delay of 122ms, got %v", delay)
+	}
+}
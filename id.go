@@ -0,0 +1,166 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnknownIDFormat is returned when creating an ID generator with an unknown format
+var ErrUnknownIDFormat = NewError(CodeInvalidArgument, "unknown ID format")
+
+// IDFormat is the format of generated IDs
+type IDFormat string
+
+// ID formats
+const (
+	// IDFormatUUIDv7 generates time-ordered UUIDs (RFC 9562), e.g. 01928c4e-7a3b-7c12-9f3e-5b2a1c0d4e6f
+	IDFormatUUIDv7 IDFormat = "uuidv7"
+	// IDFormatULID generates ULIDs, e.g. 01J4Z3M8X5K7Q2W9E6R1T0Y3U8
+	IDFormatULID IDFormat = "ulid"
+)
+
+// ulidAlphabet is the Crockford base32 alphabet of ULIDs
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// IDGenerator generates IDs that start with a millisecond timestamp and are monotonic: IDs generated within the
+// same millisecond (or while the clock goes backwards) increment the random part of the previous ID, so IDs of a
+// process sort by generation order, as strings and as bytes. It is safe for concurrent use.
+type IDGenerator struct {
+	format IDFormat
+	now    func() time.Time
+
+	mu sync.Mutex
+	// lastMillis is the timestamp of the last ID, randHigh and randLow its 80 random bits
+	lastMillis uint64
+	randHigh   uint32
+	randLow    uint64
+}
+
+// NewIDGenerator creates an ID generator for the format
+func NewIDGenerator(format IDFormat) (*IDGenerator, error) {
+	if format != IDFormatUUIDv7 && format != IDFormatULID {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIDFormat, format)
+	}
+
+	return &IDGenerator{format: format, now: time.Now}, nil
+}
+
+// Format returns the format of the generated IDs
+func (g *IDGenerator) Format() IDFormat {
+	return g.format
+}
+
+// NewID generates a new ID
+func (g *IDGenerator) NewID() string {
+	millis, high, low := g.next()
+
+	if g.format == IDFormatULID {
+		return encodeULID(millis, high, low)
+	}
+
+	return encodeUUIDv7(millis, high, low)
+}
+
+// randHighMax is the largest high part of the random bits: UUIDv7 has 74 random bits (10 high bits), ULID has 80
+func (g *IDGenerator) randHighMax() uint32 {
+	if g.format == IDFormatULID {
+		return 1<<16 - 1
+	}
+
+	return 1<<10 - 1
+}
+
+// next returns the timestamp and random bits of the next ID
+func (g *IDGenerator) next() (uint64, uint32, uint64) {
+	millis := uint64(g.now().UnixMilli()) //nolint:gosec
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if millis <= g.lastMillis {
+		// Same millisecond or the clock went backwards: increment the previous ID
+		g.randLow++
+		if g.randLow == 0 {
+			g.randHigh++
+		}
+
+		if g.randHigh <= g.randHighMax() {
+			return g.lastMillis, g.randHigh, g.randLow
+		}
+
+		// The random bits overflowed, continue in the next millisecond
+		millis = g.lastMillis + 1
+	}
+
+	var random [10]byte
+	_, _ = rand.Read(random[:])
+
+	g.lastMillis = millis
+	g.randHigh = uint32(binary.BigEndian.Uint16(random[:2])) & g.randHighMax()
+	g.randLow = binary.BigEndian.Uint64(random[2:])
+
+	return g.lastMillis, g.randHigh, g.randLow
+}
+
+// encodeUUIDv7 encodes a UUIDv7 with the 48 bit timestamp, the version, 12 bits rand_a, the variant, and 62 bits rand_b
+func encodeUUIDv7(millis uint64, high uint32, low uint64) string {
+	var id [16]byte
+
+	binary.BigEndian.PutUint64(id[:8], millis<<16)
+
+	randA := uint16(high<<2) | uint16(low>>62) //nolint:gosec
+	binary.BigEndian.PutUint16(id[6:8], 0x7000|randA)
+	binary.BigEndian.PutUint64(id[8:], 0x8000000000000000|low&(1<<62-1))
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+
+	return string(buf)
+}
+
+// encodeULID encodes a ULID with the 48 bit timestamp and 80 random bits in Crockford base32
+func encodeULID(millis uint64, high uint32, low uint64) string {
+	upper := millis<<16 | uint64(high)
+
+	buf := make([]byte, 26)
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = ulidAlphabet[low&31]
+		low = low>>5 | upper<<59
+		upper >>= 5
+	}
+
+	return string(buf)
+}
+
+// defaultIDGenerator generates the IDs of NewID
+var defaultIDGenerator atomic.Pointer[IDGenerator]
+
+func init() {
+	generator, _ := NewIDGenerator(IDFormatUUIDv7)
+	defaultIDGenerator.Store(generator)
+}
+
+// NewID generates a time-ordered, monotonic ID with the default generator (UUIDv7 unless changed with ID_FORMAT or
+// SetIDGenerator). It is used for request IDs, idempotency keys, and audit records, so IDs across the framework sort
+// by time in logs and databases.
+func NewID() string {
+	return defaultIDGenerator.Load().NewID()
+}
+
+// SetIDGenerator replaces the default generator of NewID
+func SetIDGenerator(generator *IDGenerator) {
+	defaultIDGenerator.Store(generator)
+}
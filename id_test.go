@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format  IDFormat
+		pattern *regexp.Regexp
+	}{
+		{IDFormatUUIDv7, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{IDFormatULID, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			t.Parallel()
+
+			generator, err := NewIDGenerator(tt.format)
+			if err != nil {
+				t.Fatalf("failed to create generator: %v", err)
+			}
+
+			// A fixed clock forces the monotonic increment within one millisecond
+			now := time.Now()
+			generator.now = func() time.Time { return now }
+
+			ids := make([]string, 1000)
+			for i := range ids {
+				ids[i] = generator.NewID()
+
+				if !tt.pattern.MatchString(ids[i]) {
+					t.Fatalf("invalid ID %q", ids[i])
+				}
+			}
+
+			if !sort.StringsAreSorted(ids) {
+				t.Error("expected IDs within the same millisecond to be sorted")
+			}
+
+			now = now.Add(-time.Second)
+
+			if id := generator.NewID(); id <= ids[len(ids)-1] {
+				t.Errorf("expected IDs to stay monotonic when the clock goes backwards, got %q after %q", id, ids[len(ids)-1])
+			}
+		})
+	}
+}
+
+func TestIDGeneratorOverflow(t *testing.T) {
+	t.Parallel()
+
+	generator, _ := NewIDGenerator(IDFormatUUIDv7)
+
+	now := time.UnixMilli(1700000000000)
+	generator.now = func() time.Time { return now }
+
+	first := generator.NewID()
+
+	generator.randHigh, generator.randLow = generator.randHighMax(), 1<<64-1
+
+	if id := generator.NewID(); id <= first || generator.lastMillis != uint64(now.UnixMilli())+1 {
+		t.Errorf("expected an overflow to continue in the next millisecond, got %q", id)
+	}
+}
+
+func TestIDTimestamp(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(0x0123456789ab)
+
+	uuid, _ := NewIDGenerator(IDFormatUUIDv7)
+	uuid.now = func() time.Time { return now }
+
+	if id := uuid.NewID(); id[:13] != "01234567-89ab" {
+		t.Errorf("expected the timestamp in the first 48 bits, got %q", id)
+	}
+
+	ulid, _ := NewIDGenerator(IDFormatULID)
+	ulid.now = func() time.Time { return time.UnixMilli(1469918176385) }
+
+	// Timestamp of the ULID specification example
+	if id := ulid.NewID(); id[:10] != "01ARYZ6S41" {
+		t.Errorf("expected the encoded timestamp in the first 10 characters, got %q", id)
+	}
+}
+
+func TestNewIDGeneratorUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewIDGenerator("snowflake"); !errors.Is(err, ErrUnknownIDFormat) {
+		t.Errorf("expected ErrUnknownIDFormat, got %v", err)
+	}
+
+	if NewID() == NewID() {
+		t.Error("expected unique IDs")
+	}
+}
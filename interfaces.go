@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hellofresh/health-go/v5"
+)
+
+// MetricsRecorder registers and records custom metrics. It is implemented by Service and MetricsCollector,
+// so libraries can accept it instead of *Service and tests can pass a fake.
+type MetricsRecorder interface {
+	RegisterCounter(config MetricConfig) error
+	RegisterGauge(config MetricConfig) error
+	RegisterHistogram(config MetricConfig) error
+	RegisterSummary(config MetricConfig) error
+
+	IncCounter(name string, labels ...string) error
+	AddCounter(name string, value float64, labels ...string) error
+	SetGauge(name string, value float64, labels ...string) error
+	IncGauge(name string, labels ...string) error
+	DecGauge(name string, labels ...string) error
+	AddGauge(name string, value float64, labels ...string) error
+	ObserveHistogram(name string, value float64, labels ...string) error
+	ObserveSummary(name string, value float64, labels ...string) error
+}
+
+// HealthRegistrar registers health checks. It is implemented by Service.
+type HealthRegistrar interface {
+	RegisterHealthCheck(config health.Config) (*HealthCheckHandle, error)
+	AddHealthCheck(check HealthCheck) (*HealthCheckHandle, error)
+}
+
+// LifecycleHooks ties resources to the lifecycle of a service. It is implemented by Service.
+type LifecycleHooks interface {
+	// Context is canceled when the graceful shutdown starts
+	Context() context.Context
+	AddShutdownHook(hook ShutdownHook, opts ...ShutdownHookOption)
+	AddComponent(component Component)
+	Go(name string, fn func(ctx context.Context) error, opts ...TaskOption)
+}
+
+var (
+	_ MetricsRecorder = (*Service)(nil)
+	_ MetricsRecorder = (*MetricsCollector)(nil)
+	_ HealthRegistrar = (*Service)(nil)
+	_ LifecycleHooks  = (*Service)(nil)
+)
+
+// IncCounter increments a counter metric
+func (s *Service) IncCounter(name string, labels ...string) error {
+	return s.Metrics.IncCounter(name, labels...)
+}
+
+// AddCounter adds a value to a counter metric
+func (s *Service) AddCounter(name string, value float64, labels ...string) error {
+	return s.Metrics.AddCounter(name, value, labels...)
+}
+
+// SetGauge sets a gauge metric value
+func (s *Service) SetGauge(name string, value float64, labels ...string) error {
+	return s.Metrics.SetGauge(name, value, labels...)
+}
+
+// IncGauge increments a gauge metric
+func (s *Service) IncGauge(name string, labels ...string) error {
+	return s.Metrics.IncGauge(name, labels...)
+}
+
+// DecGauge decrements a gauge metric
+func (s *Service) DecGauge(name string, labels ...string) error {
+	return s.Metrics.DecGauge(name, labels...)
+}
+
+// AddGauge adds a value to a gauge metric
+func (s *Service) AddGauge(name string, value float64, labels ...string) error {
+	return s.Metrics.AddGauge(name, value, labels...)
+}
+
+// ObserveHistogram observes a value in a histogram metric
+func (s *Service) ObserveHistogram(name string, value float64, labels ...string) error {
+	return s.Metrics.ObserveHistogram(name, value, labels...)
+}
+
+// ObserveSummary observes a value in a summary metric
+func (s *Service) ObserveSummary(name string, value float64, labels ...string) error {
+	return s.Metrics.ObserveSummary(name, value, labels...)
+}
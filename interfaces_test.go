@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// instrumentCache is a library function that only depends on the interfaces
+func instrumentCache(recorder MetricsRecorder, registrar HealthRegistrar, lifecycle LifecycleHooks) error {
+	if err := recorder.RegisterCounter(MetricConfig{Name: "cache_hits_total", Help: "Cache hits"}); err != nil {
+		return err
+	}
+
+	if _, err := registrar.AddHealthCheck(HealthCheck{Name: "cache", Check: func(context.Context) error { return nil }}); err != nil {
+		return err
+	}
+
+	lifecycle.AddShutdownHook(func(context.Context) error { return nil })
+
+	return recorder.IncCounter("cache_hits_total")
+}
+
+func TestInterfaces(t *testing.T) {
+	t.Parallel()
+
+	svc := New("interfaces_test", nil)
+
+	if err := instrumentCache(svc, svc, svc); err != nil {
+		t.Fatalf("failed to instrument: %v", err)
+	}
+
+	if got := testutil.ToFloat64(svc.Metrics.counters["interfaces_test_cache_hits_total"]); got != 1 {
+		t.Errorf("expected the counter to be incremented through the interface, got %v", got)
+	}
+
+	if len(svc.Config.ShutdownHooks) != 1 {
+		t.Error("expected the shutdown hook to be added through the interface")
+	}
+}
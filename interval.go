@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Every runs a function periodically in the background until the service shuts down.
+// Runs never overlap: if a run takes longer than the interval, the missed runs are skipped.
+// The last run and the next scheduled run are listed in the admin endpoint (ADMIN_PATH/jobs), which also triggers
+// manual runs (see RunJob). With a job state store, the schedule continues across restarts and runs missed while
+// the service was down are handled by the catch-up policy (see WithCatchUp).
+// Panics are recovered, errors are logged, and runs are recorded in {service_name}_task_runs_total{task,result}
+// and {service_name}_task_duration_seconds{task}.
+func (s *Service) Every(name string, interval time.Duration, fn func(ctx context.Context) error, opts ...TaskOption) {
+	config := newTaskConfig(opts)
+
+	go s.runInterval(s.taskContext(name), s.jobs.add(name, interval), interval, fn, config)
+}
+
+// runInterval runs an interval task until its context is canceled
+func (s *Service) runInterval(ctx context.Context, job *job, interval time.Duration, fn func(ctx context.Context) error, config *taskConfig) {
+	name := job.name
+	logger := LoggerFromContext(ctx)
+	runs := s.Metrics.builtinCounterVec("task_runs_total", "Total number of interval task runs by result", "task", "result")
+	duration := s.Metrics.builtinHistogramVec("task_duration_seconds", "Duration of interval task runs in seconds",
+		[]float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300}, "task")
+	skipped := s.Metrics.builtinCounterVec("task_skipped_runs_total",
+		"Total number of interval task runs skipped because the previous run was still running", "task")
+
+	defer s.jobs.remove(job)
+
+	next := s.firstRun(ctx, name, interval, config)
+
+	for {
+		wait := time.Until(next)
+		if config.jitter > 0 {
+			wait += time.Duration(rand.Float64() * config.jitter * float64(interval)) //nolint:gosec
+		}
+
+		job.schedule(time.Now().Add(wait))
+
+		timer := time.NewTimer(wait)
+		manual := false
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-job.trigger:
+			// A manual run doesn't change the schedule
+			timer.Stop()
+
+			manual = true
+		}
+
+		job.start()
+
+		start := time.Now()
+		err := s.runTask(ctx, name, fn)
+		elapsed := time.Since(start)
+
+		job.finish(start, elapsed, err)
+		s.recordRun(ctx, name, start)
+
+		duration.WithLabelValues(name).Observe(elapsed.Seconds())
+
+		result := "success"
+		if err != nil {
+			result = "failure"
+
+			if ctx.Err() == nil {
+				logger.Error("interval task failed", "error", err, "duration", elapsed)
+			}
+		}
+
+		runs.WithLabelValues(name, result).Inc()
+
+		// Schedule the next run on the interval grid, skipping runs that would overlap
+		if !manual {
+			next = next.Add(interval)
+		}
+
+		if missed := time.Since(next); missed > 0 {
+			count := int64(missed/interval) + 1
+			next = next.Add(time.Duration(count) * interval)
+
+			skipped.WithLabelValues(name).Add(float64(count))
+			logger.Warn("interval task took longer than its interval, skipping runs", "duration", elapsed, "skipped", count)
+		}
+	}
+}
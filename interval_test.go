@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEvery(t *testing.T) {
+	t.Parallel()
+
+	svc := New("every_test", nil)
+
+	var (
+		runs    atomic.Int32
+		running atomic.Int32
+		overlap atomic.Bool
+	)
+
+	svc.Every("sync", 5*time.Millisecond, func(context.Context) error {
+		if running.Add(1) > 1 {
+			overlap.Store(true)
+		}
+		defer running.Add(-1)
+
+		count := runs.Add(1)
+		if count == 1 {
+			// Take longer than the interval
+			time.Sleep(12 * time.Millisecond)
+		}
+
+		if count == 2 {
+			return errors.New("sync failed") //nolint:err113
+		}
+
+		if count == 3 {
+			panic("boom")
+		}
+
+		return nil
+	}, WithJitter(0.1))
+
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+
+	if runs.Load() != stopped {
+		t.Error("expected the task to stop on shutdown")
+	}
+
+	if overlap.Load() {
+		t.Error("expected runs not to overlap")
+	}
+
+	results := svc.Metrics.builtinCounterVec("task_runs_total", "", "task", "result")
+	if got := testutil.ToFloat64(results.WithLabelValues("sync", "failure")); got != 2 {
+		t.Errorf("expected 2 failed runs (error and panic), got %v", got)
+	}
+
+	skipped := svc.Metrics.builtinCounterVec("task_skipped_runs_total", "", "task")
+	if got := testutil.ToFloat64(skipped.WithLabelValues("sync")); got < 1 {
+		t.Errorf("expected skipped runs after the slow run, got %v", got)
+	}
+}
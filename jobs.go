@@ -0,0 +1,213 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownJob is returned when triggering a job that doesn't exist
+	ErrUnknownJob = NewError(CodeNotFound, "unknown job")
+	// ErrJobRunning is returned when triggering a job that is already running or triggered
+	ErrJobRunning = NewError(CodeAborted, "job already running")
+)
+
+// job is the status of an interval task (see Service.Every)
+type job struct {
+	name     string
+	interval time.Duration
+	// trigger requests a manual run
+	trigger chan struct{}
+
+	mu           sync.Mutex
+	running      bool
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastResult   string
+	lastError    string
+	nextRun      time.Time
+}
+
+// jobInfo describes a job in the admin endpoint
+type jobInfo struct {
+	Name         string     `json:"name"`
+	Interval     string     `json:"interval"`
+	Running      bool       `json:"running"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastResult   string     `json:"last_result,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	NextRun      time.Time  `json:"next_run"`
+}
+
+// jobs is the registry of the interval tasks of a service
+type jobs struct {
+	mu   sync.Mutex
+	list []*job
+}
+
+// add registers the job of an interval task
+func (j *jobs) add(name string, interval time.Duration) *job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	added := &job{name: name, interval: interval, trigger: make(chan struct{}, 1)}
+	j.list = append(j.list, added)
+
+	return added
+}
+
+// remove unregisters the job of an interval task once it stopped
+func (j *jobs) remove(stopped *job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.list = slices.DeleteFunc(j.list, func(other *job) bool { return other == stopped })
+}
+
+// snapshot returns the jobs sorted by name
+func (j *jobs) snapshot() []*job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return slices.SortedStableFunc(slices.Values(j.list), func(a, b *job) int {
+		return strings.Compare(a.name, b.name)
+	})
+}
+
+// schedule records the time of the next scheduled run
+func (j *job) schedule(next time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.nextRun = next
+}
+
+// start marks the job as running
+func (j *job) start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.running = true
+}
+
+// finish records the result of a run
+func (j *job) finish(start time.Time, duration time.Duration, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.running = false
+	j.lastRun = start
+	j.lastDuration = duration
+	j.lastResult = "success"
+	j.lastError = ""
+
+	if err != nil {
+		j.lastResult = "failure"
+		j.lastError = err.Error()
+	}
+}
+
+// run requests a manual run, which fails if the job is running or a manual run is pending
+func (j *job) run() error {
+	j.mu.Lock()
+	running := j.running
+	j.mu.Unlock()
+
+	if running {
+		return fmt.Errorf("%w: %s", ErrJobRunning, j.name)
+	}
+
+	select {
+	case j.trigger <- struct{}{}:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrJobRunning, j.name)
+	}
+}
+
+// info returns the admin view of the job
+func (j *job) info() jobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := jobInfo{
+		Name:       j.name,
+		Interval:   j.interval.String(),
+		Running:    j.running,
+		LastResult: j.lastResult,
+		LastError:  j.lastError,
+		NextRun:    j.nextRun,
+	}
+
+	if !j.lastRun.IsZero() {
+		lastRun := j.lastRun
+		info.LastRun = &lastRun
+		info.LastDuration = j.lastDuration.String()
+	}
+
+	return info
+}
+
+// RunJob triggers a manual run of the interval task with the name, without changing its schedule
+func (s *Service) RunJob(name string) error {
+	triggered := false
+
+	for _, job := range s.jobs.snapshot() {
+		if job.name != name {
+			continue
+		}
+
+		if err := job.run(); err != nil {
+			return err
+		}
+
+		triggered = true
+	}
+
+	if !triggered {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+
+	return nil
+}
+
+// jobsHandler lists the interval tasks and triggers a manual run of a task (POST ?name=...)
+func (s *Service) jobsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			name := r.URL.Query().Get("name")
+			if err := s.RunJob(name); err != nil {
+				http.Error(w, err.Error(), HTTPStatus(err))
+				return
+			}
+
+			s.Logger.Info("job triggered via admin API", "job", name, "client_ip", clientIP(r))
+
+			status = http.StatusAccepted
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		infos := []jobInfo{}
+		for _, job := range s.jobs.snapshot() {
+			infos = append(infos, job.info())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"jobs": infos})
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobs(t *testing.T) {
+	t.Parallel()
+
+	svc := New("jobs_test", nil)
+
+	var runs atomic.Int32
+
+	release := make(chan struct{})
+
+	svc.Every("report", time.Hour, func(context.Context) error {
+		if runs.Add(1) == 2 {
+			<-release
+			return errors.New("report failed") //nolint:err113
+		}
+
+		return nil
+	})
+
+	defer func() {
+		if err := svc.Stop(); err != nil {
+			t.Errorf("failed to stop service: %v", err)
+		}
+	}()
+
+	handler := svc.jobsHandler()
+
+	list := func() []jobInfo {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/admin/jobs", nil))
+
+		var body struct {
+			Jobs []jobInfo `json:"jobs"`
+		}
+
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode jobs: %v", err)
+		}
+
+		return body.Jobs
+	}
+
+	waitFor := func(condition func() bool) {
+		t.Helper()
+
+		deadline := time.Now().Add(time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the job")
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitFor(func() bool { jobs := list(); return len(jobs) == 1 && !jobs[0].NextRun.IsZero() })
+
+	jobs := list()
+	if jobs[0].Name != "report" || jobs[0].Interval != "1h0m0s" || jobs[0].LastRun != nil {
+		t.Errorf("unexpected job before the first run: %+v", jobs[0])
+	}
+
+	next := jobs[0].NextRun
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/admin/jobs?name=report", nil))
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for a manual run, got %d", rec.Code)
+	}
+
+	waitFor(func() bool { return list()[0].LastResult == "success" })
+
+	if jobs := list(); jobs[0].LastRun == nil || jobs[0].NextRun.Sub(next).Abs() > time.Second {
+		t.Errorf("expected a manual run to keep the schedule, got %+v", jobs[0])
+	}
+
+	if err := svc.RunJob("report"); err != nil {
+		t.Fatalf("failed to run job: %v", err)
+	}
+
+	waitFor(func() bool { return list()[0].Running })
+
+	if err := svc.RunJob("report"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("expected ErrJobRunning for a running job, got %v", err)
+	}
+
+	close(release)
+	waitFor(func() bool { return list()[0].LastResult == "failure" })
+
+	if jobs := list(); jobs[0].LastError != "report failed" {
+		t.Errorf("expected the error of the last run, got %q", jobs[0].LastError)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/admin/jobs?name=missing", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown job, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodDelete, "/admin/jobs", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CatchUpPolicy decides what an interval task does on startup when it missed runs while the service was down
+type CatchUpPolicy string
+
+// Catch-up policies of interval tasks (see WithCatchUp)
+const (
+	// CatchUpSkip logs the missed runs and continues with the next run on the schedule
+	CatchUpSkip CatchUpPolicy = "skip"
+	// CatchUpRunOnce runs the task once right after startup, however many runs were missed
+	CatchUpRunOnce CatchUpPolicy = "run_once"
+)
+
+// WithCatchUp sets the catch-up policy of an interval task for runs missed while the service was down, e.g. during
+// a deploy. Missed runs are only detected with a job state store (see Config.JobStateStore). Defaults to CatchUpSkip.
+func WithCatchUp(policy CatchUpPolicy) TaskOption {
+	return func(config *taskConfig) {
+		config.catchUp = policy
+	}
+}
+
+// JobStateStore persists the last runs of interval tasks across restarts. Use a shared store (e.g. a database)
+// when the tasks run on multiple replicas.
+type JobStateStore interface {
+	// LastRun returns the start of the last run of a task, or the zero time if it never ran
+	LastRun(ctx context.Context, name string) (time.Time, error)
+	// SetLastRun records the start of the last run of a task
+	SetLastRun(ctx context.Context, name string, start time.Time) error
+}
+
+// FileJobStateStore is a JobStateStore backed by a JSON file, which is replaced atomically on every run
+type FileJobStateStore struct {
+	path string
+
+	mu      sync.Mutex
+	loaded  bool
+	lastRun map[string]time.Time
+}
+
+// NewFileJobStateStore creates a job state store backed by a JSON file. The file is created on the first run.
+func NewFileJobStateStore(path string) *FileJobStateStore {
+	return &FileJobStateStore{path: path, lastRun: make(map[string]time.Time)}
+}
+
+// LastRun returns the start of the last run of a task, or the zero time if it never ran
+func (f *FileJobStateStore) LastRun(_ context.Context, name string) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return time.Time{}, err
+	}
+
+	return f.lastRun[name], nil
+}
+
+// SetLastRun records the start of the last run of a task
+func (f *FileJobStateStore) SetLastRun(_ context.Context, name string, start time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return err
+	}
+
+	f.lastRun[name] = start
+
+	data, err := json.MarshalIndent(f.lastRun, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job state: %w", err)
+	}
+
+	tmp := filepath.Join(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp")
+
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write job state: %w", err)
+	}
+
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to write job state: %w", err)
+	}
+
+	return nil
+}
+
+// load reads the file once. It must be called with the lock held.
+func (f *FileJobStateStore) load() error {
+	if f.loaded {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read job state: %w", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &f.lastRun); err != nil {
+			return fmt.Errorf("failed to decode job state %s: %w", f.path, err)
+		}
+	}
+
+	f.loaded = true
+
+	return nil
+}
+
+// firstRun returns the time of the first run of an interval task. With a job state store, it continues the schedule
+// of the last run before the restart and applies the catch-up policy if runs were missed.
+func (s *Service) firstRun(ctx context.Context, name string, interval time.Duration, config *taskConfig) time.Time {
+	now := time.Now()
+
+	if s.jobState == nil {
+		return now.Add(interval)
+	}
+
+	logger := LoggerFromContext(ctx)
+
+	last, err := s.jobState.LastRun(ctx, name)
+	if err != nil {
+		logger.Error("failed to load interval task state", "error", err)
+		return now.Add(interval)
+	}
+
+	if last.IsZero() {
+		return now.Add(interval)
+	}
+
+	next := last.Add(interval)
+	if next.After(now) {
+		return next
+	}
+
+	missed := int64(now.Sub(next)/interval) + 1
+
+	s.Metrics.builtinCounterVec("task_missed_runs_total",
+		"Total number of interval task runs missed while the service was down", "task").
+		WithLabelValues(name).Add(float64(missed))
+	logger.Warn("interval task missed runs while the service was down",
+		"missed", missed, "last_run", last, "catch_up", config.catchUp)
+
+	if config.catchUp == CatchUpRunOnce {
+		return now
+	}
+
+	return next.Add(time.Duration(missed) * interval)
+}
+
+// recordRun persists the start of a run of an interval task, if a job state store is configured
+func (s *Service) recordRun(ctx context.Context, name string, start time.Time) {
+	if s.jobState == nil {
+		return
+	}
+
+	// The run is recorded even if it was interrupted by the shutdown
+	if err := s.jobState.SetLastRun(context.WithoutCancel(ctx), name, start); err != nil {
+		LoggerFromContext(ctx).Error("failed to save interval task state", "error", err)
+	}
+}
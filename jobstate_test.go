@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFileJobStateStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	if last, err := NewFileJobStateStore(path).LastRun(t.Context(), "report"); err != nil || !last.IsZero() {
+		t.Fatalf("expected no last run without a file, got %v, %v", last, err)
+	}
+
+	if err := NewFileJobStateStore(path).SetLastRun(t.Context(), "report", start); err != nil {
+		t.Fatalf("failed to save last run: %v", err)
+	}
+
+	last, err := NewFileJobStateStore(path).LastRun(t.Context(), "report")
+	if err != nil || !last.Equal(start) {
+		t.Errorf("expected the last run to survive a restart, got %v, %v", last, err)
+	}
+}
+
+func TestEvery_CatchUp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		lastRun   time.Duration
+		policy    CatchUpPolicy
+		runs      bool
+		missed    float64
+		nextRunIn time.Duration
+	}{
+		{"run once", 3 * time.Hour, CatchUpRunOnce, true, 3, 0},
+		{"skip", 3*time.Hour + 10*time.Minute, CatchUpSkip, false, 3, 50 * time.Minute},
+		{"no missed runs", 10 * time.Minute, CatchUpRunOnce, false, 0, 50 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := NewFileJobStateStore(filepath.Join(t.TempDir(), "jobs.json"))
+			last := time.Now().Add(-tt.lastRun)
+
+			if err := store.SetLastRun(t.Context(), "report", last); err != nil {
+				t.Fatalf("failed to save last run: %v", err)
+			}
+
+			config := DefaultConfig()
+			config.JobStateStore = store
+
+			svc := New("jobstate_test", config)
+
+			var runs atomic.Int32
+
+			svc.Every("report", time.Hour, func(context.Context) error {
+				runs.Add(1)
+				return nil
+			}, WithCatchUp(tt.policy))
+
+			defer func() {
+				if err := svc.Stop(); err != nil {
+					t.Errorf("failed to stop service: %v", err)
+				}
+			}()
+
+			if tt.runs {
+				deadline := time.Now().Add(time.Second)
+				for runs.Load() == 0 && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+
+				if runs.Load() != 1 {
+					t.Fatal("expected the missed runs to be caught up on startup")
+				}
+
+				deadline = time.Now().Add(time.Second)
+				for time.Now().Before(deadline) {
+					if saved, _ := store.LastRun(t.Context(), "report"); saved.After(last) {
+						break
+					}
+
+					time.Sleep(time.Millisecond)
+				}
+
+				if saved, _ := store.LastRun(t.Context(), "report"); !saved.After(last) {
+					t.Error("expected the run to be persisted")
+				}
+			} else {
+				deadline := time.Now().Add(time.Second)
+				for time.Now().Before(deadline) {
+					if jobs := svc.jobs.snapshot(); len(jobs) == 1 && !jobs[0].info().NextRun.IsZero() {
+						break
+					}
+
+					time.Sleep(time.Millisecond)
+				}
+
+				next := svc.jobs.snapshot()[0].info().NextRun
+				if until := time.Until(next); (until - tt.nextRunIn).Abs() > time.Minute {
+					t.Errorf("expected the next run in %v, got %v", tt.nextRunIn, until)
+				}
+
+				if runs.Load() != 0 {
+					t.Error("expected no run on startup")
+				}
+			}
+
+			missed := svc.Metrics.builtinCounterVec("task_missed_runs_total", "", "task")
+			if got := testutil.ToFloat64(missed.WithLabelValues("report")); got != tt.missed {
+				t.Errorf("expected %v missed runs, got %v", tt.missed, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signJWT creates a compact serialized JWT for tests
+func signJWT(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	encode := func(value any) string {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("failed to encode token: %v", err)
+		}
+
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var (
+		signature []byte
+		err       error
+	)
+
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	}
+
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthMiddleware(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	valid := map[string]any{"sub": "user-1", "iss": "https://issuer", "aud": []string{"api"}, "exp": time.Now().Add(time.Hour).Unix()}
+
+	with := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{}
+		for name, value := range valid {
+			claims[name] = value
+		}
+
+		for name, value := range overrides {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+
+		return claims
+	}
+
+	handler := JWTAuthMiddleware(NewMetricsCollector("jwt_test"), JWTConfig{
+		Key:      secret,
+		Issuer:   "https://issuer",
+		Audience: "api",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(GetClaims(r).Subject()))
+	}))
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{"valid token", signJWT(t, "HS256", "", secret, valid), http.StatusOK},
+		{"missing token", "", http.StatusUnauthorized},
+		{"malformed token", "not-a-jwt", http.StatusUnauthorized},
+		{"wrong secret", signJWT(t, "HS256", "", []byte("other"), valid), http.StatusUnauthorized},
+		{"unsigned token", signJWT(t, "none", "", nil, valid), http.StatusUnauthorized},
+		{"expired token", signJWT(t, "HS256", "", secret, with(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})), http.StatusUnauthorized},
+		{"missing exp", signJWT(t, "HS256", "", secret, with(map[string]any{"exp": nil})), http.StatusUnauthorized},
+		{"not yet valid", signJWT(t, "HS256", "", secret, with(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})), http.StatusUnauthorized},
+		{"wrong issuer", signJWT(t, "HS256", "", secret, with(map[string]any{"iss": "https://other"})), http.StatusUnauthorized},
+		{"wrong audience", signJWT(t, "HS256", "", secret, with(map[string]any{"aud": "other"})), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/api", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rec.Code)
+			}
+
+			if tt.expected == http.StatusOK && rec.Body.String() != "user-1" {
+				t.Errorf("expected the claims in the context, got %q", rec.Body.String())
+			}
+
+			if tt.expected == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a bearer challenge")
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_JWKS(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+
+	fetches := 0
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"jwks_uri": server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		fetches++
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": encode(rsaKey.N), "e": "AQAB"},
+		}})
+	})
+
+	verifier := NewJWTVerifier(nil, JWTConfig{Issuer: server.URL})
+	claims := map[string]any{"sub": "user-1", "iss": server.URL, "exp": time.Now().Add(time.Hour).Unix(), "scope": "read write"}
+
+	for _, token := range []string{signJWT(t, "RS256", "rsa", rsaKey, claims), signJWT(t, "ES256", "ec", ecKey, claims)} {
+		verified, err := verifier.Verify(t.Context(), token)
+		if err != nil {
+			t.Fatalf("failed to verify token: %v", err)
+		}
+
+		if !verified.HasScope("write") || verified.HasScope("admin") {
+			t.Errorf("unexpected scopes %v", verified.Scopes())
+		}
+	}
+
+	if fetches != 1 {
+		t.Errorf("expected the JWKS to be cached, got %d fetches", fetches)
+	}
+
+	// An HMAC token signed with the public key must not verify against the RSA key
+	confused := signJWT(t, "HS256", "rsa", rsaKey.PublicKey.N.Bytes(), claims)
+	if _, err := verifier.Verify(t.Context(), confused); err == nil {
+		t.Error("expected an algorithm mismatch to fail")
+	}
+
+	// Pretend the last fetch was a while ago, so an unknown key refetches the JWKS
+	verifier.attempted = time.Time{}
+
+	if _, err := verifier.Verify(t.Context(), signJWT(t, "RS256", "enc", rsaKey, claims)); err == nil {
+		t.Error("expected encryption keys to be ignored")
+	}
+
+	if fetches != 2 {
+		t.Errorf("expected an unknown key to refetch the JWKS once, got %d fetches", fetches)
+	}
+
+	if _, err := verifier.Verify(t.Context(), signJWT(t, "RS256", "other", rsaKey, claims)); err == nil {
+		t.Error("expected an unknown key to fail")
+	}
+
+	if fetches != 2 {
+		t.Errorf("expected refetches to be rate limited, got %d fetches", fetches)
+	}
+}
+
+func TestJWTVerifier_JWKSRefreshWithoutBlocking(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+
+	var fetches atomic.Int32
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// Every fetch after the first hangs until released
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+		}})
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	verifier := NewJWTVerifier(nil, JWTConfig{JWKSURL: server.URL})
+	claims := map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+	known := signJWT(t, "RS256", "rsa", rsaKey, claims)
+
+	if _, err := verifier.Verify(t.Context(), known); err != nil {
+		t.Fatalf("failed to verify token: %v", err)
+	}
+
+	verifier.mu.Lock()
+	verifier.attempted = time.Time{}
+	verifier.mu.Unlock()
+
+	// The client of the unknown key disconnects while the JWKS is fetched
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := verifier.Verify(ctx, signJWT(t, "RS256", "unknown", rsaKey, claims)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the canceled request to fail, got %v", err)
+	}
+
+	start := time.Now()
+
+	if _, err := verifier.Verify(t.Context(), known); err != nil || time.Since(start) > 40*time.Millisecond {
+		t.Errorf("expected cached keys to verify during the fetch, got %v after %s", err, time.Since(start))
+	}
+
+	verifier.mu.Lock()
+	refreshing := verifier.refresh != nil
+	verifier.mu.Unlock()
+
+	if !refreshing {
+		t.Error("expected the fetch to continue after the client disconnected")
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	svc := New("jwt_scope_test", nil)
+
+	api := svc.Group("/api")
+	api.Use(JWTAuthMiddleware(svc.Metrics, JWTConfig{Key: secret}))
+	api.HandleFunc("GET /profile", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	admin := api.Group("/admin")
+	admin.Use(RequireScope("admin"))
+	admin.HandleFunc("GET /users", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	svc.HandleFunc("GET /public", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	server := svc.TestServer()
+	t.Cleanup(server.Close)
+
+	user := signJWT(t, "HS256", "", secret, map[string]any{"exp": time.Now().Add(time.Hour).Unix(), "scope": "read"})
+	administrator := signJWT(t, "HS256", "", secret, map[string]any{"exp": time.Now().Add(time.Hour).Unix(), "scp": []string{"admin"}})
+
+	tests := []struct {
+		path     string
+		token    string
+		expected int
+	}{
+		{"/public", "", http.StatusNoContent},
+		{"/api/profile", "", http.StatusUnauthorized},
+		{"/api/profile", user, http.StatusNoContent},
+		{"/api/admin/users", user, http.StatusForbidden},
+		{"/api/admin/users", administrator, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.expected, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ErrLoadTestFailed is returned by SelfLoadTest when the service misses the latency or error rate targets
+var ErrLoadTestFailed = NewError(CodeFailedPrecondition, "load test failed")
+
+// LoadTestOptions configures SelfLoadTest
+type LoadTestOptions struct {
+	// Routes are the requests sent round-robin, as "METHOD /path" or "/path" for GET, e.g. "GET /users/42"
+	Routes []string
+	// Concurrency is the number of concurrent clients. Defaults to 10.
+	Concurrency int
+	// Duration is the duration of the load, including the ramp. Defaults to 10s.
+	Duration time.Duration
+	// Ramp is the time over which the clients start evenly, so caches and pools warm up before the full load
+	Ramp time.Duration
+	// Header is sent with every request, e.g. credentials of protected routes
+	Header http.Header
+	// MaxP99 fails the load test if the 99th percentile latency exceeds it. 0 disables the check.
+	MaxP99 time.Duration
+	// MaxErrorRate fails the load test if the share of failed requests (transport errors and 5xx) exceeds it,
+	// e.g. 0.01 for 1%. 0 disables the check.
+	MaxErrorRate float64
+}
+
+// LoadTestLatency holds latency percentiles estimated from the request duration histogram
+type LoadTestLatency struct {
+	Count uint64        `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+}
+
+// LoadTestReport is the result of SelfLoadTest
+type LoadTestReport struct {
+	Requests   int64         `json:"requests"`
+	Errors     int64         `json:"errors"`
+	Duration   time.Duration `json:"duration"`
+	Throughput float64       `json:"throughput"`
+	// Latency covers all requests, Routes the requests by endpoint label (see MetricsMiddlewareWithConfig)
+	Latency LoadTestLatency            `json:"latency"`
+	Routes  map[string]LoadTestLatency `json:"routes"`
+}
+
+// ErrorRate returns the share of failed requests
+func (r *LoadTestReport) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// loadTestTarget is a request of a load test
+type loadTestTarget struct {
+	method string
+	path   string
+}
+
+// SelfLoadTest sends synthetic load to routes of the service through its complete middleware chain on an ephemeral
+// loopback port, and reports the latency percentiles of {service_name}_http_request_duration_seconds over the run,
+// e.g. for canary validation or a performance gate in CI. The histogram includes all requests of the service during
+// the run, so other traffic skews the percentiles. The report is returned with ErrLoadTestFailed if it misses MaxP99
+// or MaxErrorRate. Canceling the context ends the load early.
+func (s *Service) SelfLoadTest(ctx context.Context, opts LoadTestOptions) (*LoadTestReport, error) {
+	if len(opts.Routes) == 0 {
+		return nil, fmt.Errorf("%w: no routes to load", ErrInvalidArgument)
+	}
+
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+
+	if opts.Duration <= 0 {
+		opts.Duration = 10 * time.Second
+	}
+
+	targets := make([]loadTestTarget, len(opts.Routes))
+	for i, route := range opts.Routes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok {
+			method, path = http.MethodGet, route
+		}
+
+		targets[i] = loadTestTarget{method: method, path: strings.TrimSpace(path)}
+	}
+
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: opts.Concurrency}}
+	defer client.CloseIdleConnections()
+
+	logger := s.Logger.With("subsystem", "load_test")
+	logger.Info("running load test", "routes", opts.Routes, "concurrency", opts.Concurrency, "duration", opts.Duration)
+
+	before, err := s.Metrics.requestDurations()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLoadTestFailed, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	var (
+		requests, failed atomic.Int64
+		wg               sync.WaitGroup
+	)
+
+	start := time.Now()
+
+	for worker := range opts.Concurrency {
+		delay := opts.Ramp * time.Duration(worker) / time.Duration(opts.Concurrency)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			for i := worker; ctx.Err() == nil; i++ {
+				target := targets[i%len(targets)]
+
+				status, err := loadTestRequest(ctx, client, server.URL, target, opts.Header)
+				if ctx.Err() != nil {
+					// Requests canceled at the end of the run don't count
+					return
+				}
+
+				requests.Add(1)
+
+				if err != nil || status >= http.StatusInternalServerError {
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	elapsed := time.Since(start)
+
+	after, err := s.Metrics.requestDurations()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLoadTestFailed, err)
+	}
+
+	report := &LoadTestReport{
+		Requests:   requests.Load(),
+		Errors:     failed.Load(),
+		Duration:   elapsed,
+		Throughput: float64(requests.Load()) / elapsed.Seconds(),
+	}
+
+	report.Latency, report.Routes = latencyDelta(before, after)
+
+	logger.Info("load test finished", "requests", report.Requests, "errors", report.Errors,
+		"throughput", report.Throughput, "p50", report.Latency.P50, "p90", report.Latency.P90, "p99", report.Latency.P99)
+
+	var failures []string
+
+	if opts.MaxP99 > 0 && report.Latency.P99 > opts.MaxP99 {
+		failures = append(failures, fmt.Sprintf("p99 %s exceeds %s", report.Latency.P99, opts.MaxP99))
+	}
+
+	if opts.MaxErrorRate > 0 && report.ErrorRate() > opts.MaxErrorRate {
+		failures = append(failures, fmt.Sprintf("error rate %.4f exceeds %.4f", report.ErrorRate(), opts.MaxErrorRate))
+	}
+
+	if len(failures) > 0 {
+		return report, fmt.Errorf("%w: %s", ErrLoadTestFailed, strings.Join(failures, "; "))
+	}
+
+	return report, nil
+}
+
+// loadTestRequest sends a request of a load test and returns the response status
+func loadTestRequest(ctx context.Context, client *http.Client, baseURL string, target loadTestTarget,
+	header http.Header,
+) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, target.method, baseURL+target.path, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// requestDurations gathers the series of the request duration histogram by endpoint label
+func (mc *MetricsCollector) requestDurations() (map[string][]*dto.Metric, error) {
+	families, err := mc.registry.Gather()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	series := make(map[string][]*dto.Metric)
+
+	for _, family := range families {
+		if family.GetName() != mc.prefix+"_http_request_duration_seconds" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			endpoint := labelValue(metric, "endpoint")
+			series[endpoint] = append(series[endpoint], metric)
+		}
+	}
+
+	return series, nil
+}
+
+// labelValue returns the value of a label of a series
+func labelValue(metric *dto.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+
+	return ""
+}
+
+// latencyBuckets are the cumulative counts of a histogram by upper bound
+type latencyBuckets map[float64]uint64
+
+// latencyDelta returns the latency percentiles of the observations between two gatherings of the request duration
+// histogram, in total and by endpoint
+func latencyDelta(before, after map[string][]*dto.Metric) (LoadTestLatency, map[string]LoadTestLatency) {
+	total := make(latencyBuckets)
+	routes := make(map[string]LoadTestLatency)
+
+	for endpoint, series := range after {
+		buckets := make(latencyBuckets)
+		sumBuckets(buckets, series, 1)
+		sumBuckets(buckets, before[endpoint], -1)
+
+		latency := buckets.latency()
+		if latency.Count == 0 {
+			continue
+		}
+
+		routes[endpoint] = latency
+
+		for bound, count := range buckets {
+			total[bound] += count
+		}
+	}
+
+	return total.latency(), routes
+}
+
+// sumBuckets adds (sign 1) or subtracts (sign -1) the buckets of histogram series
+func sumBuckets(buckets latencyBuckets, series []*dto.Metric, sign int) {
+	for _, metric := range series {
+		for _, bucket := range metric.GetHistogram().GetBucket() {
+			if sign > 0 {
+				buckets[bucket.GetUpperBound()] += bucket.GetCumulativeCount()
+			} else {
+				buckets[bucket.GetUpperBound()] -= bucket.GetCumulativeCount()
+			}
+		}
+
+		// The +Inf bucket is implicit
+		if sign > 0 {
+			buckets[math.Inf(1)] += metric.GetHistogram().GetSampleCount()
+		} else {
+			buckets[math.Inf(1)] -= metric.GetHistogram().GetSampleCount()
+		}
+	}
+}
+
+// latency returns the count and percentiles of the buckets
+func (b latencyBuckets) latency() LoadTestLatency {
+	return LoadTestLatency{
+		Count: b[math.Inf(1)],
+		P50:   b.quantile(0.5),
+		P90:   b.quantile(0.9),
+		P99:   b.quantile(0.99),
+	}
+}
+
+// quantile estimates a quantile by linear interpolation within its bucket, like histogram_quantile in PromQL.
+// Quantiles in the +Inf bucket are the highest finite upper bound.
+func (b latencyBuckets) quantile(q float64) time.Duration {
+	bounds := make([]float64, 0, len(b))
+	for bound := range b {
+		bounds = append(bounds, bound)
+	}
+
+	slices.Sort(bounds)
+
+	total := b[math.Inf(1)]
+	if total == 0 {
+		return 0
+	}
+
+	rank := q * float64(total)
+
+	var lower, lowerCount float64
+
+	for _, bound := range bounds {
+		count := float64(b[bound])
+		if count < rank {
+			lower, lowerCount = bound, count
+			continue
+		}
+
+		if math.IsInf(bound, 1) {
+			return seconds(lower)
+		}
+
+		if count == lowerCount {
+			return seconds(bound)
+		}
+
+		return seconds(lower + (bound-lower)*(rank-lowerCount)/(count-lowerCount))
+	}
+
+	return seconds(lower)
+}
+
+// seconds converts seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestService_SelfLoadTest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports latency by route", func(t *testing.T) {
+		t.Parallel()
+
+		svc := New("test-service", nil)
+		svc.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+			time.Sleep(time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		})
+		svc.HandleFunc("POST /orders", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		report, err := svc.SelfLoadTest(context.Background(), LoadTestOptions{
+			Routes:      []string{"/users/1", "POST /orders"},
+			Concurrency: 4,
+			Duration:    300 * time.Millisecond,
+			Ramp:        100 * time.Millisecond,
+			MaxP99:      time.Second,
+		})
+		if err != nil {
+			t.Fatalf("expected the load test to pass, got %v", err)
+		}
+
+		if report.Requests == 0 || report.Errors != 0 || report.Throughput <= 0 {
+			t.Errorf("unexpected report %+v", report)
+		}
+
+		users, orders := report.Routes["GET /users/{id}"], report.Routes["POST /orders"]
+		if users.Count == 0 || orders.Count == 0 {
+			t.Fatalf("expected latencies of both routes, got %+v", report.Routes)
+		}
+
+		if users.P50 < time.Millisecond || users.P50 > users.P99 {
+			t.Errorf("unexpected percentiles %+v", users)
+		}
+
+		if report.Latency.Count < uint64(report.Requests) {
+			t.Errorf("expected %d observations, got %d", report.Requests, report.Latency.Count)
+		}
+	})
+
+	t.Run("fails above the error rate", func(t *testing.T) {
+		t.Parallel()
+
+		svc := New("test-service", nil)
+		svc.HandleFunc("GET /fail", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		report, err := svc.SelfLoadTest(context.Background(), LoadTestOptions{
+			Routes:       []string{"/fail"},
+			Concurrency:  2,
+			Duration:     100 * time.Millisecond,
+			MaxErrorRate: 0.01,
+		})
+		if !errors.Is(err, ErrLoadTestFailed) {
+			t.Fatalf("expected ErrLoadTestFailed, got %v", err)
+		}
+
+		if report == nil || report.ErrorRate() != 1 {
+			t.Errorf("expected an error rate of 1, got %+v", report)
+		}
+	})
+
+	t.Run("requires routes", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := New("test-service", nil).SelfLoadTest(context.Background(), LoadTestOptions{}); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+	})
+}
+
+func TestLatencyBuckets_Quantile(t *testing.T) {
+	t.Parallel()
+
+	buckets := latencyBuckets{0.1: 50, 0.2: 90, 0.5: 100, math.Inf(1): 100}
+
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 100 * time.Millisecond},
+		{0.7, 150 * time.Millisecond},
+		{0.95, 350 * time.Millisecond},
+		{1, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := buckets.quantile(tt.q); (got - tt.want).Abs() > time.Microsecond {
+			t.Errorf("quantile(%v) = %s, want %s", tt.q, got, tt.want)
+		}
+	}
+
+	if got := (latencyBuckets{0.1: 0, math.Inf(1): 5}).quantile(0.5); got != 100*time.Millisecond {
+		t.Errorf("expected quantiles in the +Inf bucket to be the highest finite bound, got %s", got)
+	}
+
+	if got := (latencyBuckets{}).quantile(0.5); got != 0 {
+		t.Errorf("expected 0 without observations, got %s", got)
+	}
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnknownMemo is returned when invalidating a memo that doesn't exist
+var ErrUnknownMemo = NewError(CodeNotFound, "unknown memo")
+
+// Memo caches the results of an expensive function per key within the process, e.g. feature config or token
+// introspection lookups of handlers. Create it once with Memoize, not per request.
+type Memo[K comparable, V any] struct {
+	name string
+	ttl  time.Duration
+	fn   func(ctx context.Context, key K) (V, error)
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	mu         sync.Mutex
+	entries    map[K]memoEntry[V]
+	calls      map[K]*memoCall[V]
+	generation uint64
+	sweepSize  int
+}
+
+// memoEntry is a cached result
+type memoEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// memoCall is a running call of the function, shared by concurrent lookups of the same key
+type memoCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+	// generation is the invalidation generation the call started in, its result isn't cached after an invalidation
+	generation uint64
+}
+
+// memos holds all memos by name for the admin endpoint
+var memos = struct {
+	mu     sync.Mutex
+	byName map[string][]memo
+}{byName: make(map[string][]memo)}
+
+// memo is the type-independent view of a Memo
+type memo interface {
+	InvalidateAll()
+	stats() memoInfo
+}
+
+// memoInfo describes a memo in the admin endpoint
+type memoInfo struct {
+	Name    string `json:"name"`
+	TTL     string `json:"ttl"`
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// Memoize caches the results of fn per key for the TTL. Concurrent lookups of a missing key share a single call,
+// and errors are not cached. Lookups are recorded in {service_name}_memo_requests_total{memo,result} of the
+// metrics collector in the context, and all memos of a name can be invalidated via the admin endpoint
+// (POST ADMIN_PATH/memos?name=...).
+func Memoize[K comparable, V any](name string, ttl time.Duration, fn func(ctx context.Context, key K) (V, error)) *Memo[K, V] {
+	m := &Memo[K, V]{
+		name:      name,
+		ttl:       ttl,
+		fn:        fn,
+		entries:   make(map[K]memoEntry[V]),
+		calls:     make(map[K]*memoCall[V]),
+		sweepSize: 64,
+	}
+
+	memos.mu.Lock()
+	memos.byName[name] = append(memos.byName[name], m)
+	memos.mu.Unlock()
+
+	return m
+}
+
+// Get returns the cached result of the key, or calls the function. The function runs without the cancellation of
+// the context, so a canceled lookup doesn't fail the lookups sharing its call; Get itself returns once ctx is done.
+func (m *Memo[K, V]) Get(ctx context.Context, key K) (V, error) {
+	m.mu.Lock()
+
+	if entry, ok := m.entries[key]; ok && time.Now().Before(entry.expires) {
+		m.mu.Unlock()
+		m.record(ctx, "hit")
+
+		return entry.value, nil
+	}
+
+	call, running := m.calls[key]
+	if !running {
+		call = &memoCall[V]{done: make(chan struct{}), generation: m.generation}
+		m.calls[key] = call
+
+		go m.call(context.WithoutCancel(ctx), key, call)
+	}
+
+	m.mu.Unlock()
+
+	if running {
+		m.record(ctx, "shared")
+	} else {
+		m.record(ctx, "miss")
+	}
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err() //nolint:wrapcheck
+	}
+}
+
+// call runs the function for a key and caches a successful result
+func (m *Memo[K, V]) call(ctx context.Context, key K, call *memoCall[V]) {
+	defer close(call.done)
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			call.err = fmt.Errorf("memo %s panicked: %v", m.name, recovered) //nolint:err113
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.calls[key] == call {
+			delete(m.calls, key)
+		}
+
+		if call.err == nil && call.generation == m.generation {
+			m.entries[key] = memoEntry[V]{value: call.value, expires: time.Now().Add(m.ttl)}
+			m.sweep()
+		}
+	}()
+
+	call.value, call.err = m.fn(ctx, key)
+}
+
+// sweep removes expired entries once the cache doubled in size since the last sweep. It must be called with the
+// lock held.
+func (m *Memo[K, V]) sweep() {
+	if len(m.entries) < 2*m.sweepSize {
+		return
+	}
+
+	now := time.Now()
+
+	maps.DeleteFunc(m.entries, func(_ K, entry memoEntry[V]) bool {
+		return !now.Before(entry.expires)
+	})
+
+	m.sweepSize = max(len(m.entries), 64)
+}
+
+// Invalidate removes the cached result of a key
+func (m *Memo[K, V]) Invalidate(key K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	delete(m.calls, key)
+	m.generation++
+}
+
+// InvalidateAll removes all cached results
+func (m *Memo[K, V]) InvalidateAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clear(m.entries)
+	clear(m.calls)
+	m.generation++
+}
+
+// stats returns the admin view of the memo
+func (m *Memo[K, V]) stats() memoInfo {
+	m.mu.Lock()
+	entries := len(m.entries)
+	m.mu.Unlock()
+
+	return memoInfo{
+		Name:    m.name,
+		TTL:     m.ttl.String(),
+		Entries: entries,
+		Hits:    m.hits.Load(),
+		Misses:  m.misses.Load(),
+	}
+}
+
+// record counts a lookup, in the metrics collector of the context if there is one
+func (m *Memo[K, V]) record(ctx context.Context, result string) {
+	if result == "hit" {
+		m.hits.Add(1)
+	} else {
+		m.misses.Add(1)
+	}
+
+	if metrics, ok := ctx.Value(MetricsKey).(*MetricsCollector); ok {
+		metrics.builtinCounterVec("memo_requests_total", "Total number of memo lookups by result", "memo", "result").
+			WithLabelValues(m.name, result).Inc()
+	}
+}
+
+// InvalidateMemo removes all cached results of the memos with the name
+func InvalidateMemo(name string) error {
+	memos.mu.Lock()
+	defer memos.mu.Unlock()
+
+	named, ok := memos.byName[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownMemo, name)
+	}
+
+	for _, m := range named {
+		m.InvalidateAll()
+	}
+
+	return nil
+}
+
+// memoInfos returns the admin view of all memos, sorted by name
+func memoInfos() []memoInfo {
+	memos.mu.Lock()
+	defer memos.mu.Unlock()
+
+	infos := []memoInfo{}
+
+	for _, name := range slices.Sorted(maps.Keys(memos.byName)) {
+		for _, m := range memos.byName[name] {
+			infos = append(infos, m.stats())
+		}
+	}
+
+	return infos
+}
+
+// memosHandler lists the memos and invalidates the memos of a name (POST ?name=...)
+func (s *Service) memosHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			name := r.URL.Query().Get("name")
+			if err := InvalidateMemo(name); err != nil {
+				http.Error(w, err.Error(), HTTPStatus(err))
+				return
+			}
+
+			s.Logger.Info("memo invalidated via admin API", "memo", name, "client_ip", clientIP(r))
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"memos": memoInfos()})
+	}
+}
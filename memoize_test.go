@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMemoize(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	release := make(chan struct{})
+	flags := Memoize("test_feature_flags", time.Minute, func(_ context.Context, tenant string) (string, error) {
+		calls.Add(1)
+		<-release
+
+		return "flags of " + tenant, nil
+	})
+
+	metrics := NewMetricsCollector("memo_test")
+	ctx := context.WithValue(context.Background(), MetricsKey, metrics)
+
+	var wg sync.WaitGroup
+
+	for range 5 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if value, err := flags.Get(ctx, "acme"); err != nil || value != "flags of acme" {
+				t.Errorf("unexpected result %q (%v)", value, err)
+			}
+		}()
+	}
+
+	// Let the lookups join the running call
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if _, err := flags.Get(ctx, "acme"); err != nil || calls.Load() != 1 {
+		t.Errorf("expected a single call, got %d (%v)", calls.Load(), err)
+	}
+
+	requests := metrics.builtinCounterVec("memo_requests_total", "", "memo", "result")
+	if value := testutil.ToFloat64(requests.WithLabelValues("test_feature_flags", "hit")); value < 1 {
+		t.Errorf("expected the cached result to be counted as a hit, got %v", value)
+	}
+
+	if err := InvalidateMemo("test_feature_flags"); err != nil {
+		t.Fatalf("failed to invalidate memo: %v", err)
+	}
+
+	if _, err := flags.Get(ctx, "acme"); err != nil || calls.Load() != 2 {
+		t.Errorf("expected a call after the invalidation, got %d (%v)", calls.Load(), err)
+	}
+
+	if err := InvalidateMemo("test_unknown"); !errors.Is(err, ErrUnknownMemo) {
+		t.Errorf("expected ErrUnknownMemo, got %v", err)
+	}
+}
+
+func TestMemoizeErrorsNotCached(t *testing.T) {
+	t.Parallel()
+
+	errLookup := errors.New("lookup failed")
+
+	var calls atomic.Int32
+
+	lookup := Memoize("test_introspection", time.Minute, func(context.Context, string) (bool, error) {
+		if calls.Add(1) == 1 {
+			return false, errLookup
+		}
+
+		return true, nil
+	})
+
+	if _, err := lookup.Get(context.Background(), "token"); !errors.Is(err, errLookup) {
+		t.Fatalf("expected the lookup error, got %v", err)
+	}
+
+	if active, err := lookup.Get(context.Background(), "token"); err != nil || !active {
+		t.Errorf("expected the error not to be cached, got %v (%v)", active, err)
+	}
+}
+
+func TestMemosHandler(t *testing.T) {
+	t.Parallel()
+
+	config := Memoize("test_admin_config", time.Minute, func(context.Context, int) (int, error) { return 1, nil })
+	_, _ = config.Get(context.Background(), 1)
+
+	handler := New("memos_admin_test", nil).memosHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/memos?name=test_admin_config", nil))
+
+	if rec.Code != http.StatusOK || config.stats().Entries != 0 {
+		t.Errorf("expected the memo to be invalidated, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/memos?name=unknown", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown memo, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"strings"
+)
+
+// MetricNamePolicy controls how characters that are invalid in Prometheus metric names are handled,
+// e.g. dashes and dots in service names
+type MetricNamePolicy string
+
+// Metric name policies
+const (
+	// MetricNameUnderscore replaces invalid characters with underscores, so "my-service" becomes "my_service"
+	MetricNameUnderscore MetricNamePolicy = "underscore"
+	// MetricNameKeep keeps names unchanged. Invalid names are only accepted by scrapers supporting UTF-8 names.
+	MetricNameKeep MetricNamePolicy = "keep"
+)
+
+// SanitizeMetricName replaces characters that are invalid in Prometheus metric names with underscores
+func SanitizeMetricName(name string) string {
+	var builder strings.Builder
+
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			builder.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				builder.WriteByte('_')
+			}
+
+			builder.WriteRune(r)
+		default:
+			builder.WriteByte('_')
+		}
+	}
+
+	return builder.String()
+}
+
+// apply returns the metric name according to the policy
+func (p MetricNamePolicy) apply(name string) string {
+	if p == MetricNameKeep {
+		return name
+	}
+
+	return SanitizeMetricName(name)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestSanitizeMetricName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"my-service":       "my_service",
+		"api.v2":           "api_v2",
+		"2fa":              "_2fa",
+		"valid_name:total": "valid_name:total",
+		"café":             "caf_",
+	}
+
+	for in, want := range tests {
+		if got := SanitizeMetricName(in); got != want {
+			t.Errorf("SanitizeMetricName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMetricNamePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy MetricNamePolicy
+		want   string
+	}{
+		{name: "underscore", policy: MetricNameUnderscore, want: "my_service_orders_total"},
+		{name: "keep", policy: MetricNameKeep, want: "my-service_orders_total"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			metrics := NewMetricsCollectorWithPolicy("my-service", tt.policy)
+			if err := metrics.RegisterCounter(MetricConfig{Name: "orders_total", Help: "Total orders"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := metrics.counters[tt.want]; !ok {
+				t.Errorf("expected internal key %s", tt.want)
+			}
+
+			// Names with the raw or the sanitized prefix refer to the same metric
+			for _, name := range []string{"orders_total", "my-service_orders_total", tt.want} {
+				if err := metrics.IncCounter(name); err != nil {
+					t.Errorf("failed to increment %s: %v", name, err)
+				}
+			}
+
+			families, err := metrics.GetRegistry().Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found := false
+
+			for _, family := range families {
+				if family.GetName() == tt.want {
+					found = family.GetMetric()[0].GetCounter().GetValue() == 3
+				}
+			}
+
+			if !found {
+				t.Errorf("expected exported metric %s with value 3", tt.want)
+			}
+		})
+	}
+}
@@ -4,19 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// ErrInvalidLabels is returned when a metric is recorded with a different number of labels than it was registered with
+var ErrInvalidLabels = NewError(CodeInvalidArgument, "invalid metric labels")
+
 // MetricsCollector holds all the metrics for the service with a flexible registry
 type MetricsCollector struct {
 	serviceName string
+	policy      MetricNamePolicy
+	prefix      string
 	registry    *prometheus.Registry
 	mu          sync.RWMutex
 
@@ -30,6 +39,20 @@ type MetricsCollector struct {
 	gauges     map[string]*prometheus.GaugeVec
 	histograms map[string]*prometheus.HistogramVec
 	summaries  map[string]*prometheus.SummaryVec
+
+	// Metrics of built-in subsystems, registered on first use
+	builtins map[string]prometheus.Collector
+
+	// Default labels of custom metrics registered afterwards (see SetDefaultLabels and AddContextLabel),
+	// and the labels of each custom metric
+	defaultLabels prometheus.Labels
+	contextLabels []contextLabel
+	metricLabels  map[string]customLabels
+
+	// Non-strict mode (see SetStrict), with the metrics whose recording errors were logged
+	lenient      atomic.Bool
+	loggedErrors sync.Map
+	logger       *slog.Logger
 }
 
 // MetricConfig holds configuration for creating custom metrics
@@ -41,23 +64,35 @@ type MetricConfig struct {
 	Objectives map[float64]float64 // For summaries
 }
 
-// NewMetricsCollector creates a new metrics collector with a flexible registry
+// NewMetricsCollector creates a new metrics collector with a flexible registry.
+// Invalid characters in metric names are replaced with underscores (see MetricNameUnderscore).
 func NewMetricsCollector(serviceName string) *MetricsCollector {
+	return NewMetricsCollectorWithPolicy(serviceName, MetricNameUnderscore)
+}
+
+// NewMetricsCollectorWithPolicy creates a new metrics collector with the given metric name policy
+func NewMetricsCollectorWithPolicy(serviceName string, policy MetricNamePolicy) *MetricsCollector {
 	registry := prometheus.NewRegistry()
 
 	metricsCollector := &MetricsCollector{
 		serviceName: serviceName,
+		policy:      policy,
+		prefix:      policy.apply(serviceName),
 		registry:    registry,
 		counters:    make(map[string]*prometheus.CounterVec),
 		gauges:      make(map[string]*prometheus.GaugeVec),
 		histograms:  make(map[string]*prometheus.HistogramVec),
 		summaries:   make(map[string]*prometheus.SummaryVec),
+		builtins:    make(map[string]prometheus.Collector),
+		logger:      slog.Default(),
+
+		metricLabels: make(map[string]customLabels),
 	}
 
 	// Create built-in HTTP metrics
 	metricsCollector.httpRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: serviceName + "_http_requests_total",
+			Name: metricsCollector.prefix + "_http_requests_total",
 			Help: "Total number of HTTP requests",
 		},
 		[]string{"method", "endpoint", "status_code"},
@@ -65,7 +100,7 @@ func NewMetricsCollector(serviceName string) *MetricsCollector {
 
 	metricsCollector.httpRequestDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Name:    serviceName + "_http_request_duration_seconds",
+			Name:    metricsCollector.prefix + "_http_request_duration_seconds",
 			Help:    "HTTP request duration in seconds",
 			Buckets: prometheus.DefBuckets,
 		},
@@ -74,7 +109,7 @@ func NewMetricsCollector(serviceName string) *MetricsCollector {
 
 	metricsCollector.httpRequestsInFlight = prometheus.NewGauge(
 		prometheus.GaugeOpts{
-			Name: serviceName + "_http_requests_in_flight",
+			Name: metricsCollector.prefix + "_http_requests_in_flight",
 			Help: "Number of HTTP requests currently being processed",
 		},
 	)
@@ -101,10 +136,11 @@ func (mc *MetricsCollector) RegisterCounter(config MetricConfig) error {
 
 	counter := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: prefixedName,
-			Help: config.Help,
+			Name:        prefixedName,
+			Help:        config.Help,
+			ConstLabels: mc.defaultLabels,
 		},
-		config.Labels,
+		mc.customLabelNames(prefixedName, config.Labels),
 	)
 
 	if err := mc.registry.Register(counter); err != nil {
@@ -130,10 +166,11 @@ func (mc *MetricsCollector) RegisterGauge(config MetricConfig) error {
 
 	gauge := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: prefixedName,
-			Help: config.Help,
+			Name:        prefixedName,
+			Help:        config.Help,
+			ConstLabels: mc.defaultLabels,
 		},
-		config.Labels,
+		mc.customLabelNames(prefixedName, config.Labels),
 	)
 
 	if err := mc.registry.Register(gauge); err != nil {
@@ -164,11 +201,12 @@ func (mc *MetricsCollector) RegisterHistogram(config MetricConfig) error {
 
 	histogram := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Name:    prefixedName,
-			Help:    config.Help,
-			Buckets: buckets,
+			Name:        prefixedName,
+			Help:        config.Help,
+			Buckets:     buckets,
+			ConstLabels: mc.defaultLabels,
 		},
-		config.Labels,
+		mc.customLabelNames(prefixedName, config.Labels),
 	)
 
 	if err := mc.registry.Register(histogram); err != nil {
@@ -199,11 +237,12 @@ func (mc *MetricsCollector) RegisterSummary(config MetricConfig) error {
 
 	summary := prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
-			Name:       prefixedName,
-			Help:       config.Help,
-			Objectives: objectives,
+			Name:        prefixedName,
+			Help:        config.Help,
+			Objectives:  objectives,
+			ConstLabels: mc.defaultLabels,
 		},
-		config.Labels,
+		mc.customLabelNames(prefixedName, config.Labels),
 	)
 
 	if err := mc.registry.Register(summary); err != nil {
@@ -215,185 +254,477 @@ func (mc *MetricsCollector) RegisterSummary(config MetricConfig) error {
 	return nil
 }
 
-// IncCounter increments a counter metric
-func (mc *MetricsCollector) IncCounter(name string, labels ...string) error {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
+// UnregisterCounter removes a counter metric, so it can be registered again. The Prometheus registry keeps the
+// label names and help of a removed metric, so a metric registered again under the same name must use the same.
+func (mc *MetricsCollector) UnregisterCounter(name string) error {
+	return unregisterMetric(mc, "counter", mc.counters, name)
+}
+
+// UnregisterGauge removes a gauge metric, so it can be registered again
+func (mc *MetricsCollector) UnregisterGauge(name string) error {
+	return unregisterMetric(mc, "gauge", mc.gauges, name)
+}
+
+// UnregisterHistogram removes a histogram metric, so it can be registered again
+func (mc *MetricsCollector) UnregisterHistogram(name string) error {
+	return unregisterMetric(mc, "histogram", mc.histograms, name)
+}
+
+// UnregisterSummary removes a summary metric, so it can be registered again
+func (mc *MetricsCollector) UnregisterSummary(name string) error {
+	return unregisterMetric(mc, "summary", mc.summaries, name)
+}
+
+// Reset removes all custom metrics, e.g. before reloading plugins that register them again.
+// Built-in metrics are kept.
+func (mc *MetricsCollector) Reset() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	removeMetrics(mc, mc.counters)
+	removeMetrics(mc, mc.gauges)
+	removeMetrics(mc, mc.histograms)
+	removeMetrics(mc, mc.summaries)
+}
+
+// unregisterMetric removes a custom metric from the collector and the Prometheus registry
+func unregisterMetric[V prometheus.Collector](mc *MetricsCollector, kind string, metrics map[string]V, name string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
 
-	// Ensure metric name has service prefix
 	prefixedName := mc.ensureMetricNamePrefix(name)
 
-	counter, exists := mc.counters[prefixedName]
-	if !exists {
-		return fmt.Errorf("counter %s not found", prefixedName) //nolint:err113
+	if _, exists := metrics[prefixedName]; !exists {
+		return fmt.Errorf("%s %s not found", kind, prefixedName) //nolint:err113
 	}
 
-	counter.WithLabelValues(labels...).Inc()
+	removeMetrics(mc, metrics, prefixedName)
 
 	return nil
 }
 
-// AddCounter adds a value to a counter metric
-func (mc *MetricsCollector) AddCounter(name string, value float64, labels ...string) error {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
+// removeMetrics removes the custom metrics with the names, or all metrics of the map without names.
+// It must be called with the lock held.
+func removeMetrics[V prometheus.Collector](mc *MetricsCollector, metrics map[string]V, names ...string) {
+	if len(names) == 0 {
+		names = slices.Collect(maps.Keys(metrics))
+	}
 
-	// Ensure metric name has service prefix
-	prefixedName := mc.ensureMetricNamePrefix(name)
+	for _, name := range names {
+		mc.registry.Unregister(metrics[name])
+		delete(metrics, name)
+		delete(mc.metricLabels, name)
+		mc.loggedErrors.Delete(name)
+	}
+}
 
-	counter, exists := mc.counters[prefixedName]
-	if !exists {
-		return fmt.Errorf("counter %s not found", prefixedName) //nolint:err113
+// IncCounter increments a counter metric
+func (mc *MetricsCollector) IncCounter(name string, labels ...string) error {
+	counter, err := mc.counter(context.Background(), name, labels)
+	if counter == nil {
+		return err
 	}
 
-	counter.WithLabelValues(labels...).Add(value)
+	counter.Inc()
 
 	return nil
 }
 
-// SetGauge sets a gauge metric value
-func (mc *MetricsCollector) SetGauge(name string, value float64, labels ...string) error {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
+// AddCounter adds a value to a counter metric
+func (mc *MetricsCollector) AddCounter(name string, value float64, labels ...string) error {
+	counter, err := mc.counter(context.Background(), name, labels)
+	if counter == nil {
+		return err
+	}
 
-	// Ensure metric name has service prefix
-	prefixedName := mc.ensureMetricNamePrefix(name)
+	counter.Add(value)
 
-	gauge, exists := mc.gauges[prefixedName]
-	if !exists {
-		return fmt.Errorf("gauge %s not found", prefixedName) //nolint:err113
+	return nil
+}
+
+// SetGauge sets a gauge metric value
+func (mc *MetricsCollector) SetGauge(name string, value float64, labels ...string) error {
+	gauge, err := mc.gauge(context.Background(), name, labels)
+	if gauge == nil {
+		return err
 	}
 
-	gauge.WithLabelValues(labels...).Set(value)
+	gauge.Set(value)
 
 	return nil
 }
 
 // IncGauge increments a gauge metric
 func (mc *MetricsCollector) IncGauge(name string, labels ...string) error {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
-
-	// Ensure metric name has service prefix
-	prefixedName := mc.ensureMetricNamePrefix(name)
-
-	gauge, exists := mc.gauges[prefixedName]
-	if !exists {
-		return fmt.Errorf("gauge %s not found", prefixedName) //nolint:err113
+	gauge, err := mc.gauge(context.Background(), name, labels)
+	if gauge == nil {
+		return err
 	}
 
-	gauge.WithLabelValues(labels...).Inc()
+	gauge.Inc()
 
 	return nil
 }
 
 // DecGauge decrements a gauge metric
 func (mc *MetricsCollector) DecGauge(name string, labels ...string) error {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
-
-	// Ensure metric name has service prefix
-	prefixedName := mc.ensureMetricNamePrefix(name)
-
-	gauge, exists := mc.gauges[prefixedName]
-	if !exists {
-		return fmt.Errorf("gauge %s not found", prefixedName) //nolint:err113
+	gauge, err := mc.gauge(context.Background(), name, labels)
+	if gauge == nil {
+		return err
 	}
 
-	gauge.WithLabelValues(labels...).Dec()
+	gauge.Dec()
 
 	return nil
 }
 
 // AddGauge adds a value to a gauge metric
 func (mc *MetricsCollector) AddGauge(name string, value float64, labels ...string) error {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
-
-	// Ensure metric name has service prefix
-	prefixedName := mc.ensureMetricNamePrefix(name)
-
-	gauge, exists := mc.gauges[prefixedName]
-	if !exists {
-		return fmt.Errorf("gauge %s not found", prefixedName) //nolint:err113
+	gauge, err := mc.gauge(context.Background(), name, labels)
+	if gauge == nil {
+		return err
 	}
 
-	gauge.WithLabelValues(labels...).Add(value)
+	gauge.Add(value)
 
 	return nil
 }
 
 // ObserveHistogram observes a value in a histogram metric
 func (mc *MetricsCollector) ObserveHistogram(name string, value float64, labels ...string) error {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
-
-	// Ensure metric name has service prefix
-	prefixedName := mc.ensureMetricNamePrefix(name)
-
-	histogram, exists := mc.histograms[prefixedName]
-	if !exists {
-		return fmt.Errorf("histogram %s not found", prefixedName) //nolint:err113
+	histogram, err := mc.histogram(context.Background(), name, labels)
+	if histogram == nil {
+		return err
 	}
 
-	histogram.WithLabelValues(labels...).Observe(value)
+	histogram.Observe(value)
 
 	return nil
 }
 
 // ObserveSummary observes a value in a summary metric
 func (mc *MetricsCollector) ObserveSummary(name string, value float64, labels ...string) error {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
+	summary, err := mc.summary(context.Background(), name, labels)
+	if summary == nil {
+		return err
+	}
+
+	summary.Observe(value)
+
+	return nil
+}
+
+// counter returns the counter metric with the name and labels (see customMetric)
+func (mc *MetricsCollector) counter(ctx context.Context, name string, labels []string) (prometheus.Counter, error) {
+	return customMetric(ctx, mc, "counter", mc.counters, mc.RegisterCounter,
+		(*prometheus.CounterVec).GetMetricWithLabelValues, name, labels)
+}
+
+// gauge returns the gauge metric with the name and labels (see customMetric)
+func (mc *MetricsCollector) gauge(ctx context.Context, name string, labels []string) (prometheus.Gauge, error) {
+	return customMetric(ctx, mc, "gauge", mc.gauges, mc.RegisterGauge,
+		(*prometheus.GaugeVec).GetMetricWithLabelValues, name, labels)
+}
+
+// histogram returns the histogram metric with the name and labels (see customMetric)
+func (mc *MetricsCollector) histogram(ctx context.Context, name string, labels []string) (prometheus.Observer, error) {
+	return customMetric(ctx, mc, "histogram", mc.histograms, mc.RegisterHistogram,
+		(*prometheus.HistogramVec).GetMetricWithLabelValues, name, labels)
+}
+
+// summary returns the summary metric with the name and labels (see customMetric)
+func (mc *MetricsCollector) summary(ctx context.Context, name string, labels []string) (prometheus.Observer, error) {
+	return customMetric(ctx, mc, "summary", mc.summaries, mc.RegisterSummary,
+		(*prometheus.SummaryVec).GetMetricWithLabelValues, name, labels)
+}
+
+// contextLabel is a default label with its value taken from the context of a recording
+type contextLabel struct {
+	name  string
+	value func(ctx context.Context) string
+}
+
+// SetDefaultLabels sets labels with fixed values (e.g. instance or version) that are added to custom metrics
+// registered afterwards, so handlers don't have to pass them on every call
+func (mc *MetricsCollector) SetDefaultLabels(labels map[string]string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.defaultLabels = maps.Clone(labels)
+}
+
+// AddContextLabel adds a label to custom metrics registered afterwards, with its value taken from the context of each
+// recording, e.g. the tenant of a request (see TenantFromContext). Recording with the request helpers (IncCounter(r,
+// ...)) uses the request context; recording on the collector uses an empty context.
+func (mc *MetricsCollector) AddContextLabel(name string, value func(ctx context.Context) string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.contextLabels = append(mc.contextLabels, contextLabel{name: name, value: value})
+}
+
+// customLabels holds the labels of a custom metric: its own labels, passed on each recording, and its context labels
+type customLabels struct {
+	names   []string
+	context []contextLabel
+}
+
+// customLabelNames returns the label names of a custom metric: its labels followed by the context labels.
+// The labels are remembered to validate recordings. It must be called with the lock held.
+func (mc *MetricsCollector) customLabelNames(prefixedName string, labels []string) []string {
+	mc.metricLabels[prefixedName] = customLabels{names: slices.Clone(labels), context: slices.Clip(mc.contextLabels)}
+
+	if len(mc.contextLabels) == 0 {
+		return labels
+	}
+
+	names := slices.Clone(labels)
+	for _, label := range mc.contextLabels {
+		names = append(names, label.name)
+	}
+
+	return names
+}
+
+// SetStrict sets whether recording to unknown metrics or with the wrong number of labels returns errors (the default).
+// Otherwise, unknown metrics are registered with the default config and generic label names (label1, label2, ...)
+// on first use, and errors are logged once per metric and counted in {service_name}_metric_record_errors_total,
+// so handlers can record metrics without checking errors.
+func (mc *MetricsCollector) SetStrict(strict bool) {
+	mc.lenient.Store(!strict)
+}
+
+// customMetric returns the custom metric with the name and label values, followed by the values of its context labels
+// from ctx. In non-strict mode, unknown metrics are registered on first use, and errors are logged instead of returned,
+// so the metric is nil without an error.
+func customMetric[V any, M any](
+	ctx context.Context,
+	mc *MetricsCollector,
+	kind string,
+	metrics map[string]V,
+	register func(MetricConfig) error,
+	withLabelValues func(V, ...string) (M, error),
+	name string,
+	labels []string,
+) (M, error) {
+	var zero M
 
-	// Ensure metric name has service prefix
 	prefixedName := mc.ensureMetricNamePrefix(name)
 
-	summary, exists := mc.summaries[prefixedName]
+	mc.mu.RLock()
+	vec, exists := metrics[prefixedName]
+	mc.mu.RUnlock()
+
+	if !exists && mc.lenient.Load() {
+		config := MetricConfig{Name: name, Help: kind + " registered on first use", Labels: genericLabelNames(len(labels))}
+
+		// Registration fails if another request registered the metric in the meantime
+		if register(config) == nil {
+			mc.logger.Warn("registered unknown metric on first use, register it to set its help and label names",
+				"metric", prefixedName, "type", kind, "labels", config.Labels)
+		}
+
+		mc.mu.RLock()
+		vec, exists = metrics[prefixedName]
+		mc.mu.RUnlock()
+	}
+
 	if !exists {
-		return fmt.Errorf("summary %s not found", prefixedName) //nolint:err113
+		return zero, mc.recordError(prefixedName, fmt.Errorf("%s %s not found", kind, prefixedName)) //nolint:err113
+	}
+
+	mc.mu.RLock()
+	metricLabels := mc.metricLabels[prefixedName]
+	mc.mu.RUnlock()
+
+	// WithLabelValues of Prometheus panics with the wrong number of labels
+	if len(labels) != len(metricLabels.names) {
+		return zero, mc.recordError(prefixedName, fmt.Errorf("%w: %s %s expects %d labels %v, got %d %q",
+			ErrInvalidLabels, kind, prefixedName, len(metricLabels.names), metricLabels.names, len(labels), labels))
 	}
 
-	summary.WithLabelValues(labels...).Observe(value)
+	if len(metricLabels.context) > 0 {
+		labels = slices.Clone(labels)
+		for _, label := range metricLabels.context {
+			labels = append(labels, label.value(ctx))
+		}
+	}
+
+	metric, err := withLabelValues(vec, labels...)
+	if err != nil {
+		return zero, mc.recordError(prefixedName, fmt.Errorf("failed to record %s %s: %w", kind, prefixedName, err))
+	}
+
+	return metric, nil
+}
+
+// recordError returns the error of recording a metric in strict mode. Otherwise, it is counted and logged once per
+// metric.
+func (mc *MetricsCollector) recordError(metric string, err error) error {
+	if !mc.lenient.Load() {
+		return err
+	}
+
+	mc.builtinCounterVec("metric_record_errors_total", "Failed recordings of custom metrics in non-strict mode",
+		"metric").WithLabelValues(metric).Inc()
+
+	if _, logged := mc.loggedErrors.LoadOrStore(metric, struct{}{}); !logged {
+		mc.logger.Warn("failed to record metric", "metric", metric, "error", err)
+	}
 
 	return nil
 }
 
+// genericLabelNames returns the label names of metrics registered on first use: label1, label2, ...
+func genericLabelNames(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = "label" + strconv.Itoa(i+1)
+	}
+
+	return names
+}
+
+// Prefix returns the prefix of the metric names, the service name after the name policy
+func (mc *MetricsCollector) Prefix() string {
+	return mc.prefix
+}
+
 // GetRegistry returns the Prometheus registry for custom integrations
 func (mc *MetricsCollector) GetRegistry() *prometheus.Registry {
 	return mc.registry
 }
 
-// ensureMetricNamePrefix ensures the metric name has the service name prefix
+// builtinMetric returns the built-in metric with the given name, creating and registering it on first use.
+// Built-in subsystems use it so their metrics only show up once the subsystem is in use.
+func builtinMetric[T prometheus.Collector](mc *MetricsCollector, name string, create func(name string) T) T {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	prefixedName := mc.ensureMetricNamePrefix(name)
+
+	if existing, ok := mc.builtins[prefixedName].(T); ok {
+		return existing
+	}
+
+	collector := create(prefixedName)
+	mc.registry.MustRegister(collector)
+	mc.builtins[prefixedName] = collector
+
+	return collector
+}
+
+// builtinCounterVec returns a built-in counter metric, registering it on first use
+func (mc *MetricsCollector) builtinCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	return builtinMetric(mc, name, func(name string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	})
+}
+
+// builtinGaugeVec returns a built-in gauge metric, registering it on first use
+func (mc *MetricsCollector) builtinGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	return builtinMetric(mc, name, func(name string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+	})
+}
+
+// builtinHistogramVec returns a built-in histogram metric, registering it on first use
+func (mc *MetricsCollector) builtinHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	return builtinMetric(mc, name, func(name string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)
+	})
+}
+
+// ensureMetricNamePrefix ensures the metric name has the service name prefix and applies the name policy,
+// so the internal keys always match the exported names
 func (mc *MetricsCollector) ensureMetricNamePrefix(name string) string {
-	if !strings.HasPrefix(name, mc.serviceName+"_") {
-		return mc.serviceName + "_" + name
+	if rest, ok := strings.CutPrefix(name, mc.serviceName+"_"); ok {
+		name = rest
+	} else if rest, ok := strings.CutPrefix(name, mc.prefix+"_"); ok {
+		name = rest
 	}
 
-	return name
+	return mc.policy.apply(mc.prefix + "_" + name)
 }
 
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
 
-	statusCode int
+	statusCode  int
+	wroteHeader bool
 }
 
 // WriteHeader captures the status code
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
+	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// MetricsMiddleware creates middleware that records HTTP metrics
+// Unwrap returns the underlying response writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Write notes that the header was written with the status code
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(data) //nolint:wrapcheck
+}
+
+// unmatchedEndpoint is the endpoint label of requests that didn't match a route, e.g. 404s of unknown paths
+const unmatchedEndpoint = "unmatched"
+
+// MetricsMiddlewareConfig configures the labels of the HTTP metrics
+type MetricsMiddlewareConfig struct {
+	// RawPath labels requests with the request path instead of the route pattern. Only use it for services with
+	// a small, fixed set of paths, as every distinct path (e.g. /users/123) creates new series.
+	RawPath bool
+	// EndpointLabel returns the endpoint label of a request, e.g. the route template of a non-stdlib router. It takes
+	// precedence over RawPath; an empty label is recorded as "unmatched". Labels must have a low cardinality.
+	EndpointLabel func(r *http.Request) string
+	// Skip returns true for requests that are not recorded, e.g. while recording is disabled at runtime.
+	// Skipped requests still get the metrics collector in their context.
+	Skip func(r *http.Request) bool
+}
+
+// endpoint returns the endpoint label of a request
+func (c MetricsMiddlewareConfig) endpoint(r *http.Request) string {
+	switch {
+	case c.EndpointLabel != nil:
+		if endpoint := c.EndpointLabel(r); endpoint != "" {
+			return endpoint
+		}
+
+		return unmatchedEndpoint
+	case c.RawPath:
+		return r.URL.Path
+	default:
+		return routeEndpoint(r)
+	}
+}
+
+// MetricsMiddleware creates middleware that records HTTP metrics, labeled with the route pattern
 func MetricsMiddleware(metrics *MetricsCollector) Middleware {
+	return MetricsMiddlewareWithConfig(metrics, MetricsMiddlewareConfig{})
+}
+
+// MetricsMiddlewareWithConfig creates middleware that records HTTP metrics. The endpoint label is the pattern of the
+// matched route (e.g. "GET /users/{id}", see RoutePattern), "unmatched" for requests without a route, the raw
+// path with RawPath, or the label of EndpointLabel.
+func MetricsMiddlewareWithConfig(metrics *MetricsCollector, config MetricsMiddlewareConfig) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Add metrics collector to context
 			ctx := context.WithValue(r.Context(), MetricsKey, metrics)
 			r = r.WithContext(ctx)
 
+			if config.Skip != nil && config.Skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// Track in-flight requests
 			metrics.httpRequestsInFlight.Inc()
 			defer metrics.httpRequestsInFlight.Dec()
@@ -404,6 +735,12 @@ func MetricsMiddleware(metrics *MetricsCollector) Middleware {
 				statusCode:     200, // Default status code
 			}
 
+			// The endpoint and status of route metrics (see ObserveRouteHistogram)
+			r = r.WithContext(context.WithValue(r.Context(), metricsRequestKey, &metricsRequest{
+				writer: wrapped,
+				config: config,
+			}))
+
 			// Record request start time
 			start := time.Now()
 
@@ -414,12 +751,19 @@ func MetricsMiddleware(metrics *MetricsCollector) Middleware {
 			duration := time.Since(start).Seconds()
 			statusCode := strconv.Itoa(wrapped.statusCode)
 
+			// Requests canceled by the client are not server errors
+			if ClientCanceled(r) {
+				statusCode = strconv.Itoa(StatusClientClosedRequest)
+			}
+
+			endpoint := config.endpoint(r)
+
 			metrics.httpRequestsTotal.WithLabelValues(
-				r.Method, r.URL.Path, statusCode,
+				r.Method, endpoint, statusCode,
 			).Inc()
 
 			metrics.httpRequestDuration.WithLabelValues(
-				r.Method, r.URL.Path, statusCode,
+				r.Method, endpoint, statusCode,
 			).Observe(duration)
 		})
 	}
@@ -435,7 +779,7 @@ func GetMetrics(r *http.Request) *MetricsCollector {
 	return metrics
 }
 
-// Helper functions for easy metric manipulation from handlers
+// Helper functions for easy metric manipulation from handlers, with the context labels of the request
 
 // IncCounter increments a counter metric from a request context
 func IncCounter(r *http.Request, name string, labels ...string) error {
@@ -444,7 +788,14 @@ func IncCounter(r *http.Request, name string, labels ...string) error {
 		return errors.New("metrics not available in request context") //nolint:err113
 	}
 
-	return metrics.IncCounter(name, labels...)
+	counter, err := metrics.counter(r.Context(), name, labels)
+	if counter == nil {
+		return err
+	}
+
+	counter.Inc()
+
+	return nil
 }
 
 // AddCounter adds a value to a counter metric from a request context
@@ -454,7 +805,14 @@ func AddCounter(r *http.Request, name string, value float64, labels ...string) e
 		return errors.New("metrics not available in request context") //nolint:err113
 	}
 
-	return metrics.AddCounter(name, value, labels...)
+	counter, err := metrics.counter(r.Context(), name, labels)
+	if counter == nil {
+		return err
+	}
+
+	counter.Add(value)
+
+	return nil
 }
 
 // SetGauge sets a gauge metric value from a request context
@@ -464,7 +822,14 @@ func SetGauge(r *http.Request, name string, value float64, labels ...string) err
 		return errors.New("metrics not available in request context") //nolint:err113
 	}
 
-	return metrics.SetGauge(name, value, labels...)
+	gauge, err := metrics.gauge(r.Context(), name, labels)
+	if gauge == nil {
+		return err
+	}
+
+	gauge.Set(value)
+
+	return nil
 }
 
 // IncGauge increments a gauge metric from a request context
@@ -474,7 +839,14 @@ func IncGauge(r *http.Request, name string, labels ...string) error {
 		return errors.New("metrics not available in request context") //nolint:err113
 	}
 
-	return metrics.IncGauge(name, labels...)
+	gauge, err := metrics.gauge(r.Context(), name, labels)
+	if gauge == nil {
+		return err
+	}
+
+	gauge.Inc()
+
+	return nil
 }
 
 // DecGauge decrements a gauge metric from a request context
@@ -484,7 +856,14 @@ func DecGauge(r *http.Request, name string, labels ...string) error {
 		return errors.New("metrics not available in request context") //nolint:err113
 	}
 
-	return metrics.DecGauge(name, labels...)
+	gauge, err := metrics.gauge(r.Context(), name, labels)
+	if gauge == nil {
+		return err
+	}
+
+	gauge.Dec()
+
+	return nil
 }
 
 // AddGauge adds a value to a gauge metric from a request context
@@ -494,7 +873,14 @@ func AddGauge(r *http.Request, name string, value float64, labels ...string) err
 		return errors.New("metrics not available in request context") //nolint:err113
 	}
 
-	return metrics.AddGauge(name, value, labels...)
+	gauge, err := metrics.gauge(r.Context(), name, labels)
+	if gauge == nil {
+		return err
+	}
+
+	gauge.Add(value)
+
+	return nil
 }
 
 // ObserveHistogram observes a value in a histogram metric from a request context
@@ -504,7 +890,14 @@ func ObserveHistogram(r *http.Request, name string, value float64, labels ...str
 		return errors.New("metrics not available in request context") //nolint:err113
 	}
 
-	return metrics.ObserveHistogram(name, value, labels...)
+	histogram, err := metrics.histogram(r.Context(), name, labels)
+	if histogram == nil {
+		return err
+	}
+
+	histogram.Observe(value)
+
+	return nil
 }
 
 // ObserveSummary observes a value in a summary metric from a request context
@@ -514,52 +907,118 @@ func ObserveSummary(r *http.Request, name string, value float64, labels ...strin
 		return errors.New("metrics not available in request context") //nolint:err113
 	}
 
-	return metrics.ObserveSummary(name, value, labels...)
+	summary, err := metrics.summary(r.Context(), name, labels)
+	if summary == nil {
+		return err
+	}
+
+	summary.Observe(value)
+
+	return nil
 }
 
-// startMetricsServer starts the Prometheus metrics server
+// startMetricsServer starts the Prometheus metrics server on a TCP address or Unix domain socket, with optional (m)TLS
 func (s *Service) startMetricsServer() error {
+	tlsConfig, err := s.metricsTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	listener, err := s.metricsListener()
+	if err != nil {
+		return err
+	}
+
+	s.metricsServer = &http.Server{
+		Addr:              s.Config.MetricsAddr,
+		Handler:           s.metricsHandler(),
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       5 * time.Minute,
+		ReadHeaderTimeout: s.Config.ReadHeaderTimeout,
+		WriteTimeout:      5 * time.Minute,
+		IdleTimeout:       5 * time.Minute,
+	}
+
+	s.Logger.Info("starting metrics server", "addr", s.Config.MetricsAddr, "path", s.Config.MetricsPath,
+		"tls", tlsConfig != nil)
+
+	if tlsConfig != nil {
+		return s.metricsServer.ServeTLS(listener, "", "") //nolint:wrapcheck
+	}
+
+	return s.metricsServer.Serve(listener) //nolint:wrapcheck
+}
+
+// metricsHandler returns the handler of the metrics server with the metrics, health, admin, and internal routes
+func (s *Service) metricsHandler() http.Handler {
 	mux := http.NewServeMux()
 
 	// Use the custom registry from metrics collector
 	handler := promhttp.HandlerFor(s.Metrics.GetRegistry(), promhttp.HandlerOpts{})
-	mux.Handle(s.Config.MetricsPath, handler)
+	mux.Handle(s.Config.MetricsPath, s.protectEndpoint(s.Config.MetricsAuth, s.scrapeHandler(handler)))
+
+	// Route SLO summary endpoint
+	mux.Handle(s.Config.SLOPath, s.protectEndpoint(s.Config.MetricsAuth, s.slos.handler()))
+
+	if s.Config.AdminEnabled {
+		// Admin endpoint to show and switch the routing profile
+		mux.Handle(s.Config.AdminPath+"/routing-profile", s.adminEndpoint(s.routingProfileHandler()))
+
+		// Admin endpoint to list health checks and to enable or disable them
+		mux.Handle(s.Config.AdminPath+"/health-checks", s.adminEndpoint(s.healthChecksHandler()))
+
+		// Admin endpoint to list and change runtime settings
+		mux.Handle(s.Config.AdminPath+"/settings", s.adminEndpoint(s.settingsHandler()))
+
+		// Admin endpoint to list and invalidate memoized results
+		mux.Handle(s.Config.AdminPath+"/memos", s.adminEndpoint(s.memosHandler()))
+
+		// Admin endpoint to list interval tasks and trigger manual runs
+		mux.Handle(s.Config.AdminPath+"/jobs", s.adminEndpoint(s.jobsHandler()))
+	}
+
+	if s.Config.DebugEndpoints {
+		// Profiling and runtime variables, e.g. go tool pprof :9090/debug/pprof/heap
+		mux.Handle(s.debugPath()+"/", s.protectEndpoint(s.Config.DebugEndpointsAuth, s.debugEndpointsHandler()))
+	}
+
+	// Internal application routes registered with Service.Internal
+	mux.Handle("/", s.internal.mux)
+
+	// Load balancer health endpoint, fails while the service is draining
+	mux.HandleFunc(s.Config.LBHealthPath, s.lbHealthHandler)
+
+	// Add health check endpoints, each with its own protection (the detailed health status may leak dependency details)
+	var healthHandler, readinessHandler, livenessHandler http.Handler
 
-	// Add health check endpoints
 	if s.HealthChecker != nil {
 		// Main health check endpoint (comprehensive health status)
-		mux.Handle(s.Config.HealthPath, s.HealthChecker.Handler())
+		healthHandler = s.HealthChecker.Handler()
 
 		// Kubernetes readiness probe endpoint
-		mux.HandleFunc(s.Config.ReadinessPath, s.HealthChecker.ReadinessHandler())
+		readinessHandler = s.HealthChecker.ReadinessHandler()
 
 		// Kubernetes liveness probe endpoint
-		mux.HandleFunc(s.Config.LivenessPath, s.HealthChecker.LivenessHandler())
+		livenessHandler = s.HealthChecker.LivenessHandler()
 	} else {
 		// Fallback basic health endpoints if health checker is not available
-		mux.HandleFunc(s.Config.HealthPath, func(w http.ResponseWriter, _ *http.Request) {
+		healthHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("OK"))
 		})
-		mux.HandleFunc(s.Config.ReadinessPath, func(w http.ResponseWriter, _ *http.Request) {
+		readinessHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("Ready"))
 		})
-		mux.HandleFunc(s.Config.LivenessPath, func(w http.ResponseWriter, _ *http.Request) {
+		livenessHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write([]byte("Alive"))
 		})
 	}
 
-	s.metricsServer = &http.Server{
-		Addr:         s.Config.MetricsAddr,
-		Handler:      mux,
-		ReadTimeout:  5 * time.Minute,
-		WriteTimeout: 5 * time.Minute,
-		IdleTimeout:  5 * time.Minute,
-	}
-
-	s.Logger.Info("starting metrics server", "addr", s.Config.MetricsAddr, "path", s.Config.MetricsPath)
+	mux.Handle(s.Config.HealthPath, s.protectEndpoint(s.Config.HealthAuth, healthHandler))
+	mux.Handle(s.Config.ReadinessPath, s.protectEndpoint(s.Config.ReadinessAuth, s.drainingReadiness(readinessHandler)))
+	mux.Handle(s.Config.LivenessPath, s.protectEndpoint(s.Config.LivenessAuth, livenessHandler))
 
-	return s.metricsServer.ListenAndServe() //nolint:wrapcheck
+	return mux
 }
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -8,6 +10,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 )
 
@@ -77,7 +80,7 @@ func TestMetricsCollector_RegisterCounter(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := metrics.counters["test-service_test_counter"]; !exists {
+		if _, exists := metrics.counters["test_service_test_counter"]; !exists {
 			t.Error("expected counter to be registered")
 		}
 	})
@@ -125,7 +128,7 @@ func TestMetricsCollector_RegisterGauge(t *testing.T) {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
-	if _, exists := metrics.gauges["test-service_test_gauge"]; !exists {
+	if _, exists := metrics.gauges["test_service_test_gauge"]; !exists {
 		t.Error("expected gauge to be registered")
 	}
 }
@@ -149,7 +152,7 @@ func TestMetricsCollector_RegisterHistogram(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := metrics.histograms["test-service_test_histogram"]; !exists {
+		if _, exists := metrics.histograms["test_service_test_histogram"]; !exists {
 			t.Error("expected histogram to be registered")
 		}
 	})
@@ -171,7 +174,7 @@ func TestMetricsCollector_RegisterHistogram(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := metrics.histograms["test-service_test_histogram_custom"]; !exists {
+		if _, exists := metrics.histograms["test_service_test_histogram_custom"]; !exists {
 			t.Error("expected histogram to be registered")
 		}
 	})
@@ -196,7 +199,7 @@ func TestMetricsCollector_RegisterSummary(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := metrics.summaries["test-service_test_summary"]; !exists {
+		if _, exists := metrics.summaries["test_service_test_summary"]; !exists {
 			t.Error("expected summary to be registered")
 		}
 	})
@@ -218,7 +221,7 @@ func TestMetricsCollector_RegisterSummary(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := metrics.summaries["test-service_test_summary_custom"]; !exists {
+		if _, exists := metrics.summaries["test_service_test_summary_custom"]; !exists {
 			t.Error("expected summary to be registered")
 		}
 	})
@@ -250,7 +253,7 @@ func TestMetricsCollector_CounterOperations(t *testing.T) {
 		}
 
 		// Verify the counter was incremented
-		counter := metrics.counters["test-service_test_counter_ops"]
+		counter := metrics.counters["test_service_test_counter_ops"]
 		metric := &dto.Metric{}
 
 		err = counter.WithLabelValues("inc").Write(metric)
@@ -286,7 +289,7 @@ func TestMetricsCollector_CounterOperations(t *testing.T) {
 		}
 
 		// Verify the counter was incremented by 5.5
-		counter := metrics.counters["test-service_test_counter_ops"]
+		counter := metrics.counters["test_service_test_counter_ops"]
 		metric := &dto.Metric{}
 
 		err = counter.WithLabelValues("add").Write(metric)
@@ -315,6 +318,136 @@ func TestMetricsCollector_CounterOperations(t *testing.T) {
 	})
 }
 
+func TestMetricsCollector_DefaultLabels(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("test-service")
+	metrics.SetDefaultLabels(map[string]string{"instance": "pod-1"})
+	metrics.AddContextLabel("tenant", TenantFromContext)
+
+	if err := metrics.RegisterCounter(MetricConfig{Name: "orders_total", Help: "Orders", Labels: []string{"status"}}); err != nil {
+		t.Fatalf("failed to register counter: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req = req.WithContext(context.WithValue(context.WithValue(req.Context(), MetricsKey, metrics), TenantKey, "acme"))
+
+	if err := IncCounter(req, "orders_total", "created"); err != nil {
+		t.Fatalf("failed to increment counter: %v", err)
+	}
+
+	if err := metrics.IncCounter("orders_total", "created"); err != nil {
+		t.Fatalf("failed to increment counter outside of a request: %v", err)
+	}
+
+	expected := `
+		# HELP test_service_orders_total Orders
+		# TYPE test_service_orders_total counter
+		test_service_orders_total{instance="pod-1",status="created",tenant=""} 1
+		test_service_orders_total{instance="pod-1",status="created",tenant="acme"} 1
+	`
+
+	if err := testutil.GatherAndCompare(metrics.GetRegistry(), strings.NewReader(expected), "test_service_orders_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMetricsCollector_InvalidLabels(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("test-service")
+
+	if err := metrics.RegisterHistogram(MetricConfig{Name: "job_duration_seconds", Labels: []string{"job", "result"}}); err != nil {
+		t.Fatalf("failed to register histogram: %v", err)
+	}
+
+	err := metrics.ObserveHistogram("job_duration_seconds", 1, "import")
+	if !errors.Is(err, ErrInvalidLabels) {
+		t.Fatalf("expected ErrInvalidLabels, got %v", err)
+	}
+
+	for _, detail := range []string{"test_service_job_duration_seconds", "expects 2 labels [job result]", `got 1 ["import"]`} {
+		if !strings.Contains(err.Error(), detail) {
+			t.Errorf("expected %q in the error, got %q", detail, err)
+		}
+	}
+}
+
+func TestMetricsCollector_NonStrict(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("test-service")
+
+	if err := metrics.RegisterGauge(MetricConfig{Name: "queue_depth", Labels: []string{"queue"}}); err != nil {
+		t.Fatalf("failed to register gauge: %v", err)
+	}
+
+	if err := metrics.SetGauge("queue_depth", 1, "orders", "extra"); err == nil {
+		t.Error("expected an error for the wrong number of labels in strict mode")
+	}
+
+	metrics.SetStrict(false)
+
+	if err := metrics.IncCounter("jobs_total", "import"); err != nil {
+		t.Fatalf("expected no error for an unknown counter, got %v", err)
+	}
+
+	if value := testutil.ToFloat64(metrics.counters["test_service_jobs_total"].WithLabelValues("import")); value != 1 {
+		t.Errorf("expected the counter to be registered and incremented, got %v", value)
+	}
+
+	for range 2 {
+		if err := metrics.SetGauge("queue_depth", 1, "orders", "extra"); err != nil {
+			t.Errorf("expected the error to be logged, got %v", err)
+		}
+	}
+
+	errs := metrics.builtinCounterVec("metric_record_errors_total", "", "metric")
+	if value := testutil.ToFloat64(errs.WithLabelValues("test_service_queue_depth")); value != 2 {
+		t.Errorf("expected 2 recording errors, got %v", value)
+	}
+}
+
+func TestMetricsCollector_Unregister(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("test-service")
+
+	if err := metrics.RegisterCounter(MetricConfig{Name: "orders_total", Labels: []string{"status"}}); err != nil {
+		t.Fatalf("failed to register counter: %v", err)
+	}
+
+	if err := metrics.UnregisterCounter("orders_total"); err != nil {
+		t.Fatalf("failed to unregister counter: %v", err)
+	}
+
+	if err := metrics.UnregisterCounter("orders_total"); err == nil {
+		t.Error("expected an error for an unknown counter")
+	}
+
+	if err := metrics.RegisterCounter(MetricConfig{Name: "orders_total", Labels: []string{"status"}}); err != nil {
+		t.Fatalf("failed to register the counter again: %v", err)
+	}
+
+	if err := metrics.IncCounter("orders_total", "created"); err != nil {
+		t.Errorf("expected the counter to be recorded, got %v", err)
+	}
+
+	if err := metrics.RegisterGauge(MetricConfig{Name: "queue_depth"}); err != nil {
+		t.Fatalf("failed to register gauge: %v", err)
+	}
+
+	metrics.Reset()
+
+	if len(metrics.counters) != 0 || len(metrics.gauges) != 0 || len(metrics.metricLabels) != 0 {
+		t.Error("expected all custom metrics to be removed")
+	}
+
+	if err := metrics.RegisterGauge(MetricConfig{Name: "queue_depth"}); err != nil {
+		t.Errorf("failed to register the gauge after a reset: %v", err)
+	}
+}
+
 //nolint:gocognit
 func TestMetricsCollector_GaugeOperations(t *testing.T) {
 	t.Parallel()
@@ -342,7 +475,7 @@ func TestMetricsCollector_GaugeOperations(t *testing.T) {
 		}
 
 		// Verify the gauge value
-		gauge := metrics.gauges["test-service_test_gauge_ops"]
+		gauge := metrics.gauges["test_service_test_gauge_ops"]
 		metric := &dto.Metric{}
 
 		err = gauge.WithLabelValues("set").Write(metric)
@@ -384,7 +517,7 @@ func TestMetricsCollector_GaugeOperations(t *testing.T) {
 		}
 
 		// Verify the gauge was incremented
-		gauge := metrics.gauges["test-service_test_gauge_ops"]
+		gauge := metrics.gauges["test_service_test_gauge_ops"]
 		metric := &dto.Metric{}
 
 		err = gauge.WithLabelValues("inc").Write(metric)
@@ -426,7 +559,7 @@ func TestMetricsCollector_GaugeOperations(t *testing.T) {
 		}
 
 		// Verify the gauge was decremented
-		gauge := metrics.gauges["test-service_test_gauge_ops"]
+		gauge := metrics.gauges["test_service_test_gauge_ops"]
 		metric := &dto.Metric{}
 
 		err = gauge.WithLabelValues("dec").Write(metric)
@@ -468,7 +601,7 @@ func TestMetricsCollector_GaugeOperations(t *testing.T) {
 		}
 
 		// Verify the gauge value
-		gauge := metrics.gauges["test-service_test_gauge_ops"]
+		gauge := metrics.gauges["test_service_test_gauge_ops"]
 		metric := &dto.Metric{}
 
 		err = gauge.WithLabelValues("add").Write(metric)
@@ -521,7 +654,7 @@ func TestMetricsCollector_HistogramOperations(t *testing.T) {
 		found := false
 
 		for _, mf := range metricFamilies {
-			if mf.GetName() == "test-service_test_histogram_ops" {
+			if mf.GetName() == "test_service_test_histogram_ops" {
 				found = true
 
 				for _, metric := range mf.GetMetric() {
@@ -577,7 +710,7 @@ func TestMetricsCollector_SummaryOperations(t *testing.T) {
 		found := false
 
 		for _, mf := range metricFamilies {
-			if mf.GetName() == "test-service_test_summary_ops" {
+			if mf.GetName() == "test_service_test_summary_ops" {
 				found = true
 
 				for _, metric := range mf.GetMetric() {
@@ -707,11 +840,11 @@ func TestMetricsMiddleware_CustomMetrics(t *testing.T) {
 
 	for _, mf := range metricFamilies {
 		switch mf.GetName() {
-		case "test-service_http_requests_total":
+		case "test_service_http_requests_total":
 			foundRequestsTotal = true
-		case "test-service_http_request_duration_seconds":
+		case "test_service_http_request_duration_seconds":
 			foundRequestDuration = true
-		case "test-service_http_requests_in_flight":
+		case "test_service_http_requests_in_flight":
 			foundRequestsInFlight = true
 		}
 	}
@@ -729,6 +862,29 @@ func TestMetricsMiddleware_CustomMetrics(t *testing.T) {
 	}
 }
 
+func TestMetricsMiddleware_ClientCanceled(t *testing.T) {
+	t.Parallel()
+
+	svc := New("test-service", nil)
+
+	handler := applyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}), MetricsMiddleware(svc.Metrics))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/canceled", nil).WithContext(ctx))
+
+	if got := testutil.ToFloat64(svc.Metrics.httpRequestsTotal.WithLabelValues(http.MethodGet, unmatchedEndpoint, "499")); got != 1 {
+		t.Errorf("expected the canceled request to be recorded with status 499, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(svc.Metrics.httpRequestsTotal.WithLabelValues(http.MethodGet, unmatchedEndpoint, "500")); got != 0 {
+		t.Errorf("expected the canceled request not to be recorded as a server error, got %v", got)
+	}
+}
+
 func TestMetricsRegistry(t *testing.T) {
 	t.Parallel()
 
@@ -780,7 +936,7 @@ func TestService_RegisterMetrics(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := svc.Metrics.counters["test-service_service_test_counter"]; !exists {
+		if _, exists := svc.Metrics.counters["test_service_service_test_counter"]; !exists {
 			t.Error("expected counter to be registered")
 		}
 	})
@@ -799,7 +955,7 @@ func TestService_RegisterMetrics(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := svc.Metrics.gauges["test-service_service_test_gauge"]; !exists {
+		if _, exists := svc.Metrics.gauges["test_service_service_test_gauge"]; !exists {
 			t.Error("expected gauge to be registered")
 		}
 	})
@@ -818,7 +974,7 @@ func TestService_RegisterMetrics(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := svc.Metrics.histograms["test-service_service_test_histogram"]; !exists {
+		if _, exists := svc.Metrics.histograms["test_service_service_test_histogram"]; !exists {
 			t.Error("expected histogram to be registered")
 		}
 	})
@@ -837,8 +993,71 @@ func TestService_RegisterMetrics(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if _, exists := svc.Metrics.summaries["test-service_service_test_summary"]; !exists {
+		if _, exists := svc.Metrics.summaries["test_service_service_test_summary"]; !exists {
 			t.Error("expected summary to be registered")
 		}
 	})
 }
+
+func TestMetricsMiddleware_EndpointLabel(t *testing.T) {
+	t.Parallel()
+
+	svc := New("endpoint_label_test", nil)
+	svc.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	svc.handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+	if got := testutil.ToFloat64(svc.Metrics.httpRequestsTotal.WithLabelValues(http.MethodGet, "GET /users/{id}", "200")); got != 1 {
+		t.Errorf("expected the request to be labeled with the route pattern, got %v", got)
+	}
+
+	// Middleware wrapping the whole mux also records requests without a route
+	unmatched := NewMetricsCollector("unmatched_test")
+	MetricsMiddleware(unmatched)(svc.mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown/123", nil))
+
+	if got := testutil.ToFloat64(unmatched.httpRequestsTotal.WithLabelValues(http.MethodGet, unmatchedEndpoint, "404")); got != 1 {
+		t.Errorf("expected the request without a route to be labeled %q, got %v", unmatchedEndpoint, got)
+	}
+
+	config := DefaultConfig()
+	config.MetricsRawPath = true
+
+	raw := New("raw_path_test", config)
+	raw.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	raw.handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+	if got := testutil.ToFloat64(raw.Metrics.httpRequestsTotal.WithLabelValues(http.MethodGet, "/users/123", "200")); got != 1 {
+		t.Errorf("expected the request to be labeled with the raw path, got %v", got)
+	}
+}
+
+func TestMetricsMiddleware_EndpointLabelHook(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.MetricsEndpointLabel = func(r *http.Request) string {
+		// A router that keeps its route template in a header, e.g. set by a gateway
+		return r.Header.Get("X-Route-Template")
+	}
+
+	svc := New("endpoint_hook_test", config)
+	svc.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/42", nil)
+	req.Header.Set("X-Route-Template", "/orders/:id")
+	svc.handler().ServeHTTP(httptest.NewRecorder(), req)
+	svc.handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/42", nil))
+
+	for _, endpoint := range []string{"/orders/:id", unmatchedEndpoint} {
+		if got := testutil.ToFloat64(svc.Metrics.httpRequestsTotal.WithLabelValues(http.MethodGet, endpoint, "200")); got != 1 {
+			t.Errorf("expected one request labeled %q, got %v", endpoint, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// ErrInvalidClientCA is returned when the client CA file of the metrics server contains no certificates
+var ErrInvalidClientCA = NewError(CodeInvalidArgument, "invalid metrics client CA file")
+
+// unixSocketPrefix marks a METRICS_ADDR as a Unix domain socket, e.g. unix:///run/service/metrics.sock
+const unixSocketPrefix = "unix://"
+
+// metricsListener listens on the address of the metrics server, a TCP address or a Unix domain socket.
+// A stale socket file of a previous run is removed.
+func (s *Service) metricsListener() (net.Listener, error) {
+	path, ok := strings.CutPrefix(s.Config.MetricsAddr, unixSocketPrefix)
+	if !ok {
+		listener, err := net.Listen("tcp", s.Config.MetricsAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on metrics address: %w", err)
+		}
+
+		return listener, nil
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale metrics socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on metrics socket: %w", err)
+	}
+
+	// Only the owner and the group (e.g. a scraper sidecar) can connect
+	if err := os.Chmod(path, 0o660); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set metrics socket permissions: %w", err)
+	}
+
+	return listener, nil
+}
+
+// metricsTLSConfig returns the TLS config of the metrics server, or nil without a certificate.
+// With a client CA, clients must present a certificate signed by it (mTLS).
+func (s *Service) metricsTLSConfig() (*tls.Config, error) {
+	if s.Config.MetricsTLSCertFile == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.Config.MetricsTLSCertFile, s.Config.MetricsTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metrics TLS certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if s.Config.MetricsTLSClientCAFile != "" {
+		pem, err := os.ReadFile(s.Config.MetricsTLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metrics client CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidClientCA, s.Config.MetricsTLSClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
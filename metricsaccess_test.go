@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMetricsAuth(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.MetricsAuth = EndpointAuth{Token: "scrape"}
+	config.AdminAuth = EndpointAuth{AllowedNetworks: []string{"10.0.0.0/8"}}
+
+	svc := New("metrics_auth_test", config)
+	handler := svc.metricsHandler()
+
+	tests := []struct {
+		path     string
+		token    string
+		expected int
+	}{
+		{path: "/metrics", expected: http.StatusUnauthorized},
+		{path: "/metrics", token: "scrape", expected: http.StatusOK},
+		{path: "/slo", expected: http.StatusUnauthorized},
+		{path: "/admin/settings", token: "scrape", expected: http.StatusForbidden},
+		{path: "/live", expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.expected, recorder.Code)
+		}
+	}
+}
+
+func TestMetricsUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "metrics.sock")
+
+	// A stale socket file of a previous run
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.MetricsAddr = "unix://" + path
+
+	svc := New("metrics_socket_test", config)
+
+	listener, err := svc.metricsListener()
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := &http.Server{Handler: svc.metricsHandler()} //nolint:gosec
+
+	go func() { _ = server.Serve(listener) }()
+
+	t.Cleanup(func() { _ = server.Close() })
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o660 {
+		t.Errorf("expected socket permissions 0660, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}
+
+	resp, err := client.Get("http://metrics/metrics")
+	if err != nil {
+		t.Fatalf("request over the socket failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestMetricsMutualTLS(t *testing.T) {
+	t.Parallel()
+
+	certFile, keyFile := writeTestCertificate(t, time.Now().Add(24*time.Hour), "localhost")
+
+	config := DefaultConfig()
+	config.MetricsTLSCertFile = certFile
+	config.MetricsTLSKeyFile = keyFile
+	config.MetricsTLSClientCAFile = certFile
+
+	svc := New("metrics_mtls_test", config)
+
+	tlsConfig, err := svc.metricsTLSConfig()
+	if err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: svc.metricsHandler()} //nolint:gosec
+
+	go func() { _ = server.Serve(listener) }()
+
+	t.Cleanup(func() { _ = server.Close() })
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(certificates ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, Certificates: certificates}, //nolint:gosec
+		}}
+
+		return client.Get("https://" + listener.Addr().String() + "/metrics")
+	}
+
+	if resp, err := get(); err == nil {
+		resp.Body.Close()
+		t.Error("expected a request without client certificate to fail")
+	}
+
+	resp, err := get(cert)
+	if err != nil {
+		t.Fatalf("request with client certificate failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestMetricsTLSConfigInvalidClientCA(t *testing.T) {
+	t.Parallel()
+
+	certFile, keyFile := writeTestCertificate(t, time.Now().Add(24*time.Hour), "localhost")
+
+	config := DefaultConfig()
+	config.MetricsTLSCertFile = certFile
+	config.MetricsTLSKeyFile = keyFile
+	config.MetricsTLSClientCAFile = keyFile
+
+	if _, err := New("metrics_ca_test", config).metricsTLSConfig(); !errors.Is(err, ErrInvalidClientCA) {
+		t.Errorf("expected ErrInvalidClientCA, got %v", err)
+	}
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"runtime/debug"
 )
 
 // ContextKey is a custom type for context keys to avoid collisions
@@ -21,12 +23,12 @@ const (
 // Middleware represents a middleware function
 type Middleware func(http.Handler) http.Handler
 
-// LoggerMiddleware injects the logger into the request context
+// LoggerMiddleware injects the logger into the request context, with the request ID if there is one
 func LoggerMiddleware(logger *slog.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Create a new context with the logger
-			ctx := context.WithValue(r.Context(), LoggerKey, logger)
+			ctx := context.WithValue(r.Context(), LoggerKey, requestLogger(logger, r))
 
 			// Create a new request with the updated context
 			r = r.WithContext(ctx)
@@ -39,7 +41,12 @@ func LoggerMiddleware(logger *slog.Logger) Middleware {
 
 // GetLogger retrieves the logger from the request context
 func GetLogger(r *http.Request) *slog.Logger {
-	logger, ok := r.Context().Value(LoggerKey).(*slog.Logger)
+	return LoggerFromContext(r.Context())
+}
+
+// LoggerFromContext retrieves the logger from a context, e.g. in shutdown hooks and background tasks
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(LoggerKey).(*slog.Logger)
 	if !ok {
 		// Return a default logger if none is found
 		return slog.Default()
@@ -48,14 +55,43 @@ func GetLogger(r *http.Request) *slog.Logger {
 	return logger
 }
 
+// ContextWithLogger returns a context carrying the logger
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, LoggerKey, logger)
+}
+
 // RecoveryMiddleware recovers from panics and logs them
 func RecoveryMiddleware(logger *slog.Logger) Middleware {
+	return recoveryMiddleware(logger, nil, nil)
+}
+
+// RecoveryMiddlewareWithConfig recovers from panics, logs them, and writes the response with the renderer
+// (DefaultPanicRenderer if nil)
+func RecoveryMiddlewareWithConfig(logger *slog.Logger, renderer PanicRenderer) Middleware {
+	return recoveryMiddleware(logger, renderer, nil)
+}
+
+// recoveryMiddleware recovers from panics, logs them, reports them to the optional callback, and renders the response
+func recoveryMiddleware(logger *slog.Logger, renderer PanicRenderer, onPanic func(recovered any)) Middleware {
+	if renderer == nil {
+		renderer = DefaultPanicRenderer
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
-					logger.Error("panic recovered", "error", err, "path", r.URL.Path, "method", r.Method)
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					if err == http.ErrAbortHandler { //nolint:errorlint,err113
+						panic(err)
+					}
+
+					requestLogger(logger, r).Error("panic recovered", "error", err, "route", RoutePattern(r), "path", r.URL.Path, "method", r.Method)
+
+					if onPanic != nil {
+						onPanic(err)
+					}
+
+					renderer(w, r, PanicInfo{Recovered: err, Stack: debug.Stack()})
 				}
 			}()
 
@@ -64,19 +100,10 @@ func RecoveryMiddleware(logger *slog.Logger) Middleware {
 	}
 }
 
-// RequestLoggingMiddleware logs incoming requests
-func RequestLoggingMiddleware(logger *slog.Logger) Middleware {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			logger.Info("incoming request",
-				"method", r.Method,
-				"path", r.URL.Path,
-				"remote_addr", r.RemoteAddr,
-				"user_agent", r.UserAgent())
-
-			next.ServeHTTP(w, r)
-		})
-	}
+// ClientCanceled reports whether the client canceled the request, e.g. by closing the connection.
+// Such requests are recorded with status 499 instead of the written status and don't count against SLOs.
+func ClientCanceled(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
 }
 
 // HealthCheckerMiddleware injects the health checker into the request context
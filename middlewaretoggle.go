@@ -0,0 +1,42 @@
+package service
+
+import "net/http"
+
+// ToggleMiddleware returns the middleware with a runtime switch, so it can be disabled without a restart, e.g. when
+// logging volume is overwhelming during an incident. The switch is the bool setting "{name}_enabled" (enabled by
+// default), changed via the admin API or SETTINGS_FILE, and exported in
+// {service_name}_enabled_middlewares{middleware} (1 if enabled).
+func (s *Service) ToggleMiddleware(name string, middleware Middleware) Middleware {
+	enabled := s.middlewareSetting(name)
+
+	return func(next http.Handler) http.Handler {
+		wrapped := middleware(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if enabled.Bool() {
+				wrapped.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// middlewareSetting registers the runtime switch of a middleware and exports its state
+func (s *Service) middlewareSetting(name string) *Setting {
+	gauge := s.Metrics.builtinGaugeVec("enabled_middlewares", "Built-in and toggled middleware by state (1 if enabled)",
+		"middleware")
+
+	setting := s.Setting(name+"_enabled", true)
+	setting.watch(func(value any) {
+		state := 0.0
+		if enabled, _ := value.(bool); enabled {
+			state = 1
+		}
+
+		gauge.WithLabelValues(name).Set(state)
+	})
+
+	return setting
+}
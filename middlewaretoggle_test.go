@@ -0,0 +1,99 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBuiltinMiddlewareToggles(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	config := DefaultConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	svc := New("toggle_test", config)
+	svc.HandleFunc("GET /orders", func(w http.ResponseWriter, r *http.Request) {
+		if GetMetrics(r) == nil {
+			t.Error("expected the metrics collector in the context while recording is disabled")
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	enabled := svc.Metrics.builtinGaugeVec("enabled_middlewares", "", "middleware")
+	requests := svc.Metrics.httpRequestsTotal.WithLabelValues(http.MethodGet, "GET /orders", "200")
+
+	if testutil.ToFloat64(enabled.WithLabelValues("metrics")) != 1 || testutil.ToFloat64(enabled.WithLabelValues("access_log")) != 1 {
+		t.Fatal("expected the built-in middleware to be reported as enabled")
+	}
+
+	for _, name := range []string{"metrics_enabled", "access_log_enabled"} {
+		if err := svc.SetSetting(name, "false"); err != nil {
+			t.Fatalf("failed to disable %s: %v", name, err)
+		}
+	}
+
+	logs.Reset()
+	svc.handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	if testutil.ToFloat64(requests) != 0 || strings.Contains(logs.String(), "msg=access") {
+		t.Error("expected no metrics and no request logs while disabled")
+	}
+
+	if testutil.ToFloat64(enabled.WithLabelValues("metrics")) != 0 {
+		t.Error("expected the disabled middleware to be reported")
+	}
+
+	_ = svc.SetSetting("metrics_enabled", "true")
+	_ = svc.SetSetting("access_log_enabled", "true")
+
+	svc.handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	if testutil.ToFloat64(requests) != 1 || !strings.Contains(logs.String(), "msg=access") {
+		t.Error("expected metrics and request logs after enabling again")
+	}
+}
+
+func TestToggleMiddleware(t *testing.T) {
+	t.Parallel()
+
+	svc := New("custom_toggle_test", nil)
+
+	compression := svc.ToggleMiddleware("compression", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "identity")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	handler := compression(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		return rec.Header().Get("Content-Encoding")
+	}
+
+	if serve() != "identity" {
+		t.Error("expected the middleware to run by default")
+	}
+
+	if err := svc.SetSetting("compression_enabled", "false"); err != nil {
+		t.Fatal(err)
+	}
+
+	if serve() != "" {
+		t.Error("expected the middleware to be bypassed while disabled")
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MirrorConfig holds configuration for the mirror middleware
+type MirrorConfig struct {
+	// Target is the base URL requests are mirrored to. The path and query of the request are appended.
+	Target string
+	// Percent of requests mirrored, from 0 to 100
+	Percent float64
+	// MaxBody is the maximum request body size mirrored; larger requests are skipped. Defaults to 1MiB.
+	MaxBody int64
+	// MaxInFlight is the maximum number of concurrent mirrored requests; further requests are skipped. Defaults to 100.
+	MaxInFlight int
+	// ShadowHeader is set to "true" on mirrored requests, so the target can recognize them. Defaults to "X-Shadow".
+	ShadowHeader string
+	// Client sends the mirrored requests. Defaults to an instrumented client with a 5s timeout.
+	Client *http.Client
+}
+
+// MirrorMiddleware asynchronously mirrors a percentage of requests to a target, e.g. a rewrite of the service.
+// Mirrored responses are discarded and never affect the primary response.
+// Results are counted in {service_name}_mirror_requests_total{result} (success, failure, or skipped).
+func MirrorMiddleware(metrics *MetricsCollector, config MirrorConfig) Middleware {
+	if config.MaxBody <= 0 {
+		config.MaxBody = defaultMaxShadowBody
+	}
+
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = 100
+	}
+
+	if config.ShadowHeader == "" {
+		config.ShadowHeader = "X-Shadow"
+	}
+
+	if config.Client == nil {
+		config.Client = NewClient(metrics, ClientConfig{Name: "mirror", Timeout: 5 * time.Second})
+	}
+
+	// An invalid target makes every mirrored request fail, which shows up in the metrics
+	target, targetErr := url.Parse(config.Target)
+
+	var results *prometheus.CounterVec
+	if metrics != nil {
+		results = metrics.builtinCounterVec("mirror_requests_total", "Total number of mirrored requests by result", "result")
+	}
+
+	record := func(result string) {
+		if results != nil {
+			results.WithLabelValues(result).Inc()
+		}
+	}
+
+	inFlight := make(chan struct{}, config.MaxInFlight)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Percent <= 0 || rand.Float64()*100 >= config.Percent { //nolint:gosec
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if targetErr != nil {
+				record("failure")
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			body, ok := copyBody(r, config.MaxBody)
+			if !ok {
+				record("skipped")
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			select {
+			case inFlight <- struct{}{}:
+				mirror := mirrorRequest(r, target, body, config.ShadowHeader)
+
+				go func() {
+					defer func() { <-inFlight }()
+
+					record(sendMirror(config.Client, mirror))
+				}()
+			default:
+				record("skipped")
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// mirrorRequest creates a copy of the request addressed to the mirror target
+func mirrorRequest(r *http.Request, target *url.URL, body []byte, shadowHeader string) *http.Request {
+	mirror := r.Clone(context.WithoutCancel(r.Context()))
+
+	mirror.RequestURI = ""
+	mirror.URL = target.JoinPath(r.URL.Path)
+	mirror.URL.RawQuery = r.URL.RawQuery
+	mirror.Host = ""
+	mirror.Body = io.NopCloser(bytes.NewReader(body))
+	mirror.ContentLength = int64(len(body))
+	mirror.Header.Set(shadowHeader, "true")
+
+	return mirror
+}
+
+// sendMirror sends a mirrored request, discards the response, and returns the result
+func sendMirror(client *http.Client, r *http.Request) string {
+	resp, err := client.Do(r)
+	if err != nil {
+		return "failure"
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "failure"
+	}
+
+	return "success"
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMirrorMiddleware(t *testing.T) {
+	t.Parallel()
+
+	mirrored := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mirrored <- r
+		bodies <- string(body)
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(target.Close)
+
+	metrics := NewMetricsCollector("mirror_test")
+
+	handler := MirrorMiddleware(metrics, MirrorConfig{Target: target.URL + "/v2", Percent: 100})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		}))
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?id=1", strings.NewReader("order"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "order" {
+		t.Errorf("expected the primary response to be unaffected, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case r := <-mirrored:
+		if r.URL.Path != "/v2/orders" || r.URL.RawQuery != "id=1" {
+			t.Errorf("expected mirror to /v2/orders?id=1, got %s", r.URL)
+		}
+
+		if r.Header.Get("X-Shadow") != "true" {
+			t.Error("expected mirrored request to be marked as shadow traffic")
+		}
+
+		if body := <-bodies; body != "order" {
+			t.Errorf("expected mirrored body %q, got %q", "order", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected request to be mirrored")
+	}
+
+	results := metrics.builtinCounterVec("mirror_requests_total", "", "result")
+
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(results.WithLabelValues("failure")) != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if got := testutil.ToFloat64(results.WithLabelValues("failure")); got != 1 {
+		t.Errorf("expected the 500 from the mirror to be counted as failure, got %v", got)
+	}
+}
+
+func TestMirrorMiddlewareSkips(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("mirror_skip_test")
+
+	handler := MirrorMiddleware(metrics, MirrorConfig{Target: "http://127.0.0.1:1", Percent: 100, MaxBody: 2})(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too large")))
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rec.Code)
+	}
+
+	results := metrics.builtinCounterVec("mirror_requests_total", "", "result")
+	if got := testutil.ToFloat64(results.WithLabelValues("skipped")); got != 1 {
+		t.Errorf("expected request exceeding the body limit to be skipped, got %v", got)
+	}
+}
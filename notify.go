@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind identifies a critical event
+type EventKind string
+
+// Critical events that trigger notifications
+const (
+	// EventPanicSpike is sent when the number of recovered panics per minute exceeds PANIC_SPIKE_THRESHOLD
+	EventPanicSpike EventKind = "panic_spike"
+	// EventUnhealthy is sent when the health status flips to unhealthy
+	EventUnhealthy EventKind = "unhealthy"
+	// EventShutdownTimeout is sent when connections are closed forcefully after the shutdown timeout
+	EventShutdownTimeout EventKind = "shutdown_timeout"
+)
+
+// ErrNotifyFailed is returned when a notification could not be delivered
+var ErrNotifyFailed = NewError(CodeUnavailable, "failed to deliver notification")
+
+// Event describes a critical event of a service
+type Event struct {
+	Kind    EventKind
+	Service string
+	Message string
+	Time    time.Time
+	Fields  map[string]any
+}
+
+// String returns a human-readable summary of the event
+func (e Event) String() string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "[%s] %s: %s", e.Service, e.Kind, e.Message)
+
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(&builder, "\n%s: %v", key, e.Fields[key])
+	}
+
+	return builder.String()
+}
+
+// Notifier delivers notifications about critical events, e.g. to a chat or email
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// WebhookNotifier posts events to a Slack-compatible incoming webhook
+type WebhookNotifier struct {
+	URL string
+	// Client sends the webhook requests. Defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// Notify posts the event to the webhook
+func (n *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"text":    event.String(),
+		"kind":    event.Kind,
+		"service": event.Service,
+		"time":    event.Time,
+		"fields":  event.Fields,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotifyFailed, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotifyFailed, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotifyFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: webhook returned status %d", ErrNotifyFailed, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SMTPNotifier sends events as plain text emails
+type SMTPNotifier struct {
+	// Addr is the address of the SMTP server, e.g. "smtp.example.com:587"
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// Notify sends the event as an email
+func (n *SMTPNotifier) Notify(_ context.Context, event Event) error {
+	var message bytes.Buffer
+
+	fmt.Fprintf(&message, "From: %s\r\n", n.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&message, "Subject: [%s] %s\r\n", event.Service, event.Kind)
+	fmt.Fprintf(&message, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(event.String(), "\n", "\r\n"))
+
+	if err := smtp.SendMail(n.Addr, n.Auth, n.From, n.To, message.Bytes()); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotifyFailed, err)
+	}
+
+	return nil
+}
+
+// notifications dispatches critical events to the registered notifiers, at most once per interval and event kind
+type notifications struct {
+	mu          sync.Mutex
+	notifiers   []Notifier
+	lastSent    map[EventKind]time.Time
+	panicWindow time.Time
+	panicCount  int
+}
+
+// newNotifications creates a new notification dispatcher
+func newNotifications() *notifications {
+	return &notifications{
+		lastSent: make(map[EventKind]time.Time),
+	}
+}
+
+// AddNotifier registers a notifier for critical events
+func (s *Service) AddNotifier(notifier Notifier) {
+	s.notifications.mu.Lock()
+	defer s.notifications.mu.Unlock()
+
+	s.notifications.notifiers = append(s.notifications.notifiers, notifier)
+}
+
+// notify delivers a critical event to all notifiers, unless the same kind of event was sent within the notify interval
+func (s *Service) notify(ctx context.Context, kind EventKind, message string, fields map[string]any) {
+	n := s.notifications
+
+	n.mu.Lock()
+
+	if len(n.notifiers) == 0 || time.Since(n.lastSent[kind]) < s.Config.NotifyInterval {
+		n.mu.Unlock()
+		return
+	}
+
+	n.lastSent[kind] = time.Now()
+	notifiers := append([]Notifier(nil), n.notifiers...)
+
+	n.mu.Unlock()
+
+	event := Event{
+		Kind:    kind,
+		Service: s.Name,
+		Message: message,
+		Time:    time.Now(),
+		Fields:  fields,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(ctx, event); err != nil {
+			s.Logger.Error("failed to send notification", "kind", kind, "error", err)
+		}
+	}
+}
+
+// recordPanic counts a recovered panic and sends a notification when panics spike
+func (s *Service) recordPanic(recovered any) {
+	if s.Config.PanicSpikeThreshold <= 0 {
+		return
+	}
+
+	n := s.notifications
+	now := time.Now()
+
+	n.mu.Lock()
+
+	if now.Sub(n.panicWindow) >= time.Minute {
+		n.panicWindow, n.panicCount = now, 0
+	}
+
+	n.panicCount++
+	spike := n.panicCount == s.Config.PanicSpikeThreshold
+
+	n.mu.Unlock()
+
+	if spike {
+		go s.notify(context.Background(), EventPanicSpike,
+			fmt.Sprintf("%d panics recovered within a minute", s.Config.PanicSpikeThreshold),
+			map[string]any{"last_panic": fmt.Sprint(recovered)})
+	}
+}
+
+// notifyUnhealthy sends a notification when the health status flips to unhealthy
+func (s *Service) notifyUnhealthy(status string, failures map[string]string) {
+	fields := make(map[string]any, len(failures))
+	for name, failure := range failures {
+		fields[name] = failure
+	}
+
+	go s.notify(context.Background(), EventUnhealthy, "health status changed to "+status, fields)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hellofresh/health-go/v5"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, event Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.events = append(n.events, event)
+
+	return nil
+}
+
+func (n *recordingNotifier) kinds() []EventKind {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	kinds := make([]EventKind, 0, len(n.events))
+	for _, event := range n.events {
+		kinds = append(kinds, event.Kind)
+	}
+
+	return kinds
+}
+
+func waitForEvents(t *testing.T, notifier *recordingNotifier, count int) []EventKind {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for len(notifier.kinds()) < count && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	return notifier.kinds()
+}
+
+func TestNotify_PanicSpike(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.PanicSpikeThreshold = 3
+
+	svc := New("notify_panic_test", config)
+	notifier := &recordingNotifier{}
+	svc.AddNotifier(notifier)
+
+	svc.HandleFunc("/panic", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	for range 6 {
+		svc.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	}
+
+	waitForEvents(t, notifier, 1)
+	time.Sleep(10 * time.Millisecond)
+
+	if kinds := notifier.kinds(); len(kinds) != 1 || kinds[0] != EventPanicSpike {
+		t.Errorf("expected a single panic spike notification, got %v", kinds)
+	}
+}
+
+func TestNotify_Unhealthy(t *testing.T) {
+	t.Parallel()
+
+	svc := New("notify_health_test", nil)
+	notifier := &recordingNotifier{}
+	svc.AddNotifier(notifier)
+
+	_, _ = svc.RegisterHealthCheck(health.Config{
+		Name: "database",
+		Check: func(context.Context) error {
+			return errors.New("connection refused") //nolint:err113
+		},
+	})
+
+	svc.HealthChecker.IsHealthy(context.Background())
+	svc.HealthChecker.IsHealthy(context.Background())
+
+	kinds := waitForEvents(t, notifier, 1)
+	if len(kinds) != 1 || kinds[0] != EventUnhealthy {
+		t.Errorf("expected a single unhealthy notification, got %v", kinds)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Parallel()
+
+	var payload map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	t.Cleanup(server.Close)
+
+	notifier := &WebhookNotifier{URL: server.URL}
+
+	err := notifier.Notify(context.Background(), Event{
+		Kind:    EventShutdownTimeout,
+		Service: "api",
+		Message: "shutdown timeout exceeded",
+		Fields:  map[string]any{"addr": ":8080"},
+	})
+	if err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	text, _ := payload["text"].(string)
+	if !strings.Contains(text, "[api] shutdown_timeout: shutdown timeout exceeded") || !strings.Contains(text, "addr: :8080") {
+		t.Errorf("expected Slack-compatible text, got %q", text)
+	}
+}
+
+type deadlineNotifier struct {
+	remaining chan time.Duration
+}
+
+func (n *deadlineNotifier) Notify(ctx context.Context, _ Event) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		n.remaining <- -1
+		return nil
+	}
+
+	n.remaining <- time.Until(deadline)
+
+	return nil
+}
+
+func TestNotify_ShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	svc := New("notify_shutdown_test", nil)
+	notifier := &deadlineNotifier{remaining: make(chan time.Duration, 1)}
+	svc.AddNotifier(notifier)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		close(started)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	go func() {
+		resp, err := http.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := svc.shutdownServer(ctx, server.Config); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the shutdown timeout to be exceeded, got %v", err)
+	}
+
+	if remaining := <-notifier.remaining; remaining <= 0 || remaining > shutdownNotifyTimeout {
+		t.Errorf("expected the notification to be bounded by %v, got %v", shutdownNotifyTimeout, remaining)
+	}
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellofresh/health-go/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrTokenRefresh is returned when no OAuth2 access token could be obtained
+var ErrTokenRefresh = NewError(CodeUnavailable, "failed to obtain OAuth2 token")
+
+const (
+	// tokenRefreshMargin is the time before expiry at which tokens are refreshed
+	tokenRefreshMargin = 30 * time.Second
+	// tokenFetchTimeout limits a token refresh, which is detached from the request that triggered it
+	tokenFetchTimeout = 10 * time.Second
+)
+
+// TokenSourceConfig holds configuration for an OAuth2 client credentials token source
+type TokenSourceConfig struct {
+	// Issuer is the OAuth2 issuer URL. The token endpoint is discovered via /.well-known/openid-configuration.
+	Issuer string
+	// TokenURL is the token endpoint and takes precedence over the issuer
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Client is used for token requests. Defaults to an instrumented client with a 10s timeout.
+	Client *http.Client
+}
+
+// TokenSource obtains OAuth2 access tokens with the client credentials grant, caches them,
+// and refreshes them before they expire
+type TokenSource struct {
+	config TokenSourceConfig
+
+	refreshes *prometheus.CounterVec
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	err    error
+	// refresh is closed when the running token refresh completes, or nil without one
+	refresh chan struct{}
+}
+
+// tokenResponse is the response of an OAuth2 token endpoint
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// NewTokenSource creates a new OAuth2 client credentials token source.
+// Refreshes are counted in {service_name}_oauth_token_refresh_total{result}.
+func NewTokenSource(metrics *MetricsCollector, config TokenSourceConfig) *TokenSource {
+	if config.Client == nil {
+		config.Client = NewClient(metrics, ClientConfig{Name: "oauth", Timeout: 10 * time.Second})
+	}
+
+	source := &TokenSource{config: config}
+
+	if metrics != nil {
+		source.refreshes = metrics.builtinCounterVec("oauth_token_refresh_total",
+			"Total number of OAuth2 token refreshes by result", "result")
+	}
+
+	return source
+}
+
+// Token returns a valid access token, refreshing it if it expires soon. Concurrent callers share one refresh, and
+// the current token is used until it actually expires.
+func (ts *TokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+
+	if ts.token != "" && time.Until(ts.expiry) > tokenRefreshMargin {
+		token := ts.token
+		ts.mu.Unlock()
+
+		return token, nil
+	}
+
+	if ts.refresh == nil {
+		ts.refresh = make(chan struct{})
+
+		// The refresh outlives the request, so a disconnecting client doesn't fail it for everyone
+		go ts.refreshToken(context.WithoutCancel(ctx), ts.refresh)
+	}
+
+	refresh := ts.refresh
+	token, valid := ts.token, ts.token != "" && time.Now().Before(ts.expiry)
+	ts.mu.Unlock()
+
+	if valid {
+		return token, nil
+	}
+
+	select {
+	case <-refresh:
+	case <-ctx.Done():
+		return "", fmt.Errorf("%w: %w", ErrTokenRefresh, ctx.Err())
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token == "" || !time.Now().Before(ts.expiry) {
+		if ts.err != nil {
+			return "", ts.err
+		}
+
+		return "", fmt.Errorf("%w: token was invalidated", ErrTokenRefresh)
+	}
+
+	return ts.token, nil
+}
+
+// refreshToken fetches a new token without holding the lock and closes done afterwards. The current token is kept
+// if no new token can be obtained.
+func (ts *TokenSource) refreshToken(ctx context.Context, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+	defer cancel()
+
+	token, expiry, err := ts.fetch(ctx)
+	ts.record(err)
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.err = err
+	if err == nil {
+		ts.token, ts.expiry = token, expiry
+	}
+
+	ts.refresh = nil
+	close(done)
+}
+
+// Invalidate discards the cached token, e.g. after the token was rejected
+func (ts *TokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.token = ""
+}
+
+// HealthCheck returns a health check that fails while no access token can be obtained
+func (ts *TokenSource) HealthCheck() health.Config {
+	return health.Config{
+		Name:    "oauth_token",
+		Timeout: 10 * time.Second,
+		Check: func(ctx context.Context) error {
+			_, err := ts.Token(ctx)
+			return err
+		},
+	}
+}
+
+// record counts a token refresh
+func (ts *TokenSource) record(err error) {
+	if ts.refreshes == nil {
+		return
+	}
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	ts.refreshes.WithLabelValues(result).Inc()
+}
+
+// fetch requests a new access token from the token endpoint
+func (ts *TokenSource) fetch(ctx context.Context) (string, time.Time, error) {
+	tokenURL, err := ts.tokenURL(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(ts.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.config.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%w: %w", ErrTokenRefresh, err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(ts.config.ClientID), url.QueryEscape(ts.config.ClientSecret))
+
+	var response tokenResponse
+	if err := ts.getJSON(req, &response); err != nil {
+		return "", time.Time{}, err
+	}
+
+	if response.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("%w: empty access token", ErrTokenRefresh)
+	}
+
+	expiresIn := time.Duration(response.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = time.Hour
+	}
+
+	return response.AccessToken, time.Now().Add(expiresIn), nil
+}
+
+// tokenURL returns the configured token endpoint or discovers it from the issuer
+func (ts *TokenSource) tokenURL(ctx context.Context) (string, error) {
+	if ts.config.TokenURL != "" {
+		return ts.config.TokenURL, nil
+	}
+
+	if ts.config.Issuer == "" {
+		return "", fmt.Errorf("%w: no token URL or issuer configured", ErrTokenRefresh)
+	}
+
+	discoveryURL := strings.TrimSuffix(ts.config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTokenRefresh, err)
+	}
+
+	var discovery struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+
+	if err := ts.getJSON(req, &discovery); err != nil {
+		return "", err
+	}
+
+	if discovery.TokenEndpoint == "" {
+		return "", fmt.Errorf("%w: issuer has no token endpoint", ErrTokenRefresh)
+	}
+
+	// Discovery only happens once
+	ts.config.TokenURL = discovery.TokenEndpoint
+
+	return discovery.TokenEndpoint, nil
+}
+
+// getJSON sends a request and decodes its JSON response
+func (ts *TokenSource) getJSON(req *http.Request, target any) error {
+	resp, err := ts.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTokenRefresh, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned status %d", ErrTokenRefresh, req.URL.Path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("%w: %w", ErrTokenRefresh, err)
+	}
+
+	return nil
+}
+
+// tokenTransport is an http.RoundTripper that authorizes outbound requests with an OAuth2 access token
+type tokenTransport struct {
+	next   http.RoundTripper
+	source *TokenSource
+}
+
+// RoundTrip adds the access token to a copy of the request and sends it
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// The token may have been revoked, fetch a new one for the next request
+		t.source.Invalidate()
+	}
+
+	return resp, err //nolint:wrapcheck
+}
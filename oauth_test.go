@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestIssuer(t *testing.T, issued *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	var server *httptest.Server
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"token_endpoint":"` + server.URL + `/token"}`))
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		issued.Add(1)
+		_, _ = w.Write([]byte(`{"access_token":"token-` + r.FormValue("scope") + `","token_type":"Bearer","expires_in":3600}`))
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestTokenSource(t *testing.T) {
+	t.Parallel()
+
+	var issued atomic.Int32
+
+	issuer := newTestIssuer(t, &issued)
+	metrics := NewMetricsCollector("oauth_test")
+
+	source := NewTokenSource(metrics, TokenSourceConfig{
+		Issuer:       issuer.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read"},
+	})
+
+	var authorization string
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	t.Cleanup(upstream.Close)
+
+	client := NewClient(metrics, ClientConfig{TokenSource: source})
+
+	for range 3 {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if authorization != "Bearer token-read" {
+		t.Errorf("expected bearer token, got %q", authorization)
+	}
+
+	if count := issued.Load(); count != 1 {
+		t.Errorf("expected the token to be cached, got %d token requests", count)
+	}
+
+	source.Invalidate()
+
+	if _, err := source.Token(context.Background()); err != nil || issued.Load() != 2 {
+		t.Errorf("expected a new token after invalidation, got %v", err)
+	}
+
+	refreshes := metrics.builtinCounterVec("oauth_token_refresh_total", "", "result")
+	if got := testutil.ToFloat64(refreshes.WithLabelValues("success")); got != 2 {
+		t.Errorf("expected 2 successful refreshes, got %v", got)
+	}
+}
+
+func TestTokenSource_Failure(t *testing.T) {
+	t.Parallel()
+
+	var issued atomic.Int32
+
+	issuer := newTestIssuer(t, &issued)
+	source := NewTokenSource(nil, TokenSourceConfig{TokenURL: issuer.URL + "/token", ClientID: "client", ClientSecret: "wrong"})
+
+	if _, err := source.Token(context.Background()); !errors.Is(err, ErrTokenRefresh) {
+		t.Errorf("expected ErrTokenRefresh, got %v", err)
+	}
+
+	if err := source.HealthCheck().Check(context.Background()); err == nil {
+		t.Error("expected health check to fail without a token")
+	}
+}
+
+func TestTokenSource_DetachedRefresh(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	var issued atomic.Int32
+
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		issued.Add(1)
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+	}))
+	t.Cleanup(issuer.Close)
+
+	metrics := NewMetricsCollector("oauth_detached_test")
+	source := NewTokenSource(metrics, TokenSourceConfig{TokenURL: issuer.URL, ClientID: "client"})
+
+	// A caller that gives up doesn't cancel the refresh of the others
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := source.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the caller's deadline to be exceeded, got %v", err)
+	}
+
+	result := make(chan string, 1)
+
+	go func() {
+		token, _ := source.Token(context.Background())
+		result <- token
+	}()
+
+	close(release)
+
+	if token := <-result; token != "token" {
+		t.Errorf("expected the shared refresh to succeed, got %q", token)
+	}
+
+	if count := issued.Load(); count != 1 {
+		t.Errorf("expected one token request for both callers, got %d", count)
+	}
+
+	refreshes := metrics.builtinCounterVec("oauth_token_refresh_total", "", "result")
+	if got := testutil.ToFloat64(refreshes.WithLabelValues("failure")); got != 0 {
+		t.Errorf("expected no failed refreshes, got %v", got)
+	}
+}
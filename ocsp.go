@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSP stapling errors
+var (
+	ErrOCSPUnavailable = NewError(CodeFailedPrecondition, "OCSP stapling unavailable")
+	ErrOCSPStatus      = NewError(CodeFailedPrecondition, "certificate is not in good OCSP status")
+)
+
+// ocspRefreshInterval is the interval in which OCSP responses are checked for renewal
+const ocspRefreshInterval = time.Hour
+
+// maxOCSPResponseSize is the maximum size of an OCSP response
+const maxOCSPResponseSize = 1 << 20
+
+// recordCertificateExpiry exports the expiry of a loaded certificate as
+// {service_name}_tls_certificate_expiry_timestamp_seconds{certificate}
+func (s *Service) recordCertificateExpiry(leaf *x509.Certificate) {
+	s.Metrics.builtinGaugeVec("tls_certificate_expiry_timestamp_seconds",
+		"Expiry of the loaded TLS certificates as a Unix timestamp", "certificate").
+		WithLabelValues(certificateName(leaf)).Set(float64(leaf.NotAfter.Unix()))
+}
+
+// servedCertificate returns a function returning the certificate with its current OCSP staple.
+// Without OCSP stapling, an OCSP server, or an issuer certificate, the certificate is served without a staple.
+func (s *Service) servedCertificate(cert tls.Certificate) func() *tls.Certificate {
+	static := func() *tls.Certificate { return &cert }
+
+	if !s.Config.TLSOCSPStapling {
+		return static
+	}
+
+	ctx := s.subsystemContext("tls")
+
+	stapler, err := newOCSPStapler(cert, s.Metrics)
+	if err != nil {
+		LoggerFromContext(ctx).Warn("serving certificate without OCSP staple", "certificate", certificateName(cert.Leaf), "error", err)
+		return static
+	}
+
+	if err := stapler.refresh(ctx); err != nil {
+		LoggerFromContext(ctx).Warn("failed to fetch OCSP staple", "certificate", certificateName(cert.Leaf), "error", err)
+	}
+
+	s.Every("tls_ocsp_refresh", ocspRefreshInterval, stapler.refresh)
+
+	return stapler.certificate
+}
+
+// certificateName returns the common name of a certificate, or its first DNS name
+func certificateName(leaf *x509.Certificate) string {
+	if leaf.Subject.CommonName != "" || len(leaf.DNSNames) == 0 {
+		return leaf.Subject.CommonName
+	}
+
+	return leaf.DNSNames[0]
+}
+
+// ocspStapler staples OCSP responses to a certificate, so clients don't have to ask the CA for the revocation status
+type ocspStapler struct {
+	leaf      *x509.Certificate
+	issuer    *x509.Certificate
+	client    *http.Client
+	refreshes *prometheus.CounterVec
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	response *ocsp.Response
+}
+
+// newOCSPStapler creates a stapler for a certificate. The certificate file must contain the issuer certificate.
+func newOCSPStapler(cert tls.Certificate, metrics *MetricsCollector) (*ocspStapler, error) {
+	if cert.Leaf == nil || len(cert.Leaf.OCSPServer) == 0 {
+		return nil, fmt.Errorf("%w: certificate has no OCSP server", ErrOCSPUnavailable)
+	}
+
+	if len(cert.Certificate) < 2 {
+		return nil, fmt.Errorf("%w: certificate file contains no issuer certificate", ErrOCSPUnavailable)
+	}
+
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid issuer certificate: %w", ErrOCSPUnavailable, err)
+	}
+
+	return &ocspStapler{
+		leaf:   cert.Leaf,
+		issuer: issuer,
+		client: NewClient(metrics, ClientConfig{Name: "ocsp", Timeout: 10 * time.Second}),
+		refreshes: metrics.builtinCounterVec("tls_ocsp_refreshes_total",
+			"Total number of OCSP response refreshes by result", "result"),
+		cert: &cert,
+	}, nil
+}
+
+// certificate returns the certificate with the current OCSP staple
+func (o *ocspStapler) certificate() *tls.Certificate {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.cert
+}
+
+// refresh fetches a new OCSP response once half of the validity of the current one has passed.
+// Expired responses are no longer stapled, and neither are responses with a status other than good.
+func (o *ocspStapler) refresh(ctx context.Context) error {
+	o.mu.RLock()
+	current := o.response
+	o.mu.RUnlock()
+
+	if current != nil && time.Now().Before(current.ThisUpdate.Add(current.NextUpdate.Sub(current.ThisUpdate)/2)) {
+		return nil
+	}
+
+	response, raw, err := o.fetch(ctx)
+	if err != nil {
+		o.refreshes.WithLabelValues("failure").Inc()
+
+		if current != nil && !current.NextUpdate.IsZero() && time.Now().After(current.NextUpdate) {
+			o.staple(nil, nil)
+		}
+
+		return err
+	}
+
+	if response.Status != ocsp.Good {
+		result := "revoked"
+		if response.Status == ocsp.Unknown {
+			result = "unknown"
+		}
+
+		o.refreshes.WithLabelValues(result).Inc()
+		o.staple(nil, nil)
+
+		return fmt.Errorf("%w: status %d", ErrOCSPStatus, response.Status)
+	}
+
+	o.refreshes.WithLabelValues("success").Inc()
+	o.staple(response, raw)
+
+	LoggerFromContext(ctx).Info("refreshed OCSP staple", "certificate", certificateName(o.leaf), "next_update", response.NextUpdate)
+
+	return nil
+}
+
+// staple replaces the OCSP response stapled to the certificate
+func (o *ocspStapler) staple(response *ocsp.Response, raw []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	cert := *o.cert
+	cert.OCSPStaple = raw
+
+	o.cert = &cert
+	o.response = response
+}
+
+// fetch requests the revocation status of the certificate from the OCSP server of its issuer
+func (o *ocspStapler) fetch(ctx context.Context) (*ocsp.Response, []byte, error) {
+	body, err := ocsp.CreateRequest(o.leaf, o.issuer, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.leaf.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/ocsp-request")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("OCSP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%w: OCSP server returned status %d", ErrOCSPUnavailable, resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read OCSP response: %w", err)
+	}
+
+	response, err := ocsp.ParseResponseForCert(raw, o.leaf, o.issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+
+	return response, raw, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestOCSPStapling(t *testing.T) {
+	t.Parallel()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		request, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: request.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write(response)
+	}))
+	t.Cleanup(responder.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notAfter := time.Now().Add(12 * time.Hour).Truncate(time.Second)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		OCSPServer:   []string{responder.URL},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	chain := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+	if err := os.WriteFile(certFile, chain, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.TLSCertFile = certFile
+	config.TLSKeyFile = keyFile
+	config.TLSOCSPStapling = true
+
+	svc := New("ocsp_test", config)
+	t.Cleanup(svc.cancel)
+
+	tlsConfig, err := svc.newTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_ = conn.(*tls.Conn).Handshake() //nolint:forcetypeassert
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if len(conn.ConnectionState().OCSPResponse) == 0 {
+		t.Error("expected an OCSP staple")
+	}
+
+	expiry := svc.Metrics.builtinGaugeVec("tls_certificate_expiry_timestamp_seconds", "", "certificate").WithLabelValues("localhost")
+	if got := testutil.ToFloat64(expiry); got != float64(notAfter.Unix()) {
+		t.Errorf("expected certificate expiry %d, got %v", notAfter.Unix(), got)
+	}
+
+	refreshes := svc.Metrics.builtinCounterVec("tls_ocsp_refreshes_total", "", "result").WithLabelValues("success")
+	if got := testutil.ToFloat64(refreshes); got != 1 {
+		t.Errorf("expected 1 successful refresh, got %v", got)
+	}
+}
+
+func TestOCSPStaplingUnavailable(t *testing.T) {
+	t.Parallel()
+
+	certFile, keyFile := writeTestCertificate(t, time.Now().Add(time.Hour), "localhost")
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newOCSPStapler(cert, NewMetricsCollector("ocsp_unavailable_test")); err == nil {
+		t.Error("expected stapling to be unavailable for a certificate without OCSP server")
+	}
+}
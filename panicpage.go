@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// PanicInfo describes a panic recovered while serving a request
+type PanicInfo struct {
+	// Recovered is the value passed to panic
+	Recovered any
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+// PanicRenderer writes the response of a request whose handler panicked, e.g. to match the error format of an API
+// or to link to a crash reporter. The response may already be partially written.
+type PanicRenderer func(w http.ResponseWriter, r *http.Request, info PanicInfo)
+
+// DefaultPanicRenderer writes an opaque 500 with the error handler of the service (see WriteError), so panics
+// produce the same error bodies as other errors and reveal nothing about the panic
+func DefaultPanicRenderer(w http.ResponseWriter, r *http.Request, _ PanicInfo) {
+	renderError(w, r, http.StatusInternalServerError, ErrInternal)
+}
+
+// devPanicPage renders the panic, the stack trace, and the request dump in DevPanicRenderer
+var devPanicPage = template.Must(template.New("panic").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>panic: {{.Recovered}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+h1 { color: #b00020; }
+pre { background: #f5f5f5; padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>panic: {{.Recovered}}</h1>
+<p>{{.Method}} {{.Path}}</p>
+<h2>Stack</h2>
+<pre>{{.Stack}}</pre>
+<h2>Request</h2>
+<pre>{{.Request}}</pre>
+</body>
+</html>
+`))
+
+// DevPanicRenderer writes a 500 with the panic, the stack trace, and the request (with credentials redacted),
+// as an HTML page for browsers and as plain text otherwise. It is the default in dev mode and must never be used
+// in production, as it reveals internals of the service.
+func DevPanicRenderer(w http.ResponseWriter, r *http.Request, info PanicInfo) {
+	dumped := r.Clone(r.Context())
+	dumped.Header = redactHeaders(r.Header)
+
+	request, err := httputil.DumpRequest(dumped, false)
+	if err != nil {
+		request = fmt.Appendf(nil, "failed to dump request: %v", err)
+	}
+
+	w.Header().Del("Content-Length")
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusInternalServerError)
+
+		_, _ = fmt.Fprintf(w, "panic: %v\n\n%s\n%s", info.Recovered, info.Stack, request)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	_ = devPanicPage.Execute(w, map[string]any{
+		"Recovered": fmt.Sprint(info.Recovered),
+		"Method":    r.Method,
+		"Path":      r.URL.Path,
+		"Stack":     string(info.Stack),
+		"Request":   string(request),
+	})
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ErrUnknownPlugin is returned when installing a plugin that isn't registered
+var ErrUnknownPlugin = NewError(CodeNotFound, "unknown plugin")
+
+// Plugin installs shared functionality into a service, e.g. the middleware, health checks, and shutdown hooks of an
+// organization's auth, tracing, or audit setup
+type Plugin interface {
+	Install(s *Service) error
+}
+
+// PluginFunc is a function that implements Plugin
+type PluginFunc func(s *Service) error
+
+// Install calls the function
+func (f PluginFunc) Install(s *Service) error {
+	return f(s)
+}
+
+// pluginRegistry holds the plugin factories registered by plugin packages
+var pluginRegistry = struct {
+	mu        sync.RWMutex
+	factories map[string]func() Plugin
+}{factories: make(map[string]func() Plugin)}
+
+// Register makes a plugin available by name, typically from the init function of a plugin package, so services
+// install it with Service.Install or the PLUGINS environment variable. It panics if the name is registered twice
+// or the factory is nil.
+func Register(name string, factory func() Plugin) {
+	pluginRegistry.mu.Lock()
+	defer pluginRegistry.mu.Unlock()
+
+	if factory == nil {
+		panic("service: Register plugin factory is nil")
+	}
+
+	if _, exists := pluginRegistry.factories[name]; exists {
+		panic("service: Register called twice for plugin " + name)
+	}
+
+	pluginRegistry.factories[name] = factory
+}
+
+// RegisteredPlugins returns the names of the registered plugins, sorted
+func RegisteredPlugins() []string {
+	pluginRegistry.mu.RLock()
+	defer pluginRegistry.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(pluginRegistry.factories))
+}
+
+// Install installs registered plugins by name, in order. Plugins that are already installed are skipped.
+func (s *Service) Install(names ...string) error {
+	for _, name := range names {
+		if slices.Contains(s.plugins, name) {
+			continue
+		}
+
+		pluginRegistry.mu.RLock()
+		factory, ok := pluginRegistry.factories[name]
+		pluginRegistry.mu.RUnlock()
+
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownPlugin, name)
+		}
+
+		if err := factory().Install(s); err != nil {
+			return fmt.Errorf("failed to install plugin %s: %w", name, err)
+		}
+
+		s.plugins = append(s.plugins, name)
+		s.Logger.Debug("plugin installed", "plugin", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestInstallPlugins(t *testing.T) {
+	t.Parallel()
+
+	installs := 0
+
+	Register("test_audit", func() Plugin {
+		return PluginFunc(func(s *Service) error {
+			installs++
+
+			s.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Audited", "true")
+					next.ServeHTTP(w, r)
+				})
+			})
+
+			return nil
+		})
+	})
+
+	if !slices.Contains(RegisteredPlugins(), "test_audit") {
+		t.Errorf("expected the plugin to be registered, got %v", RegisteredPlugins())
+	}
+
+	svc := New("plugin_test", nil)
+
+	if err := svc.Install("test_audit", "test_audit"); err != nil {
+		t.Fatalf("failed to install plugin: %v", err)
+	}
+
+	if installs != 1 {
+		t.Errorf("expected the plugin to be installed once, got %d", installs)
+	}
+
+	svc.HandleFunc("GET /orders", func(http.ResponseWriter, *http.Request) {})
+
+	recorder := httptest.NewRecorder()
+	svc.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	if recorder.Header().Get("X-Audited") != "true" {
+		t.Error("expected the middleware of the plugin")
+	}
+
+	if err := svc.Install("test_missing"); !errors.Is(err, ErrUnknownPlugin) {
+		t.Errorf("expected ErrUnknownPlugin, got %v", err)
+	}
+}
+
+func TestInstallPluginError(t *testing.T) {
+	t.Parallel()
+
+	errBroken := errors.New("broken")
+
+	Register("test_broken", func() Plugin {
+		return PluginFunc(func(*Service) error { return errBroken })
+	})
+
+	if err := New("plugin_error_test", nil).Install("test_broken"); !errors.Is(err, errBroken) {
+		t.Errorf("expected the install error, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate registration")
+		}
+	}()
+
+	Register("test_broken", func() Plugin { return nil })
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Priority is the priority class of a request
+type Priority int
+
+// Priority classes, from lowest to highest
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+// priorityShares defines which share of the concurrency limit each priority class may occupy.
+// Critical requests are never rejected by the concurrency limiter.
+var priorityShares = map[Priority]float64{
+	PriorityLow:    0.5,
+	PriorityNormal: 0.8,
+	PriorityHigh:   1,
+}
+
+// String returns the name of the priority class
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePriority parses a priority class name ("low", "normal", "high", or "critical")
+func ParsePriority(name string) (Priority, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "low":
+		return PriorityLow, true
+	case "normal":
+		return PriorityNormal, true
+	case "high":
+		return PriorityHigh, true
+	case "critical":
+		return PriorityCritical, true
+	default:
+		return PriorityNormal, false
+	}
+}
+
+// WithPriority sets the priority class of a route.
+// Under the concurrency limiter, low-priority routes are shed first.
+func WithPriority(priority Priority) RouteOption {
+	return func(rt *route) {
+		rt.priority = &priority
+	}
+}
+
+// PriorityConfig configures how the priority class of a request is determined
+type PriorityConfig struct {
+	// Header is the header trusted proxies can set the priority class of a request with (empty disables it)
+	Header string
+	// TrustedProxies are the networks of proxies whose priority header is honored
+	TrustedProxies []netip.Prefix
+}
+
+// RequestPriority returns the priority class of a request. A valid priority header of a trusted proxy takes
+// precedence over the route priority, but never raises the priority above the priority of the route or high.
+// Other callers can't set their priority, so they can't escape load shedding.
+func RequestPriority(r *http.Request, config PriorityConfig) Priority {
+	priority, configured := PriorityNormal, false
+	if rt := getRoute(r); rt != nil && rt.priority != nil {
+		priority, configured = *rt.priority, true
+	}
+
+	if config.Header == "" || !(HeaderSanitizationConfig{TrustedProxies: config.TrustedProxies}).trusted(r) {
+		return priority
+	}
+
+	requested, ok := ParsePriority(r.Header.Get(config.Header))
+	if !ok {
+		return priority
+	}
+
+	requested = min(requested, PriorityHigh)
+	if configured {
+		requested = min(requested, priority)
+	}
+
+	return requested
+}
+
+// ConcurrencyLimiter limits the number of concurrently processed requests.
+// Each priority class may only use a share of the limit, so low-priority traffic is shed first
+// while high-priority and critical requests keep flowing.
+type ConcurrencyLimiter struct {
+	limit    int64
+	inFlight atomic.Int64
+
+	rejected *prometheus.CounterVec
+}
+
+// NewConcurrencyLimiter creates a new concurrency limiter
+func NewConcurrencyLimiter(limit int, metrics *MetricsCollector) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		limit: int64(limit),
+		rejected: metrics.builtinCounterVec("concurrency_limit_rejected_total",
+			"Total number of requests rejected by the concurrency limiter", "priority"),
+	}
+}
+
+// InFlight returns the number of requests currently admitted by the limiter
+func (cl *ConcurrencyLimiter) InFlight() int {
+	return int(cl.inFlight.Load())
+}
+
+// acquire admits a request of the given priority if its share of the limit isn't exhausted
+func (cl *ConcurrencyLimiter) acquire(priority Priority) bool {
+	if priority >= PriorityCritical {
+		cl.inFlight.Add(1)
+		return true
+	}
+
+	limit := int64(math.Ceil(float64(cl.limit) * priorityShares[priority]))
+
+	for {
+		current := cl.inFlight.Load()
+		if current >= limit {
+			return false
+		}
+
+		if cl.inFlight.CompareAndSwap(current, current+1) {
+			return true
+		}
+	}
+}
+
+// release frees a slot of the limiter
+func (cl *ConcurrencyLimiter) release() {
+	cl.inFlight.Add(-1)
+}
+
+// ConcurrencyLimitMiddleware rejects requests with 503 when the concurrency limit for their priority class is reached.
+// The priority is the route priority, or the priority header of trusted proxies (see RequestPriority).
+func ConcurrencyLimitMiddleware(limiter *ConcurrencyLimiter, priority PriorityConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			class := RequestPriority(r, priority)
+
+			if !limiter.acquire(class) {
+				limiter.rejected.WithLabelValues(class.String()).Inc()
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+
+				return
+			}
+			defer limiter.release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+func TestParsePriority(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]Priority{
+		"low":      PriorityLow,
+		"Normal":   PriorityNormal,
+		" high ":   PriorityHigh,
+		"critical": PriorityCritical,
+	} {
+		got, ok := ParsePriority(name)
+		if !ok || got != want {
+			t.Errorf("ParsePriority(%q): expected %s, got %s (ok=%v)", name, want, got, ok)
+		}
+	}
+
+	if _, ok := ParsePriority("urgent"); ok {
+		t.Error("expected unknown priority to be rejected")
+	}
+}
+
+func TestConcurrencyLimiter_Shares(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewConcurrencyLimiter(10, NewMetricsCollector("priority_test"))
+
+	// Low priority may use 50% of the limit
+	for range 5 {
+		if !limiter.acquire(PriorityLow) {
+			t.Fatal("expected low priority request to be admitted")
+		}
+	}
+
+	if limiter.acquire(PriorityLow) {
+		t.Error("expected low priority request to be rejected at 50%")
+	}
+
+	// Normal priority may use 80% of the limit
+	for range 3 {
+		if !limiter.acquire(PriorityNormal) {
+			t.Fatal("expected normal priority request to be admitted")
+		}
+	}
+
+	if limiter.acquire(PriorityNormal) {
+		t.Error("expected normal priority request to be rejected at 80%")
+	}
+
+	// High priority may use the full limit
+	for range 2 {
+		if !limiter.acquire(PriorityHigh) {
+			t.Fatal("expected high priority request to be admitted")
+		}
+	}
+
+	if limiter.acquire(PriorityHigh) {
+		t.Error("expected high priority request to be rejected at 100%")
+	}
+
+	// Critical requests are always admitted
+	if !limiter.acquire(PriorityCritical) {
+		t.Error("expected critical request to be admitted")
+	}
+
+	if limiter.InFlight() != 11 {
+		t.Errorf("expected 11 in-flight requests, got %d", limiter.InFlight())
+	}
+}
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.MaxConcurrentRequests = 2
+	config.TrustedProxies = []string{"192.0.2.0/24"}
+
+	svc := New("priority_test", config)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	svc.HandleFunc("/batch", func(w http.ResponseWriter, _ *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}, WithPriority(PriorityLow))
+
+	svc.HandleFunc("/interactive", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, WithPriority(PriorityHigh))
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		svc.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/batch", nil))
+	}()
+
+	<-started
+
+	// The low priority share (1 of 2) is exhausted
+	recorder := httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/batch", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected low priority request to be shed, got %d", recorder.Code)
+	}
+
+	// High priority traffic keeps flowing
+	recorder = httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/interactive", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected high priority request to pass, got %d", recorder.Code)
+	}
+
+	// Neither trusted proxies nor other callers can raise the priority of a route
+	for _, remoteAddr := range []string{"192.0.2.1:1234", "203.0.113.1:1234"} {
+		req := httptest.NewRequest(http.MethodGet, "/batch", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Priority", "high")
+
+		recorder = httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected the priority header of %s not to raise the route priority, got %d", remoteAddr, recorder.Code)
+		}
+	}
+
+	// Trusted proxies can downgrade requests via the priority header
+	req := httptest.NewRequest(http.MethodGet, "/interactive", nil)
+	req.Header.Set("X-Priority", "low")
+
+	recorder = httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected request with low priority header to be shed, got %d", recorder.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/interactive", nil)
+	req.RemoteAddr = "203.0.113.1:1234"
+	req.Header.Set("X-Priority", "low")
+
+	recorder = httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected the priority header of untrusted clients to be ignored, got %d", recorder.Code)
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+func TestRequestPriority(t *testing.T) {
+	t.Parallel()
+
+	config := PriorityConfig{Header: "X-Priority", TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+
+	for _, tt := range []struct {
+		remoteAddr string
+		header     string
+		want       Priority
+	}{
+		{"10.0.0.7:1234", "high", PriorityHigh},
+		{"10.0.0.7:1234", "critical", PriorityHigh},
+		{"10.0.0.7:1234", "low", PriorityLow},
+		{"192.0.2.1:1234", "high", PriorityNormal},
+		{"192.0.2.1:1234", "low", PriorityNormal},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Priority", tt.header)
+
+		if got := RequestPriority(req, config); got != tt.want {
+			t.Errorf("expected %s for %q from %s, got %s", tt.want, tt.header, tt.remoteAddr, got)
+		}
+	}
+}
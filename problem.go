@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrorHandlerKey is the context key for the error responses of the service
+const ErrorHandlerKey ContextKey = "error_handler"
+
+// ProblemContentType is the content type of problem details responses (RFC 9457, formerly RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of a problem details response (RFC 9457, formerly RFC 7807)
+type Problem struct {
+	// Type identifies the problem type. Empty is equivalent to "about:blank", i.e. the problem is described by the
+	// status alone.
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Code is the canonical error code (see CodeOf), e.g. "not_found"
+	Code      string         `json:"code,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// ErrorHandler writes the response of a failed request, e.g. to match the error format of an existing API
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// NewProblem returns the problem details of an error. Messages and details of a service Error are public, as are
+// the messages of other errors of client errors (4xx); server errors (5xx) only reveal their code.
+func NewProblem(r *http.Request, status int, err error) Problem {
+	problem := Problem{
+		Title:     http.StatusText(status),
+		Status:    status,
+		Instance:  r.URL.Path,
+		RequestID: RequestID(r),
+	}
+
+	if code := CodeOf(err); code != CodeUnknown && code != CodeOK {
+		problem.Code = code.String()
+	}
+
+	var serviceErr *Error
+
+	switch {
+	case errors.As(err, &serviceErr):
+		problem.Detail = serviceErr.Message
+		problem.Details = serviceErr.Details
+	case err != nil && status < http.StatusInternalServerError:
+		problem.Detail = err.Error()
+	}
+
+	return problem
+}
+
+// ProblemErrorHandler writes the error as problem details (see NewProblem). It is the default ErrorHandler.
+func ProblemErrorHandler(w http.ResponseWriter, r *http.Request, status int, err error) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(NewProblem(r, status, err))
+}
+
+// errorResponses renders the error responses of a service and counts them
+type errorResponses struct {
+	handler ErrorHandler
+	counter *prometheus.CounterVec
+}
+
+// errorResponsesMiddleware makes the error handler of the service available to WriteError
+func errorResponsesMiddleware(metrics *MetricsCollector, handler ErrorHandler) Middleware {
+	if handler == nil {
+		handler = ProblemErrorHandler
+	}
+
+	responses := &errorResponses{
+		handler: handler,
+		counter: metrics.builtinCounterVec("error_responses_total",
+			"Total number of error responses written with WriteError by route and code", "route", "code"),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ErrorHandlerKey, responses)))
+		})
+	}
+}
+
+// WriteError writes the error response of a request with the error handler of the service (problem details by
+// default, see Config.ErrorHandler), logs the error with the request logger, and counts the response in
+// {service_name}_error_responses_total{route,code}. A status of 0 is derived from the error (see HTTPStatus).
+func WriteError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if status == 0 {
+		status = HTTPStatus(err)
+	}
+
+	logger := GetLogger(r)
+	if status >= http.StatusInternalServerError {
+		logger.Error("request failed", "route", RoutePattern(r), "status", status, "error", err)
+	} else {
+		logger.Info("request rejected", "route", RoutePattern(r), "status", status, "error", err)
+	}
+
+	if responses, ok := r.Context().Value(ErrorHandlerKey).(*errorResponses); ok {
+		responses.counter.WithLabelValues(RoutePattern(r), CodeOf(err).String()).Inc()
+	}
+
+	renderError(w, r, status, err)
+}
+
+// renderError writes an error response with the error handler of the service, without logging or counting it,
+// e.g. for middleware that reports the error itself
+func renderError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if responses, ok := r.Context().Value(ErrorHandlerKey).(*errorResponses); ok {
+		responses.handler(w, r, status, err)
+		return
+	}
+
+	ProblemErrorHandler(w, r, status, err)
+}
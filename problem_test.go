@@ -0,0 +1,91 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+
+	svc := New("problem_test", nil)
+
+	svc.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, 0, fmt.Errorf("user %s: %w", r.PathValue("id"),
+			NewError(CodeNotFound, "user not found").WithDetail("id", r.PathValue("id"))))
+	})
+	svc.HandleFunc("GET /internal", func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, http.StatusInternalServerError, errors.New("connection to 10.0.0.5 refused"))
+	})
+	svc.HandleFunc("GET /panic", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	handler := svc.handler()
+
+	request := func(path string) (*httptest.ResponseRecorder, Problem) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var problem Problem
+		if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+			t.Fatalf("expected a problem details body for %s: %v", path, err)
+		}
+
+		if got := rec.Header().Get("Content-Type"); got != ProblemContentType {
+			t.Errorf("expected content type %s for %s, got %s", ProblemContentType, path, got)
+		}
+
+		return rec, problem
+	}
+
+	rec, problem := request("/users/42")
+	if rec.Code != http.StatusNotFound || problem.Status != http.StatusNotFound || problem.Code != "not_found" ||
+		problem.Detail != "user not found" || problem.Details["id"] != "42" || problem.Instance != "/users/42" ||
+		problem.RequestID == "" {
+		t.Errorf("unexpected problem %d %+v", rec.Code, problem)
+	}
+
+	rec, problem = request("/internal")
+	if rec.Code != http.StatusInternalServerError || problem.Detail != "" {
+		t.Errorf("expected server errors to hide their message, got %d %+v", rec.Code, problem)
+	}
+
+	rec, problem = request("/panic")
+	if rec.Code != http.StatusInternalServerError || problem.Code != "internal" {
+		t.Errorf("expected panics to render a problem, got %d %+v", rec.Code, problem)
+	}
+
+	responses := svc.Metrics.builtinCounterVec("error_responses_total", "", "route", "code")
+	if got := testutil.ToFloat64(responses.WithLabelValues("GET /users/{id}", "not_found")); got != 1 {
+		t.Errorf("expected 1 not_found response, got %v", got)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, status int, err error) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(CodeOf(err).String()))
+	}
+	svc := New("error_handler_test", config)
+
+	svc.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, 0, ErrPermissionDenied)
+	})
+
+	rec := httptest.NewRecorder()
+	svc.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusForbidden || rec.Body.String() != "permission_denied" {
+		t.Errorf("expected the custom error handler to render the error, got %d %q", rec.Code, rec.Body.String())
+	}
+}
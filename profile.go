@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/caarlos0/env/v11"
+)
+
+// ErrUnknownProfile is returned when loading a config with an unknown profile
+var ErrUnknownProfile = NewError(CodeInvalidArgument, "unknown config profile")
+
+// Profile selects a bundle of defaults for an environment
+type Profile string
+
+// Config profiles
+const (
+	// ProfileDev enables dev mode (pretty debug logs and a route table) and the debug endpoints with long timeouts
+	// for debugging
+	ProfileDev Profile = "dev"
+	// ProfileStaging logs JSON at debug level
+	ProfileStaging Profile = "staging"
+	// ProfileProd logs JSON, enables HSTS, drains before the shutdown, and disables the admin endpoints
+	ProfileProd Profile = "prod"
+)
+
+// profileDefaults are the defaults of each profile as environment variables. Variables set in the environment
+// override them.
+var profileDefaults = map[Profile]map[string]string{
+	ProfileDev: {
+		"DEV_MODE":         "true",
+		"DEBUG_ENDPOINTS":  "true",
+		"LOG_LEVEL":        "debug",
+		"READ_TIMEOUT":     "5m",
+		"WRITE_TIMEOUT":    "5m",
+		"SHUTDOWN_TIMEOUT": "5s",
+	},
+	ProfileStaging: {
+		"LOG_FORMAT": "json",
+		"LOG_LEVEL":  "debug",
+	},
+	ProfileProd: {
+		"LOG_FORMAT":         "json",
+		"LOG_LEVEL":          "info",
+		"HSTS_MAX_AGE":       "8760h",
+		"PRE_SHUTDOWN_DELAY": "5s",
+		"ADMIN_ENABLED":      "false",
+	},
+}
+
+// ProfileConfig returns the default config with the defaults of a profile, for services configured in code
+func ProfileConfig(profile Profile) (*Config, error) {
+	defaults, ok := profileDefaults[profile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, profile)
+	}
+
+	config := DefaultConfig()
+
+	environment := map[string]string{"PROFILE": string(profile)}
+	for name, value := range defaults {
+		environment[name] = value
+	}
+
+	if err := env.ParseWithOptions(config, env.Options{Environment: environment}); err != nil {
+		return nil, fmt.Errorf("failed to apply profile: %w", err)
+	}
+
+	config.setDefaultLogger(newLogger(os.Stdout, config.LogFormat, config.LogLevel))
+
+	return config, nil
+}
+
+// profileEnvironment returns the environment with the defaults of the profile in PROFILE for unset variables
+func profileEnvironment(environment map[string]string) (map[string]string, error) {
+	profile := Profile(environment["PROFILE"])
+	if profile == "" {
+		return environment, nil
+	}
+
+	defaults, ok := profileDefaults[profile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, profile)
+	}
+
+	for name, value := range defaults {
+		if _, set := environment[name]; !set {
+			environment[name] = value
+		}
+	}
+
+	return environment, nil
+}
+
+// newLogger creates the service logger in the given format ("text" or "json")
+func newLogger(out io.Writer, format string, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(out, options))
+	}
+
+	return slog.New(slog.NewTextHandler(out, options))
+}
+
+// HSTSMiddleware sets the Strict-Transport-Security header, so browsers only connect via HTTPS for the max age.
+// Browsers ignore the header on plain HTTP responses.
+func HSTSMiddleware(maxAge int) Middleware {
+	value := "max-age=" + strconv.Itoa(maxAge)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Strict-Transport-Security", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadFromEnv_Profile(t *testing.T) {
+	t.Setenv("PROFILE", "prod")
+	t.Setenv("PRE_SHUTDOWN_DELAY", "10s")
+
+	config, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if config.Profile != ProfileProd || config.LogFormat != "json" || config.AdminEnabled {
+		t.Errorf("expected the prod defaults, got profile %q, log format %q, admin %v",
+			config.Profile, config.LogFormat, config.AdminEnabled)
+	}
+
+	if config.HSTSMaxAge != 365*24*time.Hour {
+		t.Errorf("expected HSTS for a year, got %s", config.HSTSMaxAge)
+	}
+
+	if config.PreShutdownDelay != 10*time.Second {
+		t.Errorf("expected the environment to override the profile, got %s", config.PreShutdownDelay)
+	}
+
+	t.Setenv("PROFILE", "qa")
+
+	if _, err := LoadFromEnv(); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("expected ErrUnknownProfile, got %v", err)
+	}
+}
+
+func TestProfileConfig(t *testing.T) {
+	t.Parallel()
+
+	config, err := ProfileConfig(ProfileDev)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	if !config.DevMode || config.LogLevel != slog.LevelDebug || config.ShutdownTimeout != 5*time.Second {
+		t.Errorf("expected the dev defaults, got dev mode %v, log level %s, shutdown timeout %s",
+			config.DevMode, config.LogLevel, config.ShutdownTimeout)
+	}
+
+	if config.Addr != ":8080" {
+		t.Errorf("expected the other defaults to be kept, got addr %q", config.Addr)
+	}
+
+	if _, err := ProfileConfig("qa"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("expected ErrUnknownProfile, got %v", err)
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.HSTSMaxAge = time.Hour
+
+	svc := New("test-service", config)
+	svc.HandleFunc("GET /", func(w http.ResponseWriter, _ *http.Request) {})
+
+	recorder := httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if header := recorder.Header().Get("Strict-Transport-Security"); header != "max-age=3600" {
+		t.Errorf("expected the HSTS header, got %q", header)
+	}
+}
+
+func TestAdminDisabled(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.AdminEnabled = false
+
+	recorder := httptest.NewRecorder()
+	New("test-service", config).metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/health-checks", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected the admin endpoints to be disabled, got status %d", recorder.Code)
+	}
+}
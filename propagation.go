@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// PropagatedHeadersKey is the context key for the propagated headers
+const PropagatedHeadersKey ContextKey = "propagated_headers"
+
+// PropagationMiddleware copies the given incoming headers into the request context.
+// Instrumented clients created with NewClient automatically add them to outbound requests.
+func PropagationMiddleware(headers []string) Middleware {
+	names := make([]string, 0, len(headers))
+	for _, header := range headers {
+		names = append(names, textproto.CanonicalMIMEHeaderKey(header))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			propagated := make(http.Header)
+
+			for _, name := range names {
+				if values := r.Header.Values(name); len(values) > 0 {
+					propagated[name] = append([]string(nil), values...)
+				}
+			}
+
+			if len(propagated) > 0 {
+				r = r.WithContext(WithPropagated(r.Context(), propagated))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithPropagated returns a context carrying headers that should be propagated to outbound requests
+func WithPropagated(ctx context.Context, header http.Header) context.Context {
+	return context.WithValue(ctx, PropagatedHeadersKey, header)
+}
+
+// Propagated returns a copy of the headers propagated with the context
+func Propagated(ctx context.Context) http.Header {
+	header, ok := ctx.Value(PropagatedHeadersKey).(http.Header)
+	if !ok {
+		return http.Header{}
+	}
+
+	return header.Clone()
+}
+
+// propagationFilter returns whether an instrumented client propagates headers to a host: hosts of the allowlist
+// (case-insensitive), all hosts without an allowlist, or none for untrusted destinations
+func propagationFilter(hosts []string, untrusted bool) func(host string) bool {
+	if len(hosts) == 0 {
+		return func(string) bool { return !untrusted }
+	}
+
+	allowed := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		allowed[strings.ToLower(host)] = true
+	}
+
+	return func(host string) bool {
+		return allowed[strings.ToLower(host)]
+	}
+}
+
+// propagateHeaders returns a copy of an outbound request with the propagated headers and the request ID of its context.
+// Headers that are already set on the request are not overwritten.
+func propagateHeaders(req *http.Request) *http.Request {
+	header, _ := req.Context().Value(PropagatedHeadersKey).(http.Header)
+
+	id, hasID := req.Context().Value(RequestIDKey).(requestID)
+	hasID = hasID && req.Header.Get(id.header) == ""
+
+	if len(header) == 0 && !hasID {
+		return req
+	}
+
+	req = req.Clone(req.Context())
+
+	for name, values := range header {
+		if _, exists := req.Header[name]; !exists {
+			req.Header[name] = append([]string(nil), values...)
+		}
+	}
+
+	if hasID && req.Header.Get(id.header) == "" {
+		req.Header.Set(id.header, id.id)
+	}
+
+	return req
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPropagation(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan http.Header, 1)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	config := DefaultConfig()
+	config.PropagatedHeaders = []string{"x-tenant-id", "Accept-Language"}
+
+	svc := New("propagation_test", config)
+	client := svc.NewClient(ClientConfig{})
+
+	svc.HandleFunc("/proxy", func(w http.ResponseWriter, r *http.Request) {
+		if got := Propagated(r.Context()).Get("X-Tenant-ID"); got != "acme" {
+			t.Errorf("expected propagated tenant 'acme', got %q", got)
+		}
+
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		req.Header.Set("Accept-Language", "fr")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("outbound request failed: %v", err)
+			return
+		}
+		resp.Body.Close()
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/proxy", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	req.Header.Set("Accept-Language", "de")
+	req.Header.Set("Authorization", "Bearer secret")
+
+	svc.mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	header := <-received
+
+	if header.Get("X-Tenant-Id") != "acme" {
+		t.Errorf("expected tenant header to be propagated, got %q", header.Get("X-Tenant-Id"))
+	}
+
+	if header.Get("Accept-Language") != "fr" {
+		t.Errorf("expected explicitly set header to win, got %q", header.Get("Accept-Language"))
+	}
+
+	if header.Get("Authorization") != "" {
+		t.Error("expected headers outside the allowlist not to be propagated")
+	}
+}
+
+func TestPropagated_Empty(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if header := Propagated(req.Context()); len(header) != 0 {
+		t.Errorf("expected no propagated headers, got %v", header)
+	}
+}
+
+func TestPropagation_Destinations(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan http.Header, 1)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	ctx := WithPropagated(context.Background(), http.Header{"Authorization": {"Bearer secret"}})
+
+	tests := []struct {
+		name      string
+		config    ClientConfig
+		propagate bool
+	}{
+		{"default", ClientConfig{}, true},
+		{"egress policy", ClientConfig{Egress: &EgressPolicy{AllowPrivate: true}}, false},
+		{"allowed host", ClientConfig{Egress: &EgressPolicy{AllowPrivate: true}, PropagateTo: []string{"127.0.0.1"}}, true},
+		{"other host", ClientConfig{PropagateTo: []string{"users.internal"}}, false},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+
+		resp, err := NewClient(nil, tt.config).Do(req)
+		if err != nil {
+			t.Fatalf("%s: outbound request failed: %v", tt.name, err)
+		}
+		resp.Body.Close()
+
+		if got := (<-received).Get("Authorization") != ""; got != tt.propagate {
+			t.Errorf("%s: expected propagation %v, got %v", tt.name, tt.propagate, got)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// defaultMaxRateWindows is the default number of tenants with their own rate limit window
+	defaultMaxRateWindows = 10000
+	// rateWindowPruneBatch is the number of windows checked for expiry when a window is created, so pruning never
+	// scans all windows while holding the lock
+	rateWindowPruneBatch = 16
+)
+
+// RateLimit allows a number of requests per time window
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// enabled reports whether the rate limit restricts requests
+func (rl RateLimit) enabled() bool {
+	return rl.Requests > 0 && rl.Window > 0
+}
+
+// TenantRateLimitConfig holds configuration for per-tenant rate limits and quotas
+type TenantRateLimitConfig struct {
+	// Limits maps tenant identifiers to their rate limit
+	Limits map[string]RateLimit
+	// Lookup resolves the rate limit of a tenant dynamically and takes precedence over Limits
+	Lookup func(tenant string) (RateLimit, bool)
+	// Default applies to tenants without an explicit limit. The zero value doesn't limit requests.
+	Default RateLimit
+	// MaxTenants limits the number of distinct tenants in the usage metrics and the usage snapshot; further tenants
+	// are counted as "other". Defaults to 100.
+	MaxTenants int
+	// MaxWindows limits the number of tenants with their own rate limit window. Further tenants share one window
+	// until windows expire, so clients choosing tenant IDs can't grow the limiter without bound. Defaults to 10000.
+	MaxWindows int
+}
+
+// TenantRateLimiter enforces per-tenant rate limits using fixed windows and tracks per-tenant usage
+type TenantRateLimiter struct {
+	config TenantRateLimitConfig
+
+	mu       sync.Mutex
+	windows  map[string]*rateWindow
+	overflow *rateWindow
+	usage    map[string]uint64
+
+	usageTotal *prometheus.CounterVec
+	guard      *cardinalityGuard
+}
+
+// rateWindow counts the requests of a tenant in the current window
+type rateWindow struct {
+	start  time.Time
+	length time.Duration
+	count  int
+}
+
+// rateDecision is the result of a rate limit check
+type rateDecision struct {
+	allowed   bool
+	limit     int
+	remaining int
+	reset     time.Duration
+}
+
+// NewTenantRateLimiter creates a new per-tenant rate limiter
+func NewTenantRateLimiter(metrics *MetricsCollector, config TenantRateLimitConfig) *TenantRateLimiter {
+	if config.MaxWindows <= 0 {
+		config.MaxWindows = defaultMaxRateWindows
+	}
+
+	limiter := &TenantRateLimiter{
+		config:  config,
+		windows: make(map[string]*rateWindow),
+		usage:   make(map[string]uint64),
+		guard:   newCardinalityGuard(config.MaxTenants),
+	}
+
+	if metrics != nil {
+		limiter.usageTotal = metrics.builtinCounterVec("tenant_usage_total",
+			"Total number of requests per tenant by rate limit outcome", "tenant", "outcome")
+	}
+
+	return limiter
+}
+
+// limitFor returns the rate limit of a tenant
+func (l *TenantRateLimiter) limitFor(tenant string) RateLimit {
+	if l.config.Lookup != nil {
+		if limit, ok := l.config.Lookup(tenant); ok {
+			return limit
+		}
+	}
+
+	if limit, ok := l.config.Limits[tenant]; ok {
+		return limit
+	}
+
+	return l.config.Default
+}
+
+// allow records a request of a tenant and decides whether it is within the rate limit
+func (l *TenantRateLimiter) allow(tenant string) rateDecision {
+	limit := l.limitFor(tenant)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !limit.enabled() {
+		l.usage[l.guard.value(tenant)]++
+		return rateDecision{allowed: true}
+	}
+
+	window := l.windowLocked(tenant, limit, now)
+
+	decision := rateDecision{
+		limit: limit.Requests,
+		reset: limit.Window - now.Sub(window.start),
+	}
+
+	if window.count < limit.Requests {
+		window.count++
+		l.usage[l.guard.value(tenant)]++
+
+		decision.allowed = true
+	}
+
+	decision.remaining = limit.Requests - window.count
+
+	return decision
+}
+
+// windowLocked returns the current window of a tenant, or the shared overflow window once MaxWindows tenants have
+// a window. The caller must hold the lock.
+func (l *TenantRateLimiter) windowLocked(tenant string, limit RateLimit, now time.Time) *rateWindow {
+	window, ok := l.windows[tenant]
+	if !ok {
+		l.pruneLocked(now)
+
+		if len(l.windows) >= l.config.MaxWindows {
+			if l.overflow == nil {
+				l.overflow = &rateWindow{start: now, length: limit.Window}
+			}
+
+			window = l.overflow
+		} else {
+			window = &rateWindow{start: now, length: limit.Window}
+			l.windows[tenant] = window
+		}
+	}
+
+	if now.Sub(window.start) >= limit.Window {
+		*window = rateWindow{start: now, length: limit.Window}
+	}
+
+	return window
+}
+
+// pruneLocked removes expired windows among a few windows to bound memory usage. Map iteration starts at a random
+// entry, so all windows are checked over time. The caller must hold the lock.
+func (l *TenantRateLimiter) pruneLocked(now time.Time) {
+	checked := 0
+
+	for tenant, window := range l.windows {
+		if checked == rateWindowPruneBatch {
+			return
+		}
+
+		checked++
+
+		if now.Sub(window.start) >= window.length {
+			delete(l.windows, tenant)
+		}
+	}
+}
+
+// Usage returns the number of allowed requests of a tenant since the limiter was created. Tenants beyond
+// MaxTenants are counted together as "other".
+func (l *TenantRateLimiter) Usage(tenant string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.usage[tenant]
+}
+
+// UsageSnapshot returns the number of allowed requests of all tenants, e.g. for billing reports. Tenants beyond
+// MaxTenants are counted together as "other".
+func (l *TenantRateLimiter) UsageSnapshot() map[string]uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snapshot := make(map[string]uint64, len(l.usage))
+	for tenant, count := range l.usage {
+		snapshot[tenant] = count
+	}
+
+	return snapshot
+}
+
+// TenantRateLimitMiddleware enforces the rate limit of the request's tenant (see TenantMiddleware).
+// Requests exceeding the limit are rejected with 429. Requests without a tenant are not limited.
+func TenantRateLimitMiddleware(limiter *TenantRateLimiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := TenantID(r)
+			if tenant == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			decision := limiter.allow(tenant)
+
+			outcome := "allowed"
+			if !decision.allowed {
+				outcome = "limited"
+			}
+
+			if limiter.usageTotal != nil {
+				limiter.usageTotal.WithLabelValues(limiter.guard.value(tenant), outcome).Inc()
+			}
+
+			if decision.limit > 0 {
+				reset := strconv.Itoa(int(math.Ceil(decision.reset.Seconds())))
+
+				w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.limit))
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.remaining))
+				w.Header().Set("X-RateLimit-Reset", reset)
+
+				if !decision.allowed {
+					w.Header().Set("Retry-After", reset)
+					http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
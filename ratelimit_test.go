@@ -0,0 +1,158 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTenantRateLimitMiddleware(t *testing.T) {
+	t.Parallel()
+
+	svc := New("ratelimit_test", nil)
+	limiter := NewTenantRateLimiter(svc.Metrics, TenantRateLimitConfig{
+		Limits: map[string]RateLimit{"acme": {Requests: 2, Window: time.Minute}},
+		Lookup: func(tenant string) (RateLimit, bool) {
+			if tenant == "vip" {
+				return RateLimit{Requests: 100, Window: time.Minute}, true
+			}
+
+			return RateLimit{}, false
+		},
+	})
+
+	svc.Use(TenantMiddleware(svc.Metrics, TenantConfig{Extractor: HeaderTenantExtractor("X-Tenant-ID")}))
+	svc.Use(TenantRateLimitMiddleware(limiter))
+	svc.HandleFunc("/test", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	request := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+
+		recorder := httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	if recorder := request("acme"); recorder.Code != http.StatusOK || recorder.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("expected first request to pass with 1 remaining, got %d / %q",
+			recorder.Code, recorder.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	request("acme")
+
+	recorder := request("acme")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", recorder.Code)
+	}
+
+	if recorder.Header().Get("X-RateLimit-Remaining") != "0" || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("expected quota headers on 429, got %v", recorder.Header())
+	}
+
+	if recorder := request("vip"); recorder.Header().Get("X-RateLimit-Limit") != "100" {
+		t.Errorf("expected lookup limit of 100, got %q", recorder.Header().Get("X-RateLimit-Limit"))
+	}
+
+	if recorder := request("unlimited"); recorder.Code != http.StatusOK || recorder.Header().Get("X-RateLimit-Limit") != "" {
+		t.Error("expected tenants without limit to pass without quota headers")
+	}
+
+	if recorder := request(""); recorder.Code != http.StatusOK {
+		t.Errorf("expected requests without tenant to pass, got %d", recorder.Code)
+	}
+
+	if usage := limiter.Usage("acme"); usage != 2 {
+		t.Errorf("expected usage of 2 for acme, got %d", usage)
+	}
+
+	if snapshot := limiter.UsageSnapshot(); snapshot["unlimited"] != 1 {
+		t.Errorf("expected usage snapshot to contain unlimited tenant, got %v", snapshot)
+	}
+}
+
+func TestTenantRateLimiter_WindowReset(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewTenantRateLimiter(nil, TenantRateLimitConfig{
+		Default: RateLimit{Requests: 1, Window: 20 * time.Millisecond},
+	})
+
+	if !limiter.allow("acme").allowed {
+		t.Fatal("expected first request to be allowed")
+	}
+
+	if limiter.allow("acme").allowed {
+		t.Fatal("expected second request to be limited")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if !limiter.allow("acme").allowed {
+		t.Error("expected request to be allowed in the next window")
+	}
+}
+
+func TestTenantRateLimiter_BoundedUsage(t *testing.T) {
+	t.Parallel()
+
+	var lookups atomic.Int32
+
+	limiter := NewTenantRateLimiter(nil, TenantRateLimitConfig{
+		Lookup: func(string) (RateLimit, bool) {
+			lookups.Add(1)
+			return RateLimit{Requests: 10, Window: time.Minute}, true
+		},
+		MaxTenants: 2,
+	})
+
+	for i := range 2000 {
+		limiter.allow("tenant-" + strconv.Itoa(i))
+	}
+
+	if snapshot := limiter.UsageSnapshot(); len(snapshot) != 3 || snapshot[overflowLabel] != 1998 {
+		t.Errorf("expected 2 tenants and the overflow, got %d entries", len(snapshot))
+	}
+
+	if got := lookups.Load(); got != 2000 {
+		t.Errorf("expected one lookup per request and none while pruning, got %d", got)
+	}
+}
+
+func TestTenantRateLimiter_MaxWindows(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewTenantRateLimiter(nil, TenantRateLimitConfig{
+		Default:    RateLimit{Requests: 5, Window: time.Minute},
+		MaxWindows: 10,
+	})
+
+	allowed := 0
+
+	for i := range 1000 {
+		if limiter.allow("tenant-" + strconv.Itoa(i)).allowed {
+			allowed++
+		}
+	}
+
+	if len(limiter.windows) != 10 {
+		t.Errorf("expected 10 windows, got %d", len(limiter.windows))
+	}
+
+	// The first 10 tenants have their own windows, all further tenants share one
+	if allowed != 15 {
+		t.Errorf("expected 15 allowed requests, got %d", allowed)
+	}
+
+	if !limiter.allow("tenant-0").allowed {
+		t.Error("expected tenants with a window to keep their own limit")
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NonceStore remembers the nonces of accepted requests
+type NonceStore interface {
+	// Add stores a nonce until it expires and reports whether it was not seen before
+	Add(ctx context.Context, nonce string, expiry time.Time) (bool, error)
+}
+
+// MemoryNonceStore is an in-process NonceStore. Use a shared store (e.g. Redis) when running multiple replicas.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryNonceStore creates a new in-process nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// Add stores a nonce until it expires and reports whether it was not seen before
+func (s *MemoryNonceStore) Add(_ context.Context, nonce string, expiry time.Time) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.After(s.nextSweep) {
+		for key, expires := range s.nonces {
+			if now.After(expires) {
+				delete(s.nonces, key)
+			}
+		}
+
+		s.nextSweep = now.Add(time.Minute)
+	}
+
+	if expires, ok := s.nonces[nonce]; ok && now.Before(expires) {
+		return false, nil
+	}
+
+	s.nonces[nonce] = expiry
+
+	return true, nil
+}
+
+// ReplayConfig holds configuration for the replay protection middleware
+type ReplayConfig struct {
+	// Window is the maximum clock difference between the request timestamp and the server. Defaults to 5m.
+	Window time.Duration
+	// Store remembers seen nonces. Defaults to an in-process store.
+	Store NonceStore
+	// NonceHeader is the header carrying the unique request nonce. Defaults to "X-Nonce".
+	NonceHeader string
+	// TimestampHeader is the header carrying the request time in Unix seconds. Defaults to "X-Timestamp".
+	TimestampHeader string
+}
+
+// ReplayProtectionMiddleware rejects requests without a fresh timestamp or with a nonce that was already used.
+// Rejected requests are counted in {service_name}_replay_rejected_total{reason}.
+func ReplayProtectionMiddleware(metrics *MetricsCollector, config ReplayConfig) Middleware {
+	if config.Window <= 0 {
+		config.Window = 5 * time.Minute
+	}
+
+	if config.Store == nil {
+		config.Store = NewMemoryNonceStore()
+	}
+
+	if config.NonceHeader == "" {
+		config.NonceHeader = "X-Nonce"
+	}
+
+	if config.TimestampHeader == "" {
+		config.TimestampHeader = "X-Timestamp"
+	}
+
+	var rejected *prometheus.CounterVec
+	if metrics != nil {
+		rejected = metrics.builtinCounterVec("replay_rejected_total", "Total number of requests rejected by replay protection",
+			"reason")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reject := func(reason, message string, status int) {
+				if rejected != nil {
+					rejected.WithLabelValues(reason).Inc()
+				}
+
+				GetLogger(r).Warn("request rejected by replay protection", "reason", reason, "route", RoutePattern(r), "path", r.URL.Path)
+				http.Error(w, message, status)
+			}
+
+			nonce := r.Header.Get(config.NonceHeader)
+			if nonce == "" {
+				reject("missing_nonce", "Missing nonce", http.StatusUnauthorized)
+				return
+			}
+
+			seconds, err := strconv.ParseInt(r.Header.Get(config.TimestampHeader), 10, 64)
+			if err != nil {
+				reject("invalid_timestamp", "Invalid timestamp", http.StatusUnauthorized)
+				return
+			}
+
+			timestamp := time.Unix(seconds, 0)
+			if skew := time.Since(timestamp).Abs(); skew > config.Window {
+				reject("expired", "Request expired", http.StatusUnauthorized)
+				return
+			}
+
+			// Nonces only need to be remembered as long as their timestamp is accepted
+			fresh, err := config.Store.Add(r.Context(), nonce, timestamp.Add(config.Window))
+			if err != nil {
+				GetLogger(r).Error("failed to store nonce", "error", err)
+				reject("store_error", "Internal Server Error", http.StatusInternalServerError)
+
+				return
+			}
+
+			if !fresh {
+				reject("replayed", "Request replayed", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
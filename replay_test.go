@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type failingNonceStore struct{}
+
+func (failingNonceStore) Add(context.Context, string, time.Time) (bool, error) {
+	return false, errors.New("store unavailable") //nolint:err113
+}
+
+func TestReplayProtectionMiddleware(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("replay_test")
+	handler := ReplayProtectionMiddleware(metrics, ReplayConfig{Window: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		nonce     string
+		timestamp string
+		expected  int
+	}{
+		{"valid request", "a", now, http.StatusOK},
+		{"replayed nonce", "a", now, http.StatusUnauthorized},
+		{"new nonce", "b", now, http.StatusOK},
+		{"missing nonce", "", now, http.StatusUnauthorized},
+		{"invalid timestamp", "c", "yesterday", http.StatusUnauthorized},
+		{"expired timestamp", "d", old, http.StatusUnauthorized},
+	}
+
+	// The cases depend on each other, so they run sequentially
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/internal", nil)
+		req.Header.Set("X-Nonce", tt.nonce)
+		req.Header.Set("X-Timestamp", tt.timestamp)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, recorder.Code)
+		}
+	}
+
+	rejected := metrics.builtinCounterVec("replay_rejected_total", "", "reason")
+
+	if got := testutil.ToFloat64(rejected.WithLabelValues("replayed")); got != 1 {
+		t.Errorf("expected 1 replayed request, got %v", got)
+	}
+}
+
+func TestReplayProtectionMiddleware_StoreError(t *testing.T) {
+	t.Parallel()
+
+	handler := ReplayProtectionMiddleware(NewMetricsCollector("replay_store_test"), ReplayConfig{Store: failingNonceStore{}})(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/internal", nil)
+	req.Header.Set("X-Nonce", "a")
+	req.Header.Set("X-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", recorder.Code)
+	}
+}
+
+func TestReplayProtectionMiddleware_NilMetrics(t *testing.T) {
+	t.Parallel()
+
+	handler := ReplayProtectionMiddleware(nil, ReplayConfig{})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without metrics, got %d", recorder.Code)
+	}
+}
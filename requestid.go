@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// RequestIDKey is the context key for the request ID
+const RequestIDKey ContextKey = "request_id"
+
+// DefaultRequestIDHeader is the default header of request IDs
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// requestID is a request ID with the header it is propagated in
+type requestID struct {
+	header string
+	id     string
+}
+
+// RequestIDMiddleware propagates the request ID of the header, or generates one with NewID if the header is missing
+// or invalid. The ID is set on the response, stored in the context (see RequestID), attached to the logger returned
+// by GetLogger as "request_id", and sent along by instrumented clients created with NewClient.
+func RequestIDMiddleware(header string) Middleware {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !validRequestID(id) {
+				id = NewID()
+			}
+
+			w.Header().Set(header, id)
+
+			ctx := context.WithValue(r.Context(), RequestIDKey, requestID{header: header, id: id})
+			if logger, ok := ctx.Value(LoggerKey).(*slog.Logger); ok {
+				ctx = ContextWithLogger(ctx, logger.With("request_id", id))
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequestID returns the request ID of the request, or an empty string without RequestIDMiddleware
+func RequestID(r *http.Request) string {
+	return RequestIDFromContext(r.Context())
+}
+
+// RequestIDFromContext returns the request ID of a context, e.g. to pass it to a queue or background task
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(requestID)
+	return id.id
+}
+
+// requestLogger returns the logger with the request ID of the request, if any
+func requestLogger(logger *slog.Logger, r *http.Request) *slog.Logger {
+	if id := RequestID(r); id != "" {
+		return logger.With("request_id", id)
+	}
+
+	return logger
+}
+
+// validRequestID reports whether a request ID of a client can be used: not empty, not too long, and printable ASCII,
+// so it can't forge log lines or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := range len(id) {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
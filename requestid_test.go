@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	config := DefaultConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	svc := New("request_id_test", config)
+
+	var got string
+
+	svc.HandleFunc("/", func(_ http.ResponseWriter, r *http.Request) {
+		got = RequestID(r)
+		GetLogger(r).Info("handled")
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		generate bool
+	}{
+		{name: "propagated", incoming: "abc-123"},
+		{name: "missing", generate: true},
+		{name: "invalid", incoming: "forged\nlog line", generate: true},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1), generate: true},
+	}
+
+	for _, tt := range tests {
+		logs.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.incoming != "" {
+			req.Header.Set("X-Request-ID", tt.incoming)
+		}
+
+		rec := httptest.NewRecorder()
+		svc.handler().ServeHTTP(rec, req)
+
+		if tt.generate && (got == "" || got == tt.incoming) {
+			t.Errorf("%s: expected a generated request ID, got %q", tt.name, got)
+		}
+
+		if !tt.generate && got != tt.incoming {
+			t.Errorf("%s: expected the incoming request ID, got %q", tt.name, got)
+		}
+
+		if rec.Header().Get("X-Request-ID") != got {
+			t.Errorf("%s: expected the request ID on the response, got %q", tt.name, rec.Header().Get("X-Request-ID"))
+		}
+
+		if !strings.Contains(logs.String(), "msg=handled request_id="+got) {
+			t.Errorf("%s: expected the request ID in the handler logs:\n%s", tt.name, logs.String())
+		}
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 1)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Request-ID")
+	}))
+	defer upstream.Close()
+
+	svc := New("request_id_propagation_test", nil)
+	client := svc.NewClient(ClientConfig{})
+
+	svc.HandleFunc("/proxy", func(_ http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Errorf("outbound request failed: %v", err)
+			return
+		}
+		resp.Body.Close()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/proxy", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+
+	svc.handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	if id := <-received; id != "abc-123" {
+		t.Errorf("expected the request ID on the outbound request, got %q", id)
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// responseGuardKey is the context key for the response guard
+const responseGuardKey ContextKey = "response_guard"
+
+// ErrResponseTooLarge is returned by writes beyond the response size limit
+var ErrResponseTooLarge = NewError(CodeResourceExhausted, "response exceeds the size limit")
+
+// ResponseGuardConfig holds configuration for the response size guard (0 disables a limit)
+type ResponseGuardConfig struct {
+	// MaxSize is the maximum response body size in bytes. Larger responses are aborted.
+	MaxSize int64
+	// BufferThreshold is the maximum response body size in bytes that middleware buffers in memory
+	// (response caching and body transformations). Larger responses are streamed to the client unchanged, except
+	// for routes with response transforms, which answer them with 500.
+	BufferThreshold int64
+}
+
+// responseGuard holds the buffer threshold of a request for buffering middleware
+type responseGuard struct {
+	threshold int64
+	actions   *prometheus.CounterVec
+}
+
+// ResponseGuardMiddleware protects the memory of the service from accidentally huge responses, e.g. of an endpoint
+// returning a whole table. Responses beyond the buffer threshold are streamed instead of buffered, and responses
+// beyond the maximum size are aborted. Both log the offending route and are counted in
+// {service_name}_response_guard_total{route,action}.
+func ResponseGuardMiddleware(metrics *MetricsCollector, config ResponseGuardConfig) Middleware {
+	guard := &responseGuard{
+		threshold: config.BufferThreshold,
+		actions: metrics.builtinCounterVec("response_guard_total",
+			"Total number of responses streamed or aborted by the response size guard", "route", "action"),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if guard.threshold > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), responseGuardKey, guard))
+			}
+
+			if config.MaxSize <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limited := &sizeLimitWriter{ResponseWriter: w, limit: config.MaxSize}
+
+			next.ServeHTTP(limited, r)
+
+			if limited.exceeded {
+				guard.actions.WithLabelValues(RoutePattern(r), "aborted").Inc()
+				GetLogger(r).Error("response size limit exceeded, aborting the response",
+					"route", RoutePattern(r), "path", r.URL.Path, "limit", config.MaxSize)
+
+				// Abort the connection, so the client doesn't mistake the truncated response for a complete one
+				panic(http.ErrAbortHandler)
+			}
+		})
+	}
+}
+
+// sizeLimitWriter is a response writer that fails writes beyond the size limit
+type sizeLimitWriter struct {
+	http.ResponseWriter
+
+	limit    int64
+	written  int64
+	exceeded bool
+}
+
+// Write writes the data unless the response would exceed the size limit
+func (s *sizeLimitWriter) Write(data []byte) (int, error) {
+	if s.exceeded || s.written+int64(len(data)) > s.limit {
+		s.exceeded = true
+		return 0, ErrResponseTooLarge
+	}
+
+	n, err := s.ResponseWriter.Write(data)
+	s.written += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (s *sizeLimitWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// newGuardedBufferedResponse creates a buffered response that switches to streaming to w once it exceeds
+// the buffer threshold of the request. Without a response writer (background requests), the rest is discarded.
+func newGuardedBufferedResponse(w http.ResponseWriter, r *http.Request) *bufferedResponse {
+	buffered := newBufferedResponse()
+
+	guard, ok := r.Context().Value(responseGuardKey).(*responseGuard)
+	if !ok {
+		return buffered
+	}
+
+	if w == nil {
+		w = &discardResponse{header: make(http.Header)}
+	}
+
+	buffered.threshold = guard.threshold
+	buffered.target = w
+	buffered.onExceed = func() {
+		guard.actions.WithLabelValues(RoutePattern(r), "streamed").Inc()
+		GetLogger(r).Warn("response exceeds the buffer threshold, streaming it unchanged",
+			"route", RoutePattern(r), "path", r.URL.Path, "threshold", guard.threshold)
+	}
+
+	return buffered
+}
+
+// newStrictBufferedResponse creates a buffered response that fails writes beyond the buffer threshold of the request
+// instead of streaming the response unchanged, for middleware that must not pass the response through, e.g.
+// response transforms redacting fields. The middleware answers rejected responses with an error.
+func newStrictBufferedResponse(r *http.Request) *bufferedResponse {
+	buffered := newBufferedResponse()
+
+	guard, ok := r.Context().Value(responseGuardKey).(*responseGuard)
+	if !ok {
+		return buffered
+	}
+
+	buffered.threshold = guard.threshold
+	buffered.reject = true
+	buffered.onExceed = func() {
+		guard.actions.WithLabelValues(RoutePattern(r), "rejected").Inc()
+		GetLogger(r).Error("response exceeds the buffer threshold and can't be transformed, rejecting it",
+			"route", RoutePattern(r), "path", r.URL.Path, "threshold", guard.threshold)
+	}
+
+	return buffered
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestResponseGuardMaxSize(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.MaxResponseSize = 10
+
+	svc := New("response_guard_test", config)
+	svc.HandleFunc("/small", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	svc.HandleFunc("/large", func(w http.ResponseWriter, _ *http.Request) {
+		for range 10 {
+			if _, err := w.Write([]byte(strings.Repeat("a", 5))); err != nil {
+				return
+			}
+		}
+	})
+
+	server := svc.TestServer()
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/small")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "ok" {
+		t.Errorf("expected small responses to pass, got %q", body)
+	}
+
+	resp, err = http.Get(server.URL + "/large")
+	if err == nil {
+		_, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	if err == nil {
+		t.Error("expected the large response to be aborted")
+	}
+
+	aborted := svc.Metrics.builtinCounterVec("response_guard_total", "", "route", "action").WithLabelValues("/large", "aborted")
+	// The client may retry the aborted idempotent request once
+	if got := testutil.ToFloat64(aborted); got < 1 {
+		t.Errorf("expected the response to be counted as aborted, got %v", got)
+	}
+}
+
+func TestResponseGuardBufferThreshold(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.ResponseBufferThreshold = 10
+
+	svc := New("response_buffer_test", config)
+
+	var calls atomic.Int32
+
+	large := strings.Repeat("a", 100)
+
+	svc.HandleFunc("/export", func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(large[:50]))
+		_, _ = w.Write([]byte(large[50:]))
+	}, WithCache(CacheConfig{TTL: time.Minute}))
+
+	for range 2 {
+		recorder := httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+		if recorder.Body.String() != large {
+			t.Errorf("expected the full response to be streamed, got %d bytes", recorder.Body.Len())
+		}
+
+		if recorder.Header().Get("Content-Type") != "text/plain" {
+			t.Error("expected the headers to be streamed")
+		}
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("expected responses beyond the threshold not to be cached, got %d calls", calls.Load())
+	}
+
+	streamed := svc.Metrics.builtinCounterVec("response_guard_total", "", "route", "action").WithLabelValues("/export", "streamed")
+	if got := testutil.ToFloat64(streamed); got != 2 {
+		t.Errorf("expected 2 streamed responses, got %v", got)
+	}
+}
+
+func TestResponseGuardBufferThreshold_Transform(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.ResponseBufferThreshold = 32
+
+	svc := New("response_buffer_transform_test", config)
+
+	svc.HandleFunc("/users", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"gopher","password":"` + strings.Repeat("x", 64) + `"}`))
+	}, WithTransform(TransformConfig{Response: []BodyTransform{RedactFields("password")}}))
+
+	recorder := httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if recorder.Code != http.StatusInternalServerError || strings.Contains(recorder.Body.String(), "xxx") {
+		t.Errorf("expected the untransformed response to be rejected, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	rejected := svc.Metrics.builtinCounterVec("response_guard_total", "", "route", "action").WithLabelValues("/users", "rejected")
+	if got := testutil.ToFloat64(rejected); got != 1 {
+		t.Errorf("expected 1 rejected response, got %v", got)
+	}
+}
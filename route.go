@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// routeKey is the context key for the matched route
+const routeKey ContextKey = "route"
+
+// RouteOption configures a single route registered with Handle or HandleFunc
+type RouteOption func(*route)
+
+// route holds the per-route configuration collected from RouteOptions
+type route struct {
+	service     *Service
+	pattern     string
+	group       *RouteGroup
+	middlewares []Middleware
+	priority    *Priority
+	timeout     *time.Duration
+	maxBodySize *int64
+}
+
+// use appends a middleware that only applies to this route.
+// Route middleware runs after the service-wide middleware.
+func (rt *route) use(middleware Middleware) {
+	rt.middlewares = append(rt.middlewares, middleware)
+}
+
+// WithMiddleware adds middleware that only applies to a single route
+func WithMiddleware(middlewares ...Middleware) RouteOption {
+	return func(rt *route) {
+		for _, middleware := range middlewares {
+			rt.use(middleware)
+		}
+	}
+}
+
+// PanicHandler handles a panic recovered from a route handler
+type PanicHandler func(w http.ResponseWriter, r *http.Request, recovered any)
+
+// WithPanicHandler handles panics of a single route with a custom handler instead of the global recovery behavior
+func WithPanicHandler(handler PanicHandler) RouteOption {
+	return func(rt *route) {
+		rt.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer func() {
+					recovered := recover()
+					if recovered == nil {
+						return
+					}
+
+					if recovered == http.ErrAbortHandler { //nolint:errorlint,err113
+						panic(recovered)
+					}
+
+					GetLogger(r).Error("panic recovered", "error", recovered, "route", rt.pattern, "path", r.URL.Path, "method", r.Method)
+					handler(w, r, recovered)
+				}()
+
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
+
+// WithPanicFallback serves a fallback response when the handler of a single route panics,
+// e.g. cached data for read endpoints
+func WithPanicFallback(fallback http.Handler) RouteOption {
+	return WithPanicHandler(func(w http.ResponseWriter, r *http.Request, _ any) {
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+// buildRoute applies the service-wide middleware, the middleware of the group (if any), and the route options to
+// a handler. The middleware chain is built on the first request, so middleware added with Use after the route was
+// registered applies as well.
+func (s *Service) buildRoute(pattern string, handler http.Handler, group *RouteGroup, opts ...RouteOption) http.Handler {
+	rt := &route{
+		service: s,
+		pattern: pattern,
+		group:   group,
+	}
+
+	for _, opt := range opts {
+		opt(rt)
+	}
+
+	var (
+		build sync.Once
+		chain http.Handler
+	)
+
+	// Make the route available to all middleware, including the service-wide middleware
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		build.Do(func() {
+			chain = applyMiddleware(handler, slices.Concat(s.middlewares, group.chain(), rt.middlewares)...)
+		})
+
+		chain.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey, rt)))
+	})
+}
+
+// RoutePattern returns the pattern of the route that matched the request, e.g. "GET /users/{id}".
+// Use it instead of r.URL.Path for logs, metrics, and traces, so they agree on a low-cardinality route identity.
+// It returns an empty string for requests that didn't match a route.
+func RoutePattern(r *http.Request) string {
+	if rt := getRoute(r); rt != nil {
+		return rt.pattern
+	}
+
+	return r.Pattern
+}
+
+// getRoute retrieves the matched route from the request context
+func getRoute(r *http.Request) *route {
+	rt, ok := r.Context().Value(routeKey).(*route)
+	if !ok {
+		return nil
+	}
+
+	return rt
+}
+
+// Routes returns the sorted patterns of the routes registered on the main server, including the dynamic routes
+// (see SetRoutes)
+func (s *Service) Routes() []string {
+	routes := slices.Concat(s.routes, s.dynamicRoutes())
+	slices.Sort(routes)
+
+	return routes
+}
+
+// InternalRoutes returns the sorted patterns of the routes registered with Service.Internal
+func (s *Service) InternalRoutes() []string {
+	routes := slices.Clone(s.internalRoutes)
+	slices.Sort(routes)
+
+	return routes
+}
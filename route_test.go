@@ -0,0 +1,113 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	t.Parallel()
+
+	svc := New("route_test", nil)
+
+	svc.HandleFunc("/with", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Route", "with")
+			next.ServeHTTP(w, r)
+		})
+	}))
+
+	svc.HandleFunc("/without", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/with", nil))
+
+	if recorder.Header().Get("X-Route") != "with" {
+		t.Error("expected route middleware to be applied")
+	}
+
+	recorder = httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/without", nil))
+
+	if recorder.Header().Get("X-Route") != "" {
+		t.Error("expected route middleware not to be applied to other routes")
+	}
+}
+
+func TestWithPanicHandler(t *testing.T) {
+	t.Parallel()
+
+	svc := New("route_test", nil)
+
+	var recovered any
+
+	svc.HandleFunc("/custom", func(_ http.ResponseWriter, _ *http.Request) {
+		panic("custom panic")
+	}, WithPanicHandler(func(w http.ResponseWriter, _ *http.Request, value any) {
+		recovered = value
+
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	recorder := httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/custom", nil))
+
+	if recorder.Code != http.StatusTeapot {
+		t.Errorf("expected status 418, got %d", recorder.Code)
+	}
+
+	if recovered != "custom panic" {
+		t.Errorf("expected recovered value 'custom panic', got %v", recovered)
+	}
+}
+
+func TestWithPanicFallback(t *testing.T) {
+	t.Parallel()
+
+	svc := New("route_test", nil)
+
+	svc.HandleFunc("/items", func(_ http.ResponseWriter, _ *http.Request) {
+		panic("database exploded")
+	}, WithPanicFallback(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("cached items"))
+	})))
+
+	recorder := httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", recorder.Code)
+	}
+
+	if !strings.Contains(recorder.Body.String(), "cached items") {
+		t.Errorf("expected fallback body, got %s", recorder.Body.String())
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	t.Parallel()
+
+	svc := New("route_test", nil)
+
+	var pattern string
+
+	svc.HandleFunc("GET /users/{id}", func(_ http.ResponseWriter, r *http.Request) {
+		pattern = RoutePattern(r)
+	})
+
+	svc.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+	if pattern != "GET /users/{id}" {
+		t.Errorf("expected the registered pattern, got %q", pattern)
+	}
+
+	if got := RoutePattern(httptest.NewRequest(http.MethodGet, "/users/123", nil)); got != "" {
+		t.Errorf("expected no pattern for unrouted requests, got %q", got)
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// metricsRequestKey is the context key of the state of a request recorded by the metrics middleware
+const metricsRequestKey ContextKey = "metrics_request"
+
+// metricsRequest is the state of a request recorded by the metrics middleware: the response writer that captures
+// the status and the config that determines the endpoint label
+type metricsRequest struct {
+	writer *responseWriter
+	config MetricsMiddlewareConfig
+}
+
+// routeLabelNames are the labels of route metrics, appended to their own labels. They match the labels of the built-in
+// HTTP metrics, so route metrics can be joined with them.
+var routeLabelNames = []string{"method", "endpoint", "status_code"}
+
+// RegisterRouteHistogram registers a new histogram metric with the route labels method, endpoint, and status_code
+// after its own labels. Observe it with ObserveRouteHistogram, so the route labels can't be mixed up.
+func (mc *MetricsCollector) RegisterRouteHistogram(config MetricConfig) error {
+	config.Labels = slices.Concat(config.Labels, routeLabelNames)
+	return mc.RegisterHistogram(config)
+}
+
+// RegisterRouteSummary registers a new summary metric with the route labels method, endpoint, and status_code
+// after its own labels. Observe it with ObserveRouteSummary, so the route labels can't be mixed up.
+func (mc *MetricsCollector) RegisterRouteSummary(config MetricConfig) error {
+	config.Labels = slices.Concat(config.Labels, routeLabelNames)
+	return mc.RegisterSummary(config)
+}
+
+// ObserveRouteHistogram observes a value in a histogram metric registered with RegisterRouteHistogram. The labels are
+// the own labels of the metric; the route labels are taken from the request.
+func ObserveRouteHistogram(r *http.Request, name string, value float64, labels ...string) error {
+	return ObserveHistogram(r, name, value, slices.Concat(labels, routeLabelValues(r))...)
+}
+
+// ObserveRouteSummary observes a value in a summary metric registered with RegisterRouteSummary. The labels are
+// the own labels of the metric; the route labels are taken from the request.
+func ObserveRouteSummary(r *http.Request, name string, value float64, labels ...string) error {
+	return ObserveSummary(r, name, value, slices.Concat(labels, routeLabelValues(r))...)
+}
+
+// routeLabelValues returns the values of the route labels of a request, with the endpoint label of the built-in HTTP
+// metrics. The status code is the status of the response if it was already written, or an empty string before.
+func routeLabelValues(r *http.Request) []string {
+	state, ok := r.Context().Value(metricsRequestKey).(*metricsRequest)
+	if !ok {
+		return []string{r.Method, routeEndpoint(r), ""}
+	}
+
+	status := ""
+	if state.writer.wroteHeader {
+		status = strconv.Itoa(state.writer.statusCode)
+	}
+
+	return []string{r.Method, state.config.endpoint(r), status}
+}
+
+// routeEndpoint returns the endpoint label of a request: the pattern of the matched route, or "unmatched"
+func routeEndpoint(r *http.Request) string {
+	if pattern := RoutePattern(r); pattern != "" {
+		return pattern
+	}
+
+	return unmatchedEndpoint
+}
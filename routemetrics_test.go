@@ -0,0 +1,58 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveRouteHistogram(t *testing.T) {
+	t.Parallel()
+
+	svc := New("route_metrics_test", nil)
+
+	if err := svc.RegisterRouteHistogram(MetricConfig{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database query duration",
+		Labels:  []string{"query"},
+		Buckets: []float64{1},
+	}); err != nil {
+		t.Fatalf("failed to register histogram: %v", err)
+	}
+
+	svc.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// Before the response is written, the status is unknown
+		if err := ObserveRouteHistogram(r, "db_query_duration_seconds", 0.5, "select_user"); err != nil {
+			t.Errorf("failed to observe: %v", err)
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+
+		if err := ObserveRouteHistogram(r, "db_query_duration_seconds", 0.5, "update_user"); err != nil {
+			t.Errorf("failed to observe: %v", err)
+		}
+	})
+
+	svc.handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	expected := `
+		# HELP route_metrics_test_db_query_duration_seconds Database query duration
+		# TYPE route_metrics_test_db_query_duration_seconds histogram
+		route_metrics_test_db_query_duration_seconds_bucket{endpoint="GET /users/{id}",method="GET",query="select_user",status_code="",le="1"} 1
+		route_metrics_test_db_query_duration_seconds_bucket{endpoint="GET /users/{id}",method="GET",query="select_user",status_code="",le="+Inf"} 1
+		route_metrics_test_db_query_duration_seconds_sum{endpoint="GET /users/{id}",method="GET",query="select_user",status_code=""} 0.5
+		route_metrics_test_db_query_duration_seconds_count{endpoint="GET /users/{id}",method="GET",query="select_user",status_code=""} 1
+		route_metrics_test_db_query_duration_seconds_bucket{endpoint="GET /users/{id}",method="GET",query="update_user",status_code="202",le="1"} 1
+		route_metrics_test_db_query_duration_seconds_bucket{endpoint="GET /users/{id}",method="GET",query="update_user",status_code="202",le="+Inf"} 1
+		route_metrics_test_db_query_duration_seconds_sum{endpoint="GET /users/{id}",method="GET",query="update_user",status_code="202"} 0.5
+		route_metrics_test_db_query_duration_seconds_count{endpoint="GET /users/{id}",method="GET",query="update_user",status_code="202"} 1
+	`
+
+	if err := testutil.GatherAndCompare(svc.Metrics.GetRegistry(), strings.NewReader(expected),
+		"route_metrics_test_db_query_duration_seconds"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// ErrInvalidRoutes is returned by SetRoutes when the new route table can't be built
+var ErrInvalidRoutes = NewError(CodeInvalidArgument, "invalid route table")
+
+// routeTable is a set of routes of the main server that is replaced as a whole (see Service.SetRoutes)
+type routeTable struct {
+	mux    *http.ServeMux
+	routes []string
+}
+
+// SetRoutes atomically replaces the dynamic routes of the main server, e.g. the webhooks of the tenants of a
+// plugin-style application. The build function registers the complete new set of routes on the given group, which
+// can also have its own middleware. Requests in flight finish on the routes they matched, new requests are matched
+// against the new routes. Routes registered with Handle and HandleFunc take precedence over dynamic routes.
+// If the new routes can't be built (e.g. conflicting patterns), the current routes stay in place and an error
+// wrapping ErrInvalidRoutes is returned.
+func (s *Service) SetRoutes(build func(routes *RouteGroup)) error {
+	table := &routeTable{mux: http.NewServeMux()}
+
+	if err := s.buildRouteTable(table, build); err != nil {
+		return err
+	}
+
+	s.routeTable.Store(table)
+	s.Logger.Info("route table replaced", "routes", len(table.routes))
+
+	return nil
+}
+
+// buildRouteTable registers the routes of a table. ServeMux panics on invalid and conflicting patterns, which are
+// turned into an error.
+func (s *Service) buildRouteTable(table *routeTable, build func(routes *RouteGroup)) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidRoutes, recovered)
+		}
+	}()
+
+	build(&RouteGroup{service: s, mux: table.mux, routes: &table.routes})
+
+	return nil
+}
+
+// routeHandler matches requests against the routes of the service, then against the dynamic routes (see SetRoutes),
+// and then against the built-in well-known routes
+func (s *Service) routeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if table := s.routeTable.Load(); table != nil || s.wellKnown != nil {
+			if _, pattern := s.mux.Handler(r); pattern == "" {
+				// Unmatched requests keep the 404 or 405 response of the static routes
+				for _, mux := range []*http.ServeMux{table.serveMux(), s.wellKnown} {
+					if mux == nil {
+						continue
+					}
+
+					if _, pattern := mux.Handler(r); pattern != "" {
+						mux.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+		}
+
+		s.mux.ServeHTTP(w, r)
+	})
+}
+
+// serveMux returns the mux of the table, or nil if no table was set
+func (t *routeTable) serveMux() *http.ServeMux {
+	if t == nil {
+		return nil
+	}
+
+	return t.mux
+}
+
+// dynamicRoutes returns the patterns of the current dynamic routes
+func (s *Service) dynamicRoutes() []string {
+	if table := s.routeTable.Load(); table != nil {
+		return slices.Clone(table.routes)
+	}
+
+	return nil
+}
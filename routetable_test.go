@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestSetRoutes(t *testing.T) {
+	t.Parallel()
+
+	svc := New("routetable_test", nil)
+	svc.HandleFunc("GET /webhooks/static", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := svc.handler()
+
+	request := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+
+		return rec.Code
+	}
+
+	webhook := func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	err := svc.SetRoutes(func(routes *RouteGroup) {
+		routes.HandleFunc("POST /webhooks/acme", webhook)
+		routes.HandleFunc("POST /webhooks/slow", func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusAccepted)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := request("/webhooks/acme"); code != http.StatusAccepted {
+		t.Errorf("expected the dynamic route to be served, got %d", code)
+	}
+
+	if code := request("/webhooks/static"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected the static route to take precedence, got %d", code)
+	}
+
+	inFlight := make(chan int)
+
+	go func() {
+		inFlight <- request("/webhooks/slow")
+	}()
+
+	<-started
+
+	err = svc.SetRoutes(func(routes *RouteGroup) {
+		routes.HandleFunc("POST /webhooks/globex", webhook)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(release)
+
+	if code := <-inFlight; code != http.StatusAccepted {
+		t.Errorf("expected the in-flight request to finish on the old routes, got %d", code)
+	}
+
+	if code := request("/webhooks/acme"); code != http.StatusNotFound {
+		t.Errorf("expected the removed route to be gone, got %d", code)
+	}
+
+	if code := request("/webhooks/globex"); code != http.StatusAccepted {
+		t.Errorf("expected the new route to be served, got %d", code)
+	}
+
+	err = svc.SetRoutes(func(routes *RouteGroup) {
+		routes.HandleFunc("POST /webhooks/initech", webhook)
+		routes.HandleFunc("POST /webhooks/initech", webhook)
+	})
+	if !errors.Is(err, ErrInvalidRoutes) {
+		t.Errorf("expected ErrInvalidRoutes for conflicting routes, got %v", err)
+	}
+
+	if code := request("/webhooks/globex"); code != http.StatusAccepted {
+		t.Errorf("expected the routes to stay in place after a failed replacement, got %d", code)
+	}
+
+	if routes := svc.Routes(); !slices.Equal(routes, []string{"GET /webhooks/static", "POST /webhooks/globex"}) {
+		t.Errorf("unexpected routes %v", routes)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrUnknownRoutingProfile is returned when switching to a routing profile that was not added
+var ErrUnknownRoutingProfile = NewError(CodeNotFound, "unknown routing profile")
+
+// RoutingProfile is a named set of handler bindings and feature toggles.
+// Exactly one profile is active and can be switched atomically at runtime, e.g. for blue/green handler
+// implementations or a quick rollback of a dark launch.
+type RoutingProfile struct {
+	Name string
+	// Handlers binds names to handlers, served with ProfileHandler
+	Handlers map[string]http.Handler
+	// Features are toggles checked with FeatureEnabled
+	Features map[string]bool
+}
+
+// routingProfiles holds the routing profiles of a service
+type routingProfiles struct {
+	metrics *MetricsCollector
+
+	mu       sync.RWMutex
+	profiles map[string]*RoutingProfile
+	active   atomic.Pointer[RoutingProfile]
+}
+
+// newRoutingProfiles creates a new routing profile registry
+func newRoutingProfiles(metrics *MetricsCollector) *routingProfiles {
+	return &routingProfiles{
+		metrics:  metrics,
+		profiles: make(map[string]*RoutingProfile),
+	}
+}
+
+// AddRoutingProfile adds a routing profile. The first added profile is active.
+func (s *Service) AddRoutingProfile(profile RoutingProfile) {
+	p := s.routing
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.profiles[profile.Name] = &profile
+
+	// Replacing the active profile takes effect immediately
+	active := p.active.Load()
+	if active == nil || active.Name == profile.Name {
+		active = &profile
+		p.active.Store(active)
+	}
+
+	p.record(active.Name)
+}
+
+// SwitchRoutingProfile atomically activates a routing profile. Requests already in flight finish
+// with the handler they started with.
+func (s *Service) SwitchRoutingProfile(name string) error {
+	p := s.routing
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	profile, ok := p.profiles[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRoutingProfile, name)
+	}
+
+	previous := p.active.Swap(profile)
+	p.record(name)
+
+	s.Logger.Info("switched routing profile", "from", previous.Name, "to", name)
+
+	return nil
+}
+
+// ActiveRoutingProfile returns the name of the active routing profile, or an empty string if there is none
+func (s *Service) ActiveRoutingProfile() string {
+	if profile := s.routing.active.Load(); profile != nil {
+		return profile.Name
+	}
+
+	return ""
+}
+
+// ProfileHandler returns a handler that serves the binding of the active routing profile.
+// It responds with 404 if the active profile has no handler for the binding.
+func (s *Service) ProfileHandler(binding string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		profile := s.routing.active.Load()
+		if profile == nil || profile.Handlers[binding] == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		profile.Handlers[binding].ServeHTTP(w, r)
+	})
+}
+
+// FeatureEnabled returns true if the feature toggle is enabled in the active routing profile
+func (s *Service) FeatureEnabled(feature string) bool {
+	profile := s.routing.active.Load()
+	return profile != nil && profile.Features[feature]
+}
+
+// record exports the active routing profile in {service_name}_routing_profile_active{profile}.
+// The caller must hold the lock.
+func (p *routingProfiles) record(active string) {
+	gauge := p.metrics.builtinGaugeVec("routing_profile_active", "Active routing profile (1 if active)", "profile")
+
+	for name := range p.profiles {
+		value := 0.0
+		if name == active {
+			value = 1
+		}
+
+		gauge.WithLabelValues(name).Set(value)
+	}
+}
+
+// routingProfileHandler returns the admin HTTP handler that shows (GET) or switches (POST ?profile=name) the routing profile
+func (s *Service) routingProfileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if err := s.SwitchRoutingProfile(r.URL.Query().Get("profile")); err != nil {
+				http.Error(w, err.Error(), HTTPStatus(err))
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		s.routing.mu.RLock()
+
+		names := make([]string, 0, len(s.routing.profiles))
+		for name := range s.routing.profiles {
+			names = append(names, name)
+		}
+
+		s.routing.mu.RUnlock()
+
+		sort.Strings(names)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"active": s.ActiveRoutingProfile(), "profiles": names})
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRoutingProfiles(t *testing.T) {
+	t.Parallel()
+
+	svc := New("routing_test", nil)
+
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(body))
+		})
+	}
+
+	svc.AddRoutingProfile(RoutingProfile{
+		Name:     "blue",
+		Handlers: map[string]http.Handler{"search": respond("v1")},
+	})
+	svc.AddRoutingProfile(RoutingProfile{
+		Name:     "green",
+		Handlers: map[string]http.Handler{"search": respond("v2")},
+		Features: map[string]bool{"new-checkout": true},
+	})
+
+	handler := svc.ProfileHandler("search")
+
+	serve := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search", nil))
+
+		return rec.Body.String()
+	}
+
+	if svc.ActiveRoutingProfile() != "blue" || serve() != "v1" || svc.FeatureEnabled("new-checkout") {
+		t.Fatal("expected the first profile to be active")
+	}
+
+	if err := svc.SwitchRoutingProfile("green"); err != nil {
+		t.Fatalf("failed to switch routing profile: %v", err)
+	}
+
+	if svc.ActiveRoutingProfile() != "green" || serve() != "v2" || !svc.FeatureEnabled("new-checkout") {
+		t.Error("expected the green profile to be active after switching")
+	}
+
+	if err := svc.SwitchRoutingProfile("red"); !errors.Is(err, ErrUnknownRoutingProfile) {
+		t.Errorf("expected ErrUnknownRoutingProfile, got %v", err)
+	}
+
+	active := svc.Metrics.builtinGaugeVec("routing_profile_active", "", "profile")
+	if testutil.ToFloat64(active.WithLabelValues("green")) != 1 || testutil.ToFloat64(active.WithLabelValues("blue")) != 0 {
+		t.Error("expected the active profile to be exported")
+	}
+
+	rec := httptest.NewRecorder()
+	svc.ProfileHandler("unknown").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unbound handler, got %d", rec.Code)
+	}
+}
+
+func TestRoutingProfileHandler(t *testing.T) {
+	t.Parallel()
+
+	svc := New("routing_admin_test", nil)
+	svc.AddRoutingProfile(RoutingProfile{Name: "blue"})
+	svc.AddRoutingProfile(RoutingProfile{Name: "green"})
+
+	handler := svc.routingProfileHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		active string
+	}{
+		{name: "show", method: http.MethodGet, target: "/admin/routing-profile", status: http.StatusOK, active: "blue"},
+		{name: "switch", method: http.MethodPost, target: "/admin/routing-profile?profile=green", status: http.StatusOK, active: "green"},
+		{name: "unknown", method: http.MethodPost, target: "/admin/routing-profile?profile=red", status: http.StatusNotFound},
+		{name: "method", method: http.MethodDelete, target: "/admin/routing-profile", status: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rec.Code)
+			continue
+		}
+
+		if tt.active == "" {
+			continue
+		}
+
+		var body struct {
+			Active   string   `json:"active"`
+			Profiles []string `json:"profiles"`
+		}
+
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.name, err)
+		}
+
+		if body.Active != tt.active || len(body.Profiles) != 2 {
+			t.Errorf("%s: expected active profile %s of 2, got %+v", tt.name, tt.active, body)
+		}
+	}
+}
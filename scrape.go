@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Health states exported in {service_name}_health_status{status}
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+	healthStatusDraining    = "draining"
+	healthStatusStopping    = "stopping"
+)
+
+// scrapeHandler wraps the metrics handler to export the health status on each scrape
+// and to fail scrapes once the service is stopping, if configured
+func (s *Service) scrapeHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Config.MetricsUnavailableOnStop && s.stopping.Load() {
+			w.Header().Set("Connection", "close")
+			http.Error(w, "Stopping", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if s.Config.MetricsHealthStatus {
+			s.recordHealthStatus(r.Context())
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordHealthStatus sets the current health status to 1 and all other states to 0,
+// so scrapers can distinguish a failing service from a draining one
+func (s *Service) recordHealthStatus(ctx context.Context) {
+	status := healthStatusOK
+
+	switch {
+	case s.stopping.Load():
+		status = healthStatusStopping
+	case s.IsDraining():
+		status = healthStatusDraining
+	case s.HealthChecker != nil:
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		if !s.HealthChecker.IsHealthy(ctx) {
+			status = healthStatusUnavailable
+		}
+	}
+
+	gauge := s.Metrics.builtinGaugeVec("health_status", "Current health status of the service (1 for the current status)", "status")
+
+	for _, state := range []string{healthStatusOK, healthStatusUnavailable, healthStatusDraining, healthStatusStopping} {
+		value := 0.0
+		if state == status {
+			value = 1
+		}
+
+		gauge.WithLabelValues(state).Set(value)
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestScrapeHandler(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.MetricsHealthStatus = true
+	config.MetricsUnavailableOnStop = true
+
+	svc := New("scrape_test", config)
+	handler := svc.scrapeHandler(promhttp.HandlerFor(svc.Metrics.GetRegistry(), promhttp.HandlerOpts{}))
+
+	scrape := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		return rec
+	}
+
+	if body := scrape().Body.String(); !strings.Contains(body, `scrape_test_health_status{status="ok"} 1`) {
+		t.Errorf("expected healthy status in metrics output, got:\n%s", body)
+	}
+
+	svc.draining.Store(true)
+
+	rec := scrape()
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `scrape_test_health_status{status="draining"} 1`) {
+		t.Errorf("expected draining status while draining, got %d", rec.Code)
+	}
+
+	svc.stopping.Store(true)
+
+	if rec := scrape(); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while stopping, got %d", rec.Code)
+	}
+}
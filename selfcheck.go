@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// ErrMetricsSelfCheck is returned when the metrics self-check finds problems
+var ErrMetricsSelfCheck = NewError(CodeFailedPrecondition, "metrics self-check failed")
+
+var (
+	// metricNamePattern matches metric names every Prometheus version and exposition format accepts
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	// labelNamePattern matches label names every Prometheus version and exposition format accepts
+	labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// SelfCheck gathers the registry once and validates metric and label names, duplicate help strings
+// (usually a copy and paste mistake), and the number of series per metric (0 disables the cardinality check).
+// Metrics without series are only checked by name.
+func (mc *MetricsCollector) SelfCheck(maxSeries int) error {
+	families, err := mc.registry.Gather()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMetricsSelfCheck, err)
+	}
+
+	var problems []error
+
+	// Names of registered metrics, including the ones without series yet
+	names := make(map[string]bool)
+
+	mc.mu.RLock()
+
+	for _, registered := range []map[string]bool{
+		keysOf(mc.counters), keysOf(mc.gauges), keysOf(mc.histograms), keysOf(mc.summaries), keysOf(mc.builtins),
+	} {
+		for name := range registered {
+			names[name] = true
+		}
+	}
+
+	mc.mu.RUnlock()
+
+	helps := make(map[string][]string)
+
+	for _, family := range families {
+		name := family.GetName()
+		names[name] = true
+		helps[family.GetHelp()] = append(helps[family.GetHelp()], name)
+
+		if maxSeries > 0 && len(family.GetMetric()) > maxSeries {
+			problems = append(problems, fmt.Errorf("metric %s has %d series, more than %d", name, len(family.GetMetric()), maxSeries))
+		}
+
+		labels := make(map[string]bool)
+
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = true
+			}
+		}
+
+		for label := range labels {
+			if !labelNamePattern.MatchString(label) {
+				problems = append(problems, fmt.Errorf("metric %s has invalid label name %q", name, label))
+			}
+		}
+	}
+
+	for name := range names {
+		if !metricNamePattern.MatchString(name) {
+			problems = append(problems, fmt.Errorf("invalid metric name %q", name))
+		}
+	}
+
+	for help, metrics := range helps {
+		if len(metrics) > 1 {
+			sort.Strings(metrics)
+			problems = append(problems, fmt.Errorf("metrics %v share the help string %q", metrics, help))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	// Sort the problems, so the output is stable
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Error() < problems[j].Error()
+	})
+
+	return fmt.Errorf("%w:\n%w", ErrMetricsSelfCheck, errors.Join(problems...))
+}
+
+// keysOf returns the keys of a metric map as a set
+func keysOf[T any](metrics map[string]T) map[string]bool {
+	keys := make(map[string]bool, len(metrics))
+	for name := range metrics {
+		keys[name] = true
+	}
+
+	return keys
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMetricsSelfCheck(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+
+		metrics := NewMetricsCollector("selfcheck")
+		if err := metrics.RegisterCounter(MetricConfig{Name: "orders_total", Help: "Total orders", Labels: []string{"status"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		_ = metrics.IncCounter("orders_total", "paid")
+
+		if err := metrics.SelfCheck(10); err != nil {
+			t.Errorf("expected no problems, got %v", err)
+		}
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		t.Parallel()
+
+		metrics := NewMetricsCollectorWithPolicy("self-check", MetricNameKeep)
+
+		for _, name := range []string{"a_total", "b_total"} {
+			if err := metrics.RegisterCounter(MetricConfig{Name: name, Help: "Total", Labels: []string{"id"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i := range 3 {
+			_ = metrics.IncCounter("a_total", strconv.Itoa(i))
+		}
+
+		_ = metrics.IncCounter("b_total", "1")
+
+		err := metrics.SelfCheck(2)
+		if !errors.Is(err, ErrMetricsSelfCheck) {
+			t.Fatalf("expected ErrMetricsSelfCheck, got %v", err)
+		}
+
+		for _, want := range []string{
+			`invalid metric name "self-check_a_total"`,
+			"self-check_a_total has 3 series, more than 2",
+			`share the help string "Total"`,
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in:\n%v", want, err)
+			}
+		}
+	})
+}
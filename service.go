@@ -1,12 +1,16 @@
 package service
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/hellofresh/health-go/v5"
@@ -14,16 +18,48 @@ import (
 
 // Service represents the main service instance
 type Service struct {
-	Name          string
-	Config        *Config
-	Logger        *slog.Logger
-	Metrics       *MetricsCollector
-	HealthChecker *HealthChecker
+	Name               string
+	Config             *Config
+	Logger             *slog.Logger
+	Metrics            *MetricsCollector
+	HealthChecker      *HealthChecker
+	LoadShedder        *LoadShedder
+	ConcurrencyLimiter *ConcurrencyLimiter
+	TokenSource        *TokenSource
+	Usage              *UsageMeter
+	Events             *EventPipeline
 
 	server        *http.Server
 	metricsServer *http.Server
 	mux           *http.ServeMux
+	routeTable    atomic.Pointer[routeTable]
+	wellKnown     *http.ServeMux
 	middlewares   []Middleware
+	routes        []string
+	components    []Component
+	notifications *notifications
+	ctx           context.Context //nolint:containedctx
+	cancel        context.CancelFunc
+	slos          *sloTracker
+	routing       *routingProfiles
+	settings      *settings
+	draining      atomic.Bool
+	stopping      atomic.Bool
+
+	internal         *RouteGroup
+	internalRoutes   []string
+	previousShutdown *ShutdownState
+	shutdownReason   string
+	shutdownSignal   string
+	certificates     []tls.Certificate
+	sniHook          SNIHook
+	beforeRouting    []Middleware
+	plugins          []string
+	jobs             jobs
+	jobState         JobStateStore
+	workers          workers
+	backlogs         backlogs
+	dependencies     dependencies
 }
 
 // New creates a new service instance
@@ -32,13 +68,28 @@ func New(name string, config *Config) *Service {
 		config = DefaultConfig()
 	}
 
+	// Dev mode only replaces the default logger, and leaves the config of the caller unchanged
+	logger := config.Logger
+	if config.DevMode && (logger == nil || logger == config.defaultLogger) {
+		logger = slog.New(newPrettyHandler(os.Stdout, slog.LevelDebug))
+	}
+
 	// Create metrics collector
-	metrics := NewMetricsCollector(name)
+	metrics := NewMetricsCollectorWithPolicy(name, config.MetricsNamePolicy)
+	metrics.logger = logger
+	metrics.SetStrict(config.MetricsStrict)
+	metrics.SetDefaultLabels(config.MetricsDefaultLabels)
+
+	// Local services are rarely scraped by classic scrapers, so the warning would only be noise in dev mode
+	if !config.DevMode && !metricNamePattern.MatchString(metrics.prefix) {
+		logger.Warn("service name is not a valid metric prefix, use METRICS_NAME_POLICY=underscore for classic scrapers",
+			"prefix", metrics.prefix)
+	}
 
 	// Create health checker
 	healthChecker, err := NewHealthChecker(name, config.Version)
 	if err != nil {
-		config.Logger.Error("failed to create health checker", "error", err)
+		logger.Error("failed to create health checker", "error", err)
 		// Continue without health checker - it's not critical for basic operation
 		healthChecker = nil
 	}
@@ -46,18 +97,166 @@ func New(name string, config *Config) *Service {
 	svc := &Service{
 		Name:          name,
 		Config:        config,
-		Logger:        config.Logger,
+		Logger:        logger,
 		Metrics:       metrics,
 		HealthChecker: healthChecker,
 		mux:           http.NewServeMux(),
+		slos:          newSLOTracker(metrics),
+		routing:       newRoutingProfiles(metrics),
+		settings:      newSettings(metrics, logger),
+		notifications: newNotifications(),
+	}
+
+	// The ID format applies process-wide, so IDs of clients and tasks outside of the service match
+	if config.IDFormat != "" && config.IDFormat != defaultIDGenerator.Load().Format() {
+		if generator, err := NewIDGenerator(config.IDFormat); err != nil {
+			logger.Error("failed to create ID generator, using the default format", "error", err)
+		} else {
+			SetIDGenerator(generator)
+		}
+	}
+
+	// Background tasks resolve the provided dependencies from the lifecycle context (see ResolveContext)
+	svc.ctx, svc.cancel = context.WithCancel(context.WithValue(
+		ContextWithLogger(context.Background(), logger), DependenciesKey, &svc.dependencies))
+
+	svc.loadPreviousShutdown()
+
+	svc.jobState = config.JobStateStore
+	if svc.jobState == nil && config.JobStateFile != "" {
+		svc.jobState = NewFileJobStateStore(config.JobStateFile)
+	}
+
+	if config.NotifyWebhookURL != "" {
+		svc.AddNotifier(&WebhookNotifier{URL: config.NotifyWebhookURL})
+	}
+
+	if healthChecker != nil {
+		healthChecker.onUnhealthy = svc.notifyUnhealthy
+		healthChecker.SetTimeout(config.HealthTimeout)
 	}
 
 	// Add default middleware (order matters: metrics should be first to capture all requests)
-	svc.middlewares = []Middleware{
-		MetricsMiddleware(metrics),
-		LoggerMiddleware(config.Logger),
-		RecoveryMiddleware(config.Logger),
-		RequestLoggingMiddleware(config.Logger),
+	// Recording of HTTP metrics and request logging can be disabled at runtime (see ToggleMiddleware)
+	metricsEnabled := svc.middlewareSetting("metrics")
+
+	svc.middlewares = []Middleware{MetricsMiddlewareWithConfig(metrics, MetricsMiddlewareConfig{
+		RawPath:       config.MetricsRawPath,
+		EndpointLabel: config.MetricsEndpointLabel,
+		Skip:          func(*http.Request) bool { return !metricsEnabled.Bool() },
+	})}
+
+	trustedProxies := svc.parseNetworks("trusted proxy network", config.TrustedProxies)
+	priority := PriorityConfig{Header: config.PriorityHeader, TrustedProxies: trustedProxies}
+
+	// Traffic control runs right after metrics, so rejected requests are still counted
+	if config.LoadShedding {
+		svc.LoadShedder = NewLoadShedder(config.LoadSheddingTarget, config.LoadSheddingInterval, metrics)
+		svc.LoadShedder.SetTrustedProxies(trustedProxies)
+		svc.middlewares = append(svc.middlewares, LoadSheddingMiddleware(svc.LoadShedder))
+	}
+
+	if config.MaxConcurrentRequests > 0 {
+		svc.ConcurrencyLimiter = NewConcurrencyLimiter(config.MaxConcurrentRequests, metrics)
+		svc.middlewares = append(svc.middlewares, ConcurrencyLimitMiddleware(svc.ConcurrencyLimiter, priority))
+	}
+
+	// Low-priority requests are shed while a worker backlog is exceeded (see Service.Backlog)
+	svc.middlewares = append(svc.middlewares, svc.backlogSheddingMiddleware(priority))
+
+	// Requests rejected by traffic control are not accounted
+	if config.UsageAccounting {
+		usage := UsageConfig{Sinks: svc.usageSinks()}
+
+		if config.UsageTenantHeader != "" {
+			usage.Tenant = HeaderTenantExtractor(config.UsageTenantHeader)
+		}
+
+		usage.MaxKeys = config.UsageMaxKeys
+
+		svc.Usage = NewUsageMeter(metrics, usage)
+		svc.middlewares = append(svc.middlewares, UsageMiddleware(svc.Usage))
+	}
+
+	svc.middlewares = append(svc.middlewares,
+		LoggerMiddleware(logger),
+	)
+
+	if config.RequestIDHeader != "" {
+		svc.middlewares = append(svc.middlewares, RequestIDMiddleware(config.RequestIDHeader))
+	}
+
+	if config.DebugHeader != "" || config.DebugSampledTraces {
+		svc.middlewares = append(svc.middlewares, DebugRequestMiddleware(DebugRequestConfig{
+			Header:        config.DebugHeader,
+			Token:         config.DebugToken,
+			SampledTraces: config.DebugSampledTraces,
+		}))
+	}
+
+	// Dev mode shows the panic and its stack in the response, production keeps the opaque 500
+	panicRenderer := config.PanicRenderer
+	if panicRenderer == nil && config.DevMode {
+		panicRenderer = DevPanicRenderer
+	}
+
+	svc.middlewares = append(svc.middlewares, errorResponsesMiddleware(metrics, config.ErrorHandler))
+	svc.middlewares = append(svc.middlewares, recoveryMiddleware(logger, panicRenderer, svc.recordPanic))
+
+	// Routes and groups can set their own deadline, so the middleware is installed without REQUEST_TIMEOUT as well
+	svc.middlewares = append(svc.middlewares, TimeoutMiddleware(metrics, TimeoutConfig{Timeout: config.RequestTimeout}))
+
+	if config.AccessLog {
+		svc.middlewares = append(svc.middlewares, svc.ToggleMiddleware("access_log", AccessLogMiddleware(logger,
+			AccessLogConfig{
+				SampleRate: config.AccessLogSampleRate,
+				SkipPaths:  config.AccessLogSkipPaths,
+			})))
+	}
+
+	if sink := svc.eventSink(); sink != nil {
+		svc.Events = NewEventPipeline(metrics, EventPipelineConfig{Sink: sink, BufferSize: config.RequestEventsBuffer})
+		svc.middlewares = append(svc.middlewares, EventMiddleware(svc.Events))
+
+		// Stopped after the servers, so the events of the last requests are written
+		svc.AddComponent(Component{Name: "request_events", Stop: svc.Events.Close})
+	}
+
+	if config.MaxResponseSize > 0 || config.ResponseBufferThreshold > 0 {
+		svc.middlewares = append(svc.middlewares, ResponseGuardMiddleware(metrics, ResponseGuardConfig{
+			MaxSize:         config.MaxResponseSize,
+			BufferThreshold: config.ResponseBufferThreshold,
+		}))
+	}
+
+	// Routes can set their own limit, so the middleware is installed without MAX_BODY_SIZE as well
+	svc.middlewares = append(svc.middlewares, MaxBodySizeMiddleware(metrics, MaxBodySizeConfig{Limit: config.MaxBodySize}))
+
+	if config.MinBodyRate > 0 {
+		svc.middlewares = append(svc.middlewares, MinTransferRateMiddleware(metrics, MinTransferRateConfig{
+			Rate:  config.MinBodyRate,
+			Grace: config.MinBodyRateGrace,
+		}))
+	}
+
+	if config.HSTSMaxAge > 0 {
+		svc.middlewares = append(svc.middlewares, HSTSMiddleware(int(config.HSTSMaxAge.Seconds())))
+	}
+
+	if len(config.PropagatedHeaders) > 0 {
+		svc.middlewares = append(svc.middlewares, PropagationMiddleware(config.PropagatedHeaders))
+	}
+
+	if config.OAuthClientID != "" {
+		svc.TokenSource = NewTokenSource(metrics, TokenSourceConfig{
+			Issuer:       config.OAuthIssuer,
+			TokenURL:     config.OAuthTokenURL,
+			ClientID:     config.OAuthClientID,
+			ClientSecret: config.OAuthClientSecret,
+			Scopes:       config.OAuthScopes,
+		})
+
+		_, _ = svc.RegisterHealthCheck(svc.TokenSource.HealthCheck())
 	}
 
 	// Add health checker middleware if available
@@ -65,39 +264,87 @@ func New(name string, config *Config) *Service {
 		svc.middlewares = append(svc.middlewares, HealthCheckerMiddleware(healthChecker))
 	}
 
+	svc.middlewares = append(svc.middlewares, dependenciesMiddleware(&svc.dependencies))
+
+	// Strip spoofed headers before any middleware or route reads them
+	if config.SanitizeHeaders {
+		svc.UseBeforeRouting(HeaderSanitizationMiddleware(metrics, HeaderSanitizationConfig{
+			TrustedProxies:  trustedProxies,
+			InternalHeaders: config.InternalHeaders,
+			RequestIDHeader: config.RequestIDHeader,
+		}))
+	}
+
+	svc.internal = svc.newInternalGroup()
+
+	if config.WellKnownHandlers {
+		svc.wellKnown = svc.newWellKnownMux()
+	}
+
 	return svc
 }
 
+// Context returns the lifecycle context of the service. It carries the service logger (see LoggerFromContext)
+// and is canceled when the graceful shutdown starts.
+func (s *Service) Context() context.Context {
+	return s.ctx
+}
+
+// subsystemContext returns the lifecycle context with a logger annotated with the subsystem name
+func (s *Service) subsystemContext(subsystem string) context.Context {
+	return ContextWithLogger(s.ctx, s.Logger.With("subsystem", subsystem))
+}
+
 // HandleFunc registers a handler function for the given pattern
-func (s *Service) HandleFunc(pattern string, handler http.HandlerFunc) {
+func (s *Service) HandleFunc(pattern string, handler http.HandlerFunc, opts ...RouteOption) {
 	// Apply middleware to the handler
-	wrappedHandler := applyMiddleware(handler, s.middlewares...)
+	wrappedHandler := s.buildRoute(pattern, handler, nil, opts...)
 	s.mux.Handle(pattern, wrappedHandler)
+	s.routes = append(s.routes, pattern)
 }
 
 // Handle registers a handler for the given pattern
-func (s *Service) Handle(pattern string, handler http.Handler) {
+func (s *Service) Handle(pattern string, handler http.Handler, opts ...RouteOption) {
 	// Apply middleware to the handler
-	wrappedHandler := applyMiddleware(handler, s.middlewares...)
+	wrappedHandler := s.buildRoute(pattern, handler, nil, opts...)
 	s.mux.Handle(pattern, wrappedHandler)
+	s.routes = append(s.routes, pattern)
 }
 
 // TestServer returns a httptest.Server with the service's mux
 func (s *Service) TestServer() *httptest.Server {
-	return httptest.NewServer(s.mux)
+	return httptest.NewServer(s.handler())
 }
 
-// Use adds middleware to the service
+// Use adds middleware to all routes of the service, including routes registered before. Add middleware before Start.
 func (s *Service) Use(middleware Middleware) {
 	s.middlewares = append(s.middlewares, middleware)
 }
 
+// UseBeforeRouting adds middleware that runs before requests are matched against the routes,
+// e.g. to redirect or rewrite paths. The request route (see RoutePattern) is not known yet.
+func (s *Service) UseBeforeRouting(middleware Middleware) {
+	s.beforeRouting = append(s.beforeRouting, middleware)
+}
+
+// handler returns the handler of the main server
+func (s *Service) handler() http.Handler {
+	return applyMiddleware(s.routeHandler(), s.beforeRouting...)
+}
+
 // Start starts the service with graceful shutdown handling
 func (s *Service) Start() error {
+	if err := s.prepare(); err != nil {
+		return err
+	}
+
 	// Create a channel to receive OS signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	// Mark the service as running, so a crash can be detected after a restart
+	s.writeShutdownState(s.newShutdownState(ShutdownReasonRunning))
+
 	// Start the servers in goroutines
 	serverErrors := make(chan error, 2)
 
@@ -113,16 +360,17 @@ func (s *Service) Start() error {
 	// Start main HTTP server
 	go func() {
 		s.server = &http.Server{
-			Addr:         s.Config.Addr,
-			Handler:      s.mux,
-			ReadTimeout:  s.Config.ReadTimeout,
-			WriteTimeout: s.Config.WriteTimeout,
-			IdleTimeout:  s.Config.IdleTimeout,
+			Addr:              s.Config.Addr,
+			Handler:           s.handler(),
+			ReadTimeout:       s.Config.ReadTimeout,
+			ReadHeaderTimeout: s.Config.ReadHeaderTimeout,
+			WriteTimeout:      s.Config.WriteTimeout,
+			IdleTimeout:       s.Config.IdleTimeout,
 		}
 
-		s.Logger.Info("starting service", "name", s.Name, "addr", s.Config.Addr)
+		s.logStartup()
 
-		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := s.listenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.Logger.Error("server error", "error", err)
 
 			serverErrors <- err
@@ -131,10 +379,16 @@ func (s *Service) Start() error {
 
 	// Wait for either a signal or a server error
 	select {
-	case <-quit:
-		s.Logger.Info("received shutdown signal")
+	case sig := <-quit:
+		s.Logger.Info("received shutdown signal", "signal", sig.String())
+		s.shutdownReason, s.shutdownSignal = ShutdownReasonSignal, sig.String()
 	case err := <-serverErrors:
 		s.Logger.Error("server error, shutting down", "error", err)
+
+		state := s.newShutdownState(ShutdownReasonServerError)
+		state.Error = err.Error()
+		s.recordShutdown(state)
+
 		return err
 	}
 
@@ -142,17 +396,123 @@ func (s *Service) Start() error {
 	return s.gracefulShutdown()
 }
 
-// RegisterHealthCheck adds a health check to the service
-func (s *Service) RegisterHealthCheck(config health.Config) error {
-	if s.HealthChecker != nil {
-		return s.HealthChecker.Register(config)
+// prepare validates the configuration and starts the background work of the service before it serves requests
+func (s *Service) prepare() error {
+	// Fail fast on metrics that would cause problems at scrape time
+	if s.Config.MetricsSelfCheck {
+		if err := s.Metrics.SelfCheck(s.Config.MetricsMaxSeries); err != nil {
+			s.Logger.Error("metrics self-check failed", "error", err)
+			return err
+		}
+	}
+
+	// Fail fast on plugins that are unknown or fail to install
+	if err := s.Install(s.Config.Plugins...); err != nil {
+		s.Logger.Error("failed to install plugins", "error", err)
+		return err
 	}
 
-	s.Logger.Warn("health checker not available, skipping health check registration", "name", config.Name)
+	// Fail fast on invalid health checks declared in the config
+	if err := s.registerConfiguredHealthChecks(); err != nil {
+		s.Logger.Error("failed to register configured health checks", "error", err)
+		return err
+	}
+
+	// Fail fast on an invalid settings file, later reloads on SIGHUP only log errors
+	if err := s.ReloadSettings(); err != nil {
+		s.Logger.Error("failed to load settings", "error", err)
+		return err
+	}
+
+	s.watchSettingsReload()
+	s.startUsageFlush()
+
+	// Don't serve before the mesh proxy can route the traffic of the service
+	if err := s.waitForSidecar(s.ctx); err != nil {
+		s.Logger.Error("sidecar not ready", "error", err)
+		return err
+	}
+
+	s.startWorkers()
 
 	return nil
 }
 
+// StartTest starts the service like Start, but serves the main and the metrics server on ephemeral loopback
+// ports and doesn't handle signals, e.g. for integration tests (see package servicetest). Stop shuts the servers
+// down gracefully.
+func (s *Service) StartTest() (main, internal *httptest.Server, err error) {
+	if err := s.prepare(); err != nil {
+		return nil, nil, err
+	}
+
+	main = httptest.NewServer(s.handler())
+	internal = httptest.NewServer(s.metricsHandler())
+
+	s.server, s.metricsServer = main.Config, internal.Config
+
+	return main, internal, nil
+}
+
+// listenAndServe listens on the configured address and serves the main server with TLS and the connection limits
+func (s *Service) listenAndServe() error {
+	addr := s.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	var tlsConfig *tls.Config
+
+	if s.tlsEnabled() {
+		config, err := s.newTLSConfig()
+		if err != nil {
+			return err
+		}
+
+		tlsConfig = config
+		s.server.TLSConfig = config
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	limits := ConnectionLimits{
+		MaxConnections:      s.Config.MaxConnections,
+		MaxConnectionsPerIP: s.Config.MaxConnectionsPerIP,
+		AcceptRate:          s.Config.AcceptRate,
+	}
+	if limits.enabled() {
+		listener = LimitListener(listener, s.Metrics, limits)
+	}
+
+	// The TLS listener uses the config directly (ServeTLS would clone it), so rotated session ticket keys take effect
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	return s.server.Serve(listener) //nolint:wrapcheck
+}
+
+// RegisterHealthCheck adds a health check to the service.
+// The returned handle removes, disables, or enables the check at runtime.
+func (s *Service) RegisterHealthCheck(config health.Config) (*HealthCheckHandle, error) {
+	return s.AddHealthCheck(HealthCheckFromConfig(config))
+}
+
+// AddHealthCheck adds a native health check to the service, supporting intervals, failure thresholds,
+// and degraded checks. The returned handle removes, disables, or enables the check at runtime.
+func (s *Service) AddHealthCheck(check HealthCheck) (*HealthCheckHandle, error) {
+	if s.HealthChecker != nil {
+		return s.HealthChecker.RegisterCheck(check)
+	}
+
+	s.Logger.Warn("health checker not available, skipping health check registration", "name", check.Name)
+
+	return &HealthCheckHandle{name: check.Name}, nil
+}
+
 // RegisterCounter registers a new counter metric
 func (s *Service) RegisterCounter(config MetricConfig) error {
 	return s.Metrics.RegisterCounter(config)
@@ -173,6 +533,16 @@ func (s *Service) RegisterSummary(config MetricConfig) error {
 	return s.Metrics.RegisterSummary(config)
 }
 
+// RegisterRouteHistogram registers a new histogram metric with route labels (see ObserveRouteHistogram)
+func (s *Service) RegisterRouteHistogram(config MetricConfig) error {
+	return s.Metrics.RegisterRouteHistogram(config)
+}
+
+// RegisterRouteSummary registers a new summary metric with route labels (see ObserveRouteSummary)
+func (s *Service) RegisterRouteSummary(config MetricConfig) error {
+	return s.Metrics.RegisterRouteSummary(config)
+}
+
 // GetHealthChecker returns the health checker instance
 func (s *Service) GetHealthChecker() *HealthChecker {
 	return s.HealthChecker
@@ -1,11 +1,17 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNew(t *testing.T) {
@@ -153,6 +159,32 @@ func TestGetLogger(t *testing.T) {
 	}
 }
 
+func TestLoggerFromContext(t *testing.T) {
+	t.Parallel()
+
+	svc := New("test", nil)
+
+	if LoggerFromContext(svc.Context()) != svc.Logger {
+		t.Error("expected the service context to carry the service logger")
+	}
+
+	if LoggerFromContext(context.Background()) != slog.Default() {
+		t.Error("expected the default logger for contexts without a logger")
+	}
+
+	if LoggerFromContext(svc.subsystemContext("worker")) == svc.Logger {
+		t.Error("expected subsystem contexts to carry an annotated logger")
+	}
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+
+	if svc.Context().Err() == nil {
+		t.Error("expected the service context to be canceled on shutdown")
+	}
+}
+
 func TestGetMetrics(t *testing.T) {
 	t.Parallel()
 
@@ -202,6 +234,54 @@ func TestRecoveryMiddleware(t *testing.T) {
 	}
 }
 
+func TestRecoveryMiddlewareDevMode(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.DevMode = true
+
+	svc := New("recovery_dev_test", config)
+	svc.HandleFunc("GET /orders", func(http.ResponseWriter, *http.Request) {
+		panic("out of orders")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("Authorization", "Bearer secret")
+
+	recorder := httptest.NewRecorder()
+	svc.handler().ServeHTTP(recorder, req)
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusInternalServerError || !strings.Contains(body, "panic: out of orders") ||
+		!strings.Contains(body, "TestRecoveryMiddlewareDevMode") {
+		t.Errorf("expected the panic and its stack, got %d: %s", recorder.Code, body)
+	}
+
+	if strings.Contains(body, "secret") {
+		t.Error("expected credentials to be redacted")
+	}
+}
+
+func TestRecoveryMiddlewareWithConfig(t *testing.T) {
+	t.Parallel()
+
+	handler := RecoveryMiddlewareWithConfig(slog.New(slog.DiscardHandler),
+		func(w http.ResponseWriter, _ *http.Request, info PanicInfo) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprint(w, info.Recovered)
+		})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("custom")
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Body.String() != "custom" {
+		t.Errorf("expected the custom renderer, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
 func TestMetricsMiddleware(t *testing.T) {
 	t.Parallel()
 
@@ -230,7 +310,7 @@ func TestShutdownHooks(t *testing.T) {
 
 	hookCalled := false
 
-	svc.AddShutdownHook(func() error {
+	svc.AddShutdownHook(func(context.Context) error {
 		hookCalled = true
 		return nil
 	})
@@ -250,6 +330,115 @@ func TestShutdownHooks(t *testing.T) {
 	}
 }
 
+func TestShutdownHookTimeout(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.ShutdownHooksParallel = true
+
+	svc := New("hook_timeout_test", config)
+
+	canceled := make(chan struct{})
+
+	svc.AddShutdownHook(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(canceled)
+
+		return ctx.Err()
+	}, WithHookTimeout(10*time.Millisecond))
+
+	// Ignores its context and is abandoned after the timeout
+	block := make(chan struct{})
+	defer close(block)
+
+	svc.AddShutdownHook(ShutdownHookFunc(func() error {
+		<-block
+		return nil
+	}), WithHookTimeout(10*time.Millisecond))
+
+	start := time.Now()
+	incomplete := svc.runShutdownHooks(context.Background(),
+		svc.Metrics.builtinGaugeVec("shutdown_hooks_duration_seconds", "", "hook"))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the hooks to be abandoned after their timeout, took %v", elapsed)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("expected the context of the hook to be canceled")
+	}
+
+	if !slices.Equal(incomplete, []string{"0", "1"}) {
+		t.Errorf("expected both hooks to be incomplete, got %v", incomplete)
+	}
+}
+
+func TestShutdownMetrics(t *testing.T) {
+	t.Parallel()
+
+	pushed := make(chan string, 1)
+
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushed <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer pushgateway.Close()
+
+	config := DefaultConfig()
+	config.MetricsPushURL = pushgateway.URL
+
+	svc := New("shutdown_test", config)
+	svc.AddShutdownHook(func(context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	if err := svc.gracefulShutdown(); err != nil {
+		t.Fatalf("graceful shutdown failed: %v", err)
+	}
+
+	hookDuration := svc.Metrics.builtinGaugeVec("shutdown_hooks_duration_seconds", "", "hook")
+	if got := testutil.ToFloat64(hookDuration.WithLabelValues("0")); got < 0.01 {
+		t.Errorf("expected hook duration of at least 10ms, got %v", got)
+	}
+
+	select {
+	case path := <-pushed:
+		if !strings.Contains(path, "shutdown_test") {
+			t.Errorf("expected metrics to be pushed for job shutdown_test, got %s", path)
+		}
+	default:
+		t.Error("expected metrics to be pushed during shutdown")
+	}
+}
+
+func TestShutdownMetrics_Timeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer pushgateway.Close()
+	defer close(release)
+
+	config := DefaultConfig()
+	config.MetricsPushURL = pushgateway.URL
+	config.ShutdownTimeout = 50 * time.Millisecond
+
+	svc := New("shutdown_push_test", config)
+	start := time.Now()
+
+	_ = svc.gracefulShutdown()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected a hanging Pushgateway not to outlast the shutdown timeout, took %v", elapsed)
+	}
+}
+
 func TestUse(t *testing.T) {
 	t.Parallel()
 
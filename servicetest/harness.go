@@ -0,0 +1,240 @@
+// Package servicetest boots a complete service in-process for integration tests, without containers or fixed
+// ports, and provides typed clients for the main server, the health and metrics endpoints, and the admin API:
+//
+//	func TestOrders(t *testing.T) {
+//		svc := service.New("orders", servicetest.Config())
+//		registerRoutes(svc)
+//
+//		h := servicetest.New(t, svc, servicetest.WithDependency(orders.NewMemoryRepository()))
+//		before := h.Metrics()
+//
+//		resp := h.Get("/orders/42")
+//		// ...
+//
+//		if got := h.Metrics().Delta(before, "http_requests_total", "method", "GET"); got != 1 {
+//			t.Errorf("expected 1 request, got %v", got)
+//		}
+//	}
+package servicetest
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"atomicgo.dev/service"
+	"github.com/hellofresh/health-go/v5"
+)
+
+// Config returns the default config for tests: logs are discarded, and the shutdown doesn't wait for connections
+// longer than a few seconds
+func Config() *service.Config {
+	config := service.DefaultConfig()
+	config.Logger = slog.New(slog.DiscardHandler)
+	config.ShutdownTimeout = 5 * time.Second
+
+	return config
+}
+
+// Option configures a Harness
+type Option func(h *Harness)
+
+// WithDependency provides a fake dependency, e.g. an in-memory repository, to the handlers and workers of the
+// service (see service.Service.Provide). The service mustn't provide a real dependency of the same type itself.
+func WithDependency(dependency any) Option {
+	return func(h *Harness) {
+		h.Service.Provide(dependency)
+	}
+}
+
+// WithHeader sets a header on all requests of the harness, e.g. the credentials of protected endpoints
+func WithHeader(key, value string) Option {
+	return func(h *Harness) {
+		h.header.Set(key, value)
+	}
+}
+
+// Harness is a service running on ephemeral loopback ports for the duration of a test
+type Harness struct {
+	// Service is the service under test
+	Service *service.Service
+	// Main is the main server of the service
+	Main *httptest.Server
+	// Internal is the metrics server of the service, which serves the health, metrics, and admin endpoints
+	Internal *httptest.Server
+
+	t      testing.TB
+	header http.Header
+}
+
+// New boots the service like Start and stops it gracefully when the test ends. The test fails if the service
+// doesn't start, e.g. because of an invalid health check in the config.
+func New(t testing.TB, svc *service.Service, opts ...Option) *Harness {
+	t.Helper()
+
+	h := &Harness{Service: svc, t: t, header: make(http.Header)}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	main, internal, err := svc.StartTest()
+	if err != nil {
+		t.Fatalf("servicetest: failed to start service: %v", err)
+	}
+
+	h.Main, h.Internal = main, internal
+
+	t.Cleanup(func() {
+		if err := svc.Stop(); err != nil {
+			t.Errorf("servicetest: failed to stop service: %v", err)
+		}
+
+		main.Close()
+		internal.Close()
+	})
+
+	return h
+}
+
+// Do sends a request to the main server. Relative URLs are resolved against the main server.
+func (h *Harness) Do(req *http.Request) *http.Response {
+	h.t.Helper()
+
+	return h.do(h.Main, req)
+}
+
+// Get sends a GET request to a path of the main server
+func (h *Harness) Get(path string) *http.Response {
+	h.t.Helper()
+
+	return h.Do(h.newRequest(http.MethodGet, path, nil))
+}
+
+// Post sends a POST request with a body to a path of the main server
+func (h *Harness) Post(path, contentType string, body io.Reader) *http.Response {
+	h.t.Helper()
+
+	req := h.newRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", contentType)
+
+	return h.Do(req)
+}
+
+// Health returns the health status of the service and the status code of the health endpoint
+func (h *Harness) Health() (health.Check, int) {
+	h.t.Helper()
+
+	var check health.Check
+
+	status := h.internalJSON(http.MethodGet, h.Service.Config.HealthPath, &check)
+
+	return check, status
+}
+
+// Ready returns the status code of the readiness endpoint
+func (h *Harness) Ready() int {
+	h.t.Helper()
+
+	return h.internalStatus(h.Service.Config.ReadinessPath)
+}
+
+// Live returns the status code of the liveness endpoint
+func (h *Harness) Live() int {
+	h.t.Helper()
+
+	return h.internalStatus(h.Service.Config.LivenessPath)
+}
+
+// WaitReady waits until the readiness endpoint reports the service as ready, e.g. after warmup, and fails the test
+// if it isn't ready within the timeout
+func (h *Harness) WaitReady(timeout time.Duration) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(timeout)
+
+	for h.Ready() != http.StatusOK {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("servicetest: service not ready after %s", timeout)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Admin sends a request to an admin endpoint (ADMIN_PATH), e.g. Admin(http.MethodPost, "/jobs?name=cleanup"),
+// and decodes the JSON response into out, if not nil. It returns the status code.
+func (h *Harness) Admin(method, path string, out any) int {
+	h.t.Helper()
+
+	return h.internalJSON(method, h.Service.Config.AdminPath+path, out)
+}
+
+// newRequest creates a request with the context of the test
+func (h *Harness) newRequest(method, path string, body io.Reader) *http.Request {
+	h.t.Helper()
+
+	req, err := http.NewRequestWithContext(h.t.Context(), method, path, body)
+	if err != nil {
+		h.t.Fatalf("servicetest: invalid request %s %s: %v", method, path, err)
+	}
+
+	return req
+}
+
+// do sends a request to a server with the headers of the harness
+func (h *Harness) do(server *httptest.Server, req *http.Request) *http.Response {
+	h.t.Helper()
+
+	if !req.URL.IsAbs() {
+		target, err := req.URL.Parse(server.URL + req.URL.String())
+		if err != nil {
+			h.t.Fatalf("servicetest: invalid URL %s: %v", req.URL, err)
+		}
+
+		req.URL, req.Host = target, target.Host
+	}
+
+	for key, values := range h.header {
+		if req.Header.Get(key) == "" {
+			req.Header[key] = values
+		}
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		h.t.Fatalf("servicetest: %s %s failed: %v", req.Method, req.URL, err)
+	}
+
+	h.t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp
+}
+
+// internalStatus sends a GET request to a path of the metrics server and returns the status code
+func (h *Harness) internalStatus(path string) int {
+	h.t.Helper()
+
+	return h.internalJSON(http.MethodGet, path, nil)
+}
+
+// internalJSON sends a request to the metrics server and decodes the JSON response into out, if not nil
+func (h *Harness) internalJSON(method, path string, out any) int {
+	h.t.Helper()
+
+	resp := h.do(h.Internal, h.newRequest(method, path, nil))
+	defer resp.Body.Close()
+
+	if out != nil && strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			h.t.Fatalf("servicetest: invalid JSON response of %s %s: %v", method, path, err)
+		}
+	}
+
+	return resp.StatusCode
+}
@@ -0,0 +1,63 @@
+package servicetest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"atomicgo.dev/service"
+	"github.com/hellofresh/health-go/v5"
+)
+
+// greeter is a dependency of the test service
+type greeter interface {
+	Greet(name string) string
+}
+
+// fakeGreeter is the fake greeter of the tests
+type fakeGreeter struct{}
+
+func (fakeGreeter) Greet(name string) string {
+	return "hello " + name
+}
+
+func TestHarness(t *testing.T) {
+	t.Parallel()
+
+	svc := service.New("harness_test", Config())
+	svc.HandleFunc("GET /greet/{name}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(service.Resolve[greeter](r).Greet(r.PathValue("name"))))
+	})
+
+	h := New(t, svc, WithDependency(fakeGreeter{}))
+	h.WaitReady(time.Second)
+
+	before := h.Metrics()
+
+	if resp := h.Get("/greet/gopher"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	after := h.Metrics()
+	if got := after.Delta(before, "http_requests_total", "method", "GET", "status_code", "200"); got != 1 {
+		t.Errorf("expected 1 request in the metrics, got %v\n%s", got, after)
+	}
+
+	if after.Family("harness_test_http_request_duration_seconds") == nil {
+		t.Error("expected families to be found by their full name")
+	}
+
+	check, status := h.Health()
+	if status != http.StatusOK || check.Status != health.StatusOK {
+		t.Errorf("expected a healthy service, got %d %s", status, check.Status)
+	}
+
+	if code := h.Live(); code != http.StatusOK {
+		t.Errorf("expected the service to be live, got %d", code)
+	}
+
+	var jobs map[string]any
+	if code := h.Admin(http.MethodGet, "/jobs", &jobs); code != http.StatusOK {
+		t.Errorf("expected the admin API to respond, got %d", code)
+	}
+}
@@ -0,0 +1,120 @@
+package servicetest
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Snapshot is the state of the metric families of a service at one scrape (see Harness.Metrics)
+type Snapshot struct {
+	prefix   string
+	families map[string]*dto.MetricFamily
+}
+
+// Metrics scrapes the metrics endpoint of the service
+func (h *Harness) Metrics() Snapshot {
+	h.t.Helper()
+
+	req := h.newRequest(http.MethodGet, h.Service.Config.MetricsPath, nil)
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+
+	resp := h.do(h.Internal, req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("servicetest: metrics endpoint returned %d", resp.StatusCode)
+	}
+
+	var parser expfmt.TextParser
+
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		h.t.Fatalf("servicetest: invalid metrics: %v", err)
+	}
+
+	return Snapshot{prefix: h.Service.Metrics.Prefix() + "_", families: families}
+}
+
+// Family returns a metric family by name, with or without the prefix of the service, or nil if it has no series
+func (s Snapshot) Family(name string) *dto.MetricFamily {
+	if family, ok := s.families[name]; ok {
+		return family
+	}
+
+	return s.families[s.prefix+name]
+}
+
+// Names returns the sorted names of the metric families
+func (s Snapshot) Names() []string {
+	names := make([]string, 0, len(s.families))
+	for name := range s.families {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Value returns the sum of the series of a metric family matching the labels, given as name-value pairs. Histograms
+// and summaries count their observations. Missing families are 0.
+func (s Snapshot) Value(name string, labels ...string) float64 {
+	family := s.Family(name)
+	if family == nil {
+		return 0
+	}
+
+	var sum float64
+
+	for _, metric := range family.GetMetric() {
+		if matchLabels(metric, labels) {
+			sum += value(metric)
+		}
+	}
+
+	return sum
+}
+
+// Delta returns the change of the value of a metric family since an earlier snapshot (see Value)
+func (s Snapshot) Delta(before Snapshot, name string, labels ...string) float64 {
+	return s.Value(name, labels...) - before.Value(name, labels...)
+}
+
+// String returns the names of the metric families
+func (s Snapshot) String() string {
+	return strings.Join(s.Names(), "\n")
+}
+
+// matchLabels reports whether a series has all labels of the name-value pairs
+func matchLabels(metric *dto.Metric, labels []string) bool {
+	for i := 0; i+1 < len(labels); i += 2 {
+		matched := slices.ContainsFunc(metric.GetLabel(), func(pair *dto.LabelPair) bool {
+			return pair.GetName() == labels[i] && pair.GetValue() == labels[i+1]
+		})
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// value returns the value of a series, or the number of observations of histograms and summaries
+func value(metric *dto.Metric) float64 {
+	switch {
+	case metric.GetCounter() != nil:
+		return metric.GetCounter().GetValue()
+	case metric.GetGauge() != nil:
+		return metric.GetGauge().GetValue()
+	case metric.GetHistogram() != nil:
+		return float64(metric.GetHistogram().GetSampleCount())
+	case metric.GetSummary() != nil:
+		return float64(metric.GetSummary().GetSampleCount())
+	default:
+		return metric.GetUntyped().GetValue()
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrUnknownSetting is returned when changing a setting that was not registered
+	ErrUnknownSetting = NewError(CodeNotFound, "unknown setting")
+	// ErrInvalidSetting is returned when a setting value can't be parsed as the type of its default
+	ErrInvalidSetting = NewError(CodeInvalidArgument, "invalid setting value")
+)
+
+// Sources of setting changes in the audit log
+const (
+	settingSourceCode   = "code"
+	settingSourceAdmin  = "admin"
+	settingSourceReload = "reload"
+)
+
+// Setting is an operational tunable that can be changed at runtime without a restart, via the admin API
+// (ADMIN_PATH/settings) or a reload of SETTINGS_FILE. Reading a setting is safe for concurrent use.
+type Setting struct {
+	name         string
+	defaultValue any
+	value        atomic.Value
+	registry     *settings
+
+	mu       sync.Mutex
+	watchers []func(value any)
+}
+
+// Name returns the name of the setting
+func (s *Setting) Name() string {
+	return s.name
+}
+
+// Value returns the current value, with the type of the default
+func (s *Setting) Value() any {
+	return s.value.Load()
+}
+
+// String returns the current value of a string setting
+func (s *Setting) String() string {
+	value, _ := s.Value().(string)
+	return value
+}
+
+// Int returns the current value of an int setting
+func (s *Setting) Int() int {
+	value, _ := s.Value().(int)
+	return value
+}
+
+// Float returns the current value of a float64 setting
+func (s *Setting) Float() float64 {
+	value, _ := s.Value().(float64)
+	return value
+}
+
+// Bool returns the current value of a bool setting
+func (s *Setting) Bool() bool {
+	value, _ := s.Value().(bool)
+	return value
+}
+
+// Duration returns the current value of a time.Duration setting
+func (s *Setting) Duration() time.Duration {
+	value, _ := s.Value().(time.Duration)
+	return value
+}
+
+// Set parses the value as the type of the default and changes the setting
+func (s *Setting) Set(value string) error {
+	return s.set(value, settingSourceCode)
+}
+
+// set changes the setting and writes the change to the audit log
+func (s *Setting) set(value, source string, attrs ...any) error {
+	parsed, err := parseSettingValue(s.defaultValue, value)
+	if err != nil {
+		return fmt.Errorf("%w: %s=%q: %w", ErrInvalidSetting, s.name, value, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.value.Swap(parsed)
+	if previous != parsed {
+		s.registry.audit(s.name, previous, parsed, source, attrs...)
+
+		for _, watcher := range s.watchers {
+			watcher(parsed)
+		}
+	}
+
+	return nil
+}
+
+// watch calls the function with the current value and after every change
+func (s *Setting) watch(fn func(value any)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watchers = append(s.watchers, fn)
+	fn(s.Value())
+}
+
+// parseSettingValue parses a value as the type of the default
+func parseSettingValue(defaultValue any, value string) (any, error) {
+	switch defaultValue.(type) {
+	case string:
+		return value, nil
+	case int:
+		return strconv.Atoi(value) //nolint:wrapcheck
+	case float64:
+		return strconv.ParseFloat(value, 64) //nolint:wrapcheck
+	case bool:
+		return strconv.ParseBool(value) //nolint:wrapcheck
+	case time.Duration:
+		return time.ParseDuration(value) //nolint:wrapcheck
+	default:
+		return nil, fmt.Errorf("unsupported type %T", defaultValue)
+	}
+}
+
+// settings is the runtime settings registry of a service
+type settings struct {
+	metrics *MetricsCollector
+	logger  *slog.Logger
+
+	mu       sync.Mutex
+	settings map[string]*Setting
+	// pending holds reloaded values of settings that are not registered yet
+	pending map[string]string
+}
+
+// newSettings creates a new settings registry
+func newSettings(metrics *MetricsCollector, logger *slog.Logger) *settings {
+	return &settings{
+		metrics:  metrics,
+		logger:   logger,
+		settings: make(map[string]*Setting),
+		pending:  make(map[string]string),
+	}
+}
+
+// Setting returns the runtime setting with the name, registering it with the default on first use.
+// The default must be a string, int, float64, bool, or time.Duration; it also sets the type of the setting.
+// Keep the returned setting to read it on hot paths:
+//
+//	threshold := svc.Setting("shed_threshold", 0.8)
+//	...
+//	if load > threshold.Float() { ... }
+func (s *Service) Setting(name string, defaultValue any) *Setting {
+	switch defaultValue.(type) {
+	case string, int, float64, bool, time.Duration:
+	default:
+		panic(fmt.Sprintf("setting %s: unsupported type %T", name, defaultValue))
+	}
+
+	r := s.settings
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if setting, ok := r.settings[name]; ok {
+		return setting
+	}
+
+	setting := &Setting{name: name, defaultValue: defaultValue, registry: r}
+	setting.value.Store(defaultValue)
+	r.settings[name] = setting
+
+	if value, ok := r.pending[name]; ok {
+		delete(r.pending, name)
+
+		if err := setting.set(value, settingSourceReload); err != nil {
+			s.Logger.Error("invalid reloaded setting, using the default", "setting", name, "error", err)
+		}
+	}
+
+	return setting
+}
+
+// SetSetting changes a registered setting, parsing the value as the type of its default
+func (s *Service) SetSetting(name, value string) error {
+	return s.setSetting(name, value, settingSourceCode)
+}
+
+// setSetting changes a registered setting from a source
+func (s *Service) setSetting(name, value, source string, attrs ...any) error {
+	s.settings.mu.Lock()
+	setting, ok := s.settings.settings[name]
+	s.settings.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSetting, name)
+	}
+
+	return setting.set(value, source, attrs...)
+}
+
+// ReloadSettings reads the "name=value" lines of SETTINGS_FILE and changes the settings. Values of settings that
+// are not registered yet are applied on registration. Settings missing from the file keep their value.
+// The service reloads the file on start and on SIGHUP.
+func (s *Service) ReloadSettings() error {
+	if s.Config.SettingsFile == "" {
+		return nil
+	}
+
+	lines, err := readLinesFile(s.Config.SettingsFile)
+	if err != nil {
+		return fmt.Errorf("failed to reload settings: %w", err)
+	}
+
+	var errs []error
+
+	for _, line := range lines {
+		name, value, found := strings.Cut(line, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		if !found || name == "" {
+			errs = append(errs, fmt.Errorf("%w: invalid line %q", ErrInvalidSetting, line))
+			continue
+		}
+
+		s.settings.mu.Lock()
+		_, registered := s.settings.settings[name]
+		if !registered {
+			s.settings.pending[name] = value
+		}
+		s.settings.mu.Unlock()
+
+		if !registered {
+			continue
+		}
+
+		if err := s.setSetting(name, value, settingSourceReload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// watchSettingsReload reloads the settings on SIGHUP until the service stops
+func (s *Service) watchSettingsReload() {
+	if s.Config.SettingsFile == "" {
+		return
+	}
+
+	s.Go("settings_reload", func(ctx context.Context) error {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+
+		defer signal.Stop(reload)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-reload:
+				if err := s.ReloadSettings(); err != nil {
+					LoggerFromContext(ctx).Error("failed to reload settings", "error", err)
+				}
+			}
+		}
+	})
+}
+
+// audit logs a setting change and counts it in {service_name}_setting_changes_total{setting,source}
+func (r *settings) audit(name string, previous, value any, source string, attrs ...any) {
+	r.metrics.builtinCounterVec("setting_changes_total", "Total number of runtime setting changes by source",
+		"setting", "source").WithLabelValues(name, source).Inc()
+
+	r.logger.Info("changed setting",
+		append([]any{"audit_id", NewID(), "setting", name, "from", previous, "to", value, "source", source}, attrs...)...)
+}
+
+// settingInfo is the admin API representation of a setting
+type settingInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+}
+
+// list returns the settings sorted by name
+func (r *settings) list() []settingInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]settingInfo, 0, len(r.settings))
+	for _, setting := range r.settings {
+		infos = append(infos, settingInfo{
+			Name:    setting.name,
+			Type:    fmt.Sprintf("%T", setting.defaultValue),
+			Value:   fmt.Sprint(setting.Value()),
+			Default: fmt.Sprint(setting.defaultValue),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos
+}
+
+// settingsHandler returns the admin handler to list the settings, and to change one with POST ?name=...&value=...
+func (s *Service) settingsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			query := r.URL.Query()
+
+			err := s.setSetting(query.Get("name"), query.Get("value"), settingSourceAdmin, "client_ip", clientIP(r))
+			if err != nil {
+				http.Error(w, err.Error(), HTTPStatus(err))
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"settings": s.settings.list()})
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSetting(t *testing.T) {
+	t.Parallel()
+
+	svc := New("settings_test", nil)
+
+	threshold := svc.Setting("shed_threshold", 0.8)
+	if threshold.Float() != 0.8 {
+		t.Fatalf("expected the default, got %v", threshold.Value())
+	}
+
+	if svc.Setting("shed_threshold", 0.5) != threshold {
+		t.Error("expected the registered setting to be returned")
+	}
+
+	if err := svc.SetSetting("shed_threshold", "0.9"); err != nil {
+		t.Fatalf("failed to change setting: %v", err)
+	}
+
+	if threshold.Float() != 0.9 {
+		t.Errorf("expected 0.9, got %v", threshold.Value())
+	}
+
+	if err := threshold.Set("high"); !errors.Is(err, ErrInvalidSetting) {
+		t.Errorf("expected ErrInvalidSetting, got %v", err)
+	}
+
+	if err := svc.SetSetting("unknown", "1"); !errors.Is(err, ErrUnknownSetting) {
+		t.Errorf("expected ErrUnknownSetting, got %v", err)
+	}
+
+	timeout := svc.Setting("upstream_timeout", time.Second)
+	if err := timeout.Set("250ms"); err != nil || timeout.Duration() != 250*time.Millisecond {
+		t.Errorf("expected 250ms, got %v (%v)", timeout.Value(), err)
+	}
+
+	changes := svc.Metrics.builtinCounterVec("setting_changes_total", "", "setting", "source")
+	if testutil.ToFloat64(changes.WithLabelValues("shed_threshold", settingSourceCode)) != 1 {
+		t.Error("expected the change to be counted")
+	}
+}
+
+func TestSettingUnsupportedType(t *testing.T) {
+	t.Parallel()
+
+	svc := New("settings_type_test", nil)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an unsupported type")
+		}
+	}()
+
+	svc.Setting("ratio", float32(0.5))
+}
+
+func TestReloadSettings(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "settings")
+
+	config := DefaultConfig()
+	config.SettingsFile = path
+
+	svc := New("settings_reload_test", config)
+	enabled := svc.Setting("feature_enabled", false)
+
+	if err := os.WriteFile(path, []byte("# tunables\nfeature_enabled = true\nbatch_size=50\n"), 0o600); err != nil {
+		t.Fatalf("failed to write settings file: %v", err)
+	}
+
+	if err := svc.ReloadSettings(); err != nil {
+		t.Fatalf("failed to reload settings: %v", err)
+	}
+
+	if !enabled.Bool() {
+		t.Error("expected the reloaded value")
+	}
+
+	if svc.Setting("batch_size", 100).Int() != 50 {
+		t.Error("expected the reloaded value to apply on registration")
+	}
+
+	if err := os.WriteFile(path, []byte("feature_enabled=maybe\n"), 0o600); err != nil {
+		t.Fatalf("failed to write settings file: %v", err)
+	}
+
+	if err := svc.ReloadSettings(); !errors.Is(err, ErrInvalidSetting) {
+		t.Errorf("expected ErrInvalidSetting, got %v", err)
+	}
+
+	if !enabled.Bool() {
+		t.Error("expected an invalid value to keep the previous value")
+	}
+}
+
+func TestSettingsHandler(t *testing.T) {
+	t.Parallel()
+
+	svc := New("settings_admin_test", nil)
+	limit := svc.Setting("rate_limit", 100)
+
+	handler := svc.settingsHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/settings?name=rate_limit&value=250", nil))
+
+	if rec.Code != http.StatusOK || limit.Int() != 250 {
+		t.Fatalf("expected the setting to change, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		Settings []settingInfo `json:"settings"`
+	}
+
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	want := settingInfo{Name: "rate_limit", Type: "int", Value: "250", Default: "100"}
+	if !slices.Contains(body.Settings, want) {
+		t.Errorf("unexpected settings: %+v", body.Settings)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/settings?name=rate_limit&value=many", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid value, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/settings?name=unknown&value=1", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown setting, got %d", rec.Code)
+	}
+}
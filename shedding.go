@@ -0,0 +1,183 @@
+package service
+
+import (
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// shedRateStep is the amount the shed rate changes per interval
+	shedRateStep = 0.1
+	// maxShedRate is the highest automatic shed rate, so some traffic keeps flowing to measure recovery
+	maxShedRate = 0.9
+	// maxQueueTime caps the proxy queue time, so a skewed proxy clock can't shed all traffic on its own
+	maxQueueTime = 10 * time.Second
+)
+
+// LoadShedder implements adaptive load shedding based on request latency.
+//
+// Like CoDel, it tracks the minimum latency per interval. If even the fastest request of an interval
+// exceeds the latency target, the service has a standing queue and the shed rate is increased.
+// Otherwise the shed rate is decreased again.
+type LoadShedder struct {
+	target   time.Duration
+	interval time.Duration
+
+	mu            sync.Mutex
+	intervalStart time.Time
+	minLatency    time.Duration
+	rate          float64
+	override      *float64
+	proxies       HeaderSanitizationConfig
+
+	shedTotal prometheus.Counter
+	shedRate  prometheus.Gauge
+}
+
+// NewLoadShedder creates a new load shedder with the given latency target and measurement interval
+func NewLoadShedder(target, interval time.Duration, metrics *MetricsCollector) *LoadShedder {
+	return &LoadShedder{
+		target:        target,
+		interval:      interval,
+		intervalStart: time.Now(),
+		minLatency:    math.MaxInt64,
+		shedTotal: metrics.builtinCounterVec("load_shed_total",
+			"Total number of requests rejected by the load shedder").WithLabelValues(),
+		shedRate: metrics.builtinGaugeVec("load_shed_rate",
+			"Fraction of requests currently rejected by the load shedder").WithLabelValues(),
+	}
+}
+
+// Rate returns the fraction of requests that are currently rejected
+func (ls *LoadShedder) Rate() float64 {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.override != nil {
+		return *ls.override
+	}
+
+	return ls.rate
+}
+
+// SetOverride manually sets the shed rate (0 to 1), disabling the adaptive behavior until ClearOverride is called
+func (ls *LoadShedder) SetOverride(rate float64) {
+	rate = math.Max(0, math.Min(1, rate))
+
+	ls.mu.Lock()
+	ls.override = &rate
+	ls.mu.Unlock()
+
+	ls.shedRate.Set(rate)
+}
+
+// ClearOverride removes a manual override and resumes adaptive load shedding
+func (ls *LoadShedder) ClearOverride() {
+	ls.mu.Lock()
+	ls.override = nil
+	rate := ls.rate
+	ls.mu.Unlock()
+
+	ls.shedRate.Set(rate)
+}
+
+// SetTrustedProxies sets the networks of proxies whose X-Request-Start header is included in the latency.
+// Without trusted proxies, the header is ignored. It must be called before the shedder serves requests.
+func (ls *LoadShedder) SetTrustedProxies(networks []netip.Prefix) {
+	ls.proxies = HeaderSanitizationConfig{TrustedProxies: networks}
+}
+
+// shouldShed decides whether a request should be rejected
+func (ls *LoadShedder) shouldShed() bool {
+	rate := ls.Rate()
+
+	return rate > 0 && rand.Float64() < rate //nolint:gosec
+}
+
+// observe records the latency of a request and adjusts the shed rate at the end of each interval
+func (ls *LoadShedder) observe(latency time.Duration) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.minLatency = min(ls.minLatency, latency)
+
+	if time.Since(ls.intervalStart) < ls.interval {
+		return
+	}
+
+	if ls.minLatency > ls.target {
+		ls.rate = math.Min(ls.rate+shedRateStep, maxShedRate)
+	} else {
+		ls.rate = math.Max(ls.rate-shedRateStep, 0)
+	}
+
+	ls.intervalStart = time.Now()
+	ls.minLatency = math.MaxInt64
+
+	if ls.override == nil {
+		ls.shedRate.Set(ls.rate)
+	}
+}
+
+// queueTime returns the time a request spent queued in a proxy, based on the X-Request-Start header
+// set by proxies like NGINX or Heroku ("t=1700000000.123" or a plain timestamp in s, ms, or µs), at most maxQueueTime
+func queueTime(r *http.Request) time.Duration {
+	header := strings.TrimPrefix(r.Header.Get("X-Request-Start"), "t=")
+	if header == "" {
+		return 0
+	}
+
+	value, err := strconv.ParseFloat(header, 64)
+	if err != nil || value <= 0 {
+		return 0
+	}
+
+	// Normalize the timestamp to seconds
+	for value > 1e11 {
+		value /= 1000
+	}
+
+	sec, frac := math.Modf(value)
+
+	queued := time.Since(time.Unix(int64(sec), int64(frac*1e9)))
+	if queued < 0 {
+		return 0
+	}
+
+	return min(queued, maxQueueTime)
+}
+
+// LoadSheddingMiddleware rejects requests with 503 while the load shedder is shedding traffic. The latency includes
+// the proxy queue time of requests from trusted proxies (see LoadShedder.SetTrustedProxies).
+func LoadSheddingMiddleware(shedder *LoadShedder) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if shedder.shouldShed() {
+				shedder.shedTotal.Inc()
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+
+				return
+			}
+
+			start := time.Now()
+
+			var queued time.Duration
+			if shedder.proxies.trusted(r) {
+				queued = queueTime(r)
+			}
+
+			next.ServeHTTP(w, r)
+
+			shedder.observe(queued + time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLoadShedder_Adaptive(t *testing.T) {
+	t.Parallel()
+
+	shedder := NewLoadShedder(time.Millisecond, 0, NewMetricsCollector("shed_test"))
+
+	// Every observation closes an interval, so slow requests raise the shed rate step by step
+	for range 3 {
+		shedder.observe(10 * time.Millisecond)
+	}
+
+	if rate := shedder.Rate(); rate < 0.29 || rate > 0.31 {
+		t.Errorf("expected shed rate of 0.3, got %v", rate)
+	}
+
+	for range 20 {
+		shedder.observe(10 * time.Millisecond)
+	}
+
+	if rate := shedder.Rate(); rate != maxShedRate {
+		t.Errorf("expected shed rate to be capped at %v, got %v", maxShedRate, rate)
+	}
+
+	for range 20 {
+		shedder.observe(0)
+	}
+
+	if rate := shedder.Rate(); rate != 0 {
+		t.Errorf("expected shed rate to recover to 0, got %v", rate)
+	}
+}
+
+func TestLoadShedder_Override(t *testing.T) {
+	t.Parallel()
+
+	shedder := NewLoadShedder(time.Second, time.Second, NewMetricsCollector("shed_test"))
+
+	handler := LoadSheddingMiddleware(shedder)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	shedder.SetOverride(1)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", recorder.Code)
+	}
+
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	shedder.ClearOverride()
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", recorder.Code)
+	}
+}
+
+func TestQueueTime(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now().Add(-time.Second)
+
+	for _, header := range []string{
+		"t=" + strconv.FormatFloat(float64(start.UnixMilli())/1000, 'f', 3, 64),
+		strconv.FormatInt(start.UnixMilli(), 10),
+		strconv.FormatInt(start.UnixMicro(), 10),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-Start", header)
+
+		if queued := queueTime(req); queued < 900*time.Millisecond || queued > 2*time.Second {
+			t.Errorf("unexpected queue time %v for header %q", queued, header)
+		}
+	}
+
+	if queued := queueTime(httptest.NewRequest(http.MethodGet, "/", nil)); queued != 0 {
+		t.Errorf("expected no queue time without header, got %v", queued)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-Start", "t=1")
+
+	if queued := queueTime(req); queued != maxQueueTime {
+		t.Errorf("expected queue time to be capped at %v, got %v", maxQueueTime, queued)
+	}
+}
+
+func TestLoadSheddingMiddleware_TrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	shedder := NewLoadShedder(time.Second, 0, NewMetricsCollector("shed_test"))
+	shedder.SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	handler := LoadSheddingMiddleware(shedder)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	request := func(remoteAddr string) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Request-Start", strconv.FormatInt(time.Now().Add(-5*time.Second).UnixMilli(), 10))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request("192.0.2.1:1234")
+
+	if rate := shedder.Rate(); rate != 0 {
+		t.Errorf("expected X-Request-Start of untrusted clients to be ignored, got shed rate %v", rate)
+	}
+
+	request("10.0.0.7:1234")
+
+	if rate := shedder.Rate(); rate == 0 {
+		t.Error("expected X-Request-Start of trusted proxies to count")
+	}
+}
+
+func TestNew_LoadShedding(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.LoadShedding = true
+
+	svc := New("shed_test", config)
+	if svc.LoadShedder == nil {
+		t.Fatal("expected load shedder to be enabled")
+	}
+
+	svc.LoadShedder.SetOverride(1)
+	svc.HandleFunc("/test", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", recorder.Code)
+	}
+}
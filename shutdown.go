@@ -2,24 +2,53 @@ package service
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
 )
 
+// shutdownNotifyTimeout limits the notification about an exceeded shutdown timeout, which is sent after the shutdown
+// budget is used up, so a slow notifier can't delay the exit past the termination grace period
+const shutdownNotifyTimeout = 2 * time.Second
+
 // gracefulShutdown performs graceful shutdown of the service
 func (s *Service) gracefulShutdown() error {
 	s.Logger.Info("starting graceful shutdown")
 
-	// Create a context with timeout for shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), s.Config.ShutdownTimeout)
+	start := time.Now()
+
+	reason := s.shutdownReason
+	if reason == "" {
+		reason = ShutdownReasonStop
+	}
+
+	state := s.newShutdownState(reason)
+	state.Signal = s.shutdownSignal
+
+	hookDuration := s.Metrics.builtinGaugeVec("shutdown_hooks_duration_seconds",
+		"Duration of each shutdown hook in seconds", "hook")
+
+	// Tell load balancers to stop routing traffic before the listeners close
+	s.drain()
+
+	// Draining is over, the service is stopping for good
+	s.stopping.Store(true)
+
+	// Stop background tasks bound to the service lifecycle
+	s.cancel()
+
+	// Create a context with timeout for shutdown, carrying the shutdown logger
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(s.subsystemContext("shutdown")), s.Config.ShutdownTimeout)
 	defer cancel()
 
 	// Execute shutdown hooks
-	for i, hook := range s.Config.ShutdownHooks {
-		s.Logger.Info("executing shutdown hook", "index", i)
-
-		if err := hook(); err != nil {
-			s.Logger.Error("shutdown hook failed", "index", i, "error", err)
-		}
-	}
+	state.IncompleteHooks = s.runShutdownHooks(ctx, hookDuration)
 
 	// Shutdown servers
 	var shutdownErrors []error
@@ -28,9 +57,10 @@ func (s *Service) gracefulShutdown() error {
 	if s.server != nil {
 		s.Logger.Info("shutting down HTTP server")
 
-		if err := s.server.Shutdown(ctx); err != nil {
+		if err := s.shutdownServer(ctx, s.server); err != nil {
 			s.Logger.Error("HTTP server shutdown error", "error", err)
 			shutdownErrors = append(shutdownErrors, err)
+			state.Forced = state.Forced || errors.Is(err, context.DeadlineExceeded)
 		}
 	}
 
@@ -38,12 +68,37 @@ func (s *Service) gracefulShutdown() error {
 	if s.metricsServer != nil {
 		s.Logger.Info("shutting down metrics server")
 
-		if err := s.metricsServer.Shutdown(ctx); err != nil {
+		if err := s.shutdownServer(ctx, s.metricsServer); err != nil {
 			s.Logger.Error("metrics server shutdown error", "error", err)
 			shutdownErrors = append(shutdownErrors, err)
+			state.Forced = state.Forced || errors.Is(err, context.DeadlineExceeded)
 		}
 	}
 
+	// Workers may still use the components, which are stopped next
+	state.IncompleteWorkers = s.waitForWorkers(ctx)
+
+	// Write the usage of the last requests before the process exits
+	s.flushUsage(ctx)
+
+	// Stop managed components once no request can use them anymore
+	failedComponents, componentErrors := s.stopComponents(ctx)
+	state.IncompleteComponents = failedComponents
+	shutdownErrors = append(shutdownErrors, componentErrors...)
+
+	s.Metrics.builtinGaugeVec("shutdown_duration_seconds", "Duration of the last graceful shutdown in seconds").
+		WithLabelValues().Set(time.Since(start).Seconds())
+
+	// Flush the final metrics, as the metrics server is no longer reachable
+	s.pushMetrics(ctx)
+
+	// The mesh proxy is no longer needed once all outbound calls are done
+	s.stopSidecar(ctx)
+
+	state.DurationSeconds = time.Since(start).Seconds()
+	state.Clean = len(shutdownErrors) == 0 && len(state.IncompleteHooks) == 0 && len(state.IncompleteWorkers) == 0
+	s.recordShutdown(state)
+
 	if len(shutdownErrors) > 0 {
 		s.Logger.Error("shutdown completed with errors", "error_count", len(shutdownErrors))
 		return shutdownErrors[0] // Return first error
@@ -54,11 +109,121 @@ func (s *Service) gracefulShutdown() error {
 	return nil
 }
 
-// AddShutdownHook adds a function to be called during graceful shutdown
-func (s *Service) AddShutdownHook(hook func() error) {
+// shutdownServer shuts down a server gracefully and forcefully closes it if the shutdown timeout is exceeded
+func (s *Service) shutdownServer(ctx context.Context, server *http.Server) error {
+	err := server.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		s.Logger.Warn("shutdown timeout exceeded, closing remaining connections", "addr", server.Addr)
+		s.Metrics.builtinCounterVec("shutdown_forced_total",
+			"Total number of servers forcefully closed after the shutdown timeout").WithLabelValues().Inc()
+
+		_ = server.Close()
+
+		notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownNotifyTimeout)
+		defer cancel()
+
+		s.notify(notifyCtx, EventShutdownTimeout, "shutdown timeout exceeded, connections were closed forcefully",
+			map[string]any{"addr": server.Addr, "timeout": s.Config.ShutdownTimeout.String()})
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// pushMetrics pushes all metrics to the Pushgateway, if configured, within the shutdown timeout
+func (s *Service) pushMetrics(ctx context.Context) {
+	if s.Config.MetricsPushURL == "" {
+		return
+	}
+
+	if err := push.New(s.Config.MetricsPushURL, s.Name).Gatherer(s.Metrics.GetRegistry()).PushContext(ctx); err != nil {
+		s.Logger.Error("failed to push metrics", "url", s.Config.MetricsPushURL, "error", err)
+	}
+}
+
+// AddShutdownHook adds a function to be called during graceful shutdown. Use ShutdownHookFunc for hooks
+// without a context.
+func (s *Service) AddShutdownHook(hook ShutdownHook, opts ...ShutdownHookOption) {
+	config := &shutdownHookConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.timeout > 0 {
+		hook = hook.withTimeout(config.timeout)
+	}
+
 	s.Config.ShutdownHooks = append(s.Config.ShutdownHooks, hook)
 }
 
+// runShutdownHooks runs the shutdown hooks one after another, or in parallel with SHUTDOWN_HOOKS_PARALLEL,
+// and returns the indexes of the hooks that failed or timed out
+func (s *Service) runShutdownHooks(ctx context.Context, hookDuration *prometheus.GaugeVec) []string {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []int
+	)
+
+	for i, hook := range s.Config.ShutdownHooks {
+		run := func() {
+			if !s.runShutdownHook(ctx, i, hook, hookDuration) {
+				mu.Lock()
+				failed = append(failed, i)
+				mu.Unlock()
+			}
+		}
+
+		if !s.Config.ShutdownHooksParallel {
+			run()
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			run()
+		}()
+	}
+
+	wg.Wait()
+	slices.Sort(failed)
+
+	incomplete := make([]string, 0, len(failed))
+	for _, i := range failed {
+		incomplete = append(incomplete, strconv.Itoa(i))
+	}
+
+	return incomplete
+}
+
+// runShutdownHook runs a shutdown hook within SHUTDOWN_HOOK_TIMEOUT and reports whether it succeeded
+func (s *Service) runShutdownHook(ctx context.Context, i int, hook ShutdownHook, hookDuration *prometheus.GaugeVec) bool {
+	logger := LoggerFromContext(ctx)
+	logger.Info("executing shutdown hook", "index", i)
+
+	if s.Config.ShutdownHookTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, s.Config.ShutdownHookTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := hook.call(ctx)
+
+	hookDuration.WithLabelValues(strconv.Itoa(i)).Set(time.Since(start).Seconds())
+
+	switch {
+	case errors.Is(err, ErrShutdownHookTimeout):
+		logger.Error("shutdown hook timed out, continuing the shutdown", "index", i, "duration", time.Since(start))
+	case err != nil:
+		logger.Error("shutdown hook failed", "index", i, "error", err)
+	}
+
+	return err == nil
+}
+
 // Stop stops the service gracefully
 func (s *Service) Stop() error {
 	return s.gracefulShutdown()
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Shutdown reasons recorded in the shutdown state
+const (
+	// ShutdownReasonRunning is recorded on start. Finding it after a restart means the process crashed or was killed.
+	ShutdownReasonRunning = "running"
+	// ShutdownReasonSignal is recorded when the service stopped after SIGINT or SIGTERM
+	ShutdownReasonSignal = "signal"
+	// ShutdownReasonServerError is recorded when a server failed and the service stopped without graceful shutdown
+	ShutdownReasonServerError = "server_error"
+	// ShutdownReasonStop is recorded when the service was stopped with Stop
+	ShutdownReasonStop = "stop"
+)
+
+// ShutdownState describes how the service stopped, for crash analysis after a restart
+type ShutdownState struct {
+	Reason  string    `json:"reason"`
+	Signal  string    `json:"signal,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Version string    `json:"version"`
+	PID     int       `json:"pid"`
+	Time    time.Time `json:"time"`
+	// DurationSeconds is the duration of the graceful shutdown
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Clean is true if all hooks, components, and workers stopped without errors and no connection was closed
+	// forcefully
+	Clean bool `json:"clean"`
+	// Forced is true if connections were closed after the shutdown timeout
+	Forced bool `json:"forced,omitempty"`
+	// IncompleteHooks lists the indexes of shutdown hooks that failed
+	IncompleteHooks []string `json:"incomplete_hooks,omitempty"`
+	// IncompleteComponents lists the components that failed to stop
+	IncompleteComponents []string `json:"incomplete_components,omitempty"`
+	// IncompleteWorkers lists the workers still running after the shutdown timeout
+	IncompleteWorkers []string `json:"incomplete_workers,omitempty"`
+}
+
+// ReadShutdownState reads a shutdown state file written by a previous run.
+// It returns nil and no error if the file does not exist.
+func ReadShutdownState(path string) (*ShutdownState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil //nolint:nilnil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shutdown state: %w", err)
+	}
+
+	var state ShutdownState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse shutdown state: %w", err)
+	}
+
+	return &state, nil
+}
+
+// PreviousShutdown returns the shutdown state of the previous run, read from SHUTDOWN_STATE_FILE.
+// It returns nil if no state file is configured or the service never ran before.
+func (s *Service) PreviousShutdown() *ShutdownState {
+	return s.previousShutdown
+}
+
+// loadPreviousShutdown reads the state of the previous run and warns if it did not stop cleanly
+func (s *Service) loadPreviousShutdown() {
+	if s.Config.ShutdownStateFile == "" {
+		return
+	}
+
+	state, err := ReadShutdownState(s.Config.ShutdownStateFile)
+	if err != nil {
+		s.Logger.Warn("failed to read previous shutdown state", "error", err)
+		return
+	}
+
+	if state != nil && !state.Clean {
+		s.Logger.Warn("previous run did not stop cleanly", "reason", state.Reason, "time", state.Time)
+	}
+
+	s.previousShutdown = state
+}
+
+// newShutdownState creates a shutdown state with the service information filled in
+func (s *Service) newShutdownState(reason string) *ShutdownState {
+	return &ShutdownState{
+		Reason:  reason,
+		Version: s.Config.Version,
+		PID:     os.Getpid(),
+		Time:    time.Now(),
+	}
+}
+
+// recordShutdown logs the final shutdown state and writes it to the state file, if configured
+func (s *Service) recordShutdown(state *ShutdownState) {
+	s.Logger.Info("shutdown state",
+		"reason", state.Reason,
+		"signal", state.Signal,
+		"clean", state.Clean,
+		"forced", state.Forced,
+		"duration", time.Duration(state.DurationSeconds*float64(time.Second)),
+		"incomplete_hooks", state.IncompleteHooks,
+		"incomplete_components", state.IncompleteComponents,
+		"incomplete_workers", state.IncompleteWorkers,
+	)
+
+	s.writeShutdownState(state)
+}
+
+// writeShutdownState atomically replaces the state file, if configured
+func (s *Service) writeShutdownState(state *ShutdownState) {
+	path := s.Config.ShutdownStateFile
+	if path == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+
+		err = os.WriteFile(tmp, data, 0o600)
+		if err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+
+	if err != nil {
+		s.Logger.Error("failed to write shutdown state", "path", path, "error", err)
+	}
+}
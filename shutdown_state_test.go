@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestShutdownState(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.ShutdownStateFile = filepath.Join(t.TempDir(), "shutdown.json")
+
+	svc := New("shutdown_state_test", config)
+	if svc.PreviousShutdown() != nil {
+		t.Fatal("expected no previous shutdown on the first run")
+	}
+
+	svc.AddShutdownHook(func(context.Context) error { return nil })
+	svc.AddShutdownHook(func(context.Context) error { return errors.New("flush failed") }) //nolint:err113
+
+	svc.writeShutdownState(svc.newShutdownState(ShutdownReasonRunning))
+
+	// A restart while the state file says "running" means the previous run crashed
+	crashed := New("shutdown_state_test", config)
+	if previous := crashed.PreviousShutdown(); previous == nil || previous.Reason != ShutdownReasonRunning || previous.Clean {
+		t.Fatalf("expected an unclean running state, got %+v", previous)
+	}
+
+	if err := svc.gracefulShutdown(); err != nil {
+		t.Fatalf("graceful shutdown failed: %v", err)
+	}
+
+	state, err := ReadShutdownState(config.ShutdownStateFile)
+	if err != nil {
+		t.Fatalf("failed to read shutdown state: %v", err)
+	}
+
+	if state.Reason != ShutdownReasonStop {
+		t.Errorf("expected reason %q, got %q", ShutdownReasonStop, state.Reason)
+	}
+
+	if state.Clean {
+		t.Error("expected an unclean shutdown because a hook failed")
+	}
+
+	if !slices.Equal(state.IncompleteHooks, []string{"1"}) {
+		t.Errorf("expected hook 1 to be incomplete, got %v", state.IncompleteHooks)
+	}
+}
+
+func TestReadShutdownStateMissing(t *testing.T) {
+	t.Parallel()
+
+	state, err := ReadShutdownState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || state != nil {
+		t.Errorf("expected no state and no error, got %+v, %v", state, err)
+	}
+}
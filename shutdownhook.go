@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ErrShutdownHookTimeout is returned when a shutdown hook didn't return within its timeout or the shutdown timeout
+var ErrShutdownHookTimeout = NewError(CodeDeadlineExceeded, "shutdown hook timed out")
+
+// ShutdownHook is called during graceful shutdown. The context is canceled when the timeout of the hook or the
+// shutdown timeout is exceeded; the shutdown then continues without waiting for the hook.
+type ShutdownHook func(ctx context.Context) error
+
+// ShutdownHookFunc adapts a hook without a context, e.g. svc.AddShutdownHook(service.ShutdownHookFunc(db.Close))
+func ShutdownHookFunc(hook func() error) ShutdownHook {
+	return func(context.Context) error {
+		return hook()
+	}
+}
+
+// ShutdownHookOption configures a shutdown hook added with AddShutdownHook
+type ShutdownHookOption func(*shutdownHookConfig)
+
+// shutdownHookConfig holds the configuration of a shutdown hook
+type shutdownHookConfig struct {
+	timeout time.Duration
+}
+
+// WithHookTimeout limits the duration of a shutdown hook, so a slow hook doesn't use up the shutdown timeout of
+// the hooks and servers after it
+func WithHookTimeout(timeout time.Duration) ShutdownHookOption {
+	return func(config *shutdownHookConfig) {
+		config.timeout = timeout
+	}
+}
+
+// withTimeout returns the hook limited to the timeout
+func (h ShutdownHook) withTimeout(timeout time.Duration) ShutdownHook {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return h.call(ctx)
+	}
+}
+
+// call runs the hook and stops waiting for it once the context is done, so hooks that ignore their context can't
+// block the shutdown. Panics are returned as errors.
+func (h ShutdownHook) call(ctx context.Context) error {
+	done := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- fmt.Errorf("shutdown hook panicked: %v", recovered) //nolint:err113
+			}
+		}()
+
+		done <- h(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrShutdownHookTimeout, context.Cause(ctx))
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrSidecarNotReady is returned by Start when the sidecar doesn't get ready within SIDECAR_READY_TIMEOUT
+var ErrSidecarNotReady = NewError(CodeUnavailable, "sidecar not ready")
+
+// sidecarPollInterval is the interval in which the readiness of the sidecar is polled
+const sidecarPollInterval = 500 * time.Millisecond
+
+// waitForSidecar waits until the readiness endpoint of the sidecar (SIDECAR_READY_URL) responds with a 2xx status,
+// so the service doesn't serve or call other services before the mesh proxy can route its traffic
+func (s *Service) waitForSidecar(ctx context.Context) error {
+	if s.Config.SidecarReadyURL == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Config.SidecarReadyTimeout)
+	defer cancel()
+
+	logger := s.Logger.With("subsystem", "sidecar")
+	logger.Info("waiting for sidecar", "url", s.Config.SidecarReadyURL, "timeout", s.Config.SidecarReadyTimeout)
+
+	start := time.Now()
+
+	ticker := time.NewTicker(sidecarPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := CheckEndpoint(ctx, s.Config.SidecarReadyURL)
+		if err == nil {
+			s.Metrics.builtinGaugeVec("sidecar_ready_wait_seconds", "Time waited for the sidecar to get ready on start").
+				WithLabelValues().Set(time.Since(start).Seconds())
+			logger.Info("sidecar ready", "duration", time.Since(start))
+
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w after %s: %w", ErrSidecarNotReady, time.Since(start).Round(time.Millisecond), err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopSidecar asks the sidecar to shut down (SIDECAR_SHUTDOWN_URL) once the service no longer needs it,
+// so the pod can terminate without the proxy dropping the last requests of the graceful shutdown
+func (s *Service) stopSidecar(ctx context.Context) {
+	if s.Config.SidecarShutdownURL == "" {
+		return
+	}
+
+	logger := LoggerFromContext(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Config.SidecarShutdownURL, nil)
+	if err != nil {
+		logger.Error("invalid sidecar shutdown URL", "url", s.Config.SidecarShutdownURL, "error", err)
+		return
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger.Error("failed to shut down sidecar", "url", s.Config.SidecarShutdownURL, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		logger.Error("failed to shut down sidecar", "url", s.Config.SidecarShutdownURL, "status", resp.StatusCode)
+		return
+	}
+
+	logger.Info("sidecar shut down", "url", s.Config.SidecarShutdownURL)
+}
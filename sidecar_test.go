@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestService_WaitForSidecar(t *testing.T) {
+	t.Parallel()
+
+	t.Run("waits until the sidecar is ready", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+
+		sidecar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if requests.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer sidecar.Close()
+
+		config := DefaultConfig()
+		config.SidecarReadyURL = sidecar.URL
+
+		if err := New("test-service", config).waitForSidecar(context.Background()); err != nil {
+			t.Fatalf("expected the sidecar to get ready, got %v", err)
+		}
+
+		if requests.Load() != 3 {
+			t.Errorf("expected 3 readiness requests, got %d", requests.Load())
+		}
+	})
+
+	t.Run("fails after the timeout", func(t *testing.T) {
+		t.Parallel()
+
+		sidecar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer sidecar.Close()
+
+		config := DefaultConfig()
+		config.SidecarReadyURL = sidecar.URL
+		config.SidecarReadyTimeout = 100 * time.Millisecond
+
+		if err := New("test-service", config).waitForSidecar(context.Background()); !errors.Is(err, ErrSidecarNotReady) {
+			t.Errorf("expected ErrSidecarNotReady, got %v", err)
+		}
+	})
+
+	t.Run("doesn't wait without a URL", func(t *testing.T) {
+		t.Parallel()
+
+		if err := New("test-service", nil).waitForSidecar(context.Background()); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
+
+func TestService_StopSidecar(t *testing.T) {
+	t.Parallel()
+
+	var method atomic.Value
+
+	sidecar := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		method.Store(r.Method + " " + r.URL.Path)
+	}))
+	defer sidecar.Close()
+
+	config := DefaultConfig()
+	config.SidecarShutdownURL = sidecar.URL + "/quitquitquit"
+
+	svc := New("test-service", config)
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if method.Load() != "POST /quitquitquit" {
+		t.Errorf("expected the sidecar to be shut down, got %v", method.Load())
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestSignatureMiddleware(t *testing.T) {
+	t.Parallel()
+
+	key := SigningKey{ID: "v1", Secret: []byte("secret")}
+	svc := New("signing_test", nil)
+
+	svc.Use(SignatureMiddleware(svc.Metrics, SignatureConfig{Keys: map[string][]byte{key.ID: key.Secret}}))
+	svc.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	})
+
+	server := svc.TestServer()
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name     string
+		key      *SigningKey
+		expected int
+	}{
+		{"signed request", &key, http.StatusOK},
+		{"unsigned request", nil, http.StatusUnauthorized},
+		{"wrong secret", &SigningKey{ID: "v1", Secret: []byte("other")}, http.StatusUnauthorized},
+		{"unknown key", &SigningKey{ID: "v2", Secret: []byte("secret")}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := NewClient(nil, ClientConfig{SigningKey: tt.key})
+
+			resp, err := client.Post(server.URL+"/internal?b=2&a=1", "text/plain", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+
+			if body, _ := io.ReadAll(resp.Body); tt.expected == http.StatusOK && string(body) != "payload" {
+				t.Errorf("expected body to be readable after verification, got %q", body)
+			}
+		})
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	t.Parallel()
+
+	key := SigningKey{ID: "v1", Secret: []byte("secret")}
+	keys := map[string][]byte{key.ID: key.Secret}
+
+	sign := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/internal", strings.NewReader("payload"))
+		if err := SignRequest(req, key); err != nil {
+			t.Fatalf("failed to sign request: %v", err)
+		}
+
+		return req
+	}
+
+	if err := VerifyRequest(sign(), keys, time.Minute); err != nil {
+		t.Errorf("expected valid signature, got %v", err)
+	}
+
+	tampered := sign()
+	tampered.Body = io.NopCloser(strings.NewReader("tampered"))
+
+	if err := VerifyRequest(tampered, keys, time.Minute); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected ErrSignatureInvalid for a tampered body, got %v", err)
+	}
+
+	expired := sign()
+	expired.Header.Set(SignatureTimestampHeader, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+
+	if err := VerifyRequest(expired, keys, time.Minute); !errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("expected ErrSignatureExpired, got %v", err)
+	}
+
+	otherHost := sign()
+	otherHost.Host = "other.example.com"
+
+	if err := VerifyRequest(otherHost, keys, time.Minute); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected ErrSignatureInvalid for another host, got %v", err)
+	}
+
+	otherKey := sign()
+	otherKey.Header.Set(SignatureKeyIDHeader, "v2")
+
+	if err := VerifyRequest(otherKey, map[string][]byte{"v1": key.Secret, "v2": key.Secret}, time.Minute); !errors.Is(err,
+		ErrSignatureInvalid) {
+		t.Errorf("expected ErrSignatureInvalid for a swapped key ID, got %v", err)
+	}
+
+	// Unknown keys are rejected before the body is read
+	unknown := sign()
+	unknown.Header.Set(SignatureKeyIDHeader, "unknown")
+	unknown.Body = io.NopCloser(iotest.ErrReader(errors.New("body read"))) //nolint:err113
+
+	if err := VerifyRequest(unknown, keys, time.Minute); !errors.Is(err, ErrSignatureKey) {
+		t.Errorf("expected ErrSignatureKey, got %v", err)
+	}
+
+	large := httptest.NewRequest(http.MethodPost, "/internal", strings.NewReader(strings.Repeat("x", 100)))
+	if err := SignRequest(large, key); err != nil {
+		t.Fatalf("failed to sign request: %v", err)
+	}
+
+	if err := verifyRequest(large, keys, time.Minute, 10); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected ErrSignatureInvalid for a body over the limit, got %v", err)
+	}
+}
+
+func TestSignatureMiddleware_NilMetrics(t *testing.T) {
+	t.Parallel()
+
+	handler := SignatureMiddleware(nil, SignatureConfig{Keys: map[string][]byte{"v1": []byte("secret")}})(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without metrics, got %d", recorder.Code)
+	}
+}
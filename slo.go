@@ -0,0 +1,184 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SLO describes the service level objective of a route
+type SLO struct {
+	// LatencyTarget is the maximum duration of a good request, e.g. the p99 latency target.
+	// A zero value disables the latency objective.
+	LatencyTarget time.Duration
+	// Availability is the availability objective in percent, e.g. 99.9
+	Availability float64
+}
+
+// WithSLO declares a service level objective for a route.
+// Requests are counted as good when they complete within the latency target without a server error.
+// The resulting SLI metrics can be used for multi-window burn-rate alerting.
+func WithSLO(slo SLO) RouteOption {
+	return func(rt *route) {
+		rt.use(rt.service.slos.middleware(rt.pattern, slo))
+	}
+}
+
+// SLOStatus is the summary of a route SLO since the service started
+type SLOStatus struct {
+	Route                 string  `json:"route"`
+	LatencyTargetSeconds  float64 `json:"latency_target_seconds"`
+	AvailabilityObjective float64 `json:"availability_objective"`
+	Requests              uint64  `json:"requests"`
+	Good                  uint64  `json:"good"`
+	Bad                   uint64  `json:"bad"`
+	Availability          float64 `json:"availability"`
+	ErrorBudgetRemaining  float64 `json:"error_budget_remaining"`
+}
+
+// sloTracker keeps track of all route SLOs of a service
+type sloTracker struct {
+	metrics *MetricsCollector
+
+	mu     sync.RWMutex
+	routes map[string]*sloRoute
+}
+
+// sloRoute holds the SLO and the request counts of a single route
+type sloRoute struct {
+	route string
+	slo   SLO
+	good  atomic.Uint64
+	bad   atomic.Uint64
+}
+
+// newSLOTracker creates a new SLO tracker
+func newSLOTracker(metrics *MetricsCollector) *sloTracker {
+	return &sloTracker{
+		metrics: metrics,
+		routes:  make(map[string]*sloRoute),
+	}
+}
+
+// register adds a route SLO and exports its objectives as metrics
+func (t *sloTracker) register(pattern string, slo SLO) *sloRoute {
+	state := &sloRoute{route: pattern, slo: slo}
+
+	t.mu.Lock()
+	t.routes[pattern] = state
+	t.mu.Unlock()
+
+	t.metrics.builtinGaugeVec("slo_latency_target_seconds", "Latency target of the route SLO in seconds", "route").
+		WithLabelValues(pattern).Set(slo.LatencyTarget.Seconds())
+	t.metrics.builtinGaugeVec("slo_availability_objective", "Availability objective of the route SLO as a ratio", "route").
+		WithLabelValues(pattern).Set(slo.Availability / 100)
+
+	return state
+}
+
+// middleware returns a middleware that classifies requests as good or bad according to the SLO
+func (t *sloTracker) middleware(pattern string, slo SLO) Middleware {
+	state := t.register(pattern, slo)
+	requests := t.metrics.builtinCounterVec("slo_requests_total", "Total number of requests evaluated against the route SLO",
+		"route", "result")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			start := time.Now()
+
+			defer func() {
+				recovered := recover()
+
+				good := recovered == nil && wrapped.statusCode < http.StatusInternalServerError &&
+					(slo.LatencyTarget == 0 || time.Since(start) <= slo.LatencyTarget)
+
+				result := "bad"
+
+				switch {
+				case recovered == nil && ClientCanceled(r):
+					// Impatient clients don't count against the SLO
+					result = "canceled"
+				case good:
+					result = "good"
+
+					state.good.Add(1)
+				default:
+					state.bad.Add(1)
+				}
+
+				requests.WithLabelValues(pattern, result).Inc()
+
+				if recovered != nil {
+					panic(recovered)
+				}
+			}()
+
+			next.ServeHTTP(wrapped, r)
+		})
+	}
+}
+
+// status returns the summary of all route SLOs, sorted by route
+func (t *sloTracker) status() []SLOStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	statuses := make([]SLOStatus, 0, len(t.routes))
+
+	for _, state := range t.routes {
+		good := state.good.Load()
+		bad := state.bad.Load()
+		total := good + bad
+
+		status := SLOStatus{
+			Route:                 state.route,
+			LatencyTargetSeconds:  state.slo.LatencyTarget.Seconds(),
+			AvailabilityObjective: state.slo.Availability,
+			Requests:              total,
+			Good:                  good,
+			Bad:                   bad,
+			Availability:          100,
+			ErrorBudgetRemaining:  1,
+		}
+
+		if total > 0 {
+			errorRate := float64(bad) / float64(total)
+			status.Availability = (1 - errorRate) * 100
+
+			if budget := 1 - state.slo.Availability/100; budget > 0 {
+				status.ErrorBudgetRemaining = 1 - errorRate/budget
+			} else if bad > 0 {
+				status.ErrorBudgetRemaining = 0
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Route < statuses[j].Route
+	})
+
+	return statuses
+}
+
+// handler returns an HTTP handler that renders the SLO summary as JSON
+func (t *sloTracker) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"slos": t.status()})
+	}
+}
+
+// SLOStatus returns the summary of all route SLOs since the service started
+func (s *Service) SLOStatus() []SLOStatus {
+	return s.slos.status()
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithSLO(t *testing.T) {
+	t.Parallel()
+
+	svc := New("slo_test", nil)
+
+	svc.HandleFunc("/ok", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, WithSLO(SLO{LatencyTarget: time.Second, Availability: 99}))
+
+	svc.HandleFunc("/fail", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithSLO(SLO{LatencyTarget: time.Second, Availability: 99.9}))
+
+	svc.HandleFunc("/slow", func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}, WithSLO(SLO{LatencyTarget: time.Millisecond, Availability: 99.9}))
+
+	for _, path := range []string{"/ok", "/ok", "/fail", "/slow"} {
+		svc.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests canceled by the client don't count against the SLO
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil).WithContext(ctx))
+
+	requests := svc.Metrics.builtinCounterVec("slo_requests_total", "", "route", "result")
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("/fail", "canceled")); got != 1 {
+		t.Errorf("expected 1 canceled request for /fail, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("/ok", "good")); got != 2 {
+		t.Errorf("expected 2 good requests for /ok, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("/fail", "bad")); got != 1 {
+		t.Errorf("expected 1 bad request for /fail, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("/slow", "bad")); got != 1 {
+		t.Errorf("expected 1 bad request for /slow, got %v", got)
+	}
+
+	statuses := svc.SLOStatus()
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 SLOs, got %d", len(statuses))
+	}
+
+	for _, status := range statuses {
+		switch status.Route {
+		case "/ok":
+			if status.Availability != 100 || status.ErrorBudgetRemaining != 1 {
+				t.Errorf("unexpected status for /ok: %+v", status)
+			}
+		case "/fail":
+			if status.Availability != 0 || status.ErrorBudgetRemaining >= 0 {
+				t.Errorf("unexpected status for /fail: %+v", status)
+			}
+		}
+	}
+}
+
+func TestSLOHandler(t *testing.T) {
+	t.Parallel()
+
+	svc := New("slo_test", nil)
+
+	svc.HandleFunc("/panic", func(_ http.ResponseWriter, _ *http.Request) {
+		panic("boom")
+	}, WithSLO(SLO{Availability: 99.9}))
+
+	svc.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	recorder := httptest.NewRecorder()
+	svc.slos.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slo", nil))
+
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("expected JSON content type, got %s", recorder.Header().Get("Content-Type"))
+	}
+
+	var body struct {
+		SLOs []SLOStatus `json:"slos"`
+	}
+
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode SLO summary: %v", err)
+	}
+
+	if len(body.SLOs) != 1 || body.SLOs[0].Bad != 1 {
+		t.Errorf("expected one bad request for /panic, got %+v", body.SLOs)
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrSlowClient is returned when reading a request body that is transferred slower than the minimum rate
+var ErrSlowClient = NewError(CodeDeadlineExceeded, "request body transfer too slow")
+
+// MinTransferRateConfig holds configuration for the minimum request body transfer rate
+type MinTransferRateConfig struct {
+	// Rate is the minimum average transfer rate in bytes per second
+	Rate int64
+	// Grace is the time a client has before the rate is enforced, and the maximum time a single read may block.
+	// Defaults to 5s.
+	Grace time.Duration
+}
+
+// MinTransferRateMiddleware disconnects clients that send request bodies deliberately slowly (slowloris attacks).
+// Unlike a fixed READ_TIMEOUT, large uploads of fast clients are not cut off.
+// Body reads of slow clients fail with ErrSlowClient and the connection is closed after the response.
+// Disconnects are counted in {service_name}_slow_client_disconnects_total.
+func MinTransferRateMiddleware(metrics *MetricsCollector, config MinTransferRateConfig) Middleware {
+	if config.Grace <= 0 {
+		config.Grace = 5 * time.Second
+	}
+
+	disconnects := metrics.builtinCounterVec("slow_client_disconnects_total",
+		"Total number of clients disconnected for sending request bodies too slowly").WithLabelValues()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Rate <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := &minRateReader{
+				ReadCloser:  r.Body,
+				config:      config,
+				controller:  http.NewResponseController(w),
+				start:       time.Now(),
+				disconnects: disconnects,
+				onSlow: func() {
+					w.Header().Set("Connection", "close")
+					GetLogger(r).Warn("slow client disconnected", "route", RoutePattern(r), "client_ip", clientIP(r))
+				},
+			}
+			r.Body = body
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// minRateReader is a request body that fails when the client sends it slower than the minimum rate
+type minRateReader struct {
+	io.ReadCloser
+
+	config      MinTransferRateConfig
+	controller  *http.ResponseController
+	start       time.Time
+	read        int64
+	slow        bool
+	disconnects prometheus.Counter
+	onSlow      func()
+}
+
+// Read reads from the body with a read deadline and checks the average transfer rate
+func (m *minRateReader) Read(p []byte) (int, error) {
+	if m.slow {
+		return 0, ErrSlowClient
+	}
+
+	// Unsupported deadlines (e.g. in tests) only disable the blocking protection, the rate is still checked
+	_ = m.controller.SetReadDeadline(time.Now().Add(m.config.Grace))
+
+	n, err := m.ReadCloser.Read(p)
+	m.read += int64(n)
+
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, m.fail()
+	}
+
+	if elapsed := time.Since(m.start); err == nil && elapsed > m.config.Grace &&
+		float64(m.read)/elapsed.Seconds() < float64(m.config.Rate) {
+		return n, m.fail()
+	}
+
+	if err != nil {
+		// The body is complete, so the deadline mustn't affect the rest of the connection
+		_ = m.controller.SetReadDeadline(time.Time{})
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// fail marks the client as slow and returns ErrSlowClient
+func (m *minRateReader) fail() error {
+	if !m.slow {
+		m.slow = true
+		m.disconnects.Inc()
+		m.onSlow()
+	}
+
+	return ErrSlowClient
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMinTransferRateMiddleware(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("slow_client_test")
+
+	handler := MinTransferRateMiddleware(metrics, MinTransferRateConfig{
+		Rate:  1000,
+		Grace: 50 * time.Millisecond,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			if !errors.Is(err, ErrSlowClient) {
+				t.Errorf("expected ErrSlowClient, got %v", err)
+			}
+
+			http.Error(w, "Request Timeout", http.StatusRequestTimeout)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// A fast client is not affected
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 10000))))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected 200 for a fast client, got %d", recorder.Code)
+	}
+
+	// A client trickling the body byte by byte is disconnected after the grace period
+	reader, writer := io.Pipe()
+
+	go func() {
+		defer writer.Close()
+
+		for range 20 {
+			if _, err := writer.Write([]byte("a")); err != nil {
+				return
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", reader))
+	_ = reader.Close()
+
+	if recorder.Code != http.StatusRequestTimeout {
+		t.Errorf("expected 408 for a slow client, got %d", recorder.Code)
+	}
+
+	if recorder.Header().Get("Connection") != "close" {
+		t.Error("expected the connection of a slow client to be closed")
+	}
+
+	disconnects := metrics.builtinCounterVec("slow_client_disconnects_total", "").WithLabelValues()
+	if got := testutil.ToFloat64(disconnects); got != 1 {
+		t.Errorf("expected 1 slow client disconnect, got %v", got)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SmokeTestFlag is the command line flag that runs the smoke test in SmokeTestMain
+const SmokeTestFlag = "--smoke-test"
+
+// ErrSmokeTestFailed is returned by RunSmokeTest when the service doesn't get ready or a route fails
+var ErrSmokeTestFailed = NewError(CodeUnavailable, "smoke test failed")
+
+// smokeTestPollInterval is the interval in which the smoke test polls the readiness endpoint
+const smokeTestPollInterval = 100 * time.Millisecond
+
+// RunSmokeTest boots the service with its own wiring on ephemeral loopback ports, waits until it is ready, and sends
+// a GET request to each route of SMOKE_TEST_ROUTES and the given routes, expecting a status below 400.
+// The context limits the whole run, e.g. for a container HEALTHCHECK or a CI step.
+func (s *Service) RunSmokeTest(ctx context.Context, routes ...string) error {
+	if err := s.registerConfiguredHealthChecks(); err != nil {
+		return err
+	}
+
+	main := httptest.NewServer(s.handler())
+	defer main.Close()
+
+	internal := httptest.NewServer(s.metricsHandler())
+	defer internal.Close()
+
+	logger := s.Logger.With("subsystem", "smoke_test")
+	logger.Info("running smoke test", "addr", main.URL, "metrics_addr", internal.URL)
+
+	if err := s.waitReady(ctx, internal.URL+s.Config.ReadinessPath); err != nil {
+		return err
+	}
+
+	var failures []string
+
+	for _, route := range append(slices.Clone(s.Config.SmokeTestRoutes), routes...) {
+		start := time.Now()
+
+		status, err := smokeTestRequest(ctx, main.URL+route, nil)
+		if err == nil && status >= http.StatusBadRequest {
+			err = fmt.Errorf("status %d", status)
+		}
+
+		if err != nil {
+			logger.Error("smoke test route failed", "route", route, "error", err)
+			failures = append(failures, route+": "+err.Error())
+
+			continue
+		}
+
+		logger.Info("smoke test route passed", "route", route, "status", status, "duration", time.Since(start))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%w: %s", ErrSmokeTestFailed, strings.Join(failures, "; "))
+	}
+
+	logger.Info("smoke test passed")
+
+	return nil
+}
+
+// SmokeTestMain runs the smoke test within SMOKE_TEST_TIMEOUT and exits with status 0 or 1 if the command line
+// contains --smoke-test. Otherwise, it returns, so it can be called in main before Start:
+//
+//	svc.SmokeTestMain()
+//	svc.Start()
+func (s *Service) SmokeTestMain(routes ...string) {
+	if !slices.Contains(os.Args[1:], SmokeTestFlag) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Config.SmokeTestTimeout)
+
+	err := s.RunSmokeTest(ctx, routes...)
+
+	cancel()
+
+	if err != nil {
+		s.Logger.Error("smoke test failed", "error", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// waitReady polls the readiness endpoint until it passes. Credentials of a protected endpoint are sent along.
+func (s *Service) waitReady(ctx context.Context, url string) error {
+	auth := s.Config.ReadinessAuth
+
+	setAuth := func(req *http.Request) {
+		if user, password, ok := strings.Cut(auth.BasicAuth, ":"); ok {
+			req.SetBasicAuth(user, password)
+		}
+
+		if auth.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+auth.Token)
+		}
+	}
+
+	ticker := time.NewTicker(smokeTestPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := smokeTestRequest(ctx, url, setAuth)
+		if err == nil && status == http.StatusOK {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: service not ready (status %d): %w", ErrSmokeTestFailed, status, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// smokeTestRequest sends a GET request and returns the response status
+func smokeTestRequest(ctx context.Context, url string, prepare func(req *http.Request)) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if prepare != nil {
+		prepare(req)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
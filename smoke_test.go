@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestService_RunSmokeTest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("passes when ready and all routes succeed", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultConfig()
+		config.SmokeTestRoutes = []string{"/hello"}
+
+		svc := New("test-service", config)
+		svc.HandleFunc("GET /hello", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		})
+		svc.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := svc.RunSmokeTest(ctx, "/users/1"); err != nil {
+			t.Errorf("expected the smoke test to pass, got %v", err)
+		}
+	})
+
+	t.Run("fails on failing routes", func(t *testing.T) {
+		t.Parallel()
+
+		svc := New("test-service", nil)
+		svc.HandleFunc("GET /broken", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := svc.RunSmokeTest(ctx, "/broken", "/missing")
+		if !errors.Is(err, ErrSmokeTestFailed) {
+			t.Fatalf("expected ErrSmokeTestFailed, got %v", err)
+		}
+
+		if !strings.Contains(err.Error(), "/broken: status 500") || !strings.Contains(err.Error(), "/missing: status 404") {
+			t.Errorf("expected both failing routes in the error, got %v", err)
+		}
+	})
+
+	t.Run("fails when the service doesn't get ready", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultConfig()
+		config.ReadinessAuth = EndpointAuth{Token: "secret"}
+
+		svc := New("test-service", config)
+		svc.HealthChecker.AddReadinessGate("never", func(_ context.Context) error {
+			return errors.New("not warm") //nolint:err113
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		if err := svc.RunSmokeTest(ctx); !errors.Is(err, ErrSmokeTestFailed) {
+			t.Errorf("expected ErrSmokeTestFailed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrUnknownServerName is returned when no certificate matches the server name of a TLS handshake
+var ErrUnknownServerName = NewError(CodeNotFound, "unknown TLS server name")
+
+// SNIHook selects the certificate for a TLS handshake by its server name (SNI).
+// Returning nil falls back to the loaded certificates, returning an error rejects the handshake.
+type SNIHook func(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+
+// AddCertificate loads an additional certificate, so one service can serve multiple domains behind a single listener.
+// The certificate matching the server name of a handshake is presented. It must be called before Start.
+func (s *Service) AddCertificate(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	s.certificates = append(s.certificates, cert)
+
+	return nil
+}
+
+// SetSNIHook sets a hook that selects the certificate of each TLS handshake, e.g. from a certificate store.
+// It must be called before Start.
+func (s *Service) SetSNIHook(hook SNIHook) {
+	s.sniHook = hook
+}
+
+// tlsEnabled reports whether the main server serves TLS
+func (s *Service) tlsEnabled() bool {
+	return s.Config.TLSCertFile != "" || len(s.certificates) > 0 || s.sniHook != nil
+}
+
+// certificateSelector selects the certificate of a TLS handshake
+type certificateSelector struct {
+	hook         SNIHook
+	certificates []func() *tls.Certificate
+	strict       bool
+	unknown      prometheus.Counter
+}
+
+// getCertificate returns the certificate of the hook, or the first loaded certificate matching the server name.
+// Without a match, the first certificate is presented, unless strict SNI is enabled.
+func (c *certificateSelector) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if c.hook != nil {
+		cert, err := c.hook(hello)
+		if err != nil || cert != nil {
+			return cert, err //nolint:wrapcheck
+		}
+	}
+
+	for _, get := range c.certificates {
+		cert := get()
+		if hello.ServerName != "" && cert.Leaf != nil && cert.Leaf.VerifyHostname(hello.ServerName) == nil {
+			return cert, nil
+		}
+	}
+
+	if c.strict || len(c.certificates) == 0 {
+		c.unknown.Inc()
+		return nil, fmt.Errorf("%w: %q", ErrUnknownServerName, hello.ServerName)
+	}
+
+	return c.certificates[0](), nil
+}
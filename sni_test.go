@@ -0,0 +1,93 @@
+package service
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSNICertificateSelection(t *testing.T) {
+	t.Parallel()
+
+	defaultCert, defaultKey := writeTestCertificate(t, time.Now().Add(time.Hour), "default.example")
+	otherCert, otherKey := writeTestCertificate(t, time.Now().Add(time.Hour), "other.example")
+	hookCertFile, hookKeyFile := writeTestCertificate(t, time.Now().Add(time.Hour), "hook.example")
+
+	hookCert, err := tls.LoadX509KeyPair(hookCertFile, hookKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errBlocked := errors.New("blocked")
+
+	newService := func(strict bool) *Service {
+		config := DefaultConfig()
+		config.TLSCertFile = defaultCert
+		config.TLSKeyFile = defaultKey
+		config.TLSStrictSNI = strict
+
+		svc := New("sni_test", config)
+		t.Cleanup(svc.cancel)
+
+		if err := svc.AddCertificate(otherCert, otherKey); err != nil {
+			t.Fatal(err)
+		}
+
+		svc.SetSNIHook(func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			switch hello.ServerName {
+			case "hook.example":
+				return &hookCert, nil
+			case "blocked.example":
+				return nil, errBlocked
+			}
+
+			return nil, nil //nolint:nilnil
+		})
+
+		return svc
+	}
+
+	tests := []struct {
+		name       string
+		strict     bool
+		serverName string
+		want       string
+		wantErr    error
+	}{
+		{"default", false, "default.example", "default.example", nil},
+		{"additional certificate", false, "other.example", "other.example", nil},
+		{"hook", false, "hook.example", "hook.example", nil},
+		{"hook rejects", false, "blocked.example", "", errBlocked},
+		{"unknown falls back", false, "unknown.example", "default.example", nil},
+		{"unknown rejected in strict mode", true, "unknown.example", "", ErrUnknownServerName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			config, err := newService(tt.strict).newTLSConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cert, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: tt.serverName})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := cert.Leaf.Subject.CommonName; got != tt.want {
+				t.Errorf("expected certificate %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
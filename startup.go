@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// subsystems returns the names of the enabled optional subsystems
+func (s *Service) subsystems() []string {
+	enabled := []string{}
+
+	if s.Config.DevMode {
+		enabled = append(enabled, "dev_mode")
+	}
+
+	if s.HealthChecker != nil {
+		enabled = append(enabled, "health")
+	}
+
+	if s.tlsEnabled() {
+		enabled = append(enabled, "tls")
+	}
+
+	if s.LoadShedder != nil {
+		enabled = append(enabled, "load_shedding")
+	}
+
+	if s.ConcurrencyLimiter != nil {
+		enabled = append(enabled, "concurrency_limit")
+	}
+
+	if len(s.Config.PropagatedHeaders) > 0 {
+		enabled = append(enabled, "header_propagation")
+	}
+
+	if s.Config.PreShutdownDelay > 0 {
+		enabled = append(enabled, "drain")
+	}
+
+	if s.TokenSource != nil {
+		enabled = append(enabled, "oauth")
+	}
+
+	if s.Config.MetricsPushURL != "" {
+		enabled = append(enabled, "metrics_push")
+	}
+
+	if s.Config.DebugEndpoints {
+		enabled = append(enabled, "debug_endpoints")
+	}
+
+	return enabled
+}
+
+// startupAttrs returns the attributes of the startup log record
+func (s *Service) startupAttrs() []any {
+	healthChecks := 0
+	if s.HealthChecker != nil {
+		healthChecks = s.HealthChecker.Count()
+	}
+
+	memoryLimit := "unlimited"
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		memoryLimit = formatBytes(limit)
+	}
+
+	return []any{
+		"name", s.Name,
+		"version", s.Config.Version,
+		"addr", s.Config.Addr,
+		"metrics_addr", s.Config.MetricsAddr,
+		"subsystems", s.subsystems(),
+		"plugins", s.plugins,
+		"routes", len(s.routes),
+		"internal_routes", len(s.internalRoutes),
+		"health_checks", healthChecks,
+		slog.Group("runtime",
+			"go_version", runtime.Version(),
+			"gomaxprocs", runtime.GOMAXPROCS(0),
+			"gomemlimit", memoryLimit,
+		),
+	}
+}
+
+// logStartup emits a single log record summarizing the service configuration,
+// so misconfigurations are obvious at a glance. In dev mode, a banner with the route table is printed as well.
+func (s *Service) logStartup() {
+	if s.Config.DevMode {
+		s.printBanner(os.Stdout)
+	}
+
+	s.Logger.Info("starting service", s.startupAttrs()...)
+}
+
+// formatBytes formats a number of bytes in binary units, e.g. "512MiB"
+func formatBytes(bytes int64) string {
+	const unit = 1024
+
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+
+	value, exponent := float64(bytes)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+
+	return fmt.Sprintf("%.4g%ciB", value, "KMGTP"[exponent])
+}
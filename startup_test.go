@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLogStartup(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	config := DefaultConfig()
+	config.Logger = slog.New(slog.NewTextHandler(&output, nil))
+	config.MaxConcurrentRequests = 10
+
+	svc := New("startup_test", config)
+	svc.HandleFunc("/a", func(http.ResponseWriter, *http.Request) {})
+	svc.HandleFunc("/b", func(http.ResponseWriter, *http.Request) {})
+
+	svc.logStartup()
+
+	for _, expected := range []string{
+		"msg=\"starting service\"",
+		"version=v1.0.0",
+		"addr=:8080",
+		"routes=2",
+		"health_checks=0",
+		"subsystems=\"[health concurrency_limit]\"",
+		"runtime.gomaxprocs=",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected startup record to contain %q, got %s", expected, output.String())
+		}
+	}
+
+	if lines := strings.Count(output.String(), "\n"); lines != 1 {
+		t.Errorf("expected a single log record, got %d", lines)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[int64]string{
+		512:        "512B",
+		2048:       "2KiB",
+		512 << 20:  "512MiB",
+		3 << 30:    "3GiB",
+		1536 << 10: "1.5MiB",
+	}
+
+	for input, expected := range tests {
+		if got := formatBytes(input); got != expected {
+			t.Errorf("formatBytes(%d) = %q, expected %q", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// ErrTaskPanic is returned when a background task panicked
+var ErrTaskPanic = NewError(CodeInternal, "background task panicked")
+
+// TaskOption configures a background task started with Go
+type TaskOption func(*taskConfig)
+
+// taskConfig holds the configuration of a background task
+type taskConfig struct {
+	restart    bool
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	jitter     float64
+	catchUp    CatchUpPolicy
+}
+
+// WithRestart restarts a background task with exponential backoff when it fails or panics,
+// until the service shuts down
+func WithRestart(minBackoff, maxBackoff time.Duration) TaskOption {
+	return func(config *taskConfig) {
+		config.restart = true
+		config.minBackoff = minBackoff
+		config.maxBackoff = max(minBackoff, maxBackoff)
+	}
+}
+
+// WithJitter delays each run of an interval task by a random fraction of the interval (e.g. 0.1 for up to 10%),
+// so replicas don't hit shared dependencies at the same time
+func WithJitter(fraction float64) TaskOption {
+	return func(config *taskConfig) {
+		config.jitter = fraction
+	}
+}
+
+// newTaskConfig applies the task options
+func newTaskConfig(opts []TaskOption) *taskConfig {
+	config := &taskConfig{catchUp: CatchUpSkip}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return config
+}
+
+// Go runs a function in a background goroutine. Panics are recovered and logged with their stack trace,
+// and counted in {service_name}_background_panics_total{task}.
+// The context carries a logger with the task name and is canceled when the service shuts down.
+func (s *Service) Go(name string, fn func(ctx context.Context) error, opts ...TaskOption) {
+	go s.superviseTask(s.taskContext(name), name, fn, newTaskConfig(opts))
+}
+
+// taskContext returns the lifecycle context with a logger annotated with the task name
+func (s *Service) taskContext(name string) context.Context {
+	return ContextWithLogger(s.ctx, s.Logger.With("subsystem", "task", "task", name))
+}
+
+// superviseTask runs a background task and restarts it if configured
+func (s *Service) superviseTask(ctx context.Context, name string, fn func(ctx context.Context) error, config *taskConfig) {
+	logger := LoggerFromContext(ctx)
+	backoff := config.minBackoff
+
+	for {
+		err := s.runTask(ctx, name, fn)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("background task failed", "error", err)
+		}
+
+		if !config.restart || ctx.Err() != nil {
+			return
+		}
+
+		logger.Info("restarting background task", "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if err == nil {
+			backoff = config.minBackoff
+		} else {
+			backoff = min(backoff*2, config.maxBackoff)
+		}
+	}
+}
+
+// runTask runs a background task once and converts a panic into an error
+func (s *Service) runTask(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+
+		LoggerFromContext(ctx).Error("panic recovered in background task", "error", recovered, "stack", string(debug.Stack()))
+		s.Metrics.builtinCounterVec("background_panics_total", "Total number of panics recovered in background tasks", "task").
+			WithLabelValues(name).Inc()
+
+		err = fmt.Errorf("%w: %v", ErrTaskPanic, recovered)
+	}()
+
+	return fn(ctx)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGo_Panic(t *testing.T) {
+	t.Parallel()
+
+	svc := New("task_panic_test", nil)
+	done := make(chan struct{})
+
+	svc.Go("crashing", func(context.Context) error {
+		defer close(done)
+
+		panic("boom")
+	})
+
+	<-done
+
+	panics := svc.Metrics.builtinCounterVec("background_panics_total", "", "task").WithLabelValues("crashing")
+
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(panics) != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := testutil.ToFloat64(panics); got != 1 {
+		t.Errorf("expected 1 recorded panic, got %v", got)
+	}
+}
+
+func TestGo_Restart(t *testing.T) {
+	t.Parallel()
+
+	svc := New("task_restart_test", nil)
+
+	var runs atomic.Int32
+
+	stopped := make(chan struct{})
+
+	svc.Go("flaky", func(ctx context.Context) error {
+		if runs.Add(1) < 3 {
+			return errors.New("temporary failure") //nolint:err113
+		}
+
+		<-ctx.Done()
+		close(stopped)
+
+		return nil
+	}, WithRestart(time.Millisecond, 10*time.Millisecond))
+
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if count := runs.Load(); count != 3 {
+		t.Fatalf("expected the task to be restarted twice, got %d runs", count)
+	}
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("expected the task context to be canceled on shutdown")
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TenantKey is the context key for the tenant identifier
+const TenantKey ContextKey = "tenant"
+
+// overflowLabel is the label value used once a cardinality guard is exhausted
+const overflowLabel = "other"
+
+// TenantExtractor extracts the tenant identifier from a request
+type TenantExtractor func(r *http.Request) (string, bool)
+
+// TenantConfig holds configuration for the tenant middleware
+type TenantConfig struct {
+	// Extractor extracts the tenant identifier from a request
+	Extractor TenantExtractor
+	// Required rejects requests without a tenant identifier with 400
+	Required bool
+	// MetricsLabel records requests per tenant in {service_name}_tenant_requests_total
+	MetricsLabel bool
+	// MaxTenants limits the number of distinct tenant label values; further tenants are recorded as "other".
+	// Defaults to 100.
+	MaxTenants int
+}
+
+// HeaderTenantExtractor extracts the tenant identifier from a request header
+func HeaderTenantExtractor(header string) TenantExtractor {
+	return func(r *http.Request) (string, bool) {
+		tenant := strings.TrimSpace(r.Header.Get(header))
+		return tenant, tenant != ""
+	}
+}
+
+// SubdomainTenantExtractor extracts the tenant identifier from the subdomain of the base domain,
+// e.g. "acme" from "acme.example.com" with the base domain "example.com"
+func SubdomainTenantExtractor(baseDomain string) TenantExtractor {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+
+	return func(r *http.Request) (string, bool) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		tenant, ok := strings.CutSuffix(strings.ToLower(host), suffix)
+		if !ok || tenant == "" || strings.Contains(tenant, ".") {
+			return "", false
+		}
+
+		return tenant, true
+	}
+}
+
+// TenantMiddleware extracts the tenant identifier of each request, stores it in the context,
+// and adds it to the request logger
+func TenantMiddleware(metrics *MetricsCollector, config TenantConfig) Middleware {
+	var (
+		requests *prometheus.CounterVec
+		guard    *cardinalityGuard
+	)
+
+	if config.MetricsLabel && metrics != nil {
+		requests = metrics.builtinCounterVec("tenant_requests_total", "Total number of HTTP requests per tenant", "tenant")
+		guard = newCardinalityGuard(config.MaxTenants)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, ok := config.Extractor(r)
+			if !ok {
+				if config.Required {
+					http.Error(w, "Missing tenant", http.StatusBadRequest)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), TenantKey, tenant)
+			ctx = context.WithValue(ctx, LoggerKey, GetLogger(r).With("tenant", tenant))
+			r = r.WithContext(ctx)
+
+			if requests != nil {
+				requests.WithLabelValues(guard.value(tenant)).Inc()
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TenantID returns the tenant identifier of a request, or an empty string if there is none
+func TenantID(r *http.Request) string {
+	return TenantFromContext(r.Context())
+}
+
+// TenantFromContext returns the tenant identifier of a context, or an empty string if there is none,
+// e.g. for a context label of custom metrics (see MetricsCollector.AddContextLabel)
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(TenantKey).(string)
+	return tenant
+}
+
+// cardinalityGuard limits the number of distinct values of a metric label
+type cardinalityGuard struct {
+	limit int
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// newCardinalityGuard creates a new cardinality guard (a limit <= 0 defaults to 100)
+func newCardinalityGuard(limit int) *cardinalityGuard {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	return &cardinalityGuard{
+		limit: limit,
+		seen:  make(map[string]struct{}),
+	}
+}
+
+// value returns the label value, or "other" once the limit of distinct values is reached
+func (g *cardinalityGuard) value(value string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.seen[value]; ok {
+		return value
+	}
+
+	if len(g.seen) >= g.limit {
+		return overflowLabel
+	}
+
+	g.seen[value] = struct{}{}
+
+	return value
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTenantExtractors(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", " acme ")
+
+	if tenant, ok := HeaderTenantExtractor("X-Tenant-ID")(req); !ok || tenant != "acme" {
+		t.Errorf("expected tenant 'acme' from header, got %q", tenant)
+	}
+
+	extractor := SubdomainTenantExtractor("example.com")
+
+	for host, want := range map[string]string{
+		"acme.example.com":      "acme",
+		"ACME.example.com:8080": "acme",
+		"example.com":           "",
+		"a.b.example.com":       "",
+		"acme.example.org":      "",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+
+		tenant, ok := extractor(req)
+		if tenant != want || ok != (want != "") {
+			t.Errorf("host %q: expected tenant %q, got %q (ok=%v)", host, want, tenant, ok)
+		}
+	}
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	t.Parallel()
+
+	svc := New("tenant_test", nil)
+	svc.Use(TenantMiddleware(svc.Metrics, TenantConfig{
+		Extractor:    HeaderTenantExtractor("X-Tenant-ID"),
+		Required:     true,
+		MetricsLabel: true,
+		MaxTenants:   1,
+	}))
+
+	svc.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(TenantID(r)))
+	})
+
+	for _, tenant := range []string{"acme", "acme", "globex"} {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("X-Tenant-ID", tenant)
+
+		recorder := httptest.NewRecorder()
+		svc.mux.ServeHTTP(recorder, req)
+
+		if recorder.Body.String() != tenant {
+			t.Errorf("expected tenant %q in context, got %q", tenant, recorder.Body.String())
+		}
+	}
+
+	requests := svc.Metrics.builtinCounterVec("tenant_requests_total", "", "tenant")
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("acme")); got != 2 {
+		t.Errorf("expected 2 requests for acme, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(requests.WithLabelValues(overflowLabel)); got != 1 {
+		t.Errorf("expected 1 request for other tenants, got %v", got)
+	}
+
+	recorder := httptest.NewRecorder()
+	svc.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without tenant, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutConfig holds configuration for the timeout middleware
+type TimeoutConfig struct {
+	// Timeout is the deadline of requests of routes without their own timeout (0 disables it)
+	Timeout time.Duration
+	// Status is the status of timed out requests. Defaults to 504.
+	Status int
+}
+
+// WithTimeout sets the request deadline of a single route, overriding the timeout of its group and REQUEST_TIMEOUT.
+// A timeout of 0 disables the deadline, e.g. for streaming routes.
+func WithTimeout(timeout time.Duration) RouteOption {
+	return func(rt *route) {
+		rt.timeout = &timeout
+	}
+}
+
+// routeTimeout returns the timeout of the route of a request or its innermost group, and whether there is one
+func routeTimeout(r *http.Request) (time.Duration, bool) {
+	rt := getRoute(r)
+	if rt == nil {
+		return 0, false
+	}
+
+	if rt.timeout != nil {
+		return *rt.timeout, true
+	}
+
+	for group := rt.group; group != nil; group = group.parent {
+		if group.timeout != nil {
+			return *group.timeout, true
+		}
+	}
+
+	return 0, false
+}
+
+// TimeoutMiddleware enforces a deadline per request (see WithTimeout and RouteGroup.SetTimeout for per-route
+// timeouts). The request context is canceled at the deadline, so handlers and their outbound calls stop working,
+// and requests that haven't written a response are answered with the configured status by the error handler of the
+// service (see WriteError).
+// Writes of the handler after the deadline fail with http.ErrHandlerTimeout. Timed out requests are counted in
+// {service_name}_request_timeouts_total{route}. Requests canceled by the client are neither answered nor counted.
+func TimeoutMiddleware(metrics *MetricsCollector, config TimeoutConfig) Middleware {
+	if config.Status == 0 {
+		config.Status = http.StatusGatewayTimeout
+	}
+
+	timeouts := metrics.builtinCounterVec("request_timeouts_total",
+		"Total number of requests that exceeded their deadline", "route")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := config.Timeout
+			if override, ok := routeTimeout(r); ok {
+				timeout = override
+			}
+
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			r = r.WithContext(ctx)
+			tw := &timeoutWriter{w: w, header: make(http.Header), ctx: ctx}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						panicked <- recovered
+					}
+				}()
+
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case recovered := <-panicked:
+				// Re-panic in the request goroutine, so the recovery middleware handles it
+				panic(recovered)
+			case <-done:
+			case <-ctx.Done():
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					// The client canceled the request, so nobody waits for a response and the request didn't time out
+					tw.stop(ctx.Err())
+					return
+				}
+
+				if !tw.stop(http.ErrHandlerTimeout) {
+					// The handler already started the response, which can't be replaced anymore
+					return
+				}
+
+				timeouts.WithLabelValues(RoutePattern(r)).Inc()
+				GetLogger(r).Warn("request timed out", "route", RoutePattern(r), "path", r.URL.Path, "timeout", timeout)
+
+				renderError(w, r, config.Status, NewError(CodeDeadlineExceeded, "request timed out after "+timeout.String()))
+			}
+		})
+	}
+}
+
+// timeoutWriter passes the response of a handler through until the request times out and discards it afterwards.
+// The handler gets its own header map, so it can't race with the timeout response.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+	ctx    context.Context //nolint:containedctx
+
+	mu          sync.Mutex
+	err         error
+	wroteHeader bool
+}
+
+// Header returns the header map of the handler
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// WriteHeader sends the headers of the handler, unless the request timed out
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.writeHeader(code)
+}
+
+// writeHeader sends the headers once. It must be called with the lock held.
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.stopped() != nil || tw.wroteHeader {
+		return
+	}
+
+	tw.wroteHeader = true
+	maps.Copy(tw.w.Header(), tw.header)
+	tw.w.WriteHeader(code)
+}
+
+// Write writes the body, or fails with http.ErrHandlerTimeout once the request timed out
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if err := tw.stopped(); err != nil {
+		return 0, err
+	}
+
+	tw.writeHeader(http.StatusOK)
+
+	return tw.w.Write(b) //nolint:wrapcheck
+}
+
+// Flush flushes the response, unless the request timed out
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if flusher, ok := tw.w.(http.Flusher); ok && tw.stopped() == nil {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController, e.g. to extend the write deadline
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// stopped returns the error of writes once the response of the handler is stopped: http.ErrHandlerTimeout after the
+// deadline, or the error of the context once the client canceled the request. The handler may see its context
+// expire before the middleware handles the timeout, so the deadline of the context counts as well. It must be called
+// with the lock held.
+func (tw *timeoutWriter) stopped() error {
+	if tw.err != nil {
+		return tw.err
+	}
+
+	if errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		return http.ErrHandlerTimeout
+	}
+
+	return nil
+}
+
+// stop stops the response of the handler, so its writes fail with err, and reports whether the timeout response
+// can still be sent
+func (tw *timeoutWriter) stop(err error) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.err = err
+
+	return !tw.wroteHeader
+}
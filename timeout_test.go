@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.RequestTimeout = 20 * time.Millisecond
+	svc := New("timeout_test", config)
+
+	canceled := make(chan error, 1)
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			canceled <- r.Context().Err()
+		case <-time.After(200 * time.Millisecond):
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+
+	svc.HandleFunc("GET /slow", slow)
+	svc.HandleFunc("GET /stream", slow, WithTimeout(0))
+	svc.HandleFunc("GET /patient", slow, WithTimeout(time.Second))
+	svc.HandleFunc("GET /started", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		<-r.Context().Done()
+
+		if _, err := w.Write([]byte("late")); !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Errorf("expected writes after the deadline to fail, got %v", err)
+		}
+	})
+
+	reports := svc.Group("/reports")
+	reports.SetTimeout(time.Second)
+	reports.HandleFunc("GET /slow", slow)
+
+	handler := svc.handler()
+
+	request := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return rec
+	}
+
+	rec := request("/slow")
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", rec.Code)
+	}
+
+	var problem Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil || problem.Code != CodeDeadlineExceeded.String() ||
+		rec.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("expected a problem details body, got %+v (%v)", problem, err)
+	}
+
+	select {
+	case err := <-canceled:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the deadline to be exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the request context to be canceled")
+	}
+
+	for _, path := range []string{"/stream", "/patient", "/reports/slow"} {
+		if code := request(path).Code; code != http.StatusNoContent {
+			t.Errorf("expected %s to outlive the default timeout, got %d", path, code)
+		}
+	}
+
+	if code := request("/started").Code; code != http.StatusAccepted {
+		t.Errorf("expected a started response to be kept, got %d", code)
+	}
+
+	timeouts := svc.Metrics.builtinCounterVec("request_timeouts_total", "", "route")
+	if got := testutil.ToFloat64(timeouts.WithLabelValues("GET /slow")); got != 1 {
+		t.Errorf("expected 1 timeout, got %v", got)
+	}
+}
+
+func TestTimeoutMiddleware_ClientCanceled(t *testing.T) {
+	t.Parallel()
+
+	svc := New("timeout_cancel_test", nil)
+
+	written := make(chan error, 1)
+	svc.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+
+		_, err := w.Write([]byte("late"))
+		written <- err
+	}, WithTimeout(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	rec := httptest.NewRecorder()
+	svc.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") == ProblemContentType {
+		t.Errorf("expected no response to a canceled request, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if err := <-written; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected writes after the cancellation to fail with context.Canceled, got %v", err)
+	}
+
+	timeouts := svc.Metrics.builtinCounterVec("request_timeouts_total", "", "route")
+	if got := testutil.ToFloat64(timeouts.WithLabelValues("GET /slow")); got != 0 {
+		t.Errorf("expected a canceled request not to count as timeout, got %v", got)
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrInvalidSessionTicketKey is returned when a TLS session ticket key file contains an invalid key
+var ErrInvalidSessionTicketKey = NewError(CodeInvalidArgument, "invalid TLS session ticket key")
+
+// maxSessionTicketKeys is the number of generated session ticket keys that are kept,
+// so sessions can still be resumed right after a rotation
+const maxSessionTicketKeys = 3
+
+// newTLSConfig loads the certificates of the main server and starts the session ticket key rotation and OCSP stapling
+func (s *Service) newTLSConfig() (*tls.Config, error) {
+	certificates := s.certificates
+
+	if s.Config.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.Config.TLSCertFile, s.Config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+
+		certificates = append([]tls.Certificate{cert}, certificates...)
+	}
+
+	selector := &certificateSelector{
+		hook:   s.sniHook,
+		strict: s.Config.TLSStrictSNI,
+		unknown: s.Metrics.builtinCounterVec("tls_unknown_server_names_total",
+			"Total number of TLS handshakes rejected for an unknown server name").WithLabelValues(),
+	}
+
+	for _, cert := range certificates {
+		s.recordCertificateExpiry(cert.Leaf)
+		selector.certificates = append(selector.certificates, s.servedCertificate(cert))
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: selector.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	rotator := newSessionTicketRotator(config, s.Metrics, s.Config.TLSSessionTicketKeyFile)
+	if err := rotator.rotate(s.subsystemContext("tls")); err != nil {
+		return nil, err
+	}
+
+	if s.Config.TLSSessionTicketRotation > 0 {
+		s.Every("tls_session_ticket_rotation", s.Config.TLSSessionTicketRotation, rotator.rotate)
+	}
+
+	return config, nil
+}
+
+// sessionTicketRotator rotates the session ticket keys of a TLS config, so a leaked key
+// can't decrypt sessions from long before (forward secrecy)
+type sessionTicketRotator struct {
+	config    *tls.Config
+	keyFile   string
+	rotations *prometheus.CounterVec
+
+	mu   sync.Mutex
+	keys [][32]byte
+}
+
+// newSessionTicketRotator creates a rotator that generates keys, or reads them from the key file if set
+func newSessionTicketRotator(config *tls.Config, metrics *MetricsCollector, keyFile string) *sessionTicketRotator {
+	return &sessionTicketRotator{
+		config:  config,
+		keyFile: keyFile,
+		rotations: metrics.builtinCounterVec("tls_session_ticket_rotations_total",
+			"Total number of TLS session ticket key rotations by key source", "source"),
+	}
+}
+
+// rotate generates a new session ticket key, or reloads the key file if it changed
+func (r *sessionTicketRotator) rotate(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	source := "generated"
+
+	if r.keyFile != "" {
+		source = "file"
+
+		keys, err := readSessionTicketKeys(r.keyFile)
+		if err != nil {
+			return err
+		}
+
+		if slices.Equal(keys, r.keys) {
+			return nil
+		}
+
+		r.keys = keys
+	} else {
+		var key [32]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return fmt.Errorf("failed to generate session ticket key: %w", err)
+		}
+
+		r.keys = append([][32]byte{key}, r.keys[:min(len(r.keys), maxSessionTicketKeys-1)]...)
+	}
+
+	r.config.SetSessionTicketKeys(r.keys)
+	r.rotations.WithLabelValues(source).Inc()
+
+	LoggerFromContext(ctx).Info("rotated TLS session ticket keys", "source", source, "keys", len(r.keys))
+
+	return nil
+}
+
+// readSessionTicketKeys reads session ticket keys from a file with one hex or base64 encoded 32 byte key per line.
+// The first key encrypts new tickets, all keys decrypt tickets, so all instances sharing the file can resume sessions.
+func readSessionTicketKeys(path string) ([][32]byte, error) {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session ticket keys: %w", err)
+	}
+
+	var keys [][32]byte
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		decoded, err := hex.DecodeString(line)
+		if err != nil {
+			decoded, err = base64.StdEncoding.DecodeString(line)
+		}
+
+		if err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("%w: line %d of %s is not a hex or base64 encoded 32 byte key", ErrInvalidSessionTicketKey, i+1, path)
+		}
+
+		keys = append(keys, [32]byte(decoded))
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: %s contains no keys", ErrInvalidSessionTicketKey, path)
+	}
+
+	return keys, nil
+}
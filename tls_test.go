@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// writeTestCertificate writes a self-signed certificate for the given host names and returns the file paths
+func writeTestCertificate(t *testing.T, notAfter time.Time, hosts ...string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestTLSServer(t *testing.T) {
+	t.Parallel()
+
+	certFile, keyFile := writeTestCertificate(t, time.Now().Add(24*time.Hour), "localhost")
+
+	config := DefaultConfig()
+	config.TLSCertFile = certFile
+	config.TLSKeyFile = keyFile
+
+	svc := New("tls_test", config)
+	t.Cleanup(svc.cancel)
+
+	tlsConfig, err := svc.newTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { //nolint:gosec
+		w.WriteHeader(http.StatusNoContent)
+	})}
+
+	go func() { _ = server.Serve(listener) }()
+
+	t.Cleanup(func() { _ = server.Close() })
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+	}}
+
+	resp, err := client.Get("https://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent || resp.TLS == nil {
+		t.Errorf("expected a TLS response with 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionTicketRotation(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("session_ticket_test")
+	rotator := newSessionTicketRotator(&tls.Config{}, metrics, "") //nolint:gosec
+
+	for range 5 {
+		if err := rotator.rotate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(rotator.keys) != maxSessionTicketKeys {
+		t.Errorf("expected %d keys to be kept, got %d", maxSessionTicketKeys, len(rotator.keys))
+	}
+
+	rotations := metrics.builtinCounterVec("tls_session_ticket_rotations_total", "", "source")
+	if got := testutil.ToFloat64(rotations.WithLabelValues("generated")); got != 5 {
+		t.Errorf("expected 5 rotations, got %v", got)
+	}
+}
+
+func TestSessionTicketKeyFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "tickets")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics := NewMetricsCollector("session_ticket_file_test")
+	rotator := newSessionTicketRotator(&tls.Config{}, metrics, path) //nolint:gosec
+	rotations := metrics.builtinCounterVec("tls_session_ticket_rotations_total", "", "source").WithLabelValues("file")
+
+	write("# current key first\n" + hex.EncodeToString(make([]byte, 32)) + "\n")
+
+	for range 2 {
+		if err := rotator.rotate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := testutil.ToFloat64(rotations); got != 1 {
+		t.Errorf("expected an unchanged file not to rotate the keys, got %v rotations", got)
+	}
+
+	write(strings.Repeat("a", 64) + "\n" + hex.EncodeToString(make([]byte, 32)) + "\n")
+
+	if err := rotator.rotate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.ToFloat64(rotations); got != 2 || len(rotator.keys) != 2 {
+		t.Errorf("expected the changed file to rotate to 2 keys, got %v rotations and %d keys", got, len(rotator.keys))
+	}
+
+	write("not a key\n")
+
+	if err := rotator.rotate(context.Background()); !errors.Is(err, ErrInvalidSessionTicketKey) {
+		t.Errorf("expected ErrInvalidSessionTicketKey, got %v", err)
+	}
+}
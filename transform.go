@@ -0,0 +1,244 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// defaultMaxTransformBody is the default maximum request body size that is transformed
+const defaultMaxTransformBody = 10 << 20
+
+// redactedValue replaces the values of redacted fields
+const redactedValue = "[REDACTED]"
+
+// BodyTransform transforms a decoded JSON document. Values are maps, slices, strings, json.Number, bools, or nil.
+type BodyTransform func(value any) any
+
+// TransformConfig holds configuration for request and response body transformations
+type TransformConfig struct {
+	// Request transforms JSON request bodies before the handler reads them
+	Request []BodyTransform
+	// Response transforms JSON response bodies. Responses are buffered, so don't use it for streaming routes.
+	// Responses beyond RESPONSE_BUFFER_THRESHOLD are answered with 500 instead of being sent untransformed.
+	Response []BodyTransform
+	// MaxRequestBody is the maximum request body size; larger requests are rejected with 413. Defaults to 10MiB.
+	MaxRequestBody int64
+}
+
+// WithTransform transforms the JSON request and response bodies of a single route
+func WithTransform(config TransformConfig) RouteOption {
+	return func(rt *route) {
+		rt.use(TransformMiddleware(config))
+	}
+}
+
+// TransformMiddleware transforms JSON request and response bodies, e.g. to redact fields or translate key conventions,
+// without touching the handlers. Bodies that are not JSON pass through unchanged.
+func TransformMiddleware(config TransformConfig) Middleware {
+	if config.MaxRequestBody <= 0 {
+		config.MaxRequestBody = defaultMaxTransformBody
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(config.Request) > 0 && isJSON(r.Header.Get("Content-Type")) && r.Body != nil {
+				data, err := io.ReadAll(io.LimitReader(r.Body, config.MaxRequestBody+1))
+				if err != nil {
+					http.Error(w, "Failed to read request body", http.StatusBadRequest)
+					return
+				}
+
+				if int64(len(data)) > config.MaxRequestBody {
+					http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+
+				if transformed, ok := transformJSON(data, config.Request); ok {
+					data = transformed
+				}
+
+				r.Body = io.NopCloser(bytes.NewReader(data))
+				r.ContentLength = int64(len(data))
+				r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+			}
+
+			if len(config.Response) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Responses beyond the buffer threshold fail, as streaming them untransformed could leak redacted fields
+			recorder := newStrictBufferedResponse(r)
+			next.ServeHTTP(recorder, r)
+
+			if recorder.rejected {
+				renderError(w, r, http.StatusInternalServerError, ErrResponseTooLarge)
+				return
+			}
+
+			if isJSON(recorder.header.Get("Content-Type")) {
+				if transformed, ok := transformJSON(recorder.body.Bytes(), config.Response); ok {
+					recorder.body.Reset()
+					recorder.body.Write(transformed)
+					recorder.header.Set("Content-Length", strconv.Itoa(len(transformed)))
+				}
+			}
+
+			recorder.writeTo(w)
+		})
+	}
+}
+
+// RedactFields replaces the values of the given fields, at any depth and case-insensitively, with "[REDACTED]"
+func RedactFields(fields ...string) BodyTransform {
+	redacted := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		redacted[strings.ToLower(field)] = true
+	}
+
+	var redact BodyTransform
+
+	redact = func(value any) any {
+		switch v := value.(type) {
+		case map[string]any:
+			for key, child := range v {
+				if redacted[strings.ToLower(key)] {
+					v[key] = redactedValue
+				} else {
+					v[key] = redact(child)
+				}
+			}
+		case []any:
+			for i, child := range v {
+				v[i] = redact(child)
+			}
+		}
+
+		return value
+	}
+
+	return redact
+}
+
+// SnakeCaseKeys translates all object keys to snake_case, e.g. "userId" to "user_id"
+func SnakeCaseKeys() BodyTransform {
+	return renameKeys(snakeCase)
+}
+
+// CamelCaseKeys translates all object keys to camelCase, e.g. "user_id" to "userId"
+func CamelCaseKeys() BodyTransform {
+	return renameKeys(camelCase)
+}
+
+// renameKeys returns a transform that renames all object keys at any depth
+func renameKeys(rename func(string) string) BodyTransform {
+	var transform BodyTransform
+
+	transform = func(value any) any {
+		switch v := value.(type) {
+		case map[string]any:
+			renamed := make(map[string]any, len(v))
+			for key, child := range v {
+				renamed[rename(key)] = transform(child)
+			}
+
+			return renamed
+		case []any:
+			for i, child := range v {
+				v[i] = transform(child)
+			}
+		}
+
+		return value
+	}
+
+	return transform
+}
+
+// snakeCase converts a camelCase or PascalCase key to snake_case, keeping acronyms together ("userID" to "user_id")
+func snakeCase(key string) string {
+	runes := []rune(key)
+
+	var builder strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			if prev != '_' && (unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower)) {
+				builder.WriteByte('_')
+			}
+		}
+
+		builder.WriteRune(unicode.ToLower(r))
+	}
+
+	return builder.String()
+}
+
+// camelCase converts a snake_case key to camelCase ("user_id" to "userId")
+func camelCase(key string) string {
+	parts := strings.Split(key, "_")
+
+	var builder strings.Builder
+
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		if i > 0 && builder.Len() > 0 {
+			runes := []rune(part)
+			runes[0] = unicode.ToUpper(runes[0])
+			part = string(runes)
+		}
+
+		builder.WriteString(part)
+	}
+
+	return builder.String()
+}
+
+// transformJSON decodes a JSON document, applies the transforms, and encodes it again.
+// It returns false if the document is not valid JSON.
+func transformJSON(data []byte, transforms []BodyTransform) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+
+	for _, transform := range transforms {
+		value = transform(value)
+	}
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, false
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// isJSON returns true if the content type is JSON, e.g. "application/json" or "application/problem+json"
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
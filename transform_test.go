@@ -0,0 +1,112 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestKeyCase(t *testing.T) {
+	t.Parallel()
+
+	snake := map[string]string{
+		"userId":     "user_id",
+		"UserID":     "user_id",
+		"httpServer": "http_server",
+		"HTTPServer": "http_server",
+		"address2":   "address2",
+		"already_ok": "already_ok",
+	}
+
+	for in, want := range snake {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	camel := map[string]string{
+		"user_id":     "userId",
+		"http_server": "httpServer",
+		"_private":    "private",
+		"name":        "name",
+	}
+
+	for in, want := range camel {
+		if got := camelCase(in); got != want {
+			t.Errorf("camelCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTransformMiddleware(t *testing.T) {
+	t.Parallel()
+
+	middleware := TransformMiddleware(TransformConfig{
+		Request:  []BodyTransform{SnakeCaseKeys()},
+		Response: []BodyTransform{RedactFields("password"), CamelCaseKeys()},
+	})
+
+	var received string
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"user_id":12345678901234567890,"password":"secret","roles":[{"role_name":"admin"}]}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"userId":1,"nested":{"firstName":"Ada"}}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if want := `{"nested":{"first_name":"Ada"},"user_id":1}`; received != want {
+		t.Errorf("expected request body %s, got %s", want, received)
+	}
+
+	want := `{"password":"[REDACTED]","roles":[{"roleName":"admin"}],"userId":12345678901234567890}`
+	if rec.Body.String() != want {
+		t.Errorf("expected response body %s, got %s", want, rec.Body.String())
+	}
+
+	if rec.Header().Get("Content-Length") != strconv.Itoa(len(want)) {
+		t.Errorf("expected updated Content-Length, got %s", rec.Header().Get("Content-Length"))
+	}
+}
+
+func TestTransformMiddlewarePassthrough(t *testing.T) {
+	t.Parallel()
+
+	middleware := TransformMiddleware(TransformConfig{
+		Request:        []BodyTransform{SnakeCaseKeys()},
+		Response:       []BodyTransform{CamelCaseKeys()},
+		MaxRequestBody: 16,
+	})
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(`{"user_id":1}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Body.String() != `{"user_id":1}` {
+		t.Errorf("expected non-JSON response to pass through, got %s", rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"description":"too large"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rec.Code)
+	}
+}
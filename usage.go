@@ -0,0 +1,449 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrUsageSinkFailed is returned when usage records could not be written to a sink
+var ErrUsageSinkFailed = NewError(CodeUnavailable, "failed to write usage records")
+
+// usageRequestKey is the context key for the usage of a request, so authentication further down the middleware
+// chain can report the identity to UsageMiddleware
+const usageRequestKey ContextKey = "usage_request"
+
+// defaultMaxUsageKeys is the default maximum number of usage records per flush interval
+const defaultMaxUsageKeys = 10000
+
+// UsageRecord holds the usage of a tenant and API key within a flush interval
+type UsageRecord struct {
+	Tenant        string    `json:"tenant,omitempty"`
+	Key           string    `json:"key,omitempty"`
+	Requests      int64     `json:"requests"`
+	RequestBytes  int64     `json:"request_bytes"`
+	ResponseBytes int64     `json:"response_bytes"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+}
+
+// UsageSink receives the usage records of each flush, e.g. to forward them to a billing system
+type UsageSink interface {
+	WriteUsage(ctx context.Context, records []UsageRecord) error
+}
+
+// LogUsageSink logs each usage record
+type LogUsageSink struct {
+	Logger *slog.Logger
+}
+
+// WriteUsage logs the usage records
+func (s *LogUsageSink) WriteUsage(ctx context.Context, records []UsageRecord) error {
+	for _, record := range records {
+		s.Logger.InfoContext(ctx, "usage",
+			"tenant", record.Tenant,
+			"key", record.Key,
+			"requests", record.Requests,
+			"request_bytes", record.RequestBytes,
+			"response_bytes", record.ResponseBytes,
+			"start", record.Start,
+			"end", record.End)
+	}
+
+	return nil
+}
+
+// HTTPUsageSink posts the usage records of each flush as a JSON array
+type HTTPUsageSink struct {
+	URL string
+	// Client sends the requests. Defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// WriteUsage posts the usage records to the URL
+func (s *HTTPUsageSink) WriteUsage(ctx context.Context, records []UsageRecord) error {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	payload, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUsageSinkFailed, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUsageSinkFailed, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUsageSinkFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: sink returned status %d", ErrUsageSinkFailed, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// FileUsageSink appends the usage records to a file, one JSON object per line
+type FileUsageSink struct {
+	Path string
+}
+
+// WriteUsage appends the usage records to the file
+func (s *FileUsageSink) WriteUsage(_ context.Context, records []UsageRecord) error {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("%w: %w", ErrUsageSinkFailed, err)
+		}
+	}
+
+	file, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUsageSinkFailed, err)
+	}
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", ErrUsageSinkFailed, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrUsageSinkFailed, err)
+	}
+
+	return nil
+}
+
+// UsageConfig holds configuration for usage accounting
+type UsageConfig struct {
+	// Tenant extracts the tenant of a request. Defaults to the tenant of TenantMiddleware (see TenantID).
+	Tenant TenantExtractor
+	// Key returns the identity of a request if APIKeyMiddleware further down the middleware chain didn't
+	// authenticate it. Defaults to APIKeyIdentity, e.g. for a UsageMiddleware after APIKeyMiddleware.
+	// It must only return authenticated identities, as every distinct key creates a record.
+	Key func(r *http.Request) string
+	// MaxKeys limits the number of records (tenant and key pairs) per flush interval; further pairs are accounted
+	// in a record with tenant and key "other". Defaults to 10000.
+	MaxKeys int
+	// Sinks receive the usage records of each flush
+	Sinks []UsageSink
+}
+
+// HeaderUsageKey returns the fingerprint of the API key in a request header (see APIKeyFingerprint),
+// so usage records never contain the key itself. The key isn't validated, so only use it behind a proxy that
+// authenticates the key.
+func HeaderUsageKey(header string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		key := strings.TrimSpace(r.Header.Get(header))
+		if key == "" {
+			return ""
+		}
+
+		return APIKeyFingerprint(key)
+	}
+}
+
+// APIKeyFingerprint returns a short, stable identifier of an API key that is safe to log
+func APIKeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// usageKey identifies the usage of a tenant and API key
+type usageKey struct {
+	tenant string
+	key    string
+}
+
+// UsageMeter accumulates request counts and bytes per tenant and API key, and writes them to its sinks on
+// every flush. Records of a failed flush are kept and written again with the next flush, so sinks may receive
+// records again that they already accepted when another sink failed.
+type UsageMeter struct {
+	config  UsageConfig
+	flushes *prometheus.CounterVec
+
+	// flushMu serializes flushes, so records are written in order
+	flushMu sync.Mutex
+
+	mu    sync.Mutex
+	start time.Time
+	usage map[usageKey]*UsageRecord
+	sinks []UsageSink
+}
+
+// NewUsageMeter creates a new usage meter. Flushes are recorded in {service_name}_usage_flushes_total{result}.
+func NewUsageMeter(metrics *MetricsCollector, config UsageConfig) *UsageMeter {
+	meter := &UsageMeter{
+		config: config,
+		start:  time.Now(),
+		usage:  make(map[usageKey]*UsageRecord),
+		sinks:  append([]UsageSink(nil), config.Sinks...),
+	}
+
+	if meter.config.Key == nil {
+		meter.config.Key = APIKeyIdentity
+	}
+
+	if meter.config.MaxKeys <= 0 {
+		meter.config.MaxKeys = defaultMaxUsageKeys
+	}
+
+	if meter.config.Tenant == nil {
+		meter.config.Tenant = func(r *http.Request) (string, bool) {
+			tenant := TenantID(r)
+			return tenant, tenant != ""
+		}
+	}
+
+	if metrics != nil {
+		meter.flushes = metrics.builtinCounterVec("usage_flushes_total",
+			"Total number of usage record flushes by result", "result")
+	}
+
+	return meter
+}
+
+// AddSink registers a sink for the usage records
+func (m *UsageMeter) AddSink(sink UsageSink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sinks = append(m.sinks, sink)
+}
+
+// Record adds the usage of a request, e.g. of a protocol that isn't served by UsageMiddleware
+func (m *UsageMeter) Record(tenant, key string, requestBytes, responseBytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record := m.recordLocked(usageKey{tenant: tenant, key: key})
+	record.Requests++
+	record.RequestBytes += requestBytes
+	record.ResponseBytes += responseBytes
+}
+
+// recordLocked returns the record of a tenant and key, or the overflow record once MaxKeys records exist.
+// The caller must hold the lock.
+func (m *UsageMeter) recordLocked(key usageKey) *UsageRecord {
+	if record, ok := m.usage[key]; ok {
+		return record
+	}
+
+	if len(m.usage) >= m.config.MaxKeys {
+		key = usageKey{tenant: overflowLabel, key: overflowLabel}
+		if record, ok := m.usage[key]; ok {
+			return record
+		}
+	}
+
+	record := &UsageRecord{Tenant: key.tenant, Key: key.key}
+	m.usage[key] = record
+
+	return record
+}
+
+// Flush writes the usage since the last flush to all sinks
+func (m *UsageMeter) Flush(ctx context.Context) error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+
+	start, end := m.start, time.Now()
+	usage := m.usage
+	sinks := append([]UsageSink(nil), m.sinks...)
+
+	m.start = end
+	m.usage = make(map[usageKey]*UsageRecord)
+
+	m.mu.Unlock()
+
+	if len(usage) == 0 || len(sinks) == 0 {
+		return nil
+	}
+
+	records := make([]UsageRecord, 0, len(usage))
+	for _, record := range usage {
+		record.Start, record.End = start, end
+		records = append(records, *record)
+	}
+
+	var errs []error
+
+	for _, sink := range sinks {
+		if err := sink.WriteUsage(ctx, records); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		m.restore(start, usage)
+		m.recordFlush("failure")
+
+		return err
+	}
+
+	m.recordFlush("success")
+
+	return nil
+}
+
+// restore adds the usage of a failed flush back, so it is written with the next flush
+func (m *UsageMeter) restore(start time.Time, usage map[usageKey]*UsageRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.start = start
+
+	for key, record := range usage {
+		current := m.recordLocked(key)
+		current.Requests += record.Requests
+		current.RequestBytes += record.RequestBytes
+		current.ResponseBytes += record.ResponseBytes
+	}
+}
+
+// recordFlush counts a flush by result
+func (m *UsageMeter) recordFlush(result string) {
+	if m.flushes != nil {
+		m.flushes.WithLabelValues(result).Inc()
+	}
+}
+
+// UsageMiddleware accounts the requests and the request and response body bytes per tenant and API key identity,
+// e.g. of APIKeyMiddleware further down the middleware chain. Add it after TenantMiddleware to account per tenant
+// of TenantMiddleware.
+func UsageMiddleware(meter *UsageMeter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			usage := &usageRequest{}
+			r = r.WithContext(context.WithValue(r.Context(), usageRequestKey, usage))
+
+			var body *countingReader
+			if r.Body != nil && r.Body != http.NoBody {
+				body = &countingReader{ReadCloser: r.Body}
+				r.Body = body
+			}
+
+			wrapped := &accessLogWriter{ResponseWriter: w}
+
+			next.ServeHTTP(wrapped, r)
+
+			tenant, _ := meter.config.Tenant(r)
+
+			key := usage.identity()
+			if key == "" {
+				key = meter.config.Key(r)
+			}
+
+			var requestBytes int64
+			if body != nil {
+				requestBytes = body.read.Load()
+			}
+
+			meter.Record(tenant, key, requestBytes, wrapped.written.Load())
+		})
+	}
+}
+
+// usageRequest holds the authenticated identity of a request for UsageMiddleware. Handlers may still run after
+// the middleware returned, e.g. after a timeout, so the identity is stored atomically.
+type usageRequest struct {
+	id atomic.Pointer[string]
+}
+
+// identity returns the authenticated identity, or an empty string
+func (u *usageRequest) identity() string {
+	if id := u.id.Load(); id != nil {
+		return *id
+	}
+
+	return ""
+}
+
+// setUsageIdentity reports the authenticated identity of a request to UsageMiddleware, if any
+func setUsageIdentity(r *http.Request, identity string) {
+	if usage, ok := r.Context().Value(usageRequestKey).(*usageRequest); ok {
+		usage.id.Store(&identity)
+	}
+}
+
+// countingReader counts the bytes read from a request body. Handlers may still read the body after the middleware
+// returned, e.g. after a timeout, so the count is atomic.
+type countingReader struct {
+	io.ReadCloser
+
+	read atomic.Int64
+}
+
+// Read counts the bytes read
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.read.Add(int64(n))
+
+	return n, err //nolint:wrapcheck
+}
+
+// usageSinks returns the sinks of the usage meter configured in the environment, logging records without one
+func (s *Service) usageSinks() []UsageSink {
+	var sinks []UsageSink
+
+	if s.Config.UsageSinkURL != "" {
+		sinks = append(sinks, &HTTPUsageSink{URL: s.Config.UsageSinkURL})
+	}
+
+	if s.Config.UsageSinkFile != "" {
+		sinks = append(sinks, &FileUsageSink{Path: s.Config.UsageSinkFile})
+	}
+
+	if len(sinks) == 0 {
+		sinks = append(sinks, &LogUsageSink{Logger: s.Logger.With("subsystem", "usage")})
+	}
+
+	return sinks
+}
+
+// startUsageFlush flushes the usage records periodically until the service shuts down
+func (s *Service) startUsageFlush() {
+	if s.Usage == nil {
+		return
+	}
+
+	s.Every("usage_flush", s.Config.UsageFlushInterval, s.Usage.Flush)
+}
+
+// flushUsage writes the remaining usage records once no request can add to them anymore
+func (s *Service) flushUsage(ctx context.Context) {
+	if s.Usage == nil {
+		return
+	}
+
+	if err := s.Usage.Flush(ctx); err != nil {
+		s.Logger.Error("failed to flush usage records", "error", err)
+	}
+}
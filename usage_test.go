@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// failingUsageSink fails every write
+type failingUsageSink struct{}
+
+func (failingUsageSink) WriteUsage(context.Context, []UsageRecord) error {
+	return ErrUsageSinkFailed
+}
+
+func TestUsageMiddleware(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	meter := NewUsageMeter(nil, UsageConfig{
+		Tenant: HeaderTenantExtractor("X-Tenant-ID"),
+		Key:    HeaderUsageKey("X-API-Key"),
+		Sinks:  []UsageSink{&FileUsageSink{Path: path}},
+	})
+
+	handler := UsageMiddleware(meter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, 64)
+		n, _ := r.Body.Read(body)
+		_, _ = w.Write(body[:n])
+	}))
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("order"))
+		req.Header.Set("X-Tenant-ID", "acme")
+		req.Header.Set("X-API-Key", "secret")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if err := meter.Flush(context.Background()); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), "secret") {
+		t.Error("expected the API key not to be recorded")
+	}
+
+	var records []UsageRecord
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("failed to decode record %q: %v", scanner.Text(), err)
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %+v", records)
+	}
+
+	record := records[0]
+	if record.Tenant != "acme" || record.Key != APIKeyFingerprint("secret") || record.Requests != 2 ||
+		record.RequestBytes != 10 || record.ResponseBytes != 10 {
+		t.Errorf("unexpected record: %+v", record)
+	}
+}
+
+func TestUsageMeterFailedFlush(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewMetricsCollector("usage_test")
+	meter := NewUsageMeter(metrics, UsageConfig{Sinks: []UsageSink{failingUsageSink{}}})
+
+	meter.Record("acme", "", 100, 200)
+
+	if err := meter.Flush(context.Background()); !errors.Is(err, ErrUsageSinkFailed) {
+		t.Fatalf("expected ErrUsageSinkFailed, got %v", err)
+	}
+
+	meter.Record("acme", "", 10, 20)
+
+	record := meter.usage[usageKey{tenant: "acme"}]
+	if record == nil || record.Requests != 2 || record.RequestBytes != 110 || record.ResponseBytes != 220 {
+		t.Errorf("expected the records of the failed flush to be kept, got %+v", record)
+	}
+}
+
+func TestUsageMiddlewareIdentity(t *testing.T) {
+	t.Parallel()
+
+	meter := NewUsageMeter(nil, UsageConfig{MaxKeys: 2})
+
+	auth := APIKeyMiddleware(nil, APIKeyConfig{Keys: map[string]string{"billing": "secret"}})
+	handler := UsageMiddleware(meter)(auth(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	for _, key := range []string{"secret", "secret", "random-1", "random-2", "random-3"} {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set(DefaultAPIKeyHeader, key)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if record := meter.usage[usageKey{key: "billing"}]; record == nil || record.Requests != 2 {
+		t.Errorf("expected 2 requests of the authenticated identity, got %+v", record)
+	}
+
+	if record := meter.usage[usageKey{}]; record == nil || record.Requests != 3 {
+		t.Errorf("expected unauthenticated requests to be accounted without a key, got %+v", record)
+	}
+
+	for tenant := range 5 {
+		meter.Record(strconv.Itoa(tenant), "", 0, 0)
+	}
+
+	if len(meter.usage) != 3 {
+		t.Errorf("expected the records to be capped, got %d", len(meter.usage))
+	}
+
+	if record := meter.usage[usageKey{tenant: overflowLabel, key: overflowLabel}]; record == nil || record.Requests != 5 {
+		t.Errorf("expected further records in the overflow record, got %+v", record)
+	}
+}
+
+func TestUsageMiddlewareTimedOutHandler(t *testing.T) {
+	t.Parallel()
+
+	meter := NewUsageMeter(nil, UsageConfig{})
+	read := make(chan struct{})
+
+	// The handler keeps reading the body after the middleware returned, like a handler that outlived its timeout
+	handler := UsageMiddleware(meter)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		go func() {
+			defer close(read)
+
+			_, _ = io.Copy(io.Discard, r.Body)
+		}()
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload")))
+	<-read
+
+	if record := meter.usage[usageKey{}]; record == nil || record.Requests != 1 {
+		t.Errorf("expected the request to be accounted, got %+v", record)
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrPoolNotWarm is returned by readiness gates while a connection pool has too few connections
+var ErrPoolNotWarm = NewError(CodeUnavailable, "connection pool not warmed up")
+
+// ErrUnknownComponent is returned when a component is not registered or has no pool statistics
+var ErrUnknownComponent = NewError(CodeNotFound, "unknown component")
+
+// PoolStats describes the connections of a connection pool
+type PoolStats struct {
+	Open  int
+	Idle  int
+	InUse int
+}
+
+// SQLPoolStats returns the pool statistics of a database/sql connection pool
+func SQLPoolStats(db *sql.DB) func() PoolStats {
+	return func() PoolStats {
+		stats := db.Stats()
+
+		return PoolStats{Open: stats.OpenConnections, Idle: stats.Idle, InUse: stats.InUse}
+	}
+}
+
+// MinConnectionsGate returns a readiness gate that passes once the pool has at least minConnections open connections.
+// Once warmed, the gate stays open, so idle connections being closed later don't flip the readiness.
+func MinConnectionsGate(minConnections int, stats func() PoolStats) func(ctx context.Context) error {
+	var warm atomic.Bool
+
+	return func(context.Context) error {
+		if warm.Load() {
+			return nil
+		}
+
+		open := stats().Open
+		if open < minConnections {
+			return fmt.Errorf("%w: %d of %d connections open", ErrPoolNotWarm, open, minConnections)
+		}
+
+		warm.Store(true)
+
+		return nil
+	}
+}
+
+// RequireWarmConnections keeps the service not ready until the pool of a registered component
+// has at least minConnections open connections
+func (s *Service) RequireWarmConnections(component string, minConnections int) error {
+	for _, c := range s.components {
+		if c.Name != component || c.Stats == nil {
+			continue
+		}
+
+		if s.HealthChecker == nil {
+			s.Logger.Warn("health checker not available, skipping readiness gate", "component", component)
+			return nil
+		}
+
+		s.HealthChecker.AddReadinessGate(component+"_warm_connections", MinConnectionsGate(minConnections, c.Stats))
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s has no pool statistics", ErrUnknownComponent, component)
+}
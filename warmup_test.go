@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRequireWarmConnections(t *testing.T) {
+	t.Parallel()
+
+	svc := New("warmup_test", nil)
+
+	var open atomic.Int64
+
+	svc.AddComponent(Component{
+		Name:  "db",
+		Stop:  func(context.Context) error { return nil },
+		Stats: func() PoolStats { return PoolStats{Open: int(open.Load())} },
+	})
+
+	if err := svc.RequireWarmConnections("db", 2); err != nil {
+		t.Fatalf("failed to require warm connections: %v", err)
+	}
+
+	ctx := context.Background()
+
+	open.Store(1)
+
+	if svc.HealthChecker.IsReady(ctx) {
+		t.Error("expected service not to be ready with 1 of 2 connections")
+	}
+
+	open.Store(2)
+
+	if !svc.HealthChecker.IsReady(ctx) {
+		t.Error("expected service to be ready with 2 connections")
+	}
+
+	// Once warmed, closing idle connections doesn't flip the readiness
+	open.Store(0)
+
+	if !svc.HealthChecker.IsReady(ctx) {
+		t.Error("expected service to stay ready after warm up")
+	}
+}
+
+func TestRequireWarmConnectionsUnknownComponent(t *testing.T) {
+	t.Parallel()
+
+	svc := New("warmup_unknown_test", nil)
+	svc.AddComponent(Component{Name: "cache", Stop: func(context.Context) error { return nil }})
+
+	for _, name := range []string{"db", "cache"} {
+		if err := svc.RequireWarmConnections(name, 1); !errors.Is(err, ErrUnknownComponent) {
+			t.Errorf("expected ErrUnknownComponent for %s, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// wellKnownCacheControl is the caching of the built-in responses, which rarely change
+const wellKnownCacheControl = "public, max-age=86400"
+
+// newWellKnownMux creates the built-in handlers of /favicon.ico, /robots.txt, and /.well-known/*, which browsers and
+// crawlers request from every host. They run the service-wide middleware like any route, so they show up in logs
+// and metrics with their own route instead of as 404s.
+func (s *Service) newWellKnownMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, s.buildRoute(pattern, handler, nil))
+	}
+
+	// Crawling rules are only served when set explicitly, so upgrading never changes how a service is indexed
+	if robots := s.Config.RobotsTxt; robots != "" {
+		handle("GET /robots.txt", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Cache-Control", wellKnownCacheControl)
+			_, _ = w.Write([]byte(robots))
+		})
+	}
+
+	// Browsers request a favicon for every page, including error pages and API responses opened in a tab
+	handle("GET /favicon.ico", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Cache-Control", wellKnownCacheControl)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if len(s.Config.SecurityContacts) > 0 {
+		handle("GET /.well-known/security.txt", s.securityTxtHandler)
+	}
+
+	if s.Config.ChangePasswordURL != "" {
+		handle("GET /.well-known/change-password", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, s.Config.ChangePasswordURL, http.StatusFound)
+		})
+	}
+
+	return mux
+}
+
+// securityTxtHandler serves the security contacts of the service (RFC 9116). The expiry is renewed on every
+// request, so the file never expires while the service is deployed.
+func (s *Service) securityTxtHandler(w http.ResponseWriter, _ *http.Request) {
+	var body strings.Builder
+
+	for _, contact := range s.Config.SecurityContacts {
+		body.WriteString("Contact: " + contact + "\n")
+	}
+
+	body.WriteString("Expires: " + time.Now().UTC().AddDate(0, 6, 0).Truncate(24*time.Hour).Format(time.RFC3339) + "\n")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", wellKnownCacheControl)
+	_, _ = w.Write([]byte(body.String()))
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWellKnownHandlers(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.RobotsTxt = "User-agent: *\nDisallow: /\n"
+	config.SecurityContacts = []string{"mailto:security@example.com"}
+	config.ChangePasswordURL = "https://example.com/account/password"
+	svc := New("wellknown_test", config)
+
+	svc.HandleFunc("GET /favicon.ico", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/x-icon")
+		_, _ = w.Write([]byte("icon"))
+	})
+
+	handler := svc.handler()
+
+	request := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return rec
+	}
+
+	if rec := request("/robots.txt"); rec.Code != http.StatusOK || rec.Body.String() != config.RobotsTxt {
+		t.Errorf("expected the configured robots.txt, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	if rec := request("/favicon.ico"); rec.Body.String() != "icon" {
+		t.Errorf("expected the registered route to override the built-in favicon, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec := request("/.well-known/security.txt")
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "Contact: mailto:security@example.com\n") ||
+		!strings.Contains(body, "Expires: ") {
+		t.Errorf("unexpected security.txt %d %q", rec.Code, body)
+	}
+
+	if rec := request("/.well-known/change-password"); rec.Code != http.StatusFound ||
+		rec.Header().Get("Location") != config.ChangePasswordURL {
+		t.Errorf("expected a redirect to the change password URL, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	if rec := request("/.well-known/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown well-known paths, got %d", rec.Code)
+	}
+
+	// Without ROBOTS_TXT, crawlers get the 404 of the service
+	rec = httptest.NewRecorder()
+	New("wellknown_robots_test", DefaultConfig()).handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for robots.txt without ROBOTS_TXT, got %d", rec.Code)
+	}
+
+	config = DefaultConfig()
+	config.WellKnownHandlers = false
+
+	rec = httptest.NewRecorder()
+	New("wellknown_disabled_test", config).handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 with disabled well-known handlers, got %d", rec.Code)
+	}
+}
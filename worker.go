@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// worker is a long-running background task bound to the service lifecycle (see Service.AddWorker)
+type worker struct {
+	name   string
+	fn     func(ctx context.Context) error
+	config *taskConfig
+	done   chan struct{}
+}
+
+// workers is the registry of the workers of a service
+type workers struct {
+	mu      sync.Mutex
+	started bool
+	list    []*worker
+}
+
+// AddWorker registers a long-running background task, e.g. a queue consumer, that starts when the service starts
+// and is restarted with backoff (1s up to 1m, see WithRestart) when it fails, panics, or returns. Its context is
+// canceled when the graceful shutdown starts, and the shutdown waits for it to return, up to the shutdown timeout,
+// before the components it may use are stopped. Workers added after Start start immediately.
+func (s *Service) AddWorker(name string, fn func(ctx context.Context) error, opts ...TaskOption) {
+	config := newTaskConfig(append([]TaskOption{WithRestart(time.Second, time.Minute)}, opts...))
+	w := &worker{name: name, fn: fn, config: config, done: make(chan struct{})}
+
+	s.workers.mu.Lock()
+	defer s.workers.mu.Unlock()
+
+	s.workers.list = append(s.workers.list, w)
+
+	if s.workers.started {
+		s.startWorker(w)
+	}
+}
+
+// startWorkers starts the registered workers
+func (s *Service) startWorkers() {
+	s.workers.mu.Lock()
+	defer s.workers.mu.Unlock()
+
+	if s.workers.started {
+		return
+	}
+
+	s.workers.started = true
+
+	for _, w := range s.workers.list {
+		s.startWorker(w)
+	}
+}
+
+// startWorker runs a worker until the service shuts down
+func (s *Service) startWorker(w *worker) {
+	ctx := ContextWithLogger(s.ctx, s.Logger.With("subsystem", "worker", "worker", w.name))
+
+	go func() {
+		defer close(w.done)
+
+		s.superviseTask(ctx, w.name, w.fn, w.config)
+	}()
+}
+
+// waitForWorkers waits for the started workers to return after their context was canceled and returns the names
+// of the workers still running when the context expired
+func (s *Service) waitForWorkers(ctx context.Context) []string {
+	s.workers.mu.Lock()
+	started := s.workers.started
+	list := append([]*worker(nil), s.workers.list...)
+	s.workers.mu.Unlock()
+
+	if !started {
+		return nil
+	}
+
+	var running []string
+
+	for _, w := range list {
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+			select {
+			case <-w.done:
+			default:
+				running = append(running, w.name)
+			}
+		}
+	}
+
+	if len(running) > 0 {
+		s.Logger.Warn("workers still running after the shutdown timeout", "workers", running)
+	}
+
+	return running
+}
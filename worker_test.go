@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAddWorker(t *testing.T) {
+	t.Parallel()
+
+	svc := New("worker_test", nil)
+
+	var (
+		runs    atomic.Int32
+		stopped atomic.Bool
+		late    atomic.Bool
+	)
+
+	svc.AddWorker("consumer", func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			panic("boom")
+		}
+
+		<-ctx.Done()
+
+		// Cleanup after the cancellation must finish before the shutdown completes
+		time.Sleep(20 * time.Millisecond)
+		stopped.Store(true)
+
+		return nil
+	}, WithRestart(time.Millisecond, time.Millisecond))
+
+	time.Sleep(10 * time.Millisecond)
+
+	if runs.Load() != 0 {
+		t.Fatal("expected workers not to start before the service starts")
+	}
+
+	svc.startWorkers()
+
+	svc.AddWorker("late", func(ctx context.Context) error {
+		late.Store(true)
+		<-ctx.Done()
+
+		return nil
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for (runs.Load() < 2 || !late.Load()) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if runs.Load() != 2 {
+		t.Errorf("expected the worker to restart after a panic, got %d runs", runs.Load())
+	}
+
+	if !late.Load() {
+		t.Error("expected a worker added after the start to start immediately")
+	}
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+
+	if !stopped.Load() {
+		t.Error("expected the shutdown to wait for the worker")
+	}
+}
+
+func TestAddWorker_ShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.ShutdownTimeout = 50 * time.Millisecond
+	config.ShutdownStateFile = filepath.Join(t.TempDir(), "shutdown.json")
+
+	svc := New("worker_timeout_test", config)
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	svc.AddWorker("stuck", func(context.Context) error {
+		<-release
+		return nil
+	})
+	svc.AddWorker("polite", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	svc.startWorkers()
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+
+	state, err := ReadShutdownState(config.ShutdownStateFile)
+	if err != nil {
+		t.Fatalf("failed to read shutdown state: %v", err)
+	}
+
+	if !slices.Equal(state.IncompleteWorkers, []string{"stuck"}) || state.Clean {
+		t.Errorf("expected the stuck worker in an unclean shutdown state, got %+v", state)
+	}
+}